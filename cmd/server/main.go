@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"devops-backend/internal/api"
 	"devops-backend/internal/auth"
@@ -15,6 +16,7 @@ import (
 	"devops-backend/internal/conf"
 	"devops-backend/internal/infra/data"
 	"devops-backend/internal/infra/data/provider"
+	"devops-backend/internal/redact"
 	"devops-backend/internal/server"
 	"devops-backend/internal/service"
 )
@@ -37,32 +39,83 @@ func main() {
 		logger.Error("failed to load config", "error", err)
 		os.Exit(1)
 	}
+	if err := cfg.Validate(); err != nil {
+		logger.Error("invalid config, refusing to start", "error", err)
+		os.Exit(1)
+	}
+
+	// 配置就绪后按 log_format/log_level 重建日志器，并设为默认，
+	// 让各组件共用同一输出格式而不是各自创建
+	logger = server.NewLogger(cfg.Server.LogFormat, cfg.Server.LogLevel)
+	slog.SetDefault(logger)
 
 	// 手动依赖注入
 	// data 层
-	sessionRepo, err := data.NewSQLiteSessionRepo("data/sessions.db")
+	sessionRepo, err := data.NewSQLiteSessionRepo(cfg.Data.DBPath)
 	if err != nil {
-		logger.Error("failed to init session repo", "error", err)
-		os.Exit(1)
+		if !cfg.Data.FallbackInMemory {
+			logger.Error("failed to init session repo", "error", err)
+			os.Exit(1)
+		}
+		// 降级模式：内存库顶上继续服务，后台周期性重连文件库
+		logger.Warn("session repo unavailable, serving from in-memory fallback; sessions will not survive a restart", "error", err, "db_path", cfg.Data.DBPath)
+		sessionRepo, err = data.NewFallbackSessionRepo(cfg.Data.DBPath, 0)
+		if err != nil {
+			logger.Error("failed to init in-memory fallback repo", "error", err)
+			os.Exit(1)
+		}
 	}
 	defer sessionRepo.Close()
+	if cfg.Data.AutoTitleMaxLen > 0 {
+		if titled, ok := sessionRepo.(interface{ SetAutoTitleMaxLen(int) }); ok {
+			titled.SetAutoTitleMaxLen(cfg.Data.AutoTitleMaxLen)
+		}
+	}
 	clientFactory := provider.NewMixedProvider(cfg.Eino)
+	defer clientFactory.Close()
+	if err := clientFactory.ValidateDefaultModel(cfg.Eino.DefaultModel); err != nil {
+		logger.Error("invalid default model, refusing to start", "error", err)
+		os.Exit(1)
+	}
 
 	// auth 层
-	var oidcClient *auth.OIDCClient
+	var oidcClients *auth.OIDCClients
 	var authMiddleware func(http.Handler) http.Handler
 	var authHandler *api.AuthHandler
 
 	if cfg.Auth.Enabled {
 		redirectURL := cfg.Auth.GetRedirectURL(cfg.Server.BaseURL)
-		oidcClient, err = auth.NewOIDCClient(ctx, &cfg.Auth, redirectURL)
+		oidcClients, err = auth.NewOIDCClients(ctx, &cfg.Auth, redirectURL)
 		if err != nil {
-			logger.Error("failed to init OIDC client", "error", err)
+			logger.Error("failed to init OIDC clients", "error", err)
 			os.Exit(1)
 		}
-		authMiddleware = oidcClient.AuthMiddleware()
-		authHandler = api.NewAuthHandler(oidcClient, cfg.Auth.FrontendURL)
-		logger.Info("OIDC authentication enabled (stateless)", "redirect_url", redirectURL)
+		authMiddleware = oidcClients.AuthMiddleware()
+
+		// state 存储：sqlite 后端支持多实例部署与重启后继续登录流程
+		var stateStore api.StateStore
+		switch cfg.Auth.StateStore {
+		case "sqlite":
+			sqliteStates, err := data.NewSQLiteStateStore(cfg.Data.DBPath)
+			if err != nil {
+				logger.Error("failed to init sqlite state store", "error", err)
+				os.Exit(1)
+			}
+			defer sqliteStates.Close()
+			stateStore = sqliteStates
+		case "memory", "":
+			stateStore = api.NewMemoryStateStore()
+		default:
+			logger.Error("unknown auth.state_store", "value", cfg.Auth.StateStore)
+			os.Exit(1)
+		}
+
+		authHandler = api.NewAuthHandler(oidcClients, cfg.Auth.FrontendURL, api.AuthCookieConfig{
+			Secure:   *cfg.Auth.CookieSecure,
+			SameSite: api.ParseSameSite(cfg.Auth.CookieSameSite),
+			Domain:   cfg.Auth.CookieDomain,
+		}, stateStore, cfg.Auth.AllowedReturnHosts)
+		logger.Info("OIDC authentication enabled (stateless)", "redirect_url", redirectURL, "providers", oidcClients.Names())
 	} else {
 		// Auth disabled, use no-op middleware
 		authMiddleware = func(next http.Handler) http.Handler { return next }
@@ -72,28 +125,112 @@ func main() {
 
 	// biz 层
 	sessionUsecase := biz.NewSessionUsecase(sessionRepo)
+	if cfg.Data.MaxMessagesPerTree > 0 {
+		sessionUsecase.SetMaxMessagesPerTree(cfg.Data.MaxMessagesPerTree)
+	}
+	// 回收站到期清理：启动时及之后每小时硬删除超过保留期的软删除会话树
+	if cfg.Data.TrashRetentionDays > 0 {
+		retention := time.Duration(cfg.Data.TrashRetentionDays) * 24 * time.Hour
+		go func() {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for {
+				purged, err := sessionUsecase.PurgeExpiredTrash(retention)
+				if err != nil {
+					logger.Error("failed to purge expired trash", "error", err)
+				} else if purged > 0 {
+					logger.Info("purged expired trash", "count", purged)
+				}
+				<-ticker.C
+			}
+		}()
+	}
+	// 上游探活：定期对每个 client 做只读探测，结果经 /v1/models/health 暴露
+	if cfg.Eino.HealthCheckSeconds > 0 {
+		clientFactory.StartHealthChecks(ctx, time.Duration(cfg.Eino.HealthCheckSeconds)*time.Second)
+		logger.Info("client health checks enabled", "interval_seconds", cfg.Eino.HealthCheckSeconds)
+	}
 	chatUsecase := biz.NewChatUsecase(clientFactory, cfg.Eino)
-	// service 层
-	chatService := service.NewChatService(chatUsecase, sessionUsecase)
-	// api 层
-	chatHandler := api.NewChatHandler(chatService)
-	router := api.NewRouter(chatHandler, authHandler, authMiddleware)
-
-	// init devops server with router
-	if err := server.InitDevops(ctx, router); err != nil {
-		logger.Error("failed to init devops server", "error", err)
-		os.Exit(1)
+	// 按能力注册表做历史裁剪与参数夹取（未配置上下文窗口的模型不裁剪）
+	chatUsecase.SetModelLimits(provider.GetModelCapabilityRegistry())
+	if cfg.Audit.Enabled {
+		auditLogger, err := data.NewJSONLAuditLogger(cfg.Audit.Path)
+		if err != nil {
+			logger.Error("failed to init audit logger", "error", err)
+			os.Exit(1)
+		}
+		defer auditLogger.Close()
+		chatUsecase.SetAuditLogger(auditLogger, cfg.Audit.LogFullText)
+		logger.Info("audit trail enabled", "path", cfg.Audit.Path)
+	}
+	// token 配额：无论全局配额是否开启都接上存储，用户级覆盖才能生效
+	if quotaStore, ok := sessionRepo.(biz.QuotaStore); ok {
+		chatUsecase.SetTokenQuota(quotaStore, cfg.Eino.TokenQuota, cfg.Eino.TokenQuotaWindow)
+		if cfg.Eino.TokenQuota > 0 {
+			logger.Info("token quota enabled", "quota", cfg.Eino.TokenQuota, "window", cfg.Eino.TokenQuotaWindow)
+		}
+	}
+	// 敏感内容脱敏：落库前始终生效，apply_to_model 时出站消息也脱敏
+	if cfg.Redaction.Enabled {
+		redactor, err := redact.NewRegexRedactor(cfg.Redaction.Patterns, cfg.Redaction.Replacement)
+		if err != nil {
+			logger.Error("failed to init redactor", "error", err)
+			os.Exit(1)
+		}
+		sessionUsecase.SetRedactor(redactor)
+		if cfg.Redaction.ApplyToModel {
+			chatUsecase.SetRedactor(redactor)
+		}
+		logger.Info("redaction enabled", "apply_to_model", cfg.Redaction.ApplyToModel)
 	}
-	logger.Info("devops server started", "addr", ":52538")
-
 	// build graph
-	_, err = biz.NewDebugGraphs(ctx, clientFactory, cfg.Eino)
+	debugGraphs, err := biz.NewDebugGraphs(ctx, clientFactory, cfg.Eino)
 	if err != nil {
 		logger.Error("failed to build graph", "error", err)
 		os.Exit(1)
 	}
 	logger.Info("graph built successfully", "name", "simple_chat")
 
+	// service 层
+	chatService := service.NewChatService(chatUsecase, sessionUsecase)
+	// 流式增量落库：生成过程中按配置的间隔合帧写入数据库
+	if cfg.Data.StreamFlushMs > 0 {
+		if flusher, ok := chatService.(interface{ SetStreamFlushInterval(time.Duration) }); ok {
+			flusher.SetStreamFlushInterval(time.Duration(cfg.Data.StreamFlushMs) * time.Millisecond)
+		}
+	}
+	adminService := service.NewAdminService(chatUsecase, flagconf, clientFactory)
+	modelsService := service.NewModelsService(clientFactory)
+	// api 层
+	chatHandler := api.NewChatHandler(chatService, time.Duration(cfg.Server.SSEKeepaliveSeconds)*time.Second)
+	if cfg.Server.DebugPromptEndpoint {
+		chatHandler.EnableDebugPrompt()
+	}
+	debugHandler := api.NewDebugHandler(debugGraphs)
+	adminHandler := api.NewAdminHandler(adminService)
+	modelsHandler := api.NewModelsHandler(modelsService)
+	// 管理端路由要求 admin 角色；auth 关闭时（本地开发）不做角色校验
+	var adminMiddleware func(http.Handler) http.Handler
+	if cfg.Auth.Enabled {
+		adminMiddleware = auth.RequireRole(auth.AdminRole)
+	}
+	router := api.NewRouter(chatHandler, debugHandler, authHandler, adminHandler, modelsHandler, authMiddleware, adminMiddleware)
+
+	// init devops server with router; TLS 模式下由自建 http.Server 直接提供 HTTPS
+	if cfg.Server.TLS.Enabled() {
+		if err := server.ServeTLS(ctx, router, cfg.Server.ListenAddr, cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile); err != nil {
+			logger.Error("failed to start TLS server", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("https server started", "addr", cfg.Server.ListenAddr)
+	} else {
+		if err := server.InitDevops(ctx, router, cfg.Server.ListenAddr); err != nil {
+			logger.Error("failed to init devops server", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("devops server started", "addr", cfg.Server.ListenAddr)
+	}
+
 	// wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
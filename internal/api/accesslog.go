@@ -0,0 +1,111 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 普通请求的访问日志在 handler 返回时就能拿到完整耗时，但 SSE/NDJSON 响应
+// 是在 handler 内部长时间流式写出的，常规中间件记下的时长和字节数都不对。
+// accessLogMiddleware 包装 writer + flusher 做计数：handler 返回即流结束，
+// 此时记录整条流的时长、写出字节数、帧数（flush 次数）与最终结果。
+
+// accessLogMiddleware 结构化访问日志，透传或生成 X-Request-ID
+func accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" {
+			requestID = "req_" + uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", requestID)
+
+		sw := &countingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(sw, r)
+		duration := time.Since(start)
+
+		attrs := []any{
+			"request_id", requestID,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration_ms", duration.Milliseconds(),
+			"bytes", sw.bytes,
+		}
+		if !sw.streaming {
+			slog.Info("http request", attrs...)
+			return
+		}
+		outcome := "completed"
+		switch {
+		case r.Context().Err() != nil:
+			outcome = "cancelled"
+		case sw.status >= http.StatusBadRequest:
+			outcome = "error"
+		}
+		attrs = append(attrs,
+			"chunks", sw.flushes,
+			"latency_bucket", latencyBucket(duration),
+			"outcome", outcome,
+		)
+		slog.Info("sse stream", attrs...)
+	})
+}
+
+// latencyBucket 把流时长归入固定桶，便于日志聚合统计
+func latencyBucket(d time.Duration) string {
+	switch {
+	case d < 100*time.Millisecond:
+		return "<100ms"
+	case d < time.Second:
+		return "100ms-1s"
+	case d < 10*time.Second:
+		return "1s-10s"
+	case d < time.Minute:
+		return "10s-60s"
+	default:
+		return ">60s"
+	}
+}
+
+// countingResponseWriter 统计写出字节与 flush 次数；按响应 Content-Type
+// 识别流式响应（SSE / NDJSON）
+type countingResponseWriter struct {
+	http.ResponseWriter
+	status    int
+	bytes     int64
+	flushes   int
+	streaming bool
+	wroteHead bool
+}
+
+func (w *countingResponseWriter) WriteHeader(status int) {
+	if !w.wroteHead {
+		w.wroteHead = true
+		w.status = status
+		ct := w.Header().Get("Content-Type")
+		w.streaming = strings.HasPrefix(ct, "text/event-stream") || strings.HasPrefix(ct, "application/x-ndjson")
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHead {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush 计数一帧；底层不支持 flush 时静默跳过（与 sseWriter 的容错一致）
+func (w *countingResponseWriter) Flush() {
+	w.flushes++
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captureLogs 把 slog 默认输出换成 JSON buffer，返回按行解析函数
+func captureLogs(t *testing.T) func() []map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return func() []map[string]any {
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var rec map[string]any
+			if err := json.Unmarshal([]byte(line), &rec); err != nil {
+				t.Fatalf("log line is not JSON: %v: %s", err, line)
+			}
+			records = append(records, rec)
+		}
+		return records
+	}
+}
+
+// TestAccessLog_SSEDurationSpansStream SSE 日志的时长覆盖整条流，
+// 并带有帧数、字节数与最终结果。
+func TestAccessLog_SSEDurationSpansStream(t *testing.T) {
+	logs := captureLogs(t)
+
+	handler := accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		flusher := w.(http.Flusher)
+		for i := 0; i < 3; i++ {
+			time.Sleep(25 * time.Millisecond)
+			fmt.Fprintf(w, "data: {\"seq\":%d}\n\n", i)
+			flusher.Flush()
+		}
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/chat", nil))
+
+	records := logs()
+	if len(records) != 1 {
+		t.Fatalf("expected 1 log record, got %d", len(records))
+	}
+	r := records[0]
+	if r["msg"] != "sse stream" {
+		t.Fatalf("unexpected log message: %v", r["msg"])
+	}
+	if d := r["duration_ms"].(float64); d < 75 {
+		t.Errorf("duration must span the whole stream, got %vms", d)
+	}
+	if c := r["chunks"].(float64); c != 3 {
+		t.Errorf("expected 3 chunks, got %v", c)
+	}
+	if b := r["bytes"].(float64); b <= 0 {
+		t.Errorf("expected positive byte count, got %v", b)
+	}
+	if r["outcome"] != "completed" {
+		t.Errorf("unexpected outcome: %v", r["outcome"])
+	}
+	if id, _ := r["request_id"].(string); !strings.HasPrefix(id, "req_") {
+		t.Errorf("expected generated request id, got %v", r["request_id"])
+	}
+	if rec.Header().Get("X-Request-ID") == "" {
+		t.Error("X-Request-ID must be echoed on the response")
+	}
+}
+
+// TestAccessLog_PropagatesRequestID 客户端自带的 X-Request-ID 原样透传
+func TestAccessLog_PropagatesRequestID(t *testing.T) {
+	logs := captureLogs(t)
+
+	handler := accessLogMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("X-Request-ID", "req_client_42")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	records := logs()
+	if len(records) != 1 || records[0]["msg"] != "http request" {
+		t.Fatalf("expected a plain http request record, got %v", records)
+	}
+	if records[0]["request_id"] != "req_client_42" {
+		t.Errorf("client request id not propagated: %v", records[0]["request_id"])
+	}
+	if rec.Header().Get("X-Request-ID") != "req_client_42" {
+		t.Errorf("unexpected response header: %q", rec.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestLatencyBucket(t *testing.T) {
+	cases := map[time.Duration]string{
+		20 * time.Millisecond:  "<100ms",
+		300 * time.Millisecond: "100ms-1s",
+		3 * time.Second:        "1s-10s",
+		30 * time.Second:       "10s-60s",
+		2 * time.Minute:        ">60s",
+	}
+	for d, want := range cases {
+		if got := latencyBucket(d); got != want {
+			t.Errorf("latencyBucket(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
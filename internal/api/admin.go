@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"devops-backend/internal/infra/data/provider"
+
+	"github.com/gorilla/mux"
+)
+
+// GenerationInfo 进行中生成的元数据（管理端可见）
+type GenerationInfo struct {
+	SessionID string    `json:"session_id"`
+	OwnerSub  string    `json:"owner_sub"`
+	Model     string    `json:"model"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// ReloadInfo 配置热重载结果
+type ReloadInfo struct {
+	Clients      int    `json:"clients"`
+	DefaultModel string `json:"default_model"`
+}
+
+// ModelTestRequest 模型连通性测试请求体；client 和 model 至少填一个，
+// 只填 client 时用该 client 的默认模型
+type ModelTestRequest struct {
+	Client string `json:"client"`
+	Model  string `json:"model"`
+}
+
+// ModelTestInfo 模型连通性测试结果；Error 为上游错误原文
+type ModelTestInfo struct {
+	OK        bool   `json:"ok"`
+	Client    string `json:"client,omitempty"`
+	Model     string `json:"model"`
+	Adapter   bool   `json:"adapter"`
+	LatencyMS int64  `json:"latency_ms"`
+	Output    string `json:"output,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// AdminService 管理端依赖的服务接口
+type AdminService interface {
+	ListGenerations(ctx context.Context) ([]GenerationInfo, error)
+	StopAllGenerations(ctx context.Context) (int, error)
+	ReloadConfig(ctx context.Context) (*ReloadInfo, error)
+	SetUserQuota(ctx context.Context, sub string, quota int64) error
+	ListModelCapabilities(ctx context.Context) (map[string]provider.ModelCapabilities, error)
+	SetModelCapabilities(ctx context.Context, model string, caps provider.ModelCapabilities, persist bool) error
+	TestModel(ctx context.Context, client, model string) (*ModelTestInfo, error)
+}
+
+// QuotaOverrideRequest 用户级 token 配额覆盖请求体；quota <= 0 删除覆盖回退全局值
+type QuotaOverrideRequest struct {
+	Sub   string `json:"sub"`
+	Quota int64  `json:"quota"`
+}
+
+// AdminHandler 管理端 HTTP handler（运维排障用）
+type AdminHandler struct {
+	service AdminService
+}
+
+// NewAdminHandler 创建管理端 handler
+func NewAdminHandler(service AdminService) *AdminHandler {
+	return &AdminHandler{service: service}
+}
+
+// RegisterRoutes 注册管理端路由
+func (h *AdminHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/generations", h.listGenerations).Methods(http.MethodGet)
+	r.HandleFunc("/generations/stop-all", h.stopAllGenerations).Methods(http.MethodPost)
+	r.HandleFunc("/reload", h.reloadConfig).Methods(http.MethodPost)
+	r.HandleFunc("/quota", h.setUserQuota).Methods(http.MethodPost)
+	r.HandleFunc("/capabilities", h.listCapabilities).Methods(http.MethodGet)
+	r.HandleFunc("/capabilities/{model}", h.setCapabilities).Methods(http.MethodPut)
+	r.HandleFunc("/test-model", h.testModel).Methods(http.MethodPost)
+}
+
+// listGenerations 列出所有进行中的生成
+func (h *AdminHandler) listGenerations(w http.ResponseWriter, r *http.Request) {
+	generations, err := h.service.ListGenerations(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"generations": generations,
+		"count":       len(generations),
+	})
+}
+
+// stopAllGenerations 取消所有进行中的生成（上游故障时的应急手段）
+func (h *AdminHandler) stopAllGenerations(w http.ResponseWriter, r *http.Request) {
+	stopped, err := h.service.StopAllGenerations(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "stopped",
+		"count":   stopped,
+	})
+}
+
+// reloadConfig 重新读取配置文件并热替换 client 配置（密钥轮换/新增厂商免重启）；
+// 新配置校验不通过时保持旧配置不变
+func (h *AdminHandler) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	info, err := h.service.ReloadConfig(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":       "reloaded",
+		"clients":       info.Clients,
+		"default_model": info.DefaultModel,
+	})
+}
+
+// setUserQuota 设置用户级 token 配额覆盖（quota <= 0 删除覆盖回退全局值）
+func (h *AdminHandler) setUserQuota(w http.ResponseWriter, r *http.Request) {
+	var req QuotaOverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.Sub == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "sub is required"})
+		return
+	}
+
+	if err := h.service.SetUserQuota(r.Context(), req.Sub, req.Quota); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message": "quota updated",
+		"sub":     req.Sub,
+		"quota":   req.Quota,
+	})
+}
+
+// testModel 对指定 client/model 发起最小化生成，快速诊断凭证与连通性问题；
+// 上游失败也返回 200，错误原文放在 error 字段里
+func (h *AdminHandler) testModel(w http.ResponseWriter, r *http.Request) {
+	var req ModelTestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.Client == "" && req.Model == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "client or model is required"})
+		return
+	}
+
+	info, err := h.service.TestModel(r.Context(), req.Client, req.Model)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, info)
+}
+
+// listCapabilities 返回当前生效的模型能力注册表（含硬编码与外部覆盖）
+func (h *AdminHandler) listCapabilities(w http.ResponseWriter, r *http.Request) {
+	caps, err := h.service.ListModelCapabilities(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"capabilities": caps,
+		"count":        len(caps),
+	})
+}
+
+// setCapabilities 运行时覆盖某个模型（或关键词）的能力，立即生效；
+// ?persist=true 同时写入外部 JSON 覆盖文件，重启后继续生效
+func (h *AdminHandler) setCapabilities(w http.ResponseWriter, r *http.Request) {
+	model := mux.Vars(r)["model"]
+	var caps provider.ModelCapabilities
+	if err := json.NewDecoder(r.Body).Decode(&caps); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	persist := r.URL.Query().Get("persist") == "true"
+	if err := h.service.SetModelCapabilities(r.Context(), model, caps, persist); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"message":   "capabilities updated",
+		"model":     model,
+		"persisted": persist,
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/rand"
 	"encoding/base64"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,19 +14,103 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// AuthCookieConfig controls the attributes of the auth cookies we emit.
+type AuthCookieConfig struct {
+	Secure   bool
+	SameSite http.SameSite
+	Domain   string
+}
+
+// ParseSameSite maps a config string to http.SameSite, defaulting to Lax.
+func ParseSameSite(s string) http.SameSite {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
 // AuthHandler handles authentication endpoints
 type AuthHandler struct {
-	oidcClient  *auth.OIDCClient
-	stateStore  *StateStore
+	oidcClients *auth.OIDCClients
+	stateStore  StateStore
 	frontendURL string
+	cookieCfg   AuthCookieConfig
+	// allowedReturnHosts 允许 return_to 跳转的主机名单（frontend_url 主机 + 配置的额外主机）
+	allowedReturnHosts map[string]bool
 }
 
-// NewAuthHandler creates a new auth handler
-func NewAuthHandler(oidcClient *auth.OIDCClient, frontendURL string) *AuthHandler {
+// NewAuthHandler creates a new auth handler.
+// A nil stateStore falls back to the in-memory implementation.
+// extraReturnHosts extends the post-login redirect allowlist beyond the
+// frontendURL host.
+func NewAuthHandler(oidcClients *auth.OIDCClients, frontendURL string, cookieCfg AuthCookieConfig, stateStore StateStore, extraReturnHosts []string) *AuthHandler {
+	if stateStore == nil {
+		stateStore = NewMemoryStateStore()
+	}
+
+	allowedReturnHosts := make(map[string]bool)
+	if u, err := url.Parse(frontendURL); err == nil && u.Host != "" {
+		allowedReturnHosts[strings.ToLower(u.Host)] = true
+	}
+	for _, host := range extraReturnHosts {
+		if host != "" {
+			allowedReturnHosts[strings.ToLower(host)] = true
+		}
+	}
+
 	return &AuthHandler{
-		oidcClient:  oidcClient,
-		stateStore:  NewStateStore(),
-		frontendURL: frontendURL,
+		oidcClients:        oidcClients,
+		stateStore:         stateStore,
+		frontendURL:        frontendURL,
+		cookieCfg:          cookieCfg,
+		allowedReturnHosts: allowedReturnHosts,
+	}
+}
+
+// sanitizeReturnTo validates a post-login redirect target against the host
+// allowlist, falling back to frontendURL. Relative paths stay on the current
+// origin and are allowed; protocol-relative URLs (//evil.com) and non-HTTP
+// schemes are not.
+func (h *AuthHandler) sanitizeReturnTo(returnTo string) string {
+	if returnTo == "" {
+		return h.frontendURL
+	}
+
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		return h.frontendURL
+	}
+
+	// Relative path: no scheme, no host, and not protocol-relative
+	if u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/") && !strings.HasPrefix(returnTo, "//") {
+		return returnTo
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return h.frontendURL
+	}
+	if !h.allowedReturnHosts[strings.ToLower(u.Host)] {
+		return h.frontendURL
+	}
+	return returnTo
+}
+
+// buildAuthCookie builds an auth cookie with the configured attributes.
+// A negative maxAge clears the cookie.
+func (h *AuthHandler) buildAuthCookie(name, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   h.cookieCfg.Domain,
+		HttpOnly: true,
+		Secure:   h.cookieCfg.Secure,
+		SameSite: h.cookieCfg.SameSite,
+		MaxAge:   maxAge,
 	}
 }
 
@@ -45,8 +131,16 @@ func (h *AuthHandler) RegisterRoutes(r *mux.Router, authMiddleware func(http.Han
 	}
 }
 
-// login initiates OIDC flow with PKCE
+// login initiates OIDC flow with PKCE.
+// An optional ?provider=<name> query parameter selects one of the configured
+// IdPs; the default provider is used when absent.
 func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request) {
+	_, providerName, err := h.oidcClients.Get(r.URL.Query().Get("provider"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Generate CSRF state
 	state := h.generateState()
 
@@ -58,17 +152,16 @@ func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request) {
 	}
 	codeChallenge := auth.GenerateCodeChallenge(codeVerifier)
 
-	// Get return_to URL from query parameter, default to frontend URL
-	returnTo := r.URL.Query().Get("return_to")
-	if returnTo == "" {
-		returnTo = h.frontendURL
-	}
+	// Get return_to URL from query parameter; reject off-allowlist targets
+	// (open redirect) by falling back to the frontend URL
+	returnTo := h.sanitizeReturnTo(r.URL.Query().Get("return_to"))
 
-	// Save state with code verifier and return URL
-	h.stateStore.SaveWithVerifier(state, 10*time.Minute, codeVerifier, returnTo)
+	// Save state with code verifier, return URL, and the chosen provider
+	h.stateStore.SaveWithVerifier(state, 10*time.Minute, codeVerifier, returnTo, providerName)
 
 	// Get authorization URL with PKCE
-	authURL := h.oidcClient.GetAuthURLWithPKCE(state, codeChallenge)
+	client, _, _ := h.oidcClients.Get(providerName)
+	authURL := client.GetAuthURLWithPKCE(state, codeChallenge)
 
 	// Redirect to OIDC provider
 	http.Redirect(w, r, authURL, http.StatusFound)
@@ -78,12 +171,19 @@ func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) callback(w http.ResponseWriter, r *http.Request) {
 	// Verify state and get code verifier + return URL (CSRF protection + PKCE)
 	state := r.URL.Query().Get("state")
-	codeVerifier, returnTo, ok := h.stateStore.VerifyAndGetVerifier(state)
+	codeVerifier, returnTo, providerName, ok := h.stateStore.VerifyAndGetVerifier(state)
 	if !ok {
 		http.Error(w, "invalid state parameter", http.StatusBadRequest)
 		return
 	}
 
+	// Resolve the provider that initiated this flow
+	client, providerName, err := h.oidcClients.Get(providerName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Get authorization code
 	code := r.URL.Query().Get("code")
 	if code == "" {
@@ -92,7 +192,7 @@ func (h *AuthHandler) callback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Exchange code for tokens using PKCE
-	oauth2Token, err := h.oidcClient.ExchangeCodeWithPKCE(r.Context(), code, codeVerifier)
+	oauth2Token, err := client.ExchangeCodeWithPKCE(r.Context(), code, codeVerifier)
 	if err != nil {
 		http.Error(w, "failed to exchange code: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -106,7 +206,7 @@ func (h *AuthHandler) callback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify ID token
-	idToken, err := h.oidcClient.VerifyIDToken(r.Context(), rawIDToken)
+	idToken, err := client.VerifyIDToken(r.Context(), rawIDToken)
 	if err != nil {
 		http.Error(w, "failed to verify ID token: "+err.Error(), http.StatusUnauthorized)
 		return
@@ -119,31 +219,22 @@ func (h *AuthHandler) callback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Store ID token in httpOnly cookie (stateless)
-	http.SetCookie(w, &http.Cookie{
-		Name:     auth.IDTokenCookieName,
-		Value:    rawIDToken,
-		Path:     "/",
-		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-		SameSite: http.SameSiteLaxMode,
-		MaxAge:   int(oauth2Token.Expiry.Sub(time.Now()).Seconds()),
-	})
+	// Store ID token in httpOnly cookie (stateless), plus the issuing
+	// provider so the middleware verifies against the right verifier
+	maxAge := int(oauth2Token.Expiry.Sub(time.Now()).Seconds())
+	http.SetCookie(w, h.buildAuthCookie(auth.IDTokenCookieName, rawIDToken, maxAge))
+	http.SetCookie(w, h.buildAuthCookie(auth.ProviderCookieName, providerName, maxAge))
 
-	// Redirect to saved return URL (frontend)
-	http.Redirect(w, r, returnTo, http.StatusFound)
+	// Redirect to saved return URL (frontend); re-validate in case the state
+	// entry predates the current allowlist
+	http.Redirect(w, r, h.sanitizeReturnTo(returnTo), http.StatusFound)
 }
 
 // logout clears ID token cookie
 func (h *AuthHandler) logout(w http.ResponseWriter, r *http.Request) {
-	// Clear ID token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     auth.IDTokenCookieName,
-		Value:    "",
-		Path:     "/",
-		HttpOnly: true,
-		MaxAge:   -1,
-	})
+	// Clear auth cookies (attributes must match the set cookies to overwrite them)
+	http.SetCookie(w, h.buildAuthCookie(auth.IDTokenCookieName, "", -1))
+	http.SetCookie(w, h.buildAuthCookie(auth.ProviderCookieName, "", -1))
 
 	writeJSON(w, http.StatusOK, map[string]string{"message": "logged out"})
 }
@@ -169,8 +260,20 @@ func (h *AuthHandler) generateState() string {
 	return base64.URLEncoding.EncodeToString(b)
 }
 
-// StateStore manages CSRF state parameters and PKCE verifiers (simple in-memory)
-type StateStore struct {
+// StateStore manages CSRF state parameters and PKCE verifiers.
+// Implementations must keep one-time-use and TTL semantics: a state is
+// consumed on the first successful VerifyAndGetVerifier and rejected after
+// its expiry. A shared backend (e.g. SQLite) lets the login redirect and the
+// callback land on different instances or survive a restart mid-login.
+type StateStore interface {
+	// SaveWithVerifier stores a state with expiry, code verifier (for PKCE), return URL, and provider
+	SaveWithVerifier(state string, duration time.Duration, codeVerifier, returnTo, provider string)
+	// VerifyAndGetVerifier checks and consumes a state, returning the code verifier, return URL, and provider
+	VerifyAndGetVerifier(state string) (codeVerifier, returnTo, provider string, ok bool)
+}
+
+// MemoryStateStore is the in-memory StateStore (single-instance deployments)
+type MemoryStateStore struct {
 	states sync.Map // map[state]StateData
 }
 
@@ -179,55 +282,57 @@ type StateData struct {
 	Expiry       time.Time
 	CodeVerifier string // For PKCE
 	ReturnTo     string // URL to redirect to after successful authentication
+	Provider     string // Named provider that initiated the flow
 }
 
-// NewStateStore creates a new state store
-func NewStateStore() *StateStore {
-	store := &StateStore{}
+// NewMemoryStateStore creates a new in-memory state store
+func NewMemoryStateStore() *MemoryStateStore {
+	store := &MemoryStateStore{}
 	go store.cleanup()
 	return store
 }
 
-// SaveWithVerifier stores a state with expiry, code verifier (for PKCE), and return URL
-func (s *StateStore) SaveWithVerifier(state string, duration time.Duration, codeVerifier, returnTo string) {
+// SaveWithVerifier stores a state with expiry, code verifier (for PKCE), return URL, and provider
+func (s *MemoryStateStore) SaveWithVerifier(state string, duration time.Duration, codeVerifier, returnTo, provider string) {
 	s.states.Store(state, StateData{
 		Expiry:       time.Now().Add(duration),
 		CodeVerifier: codeVerifier,
 		ReturnTo:     returnTo,
+		Provider:     provider,
 	})
 }
 
-// VerifyAndGetVerifier checks and consumes a state, returning the code verifier and StateData
-func (s *StateStore) VerifyAndGetVerifier(state string) (string, string, bool) {
+// VerifyAndGetVerifier checks and consumes a state, returning the code verifier, return URL, and provider
+func (s *MemoryStateStore) VerifyAndGetVerifier(state string) (string, string, string, bool) {
 	val, ok := s.states.Load(state)
 	if !ok {
-		return "", "", false
+		return "", "", "", false
 	}
 
 	data := val.(StateData)
 	if time.Now().After(data.Expiry) {
 		s.states.Delete(state)
-		return "", "", false
+		return "", "", "", false
 	}
 
 	s.states.Delete(state) // One-time use
-	return data.CodeVerifier, data.ReturnTo, true
+	return data.CodeVerifier, data.ReturnTo, data.Provider, true
 }
 
 // Legacy methods for backward compatibility (if not using PKCE)
 
 // Save stores a state with expiry (without verifier)
-func (s *StateStore) Save(state string, duration time.Duration) {
-	s.SaveWithVerifier(state, duration, "", "")
+func (s *MemoryStateStore) Save(state string, duration time.Duration) {
+	s.SaveWithVerifier(state, duration, "", "", "")
 }
 
 // Verify checks and consumes a state (one-time use)
-func (s *StateStore) Verify(state string) bool {
-	_, _, ok := s.VerifyAndGetVerifier(state)
+func (s *MemoryStateStore) Verify(state string) bool {
+	_, _, _, ok := s.VerifyAndGetVerifier(state)
 	return ok
 }
 
-func (s *StateStore) cleanup() {
+func (s *MemoryStateStore) cleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"devops-backend/internal/auth"
+)
+
+func TestParseSameSite(t *testing.T) {
+	cases := []struct {
+		in   string
+		want http.SameSite
+	}{
+		{"lax", http.SameSiteLaxMode},
+		{"Strict", http.SameSiteStrictMode},
+		{"none", http.SameSiteNoneMode},
+		{"", http.SameSiteLaxMode},
+		{"bogus", http.SameSiteLaxMode},
+	}
+	for _, c := range cases {
+		if got := ParseSameSite(c.in); got != c.want {
+			t.Errorf("ParseSameSite(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestBuildAuthCookie_Attributes(t *testing.T) {
+	h := &AuthHandler{cookieCfg: AuthCookieConfig{
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		Domain:   "example.com",
+	}}
+
+	w := httptest.NewRecorder()
+	http.SetCookie(w, h.buildAuthCookie(auth.IDTokenCookieName, "token-value", 3600))
+
+	raw := w.Header().Get("Set-Cookie")
+	for _, attr := range []string{
+		auth.IDTokenCookieName + "=token-value",
+		"Path=/",
+		"Domain=example.com",
+		"HttpOnly",
+		"Secure",
+		"SameSite=None",
+		"Max-Age=3600",
+	} {
+		if !strings.Contains(raw, attr) {
+			t.Errorf("Set-Cookie missing %q: %s", attr, raw)
+		}
+	}
+}
+
+func TestLogout_ClearsCookieWithMatchingAttributes(t *testing.T) {
+	h := &AuthHandler{cookieCfg: AuthCookieConfig{
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Domain:   "example.com",
+	}}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	h.logout(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	raw := w.Header().Get("Set-Cookie")
+	// 清除 cookie 必须携带与设置时一致的属性，否则浏览器不会覆盖
+	for _, attr := range []string{
+		auth.IDTokenCookieName + "=",
+		"Domain=example.com",
+		"Secure",
+		"Max-Age=0",
+	} {
+		if !strings.Contains(raw, attr) {
+			t.Errorf("Set-Cookie missing %q: %s", attr, raw)
+		}
+	}
+}
+
+func TestSanitizeReturnTo(t *testing.T) {
+	h := NewAuthHandler(nil, "http://localhost:5173", AuthCookieConfig{}, nil, []string{"app.example.com"})
+
+	cases := []struct {
+		in   string
+		want string
+	}{
+		// 合法目标
+		{"", "http://localhost:5173"},
+		{"http://localhost:5173/chat", "http://localhost:5173/chat"},
+		{"https://app.example.com/dashboard", "https://app.example.com/dashboard"},
+		{"/relative/path?x=1", "/relative/path?x=1"},
+		// 开放重定向攻击载荷，必须回退到 frontend_url
+		{"https://evil.com/phish", "http://localhost:5173"},
+		{"//evil.com", "http://localhost:5173"},
+		{"javascript:alert(1)", "http://localhost:5173"},
+		{"http://EVIL.com", "http://localhost:5173"},
+		{"ftp://localhost:5173/file", "http://localhost:5173"},
+	}
+	for _, c := range cases {
+		if got := h.sanitizeReturnTo(c.in); got != c.want {
+			t.Errorf("sanitizeReturnTo(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -6,9 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"devops-backend/internal/biz"
 	"devops-backend/internal/infra/data/provider"
 
 	"github.com/cloudwego/eino/schema"
@@ -91,24 +96,53 @@ func chatInputErrorCode(err error) (string, bool) {
 
 // ChatHandler 聊天接口处理器
 type ChatHandler struct {
-	chatService ChatService
+	chatService       ChatService
+	keepaliveInterval time.Duration // <= 0 表示禁用 keepalive
+	debugPrompt       bool          // 是否注册 /chat/debug-prompt 调试端点
 }
 
 // NewChatHandler 创建 ChatHandler
-func NewChatHandler(chatService ChatService) *ChatHandler {
+func NewChatHandler(chatService ChatService, keepaliveInterval time.Duration) *ChatHandler {
 	return &ChatHandler{
-		chatService: chatService,
+		chatService:       chatService,
+		keepaliveInterval: keepaliveInterval,
 	}
 }
 
 // RegisterRoutes 注册路由到 mux.Router
 func (h *ChatHandler) RegisterRoutes(r *mux.Router) {
 	r.HandleFunc("/chat", h.chat).Methods(http.MethodPost)
+	// OpenAI Chat Completions 兼容层（配合 /v1 前缀即标准路径 /v1/chat/completions）
+	r.HandleFunc("/chat/completions", h.openaiChatCompletions).Methods(http.MethodPost)
+	r.HandleFunc("/chat/count-tokens", h.countTokens).Methods(http.MethodPost)
+	// 调试端点会原样暴露出站 prompt，仅在配置显式开启时注册
+	if h.debugPrompt {
+		r.HandleFunc("/chat/debug-prompt", h.debugPromptHandler).Methods(http.MethodPost)
+	}
+	r.HandleFunc("/chat/compare", h.compareChat).Methods(http.MethodPost)
+	r.HandleFunc("/files", h.uploadFile).Methods(http.MethodPost)
 	r.HandleFunc("/sessions", h.listSessions).Methods(http.MethodGet)
+	// 注意：/sessions/trash 和 /sessions/bulk-delete 必须注册在 /sessions/{id} 之前，否则会被当作 id 匹配
+	r.HandleFunc("/sessions/trash", h.listTrash).Methods(http.MethodGet)
+	r.HandleFunc("/sessions/bulk-delete", h.bulkDeleteSessions).Methods(http.MethodPost)
 	r.HandleFunc("/sessions/{id}", h.getSession).Methods(http.MethodGet)
+	r.HandleFunc("/sessions/{id}", h.patchSession).Methods(http.MethodPatch)
+	r.HandleFunc("/sessions/{id}", h.deleteSession).Methods(http.MethodDelete)
+	r.HandleFunc("/sessions/{id}/restore", h.restoreSession).Methods(http.MethodPost)
+	r.HandleFunc("/sessions/{id}/pin", h.pinSession).Methods(http.MethodPost)
+	r.HandleFunc("/sessions/{id}/continue", h.continueSession).Methods(http.MethodPost)
+	r.HandleFunc("/sessions/{id}/cancel", h.cancelSession).Methods(http.MethodPost)
+	r.HandleFunc("/sessions/{id}/fork-from/{msgId}", h.forkFromMessage).Methods(http.MethodPost)
+	r.HandleFunc("/sessions/{id}/messages", h.getSessionMessages).Methods(http.MethodGet)
+	r.HandleFunc("/sessions/{id}/messages/{msgId}", h.deleteMessage).Methods(http.MethodDelete)
+	r.HandleFunc("/trees/{id}/sessions", h.listTreeSessions).Methods(http.MethodGet)
+	r.HandleFunc("/messages/{id}", h.getMessage).Methods(http.MethodGet)
 }
 
-// chat 流式聊天接口（AG-UI SSE）
+// ndjsonContentType NDJSON 流式输出的 Content-Type（Accept 协商用）
+const ndjsonContentType = "application/x-ndjson"
+
+// chat 流式聊天接口（默认 AG-UI SSE；Accept 带 application/x-ndjson 时按行输出 JSON）
 func (h *ChatHandler) chat(w http.ResponseWriter, r *http.Request) {
 	var runInput RunAgentInput
 	if err := json.NewDecoder(r.Body).Decode(&runInput); err != nil {
@@ -129,8 +163,20 @@ func (h *ChatHandler) chat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 设置 SSE 响应头
-	w.Header().Set("Content-Type", "text/event-stream")
+	// 事件命名风格：?sse_mode=message 切到统一 event: message 风格
+	format, err := sseFrameStrategyFor(r.URL.Query().Get("sse_mode"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// 内容协商：Accept 带 application/x-ndjson 时每行一个 JSON 事件，否则默认 SSE
+	ndjson := strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+	if ndjson {
+		w.Header().Set("Content-Type", ndjsonContentType)
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("X-Accel-Buffering", "no") // 禁用 nginx 缓冲
@@ -141,25 +187,28 @@ func (h *ChatHandler) chat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	encoder := newAGUIStreamEncoder(w, flusher, req.ThreadID, req.RunID)
+	encoder := newAGUIStreamEncoder(w, flusher, req.ThreadID, req.RunID, ndjson, format)
+
+	// 慢推理模型在首个 delta 之前可能空闲很久，周期性发 SSE 注释行防止中间代理断连
+	var keepalive *sseKeepaliver
+	if h.keepaliveInterval > 0 {
+		keepalive = startSSEKeepalive(encoder, h.keepaliveInterval)
+		defer keepalive.stop()
+	}
 
 	err = h.chatService.ChatStream(r.Context(), req,
 		func(info StreamMetaInfo) error {
 			return encoder.onStart(info)
 		},
 		func(chunk StreamChunk) error {
+			if keepalive != nil {
+				keepalive.reset()
+			}
 			return encoder.onChunk(chunk)
 		},
 	)
 	if err != nil {
-		code := "internal_error"
-		switch {
-		case strings.Contains(err.Error(), "session tree not found"):
-			code = "invalid_thread"
-		case strings.Contains(err.Error(), "session not found"):
-			code = "invalid_session"
-		}
-		_ = encoder.onError(code, err.Error())
+		_ = encoder.onError(chatStreamErrorCode(err), err.Error())
 		return
 	}
 
@@ -180,7 +229,7 @@ func buildChatRequestFromRunInput(input *RunAgentInput) (*ChatRequest, error) {
 		return nil, err
 	}
 
-	model, thinking := parseForwardedProps(input.ForwardedProps)
+	model, client, thinking, thinkingBudget, reasoningSummary, seed, noCache, store, metadata, systemPrompt, stateless, debug, extra, apiKey, baseURL := parseForwardedProps(input.ForwardedProps)
 	if err := validateModelInputCapabilities(msg, model); err != nil {
 		return nil, err
 	}
@@ -189,12 +238,39 @@ func buildChatRequestFromRunInput(input *RunAgentInput) (*ChatRequest, error) {
 		runID = "run_" + uuid.NewString()
 	}
 
+	// 无状态模式没有服务端历史，客户端发来的整个消息列表原样发给模型
+	var history []*schema.Message
+	if stateless {
+		history = make([]*schema.Message, 0, len(input.Messages))
+		for _, raw := range input.Messages {
+			parsed, err := parseRunAgentMessage(raw)
+			if err != nil {
+				return nil, err
+			}
+			history = append(history, parsed)
+		}
+	}
+
 	return &ChatRequest{
-		Message:  *msg,
-		Model:    model,
-		ThreadID: strings.TrimSpace(input.ThreadID),
-		RunID:    runID,
-		Thinking: thinking,
+		Message:          *msg,
+		Model:            model,
+		Client:           client,
+		ThreadID:         strings.TrimSpace(input.ThreadID),
+		RunID:            runID,
+		Thinking:         thinking,
+		ThinkingBudget:   thinkingBudget,
+		ReasoningSummary: reasoningSummary,
+		Seed:             seed,
+		NoCache:          noCache,
+		Store:            store,
+		Extra:            extra,
+		Metadata:         metadata,
+		SystemPrompt:     systemPrompt,
+		Stateless:        stateless,
+		History:          history,
+		Debug:            debug,
+		APIKey:           apiKey,
+		BaseURL:          baseURL,
 	}, nil
 }
 
@@ -426,9 +502,9 @@ func decodeBase64SizeWithEncoding(data string, enc *base64.Encoding, limit int)
 	}
 }
 
-func parseForwardedProps(props map[string]any) (model string, thinking *bool) {
+func parseForwardedProps(props map[string]any) (model, client string, thinking *bool, thinkingBudget *int, reasoningSummary string, seed *int, noCache bool, store *bool, metadata json.RawMessage, systemPrompt string, stateless, debug bool, extra map[string]any, apiKey, baseURL string) {
 	if props == nil {
-		return "", nil
+		return
 	}
 
 	if rawModel, ok := props["model"]; ok {
@@ -437,13 +513,101 @@ func parseForwardedProps(props map[string]any) (model string, thinking *bool) {
 		}
 	}
 
+	if rawClient, ok := props["client"]; ok {
+		if clientStr, ok := rawClient.(string); ok {
+			client = clientStr
+		}
+	}
+
 	if rawThinking, ok := props["thinking"]; ok {
 		if thinkingVal, ok := rawThinking.(bool); ok {
 			thinking = &thinkingVal
 		}
 	}
 
-	return model, thinking
+	// JSON 数字解码为 float64
+	if rawBudget, ok := props["thinking_budget"]; ok {
+		if budgetVal, ok := rawBudget.(float64); ok {
+			budget := int(budgetVal)
+			thinkingBudget = &budget
+		}
+	}
+
+	if rawSummary, ok := props["reasoning_summary"]; ok {
+		if summaryStr, ok := rawSummary.(string); ok {
+			reasoningSummary = summaryStr
+		}
+	}
+
+	if rawSeed, ok := props["seed"]; ok {
+		if seedVal, ok := rawSeed.(float64); ok {
+			seedInt := int(seedVal)
+			seed = &seedInt
+		}
+	}
+
+	if rawNoCache, ok := props["no_cache"]; ok {
+		if noCacheVal, ok := rawNoCache.(bool); ok {
+			noCache = noCacheVal
+		}
+	}
+
+	// store Responses API 服务端数据保留开关，覆盖 client 配置的默认值
+	if rawStore, ok := props["store"]; ok {
+		if storeVal, ok := rawStore.(bool); ok {
+			store = &storeVal
+		}
+	}
+
+	// metadata 新建对话时附加的自定义元数据，原样落库
+	if rawMeta, ok := props["metadata"]; ok && rawMeta != nil {
+		if encoded, err := json.Marshal(rawMeta); err == nil {
+			metadata = encoded
+		}
+	}
+
+	// system_prompt 新建对话时设置的对话级自定义指令
+	if rawPrompt, ok := props["system_prompt"]; ok {
+		if promptStr, ok := rawPrompt.(string); ok {
+			systemPrompt = promptStr
+		}
+	}
+
+	// stateless 无状态一次性请求，跳过会话持久化
+	if rawStateless, ok := props["stateless"]; ok {
+		if statelessVal, ok := rawStateless.(bool); ok {
+			stateless = statelessVal
+		}
+	}
+
+	// debug 响应附带路由决策追踪（client_resolution）
+	if rawDebug, ok := props["debug"]; ok {
+		if debugVal, ok := rawDebug.(bool); ok {
+			debug = debugVal
+		}
+	}
+
+	// extra 厂商自定义参数原样透传，是否生效由服务端配置决定
+	if rawExtra, ok := props["extra"]; ok {
+		if extraMap, ok := rawExtra.(map[string]any); ok && len(extraMap) > 0 {
+			extra = extraMap
+		}
+	}
+
+	// api_key / base_url 单次请求的上游凭证覆盖（BYO key），是否生效由
+	// eino.allow_user_keys 决定
+	if rawKey, ok := props["api_key"]; ok {
+		if keyStr, ok := rawKey.(string); ok {
+			apiKey = keyStr
+		}
+	}
+	if rawURL, ok := props["base_url"]; ok {
+		if urlStr, ok := rawURL.(string); ok {
+			baseURL = urlStr
+		}
+	}
+
+	return model, client, thinking, thinkingBudget, reasoningSummary, seed, noCache, store, metadata, systemPrompt, stateless, debug, extra, apiKey, baseURL
 }
 
 type aguiToolCallState struct {
@@ -452,8 +616,7 @@ type aguiToolCallState struct {
 }
 
 type aguiStreamEncoder struct {
-	w       http.ResponseWriter
-	flusher http.Flusher
+	out *sseWriter
 
 	threadID string
 	runID    string
@@ -462,15 +625,27 @@ type aguiStreamEncoder struct {
 	textStarted        bool
 	reasoningStarted   bool
 	toolCalls          map[string]*aguiToolCallState
+
+	// format 事件帧格式化策略（sse_mode 查询参数选择），nil 走默认风格
+	format sseFrameStrategy
+
+	// 吞吐统计：RUN_FINISHED 事件携带首 token 延迟、总时长与每秒 token 数
+	startedAt    time.Time
+	firstTokenAt time.Time
+	lastUsage    *schema.TokenUsage
 }
 
-func newAGUIStreamEncoder(w http.ResponseWriter, flusher http.Flusher, threadID, runID string) *aguiStreamEncoder {
+func newAGUIStreamEncoder(w http.ResponseWriter, flusher http.Flusher, threadID, runID string, ndjson bool, format sseFrameStrategy) *aguiStreamEncoder {
+	if format == nil {
+		format = aguiFrames
+	}
 	return &aguiStreamEncoder{
-		w:         w,
-		flusher:   flusher,
+		out:       newSSEWriter(w, flusher, ndjson),
 		threadID:  threadID,
 		runID:     runID,
 		toolCalls: make(map[string]*aguiToolCallState),
+		format:    format,
+		startedAt: time.Now(),
 	}
 }
 
@@ -479,13 +654,28 @@ func (e *aguiStreamEncoder) onStart(info StreamMetaInfo) error {
 	e.runID = info.RunID
 
 	return e.writeEvent(aguiRunStartedEvent{
-		Type:     "RUN_STARTED",
-		ThreadID: info.ThreadID,
-		RunID:    info.RunID,
+		Type:            "RUN_STARTED",
+		ThreadID:        info.ThreadID,
+		RunID:           info.RunID,
+		AppliedThinking: info.AppliedThinking,
 	})
 }
 
 func (e *aguiStreamEncoder) onChunk(chunk StreamChunk) error {
+	if e.firstTokenAt.IsZero() &&
+		(chunk.Content != "" || chunk.ReasoningContent != "" || len(chunk.ToolCalls) > 0 || len(chunk.AssistantGenMultiContent) > 0) {
+		e.firstTokenAt = time.Now()
+	}
+	if chunk.Usage != nil {
+		e.lastUsage = chunk.Usage
+	}
+
+	// agentic 循环的工具执行结果：闭合本轮的开放片段后单独下发结果事件，
+	// 下一轮模型输出作为新的助手消息重新开流
+	if chunk.ToolName != "" || chunk.ToolOutput != "" {
+		return e.emitToolResult(chunk)
+	}
+
 	if len(chunk.ToolCalls) > 0 {
 		if err := e.emitToolCalls(chunk.ToolCalls); err != nil {
 			return err
@@ -514,6 +704,13 @@ func (e *aguiStreamEncoder) onChunk(chunk StreamChunk) error {
 		}
 	}
 
+	// 推理→正文边界：先关闭推理片段，前端据此收起思考面板切换到答案视图
+	if chunk.ReasoningDone {
+		if err := e.endReasoning(); err != nil {
+			return err
+		}
+	}
+
 	if chunk.Content != "" {
 		if err := e.ensureTextMessageStarted(); err != nil {
 			return err
@@ -527,19 +724,107 @@ func (e *aguiStreamEncoder) onChunk(chunk StreamChunk) error {
 		}
 	}
 
+	if len(chunk.Annotations) > 0 {
+		if err := e.ensureTextMessageStarted(); err != nil {
+			return err
+		}
+		if err := e.writeEvent(aguiAnnotationsEvent{
+			Type:        "ANNOTATIONS",
+			MessageID:   e.assistantMessageID,
+			Annotations: chunk.Annotations,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if chunk.Usage != nil {
+		if err := e.writeEvent(aguiUsageEvent{
+			Type:      "USAGE",
+			MessageID: e.assistantMessageID,
+			Usage:     chunk.Usage,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// 自动生成的会话标题：首轮对话结束后下发一次，前端据此实时刷新标题
+	if chunk.Title != "" {
+		if err := e.writeEvent(aguiSessionTitleEvent{
+			Type:     "SESSION_TITLE",
+			ThreadID: e.threadID,
+			Title:    chunk.Title,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// 上下文超限重试：提示前端最旧的部分历史已被丢弃
+	if chunk.HistoryTruncated {
+		if err := e.writeEvent(aguiHistoryTruncatedEvent{
+			Type:     "HISTORY_TRUNCATED",
+			ThreadID: e.threadID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if isTruncatedFinishReason(chunk.FinishReason) {
+		if err := e.ensureTextMessageStarted(); err != nil {
+			return err
+		}
+		if err := e.writeEvent(aguiTruncatedEvent{
+			Type:      "TRUNCATED",
+			MessageID: e.assistantMessageID,
+			Reason:    chunk.FinishReason,
+		}); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// isTruncatedFinishReason 判断结束原因是否表示输出被截断
+func isTruncatedFinishReason(reason string) bool {
+	switch strings.ToLower(strings.TrimSpace(reason)) {
+	case "length", "incomplete", "max_output_tokens", "max_tokens":
+		return true
+	}
+	return false
+}
+
 func (e *aguiStreamEncoder) onDone() error {
 	if err := e.closeOpenStreams(); err != nil {
 		return err
 	}
 
-	return e.writeEvent(aguiRunFinishedEvent{
+	event := aguiRunFinishedEvent{
 		Type:     "RUN_FINISHED",
 		ThreadID: e.threadID,
 		RunID:    e.runID,
-	})
+		Status:   "done",
+	}
+	e.fillTimingStats(&event)
+	return e.writeEvent(event)
+}
+
+// fillTimingStats 计算本次生成的耗时与吞吐统计，给用户即时的性能反馈
+func (e *aguiStreamEncoder) fillTimingStats(event *aguiRunFinishedEvent) {
+	now := time.Now()
+	event.DurationMs = now.Sub(e.startedAt).Milliseconds()
+	if e.firstTokenAt.IsZero() {
+		return
+	}
+	event.TTFTMs = e.firstTokenAt.Sub(e.startedAt).Milliseconds()
+	if e.lastUsage == nil || e.lastUsage.CompletionTokens <= 0 {
+		return
+	}
+	// 吞吐按首 token 之后的生成时间计算，避免排队/建连时间稀释
+	genSeconds := now.Sub(e.firstTokenAt).Seconds()
+	if genSeconds <= 0 {
+		return
+	}
+	event.TokensPerSec = math.Round(float64(e.lastUsage.CompletionTokens)/genSeconds*10) / 10
 }
 
 func (e *aguiStreamEncoder) onError(code, message string) error {
@@ -554,15 +839,24 @@ func (e *aguiStreamEncoder) onError(code, message string) error {
 	})
 }
 
+// endReasoning 关闭进行中的推理片段（推理→正文边界或流结束时调用）
+func (e *aguiStreamEncoder) endReasoning() error {
+	if !e.reasoningStarted {
+		return nil
+	}
+	if err := e.writeEvent(aguiTextReasoningEndEvent{
+		Type:      "TEXT_MESSAGE_REASONING_END",
+		MessageID: e.assistantMessageID,
+	}); err != nil {
+		return err
+	}
+	e.reasoningStarted = false
+	return nil
+}
+
 func (e *aguiStreamEncoder) closeOpenStreams() error {
-	if e.reasoningStarted {
-		if err := e.writeEvent(aguiTextReasoningEndEvent{
-			Type:      "TEXT_MESSAGE_REASONING_END",
-			MessageID: e.assistantMessageID,
-		}); err != nil {
-			return err
-		}
-		e.reasoningStarted = false
+	if err := e.endReasoning(); err != nil {
+		return err
 	}
 
 	for toolCallID, state := range e.toolCalls {
@@ -647,6 +941,25 @@ func parseToolCallArgs(args string) any {
 	return map[string]string{"raw": args}
 }
 
+// emitToolResult 下发工具执行结果：先闭合进行中的推理/工具/文本片段
+// （TOOL_CALL_END、TEXT_MESSAGE_END），再发 TOOL_CALL_RESULT；并重置
+// 助手消息 ID，让 agentic 循环的下一轮生成以新消息开流，前端能按轮次
+// 展示整个过程
+func (e *aguiStreamEncoder) emitToolResult(chunk StreamChunk) error {
+	if err := e.closeOpenStreams(); err != nil {
+		return err
+	}
+	parent := e.assistantMessageID
+	e.assistantMessageID = ""
+	return e.writeEvent(aguiToolCallResultEvent{
+		Type:         "TOOL_CALL_RESULT",
+		ToolCallID:   chunk.ToolCallID,
+		ToolCallName: chunk.ToolName,
+		Result:       chunk.ToolOutput,
+		ParentMsgID:  parent,
+	})
+}
+
 func (e *aguiStreamEncoder) ensureTextMessageStarted() error {
 	if e.assistantMessageID == "" {
 		e.assistantMessageID = "msg_" + uuid.NewString()
@@ -668,27 +981,75 @@ func (e *aguiStreamEncoder) ensureTextMessageStarted() error {
 }
 
 func (e *aguiStreamEncoder) writeEvent(event any) error {
-	data, err := json.Marshal(event)
-	if err != nil {
-		return err
+	name, payload := e.format(event)
+	return e.out.NamedEvent(name, payload)
+}
+
+func (e *aguiStreamEncoder) writeComment(comment string) error {
+	return e.out.Comment(comment)
+}
+
+// sseKeepaliver 在流空闲时周期性发送 keepalive 注释行
+type sseKeepaliver struct {
+	encoder  *aguiStreamEncoder
+	interval time.Duration
+	ticker   *time.Ticker
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func startSSEKeepalive(encoder *aguiStreamEncoder, interval time.Duration) *sseKeepaliver {
+	k := &sseKeepaliver{
+		encoder:  encoder,
+		interval: interval,
+		ticker:   time.NewTicker(interval),
+		done:     make(chan struct{}),
 	}
-	if _, err := fmt.Fprintf(e.w, "data: %s\n\n", data); err != nil {
-		return err
+	go k.loop()
+	return k
+}
+
+func (k *sseKeepaliver) loop() {
+	for {
+		select {
+		case <-k.done:
+			return
+		case <-k.ticker.C:
+			_ = k.encoder.writeComment("keepalive")
+		}
 	}
-	e.flusher.Flush()
-	return nil
+}
+
+// reset 在收到 chunk 时重置计时，避免活跃流也发 keepalive
+func (k *sseKeepaliver) reset() {
+	k.ticker.Reset(k.interval)
+}
+
+func (k *sseKeepaliver) stop() {
+	k.stopOnce.Do(func() {
+		k.ticker.Stop()
+		close(k.done)
+	})
 }
 
 type aguiRunStartedEvent struct {
-	Type     string `json:"type"`
-	ThreadID string `json:"threadId"`
-	RunID    string `json:"runId"`
+	Type            string `json:"type"`
+	ThreadID        string `json:"threadId"`
+	RunID           string `json:"runId"`
+	AppliedThinking bool   `json:"appliedThinking"`
 }
 
 type aguiRunFinishedEvent struct {
 	Type     string `json:"type"`
 	ThreadID string `json:"threadId"`
 	RunID    string `json:"runId"`
+	Status   string `json:"status,omitempty"`
+	// TTFTMs 首 token 延迟（毫秒）
+	TTFTMs int64 `json:"ttft_ms,omitempty"`
+	// DurationMs 本次运行总时长（毫秒）
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	// TokensPerSec 生成吞吐：completion tokens / 首 token 之后的生成秒数
+	TokensPerSec float64 `json:"tokens_per_sec,omitempty"`
 }
 
 type aguiRunErrorEvent struct {
@@ -714,6 +1075,12 @@ type aguiTextMessageEndEvent struct {
 	MessageID string `json:"messageId"`
 }
 
+type aguiSessionTitleEvent struct {
+	Type     string `json:"type"`
+	ThreadID string `json:"threadId"`
+	Title    string `json:"title"`
+}
+
 type aguiTextReasoningStartEvent struct {
 	Type      string `json:"type"`
 	MessageID string `json:"messageId"`
@@ -730,6 +1097,29 @@ type aguiTextReasoningEndEvent struct {
 	MessageID string `json:"messageId"`
 }
 
+type aguiTruncatedEvent struct {
+	Type      string `json:"type"`
+	MessageID string `json:"messageId"`
+	Reason    string `json:"reason"`
+}
+
+type aguiHistoryTruncatedEvent struct {
+	Type     string `json:"type"`
+	ThreadID string `json:"threadId"`
+}
+
+type aguiUsageEvent struct {
+	Type      string             `json:"type"`
+	MessageID string             `json:"messageId"`
+	Usage     *schema.TokenUsage `json:"usage"`
+}
+
+type aguiAnnotationsEvent struct {
+	Type        string       `json:"type"`
+	MessageID   string       `json:"messageId"`
+	Annotations []Annotation `json:"annotations"`
+}
+
 type aguiToolCallStartEvent struct {
 	Type         string `json:"type"`
 	ToolCallID   string `json:"toolCallId"`
@@ -751,15 +1141,32 @@ type aguiToolCallEndEvent struct {
 	ParentMsgID  string `json:"parentMessageId,omitempty"`
 }
 
+type aguiToolCallResultEvent struct {
+	Type         string `json:"type"`
+	ToolCallID   string `json:"toolCallId,omitempty"`
+	ToolCallName string `json:"toolCallName,omitempty"`
+	Result       string `json:"result"`
+	ParentMsgID  string `json:"parentMessageId,omitempty"`
+}
+
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(data)
 }
 
-// listSessions 获取会话列表
+// listSessions 获取会话列表；?metadata_key=...&metadata_value=... 按 metadata
+// 顶层键值过滤
 func (h *ChatHandler) listSessions(w http.ResponseWriter, r *http.Request) {
-	sessions, err := h.chatService.ListSessions(r.Context())
+	var (
+		sessions []SessionInfo
+		err      error
+	)
+	if key := r.URL.Query().Get("metadata_key"); key != "" {
+		sessions, err = h.chatService.ListSessionsByMetadata(r.Context(), key, r.URL.Query().Get("metadata_value"))
+	} else {
+		sessions, err = h.chatService.ListSessions(r.Context())
+	}
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -768,6 +1175,441 @@ func (h *ChatHandler) listSessions(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, ListSessionsResponse{Sessions: sessions})
 }
 
+// listTreeSessions 列出 tree 内的所有分支 session（分支切换器用）
+func (h *ChatHandler) listTreeSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.chatService.ListTreeSessions(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListTreeSessionsResponse{Sessions: sessions})
+}
+
+// listTrash 获取回收站中的会话列表
+func (h *ChatHandler) listTrash(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.chatService.ListTrash(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ListSessionsResponse{Sessions: sessions})
+}
+
+// deleteSession 软删除会话树（移入回收站）
+func (h *ChatHandler) deleteSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.chatService.DeleteSession(r.Context(), id); err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "deleted"})
+}
+
+// bulkDeleteSessions 批量软删除会话树（移入回收站）
+func (h *ChatHandler) bulkDeleteSessions(w http.ResponseWriter, r *http.Request) {
+	var req BulkDeleteSessionsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "ids is required"})
+		return
+	}
+
+	results, err := h.chatService.BulkDeleteSessions(r.Context(), req.IDs)
+	if err != nil {
+		if strings.Contains(err.Error(), "too many") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, BulkDeleteSessionsResponse{Results: results})
+}
+
+// restoreSession 从回收站恢复会话树
+func (h *ChatHandler) restoreSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.chatService.RestoreSession(r.Context(), id); err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "restored"})
+}
+
+// pinSession 置顶/取消置顶会话树
+func (h *ChatHandler) pinSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	// 空 body 默认置顶；{"pinned": false} 取消置顶
+	var req struct {
+		Pinned *bool `json:"pinned"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	pinned := true
+	if req.Pinned != nil {
+		pinned = *req.Pinned
+	}
+
+	if err := h.chatService.SetSessionPinned(r.Context(), id, pinned); err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"message": "updated", "pinned": pinned})
+}
+
+// patchSession 部分更新会话树；目前仅支持 metadata（传 null 清空）
+func (h *ChatHandler) patchSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var req PatchSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body: " + err.Error()})
+		return
+	}
+	if req.Metadata == nil && req.SystemPrompt == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no fields to update"})
+		return
+	}
+
+	if req.Metadata != nil {
+		meta := *req.Metadata
+		// JSON null 等同清空
+		if string(meta) == "null" {
+			meta = nil
+		}
+		if err := h.chatService.SetSessionMetadata(r.Context(), id, meta); err != nil {
+			if isNotFoundErr(err) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.SystemPrompt != nil {
+		if err := h.chatService.SetSessionSystemPrompt(r.Context(), id, *req.SystemPrompt); err != nil {
+			if isNotFoundErr(err) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "updated"})
+}
+
+// continueSession 续写被截断的最后一条助手回复
+func (h *ChatHandler) continueSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["id"]
+
+	resp, err := h.chatService.ContinueSession(r.Context(), sessionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, biz.ErrNotTruncated):
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"code":  "not_truncated",
+				"error": err.Error(),
+			})
+		case isNotFoundErr(err):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case errors.Is(err, biz.ErrUpstreamSaturated):
+			// 并发满载属于暂时性故障，提示客户端稍后重试
+			w.Header().Set("Retry-After", "5")
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+				"code":  "upstream_saturated",
+				"error": err.Error(),
+			})
+		default:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// maxUploadFileBytes 上传到厂商 /files 的单文件大小上限
+const maxUploadFileBytes = 32 * 1024 * 1024
+
+// uploadFile 把文件（base64）上传到厂商 /files 接口，返回 file_id；
+// 后续请求可用 file_id 引用该文件作为输入（PDF 分析等场景）
+func (h *ChatHandler) uploadFile(w http.ResponseWriter, r *http.Request) {
+	var req UploadFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"code":  chatErrCodeInvalidRequestBody,
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if req.Filename == "" || req.Data == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "filename and data are required"})
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(req.Data)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"code":  chatErrCodeBinaryDecodeFailed,
+			"error": "invalid base64 data: " + err.Error(),
+		})
+		return
+	}
+	if len(data) > maxUploadFileBytes {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"code":  chatErrCodeBinaryPartTooLarge,
+			"error": fmt.Sprintf("file too large: max %d bytes", maxUploadFileBytes),
+		})
+		return
+	}
+
+	resp, err := h.chatService.UploadFile(r.Context(), req.Client, req.Filename, data, req.Purpose)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// countTokens 估算一次请求的 token 数（dry-run，不写入会话）
+func (h *ChatHandler) countTokens(w http.ResponseWriter, r *http.Request) {
+	var runInput RunAgentInput
+	if err := json.NewDecoder(r.Body).Decode(&runInput); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"code":  chatErrCodeInvalidRequestBody,
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	req, err := buildChatRequestFromRunInput(&runInput)
+	if err != nil {
+		resp := map[string]string{"error": err.Error()}
+		if code, ok := chatInputErrorCode(err); ok {
+			resp["code"] = code
+		}
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	resp, err := h.chatService.CountTokens(r.Context(), req)
+	if err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// EnableDebugPrompt 开启 /chat/debug-prompt 调试端点（conf.Server.DebugPromptEndpoint），
+// 必须在 RegisterRoutes 之前调用
+func (h *ChatHandler) EnableDebugPrompt() {
+	h.debugPrompt = true
+}
+
+// debugPromptHandler 返回一次请求最终将发给模型的消息列表
+// （dry-run：完整走消息组装管线，但不调用模型、不写入会话）
+func (h *ChatHandler) debugPromptHandler(w http.ResponseWriter, r *http.Request) {
+	var runInput RunAgentInput
+	if err := json.NewDecoder(r.Body).Decode(&runInput); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"code":  chatErrCodeInvalidRequestBody,
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+
+	req, err := buildChatRequestFromRunInput(&runInput)
+	if err != nil {
+		resp := map[string]string{"error": err.Error()}
+		if code, ok := chatInputErrorCode(err); ok {
+			resp["code"] = code
+		}
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	resp, err := h.chatService.DebugPrompt(r.Context(), req)
+	if err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, biz.ErrModelNotAllowed) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// cancelSession 取消 session 上进行中的生成
+func (h *ChatHandler) cancelSession(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["id"]
+
+	if err := h.chatService.CancelSession(r.Context(), sessionID); err != nil {
+		if errors.Is(err, biz.ErrNoActiveGeneration) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "canceled"})
+}
+
+// getSessionMessages 沿线性链分页读取会话消息：?after={msgId}&limit=N，
+// 前端滚动加载长对话用，不触发整棵 tree 读取
+func (h *ChatHandler) getSessionMessages(w http.ResponseWriter, r *http.Request) {
+	sessionID := mux.Vars(r)["id"]
+
+	var afterID int64
+	if after := r.URL.Query().Get("after"); after != "" {
+		parsed, err := strconv.ParseInt(after, 10, 64)
+		if err != nil || parsed < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid after cursor"})
+			return
+		}
+		afterID = parsed
+	}
+	var limit int
+	if l := r.URL.Query().Get("limit"); l != "" {
+		parsed, err := strconv.Atoi(l)
+		if err != nil || parsed <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = parsed
+	}
+
+	resp, err := h.chatService.GetSessionMessages(r.Context(), sessionID, afterID, limit)
+	if err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// deleteMessage 删除消息及其后代
+func (h *ChatHandler) deleteMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["id"]
+	msgID, err := strconv.ParseInt(vars["msgId"], 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid message id"})
+		return
+	}
+
+	if err := h.chatService.DeleteMessage(r.Context(), sessionID, msgID); err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"message": "deleted"})
+}
+
+// forkFromMessage 从指定消息 fork 出新分支（时间漫游）：
+// 返回的新 session 从该历史节点继续，后续 /chat 即可从那一刻续聊
+func (h *ChatHandler) forkFromMessage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	sessionID := vars["id"]
+	msgID, err := strconv.ParseInt(vars["msgId"], 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid message id"})
+		return
+	}
+
+	newSessionID, err := h.chatService.ForkSessionFrom(r.Context(), sessionID, msgID)
+	if err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"session": newSessionID})
+}
+
+// getMessage 按 ID 获取单条消息
+func (h *ChatHandler) getMessage(w http.ResponseWriter, r *http.Request) {
+	msgID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid message id"})
+		return
+	}
+
+	resp, err := h.chatService.GetMessage(r.Context(), msgID)
+	if err != nil {
+		if isNotFoundErr(err) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
 // getSession 获取会话详情（支持 session_id 或 tree_id）
 func (h *ChatHandler) getSession(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
@@ -775,7 +1617,7 @@ func (h *ChatHandler) getSession(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.chatService.GetSession(r.Context(), sessionID)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if isNotFoundErr(err) {
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
 			return
 		}
@@ -8,7 +8,11 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
 )
 
 func TestParseRunAgentContent_SuccessTextAndBinary(t *testing.T) {
@@ -255,7 +259,7 @@ func TestParseRunAgentContent_ErrorCodes(t *testing.T) {
 }
 
 func TestChatHandler_InvalidContentReturnsCode(t *testing.T) {
-	handler := NewChatHandler(noopChatService{})
+	handler := NewChatHandler(noopChatService{}, 0)
 	body := `{"messages":[{"role":"user","content":[{"type":"unknown"}]}]}`
 
 	req := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBufferString(body))
@@ -277,7 +281,7 @@ func TestChatHandler_InvalidContentReturnsCode(t *testing.T) {
 }
 
 func TestChatHandler_InvalidBodyReturnsCode(t *testing.T) {
-	handler := NewChatHandler(noopChatService{})
+	handler := NewChatHandler(noopChatService{}, 0)
 	req := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBufferString("{"))
 	recorder := httptest.NewRecorder()
 
@@ -296,6 +300,182 @@ func TestChatHandler_InvalidBodyReturnsCode(t *testing.T) {
 	}
 }
 
+func TestChatHandler_KeepaliveEmittedWhileIdle(t *testing.T) {
+	handler := NewChatHandler(slowChatService{delay: 120 * time.Millisecond}, 30*time.Millisecond)
+	body := `{"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+
+	handler.chat(recorder, req)
+
+	if !strings.Contains(recorder.Body.String(), ": keepalive\n\n") {
+		t.Fatalf("expected keepalive comment in SSE output, got: %q", recorder.Body.String())
+	}
+}
+
+func TestAGUIStreamEncoder_ReasoningDoneBoundaryOrdering(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	encoder := newAGUIStreamEncoder(recorder, recorder, "t1", "r1", false, nil)
+
+	if err := encoder.onStart(StreamMetaInfo{ThreadID: "t1", RunID: "r1"}); err != nil {
+		t.Fatalf("onStart failed: %v", err)
+	}
+	if err := encoder.onChunk(StreamChunk{ReasoningContent: "think"}); err != nil {
+		t.Fatalf("reasoning chunk failed: %v", err)
+	}
+	if err := encoder.onChunk(StreamChunk{Content: "answer", ReasoningDone: true}); err != nil {
+		t.Fatalf("content chunk failed: %v", err)
+	}
+	if err := encoder.onDone(); err != nil {
+		t.Fatalf("onDone failed: %v", err)
+	}
+
+	var types []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("invalid event JSON: %q (%v)", line, err)
+		}
+		types = append(types, event["type"].(string))
+	}
+
+	reasoningEnd := indexOf(types, "TEXT_MESSAGE_REASONING_END")
+	contentDelta := indexOf(types, "TEXT_MESSAGE_DELTA")
+	if reasoningEnd == -1 || contentDelta == -1 {
+		t.Fatalf("missing boundary events, got: %v", types)
+	}
+	if reasoningEnd > contentDelta {
+		t.Errorf("reasoning end must precede first content delta, got: %v", types)
+	}
+	count := 0
+	for _, tp := range types {
+		if tp == "TEXT_MESSAGE_REASONING_END" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("expected exactly one reasoning end event, got %d in %v", count, types)
+	}
+}
+
+func TestAGUIStreamEncoder_RunFinishedCarriesThroughputStats(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	encoder := newAGUIStreamEncoder(recorder, recorder, "t1", "r1", false, nil)
+
+	if err := encoder.onStart(StreamMetaInfo{ThreadID: "t1", RunID: "r1"}); err != nil {
+		t.Fatalf("onStart failed: %v", err)
+	}
+	time.Sleep(15 * time.Millisecond)
+	if err := encoder.onChunk(StreamChunk{
+		Content: "hi",
+		Usage:   &schema.TokenUsage{CompletionTokens: 20},
+	}); err != nil {
+		t.Fatalf("content chunk failed: %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := encoder.onDone(); err != nil {
+		t.Fatalf("onDone failed: %v", err)
+	}
+
+	var finished map[string]any
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("invalid event JSON: %q (%v)", line, err)
+		}
+		if event["type"] == "RUN_FINISHED" {
+			finished = event
+		}
+	}
+	if finished == nil {
+		t.Fatalf("expected RUN_FINISHED event, got: %q", recorder.Body.String())
+	}
+
+	if finished["status"] != "done" {
+		t.Errorf("expected status done, got: %v", finished["status"])
+	}
+	ttft, ok := finished["ttft_ms"].(float64)
+	if !ok || ttft < 1 {
+		t.Errorf("implausible ttft_ms: %v", finished["ttft_ms"])
+	}
+	duration, ok := finished["duration_ms"].(float64)
+	if !ok || duration < ttft {
+		t.Errorf("duration_ms %v must cover ttft_ms %v", finished["duration_ms"], ttft)
+	}
+	if tps, ok := finished["tokens_per_sec"].(float64); !ok || tps <= 0 {
+		t.Errorf("implausible tokens_per_sec: %v", finished["tokens_per_sec"])
+	}
+}
+
+func indexOf(values []string, target string) int {
+	for i, v := range values {
+		if v == target {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestChatHandler_NDJSONAcceptStreamsJSONLines(t *testing.T) {
+	handler := NewChatHandler(slowChatService{}, 0)
+	body := `{"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBufferString(body))
+	req.Header.Set("Accept", ndjsonContentType)
+	recorder := httptest.NewRecorder()
+
+	handler.chat(recorder, req)
+
+	if got := recorder.Header().Get("Content-Type"); got != ndjsonContentType {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	if strings.Contains(recorder.Body.String(), "data: ") {
+		t.Fatalf("NDJSON output must not contain SSE framing, got: %q", recorder.Body.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("expected multiple JSON lines, got: %q", recorder.Body.String())
+	}
+	var sawRunFinished bool
+	for _, line := range lines {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("line is not standalone JSON: %q (%v)", line, err)
+		}
+		if event["type"] == "RUN_FINISHED" {
+			sawRunFinished = true
+		}
+	}
+	if !sawRunFinished {
+		t.Fatalf("expected RUN_FINISHED event, got: %q", recorder.Body.String())
+	}
+}
+
+func TestChatHandler_DefaultAcceptKeepsSSE(t *testing.T) {
+	handler := NewChatHandler(slowChatService{}, 0)
+	body := `{"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+
+	handler.chat(recorder, req)
+
+	if got := recorder.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	if !strings.Contains(recorder.Body.String(), "data: ") {
+		t.Fatalf("expected SSE framing by default, got: %q", recorder.Body.String())
+	}
+}
+
 func mustMarshalJSON(t *testing.T, v any) json.RawMessage {
 	t.Helper()
 	data, err := json.Marshal(v)
@@ -329,10 +509,166 @@ func (noopChatService) ChatStream(context.Context, *ChatRequest, StreamStartCall
 	return nil
 }
 
+func (noopChatService) CompareChat(context.Context, *CompareRequest) (*CompareResponse, error) {
+	return nil, nil
+}
+
+func (noopChatService) CompareChatStream(context.Context, *CompareRequest, CompareEventCallback) error {
+	return nil
+}
+
+func (noopChatService) ContinueSession(context.Context, string) (*ChatResponse, error) {
+	return nil, nil
+}
+
+func (noopChatService) CountTokens(context.Context, *ChatRequest) (*CountTokensResponse, error) {
+	return nil, nil
+}
+
+func (noopChatService) DebugPrompt(context.Context, *ChatRequest) (*DebugPromptResponse, error) {
+	return nil, nil
+}
+
+func (noopChatService) UploadFile(context.Context, string, string, []byte, string) (*UploadFileResponse, error) {
+	return nil, nil
+}
+
+func (noopChatService) CancelSession(context.Context, string) error {
+	return nil
+}
+
+func (noopChatService) DeleteMessage(context.Context, string, int64) error {
+	return nil
+}
+
+func (noopChatService) ForkSessionFrom(context.Context, string, int64) (string, error) {
+	return "", nil
+}
+
+func (noopChatService) GetMessage(context.Context, int64) (*ChatResponse, error) {
+	return nil, nil
+}
+
 func (noopChatService) ListSessions(context.Context) ([]SessionInfo, error) {
 	return nil, nil
 }
 
+func (noopChatService) ListSessionsByMetadata(context.Context, string, string) ([]SessionInfo, error) {
+	return nil, nil
+}
+
+func (noopChatService) ListTrash(context.Context) ([]SessionInfo, error) {
+	return nil, nil
+}
+
+func (noopChatService) ListTreeSessions(context.Context, string) ([]TreeSessionInfo, error) {
+	return nil, nil
+}
+
+func (noopChatService) DeleteSession(context.Context, string) error {
+	return nil
+}
+
+func (noopChatService) RestoreSession(context.Context, string) error {
+	return nil
+}
+
+func (noopChatService) SetSessionPinned(context.Context, string, bool) error {
+	return nil
+}
+
+func (noopChatService) SetSessionMetadata(context.Context, string, json.RawMessage) error {
+	return nil
+}
+
+func (noopChatService) SetSessionSystemPrompt(context.Context, string, string) error {
+	return nil
+}
+
+func (noopChatService) BulkDeleteSessions(context.Context, []string) (map[string]string, error) {
+	return nil, nil
+}
+
 func (noopChatService) GetSession(context.Context, string) (*GetSessionResponse, error) {
 	return nil, nil
 }
+
+func (noopChatService) GetSessionMessages(context.Context, string, int64, int) (*GetSessionMessagesResponse, error) {
+	return nil, nil
+}
+
+// slowChatService 模拟上游长时间无输出的流
+type slowChatService struct {
+	noopChatService
+	delay time.Duration
+}
+
+func (s slowChatService) ChatStream(_ context.Context, req *ChatRequest, onStart StreamStartCallback, onChunk StreamChunkCallback) error {
+	if err := onStart(StreamMetaInfo{ThreadID: req.ThreadID, RunID: req.RunID}); err != nil {
+		return err
+	}
+	time.Sleep(s.delay)
+	return onChunk(StreamChunk{Content: "ok"})
+}
+
+// TestAGUIStreamEncoder_AgenticToolRoundTrip 一次工具往返：工具调用事件后
+// 下发 TOOL_CALL_RESULT，下一轮模型输出作为新的助手消息继续推流。
+func TestAGUIStreamEncoder_AgenticToolRoundTrip(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	encoder := newAGUIStreamEncoder(recorder, recorder, "t1", "r1", false, nil)
+
+	if err := encoder.onStart(StreamMetaInfo{ThreadID: "t1", RunID: "r1"}); err != nil {
+		t.Fatalf("onStart failed: %v", err)
+	}
+	if err := encoder.onChunk(StreamChunk{ToolCalls: []schema.ToolCall{{
+		ID: "call_1", Type: "function",
+		Function: schema.FunctionCall{Name: "ping", Arguments: `{}`},
+	}}}); err != nil {
+		t.Fatalf("tool call chunk failed: %v", err)
+	}
+	if err := encoder.onChunk(StreamChunk{ToolName: "ping", ToolCallID: "call_1", ToolOutput: "pong"}); err != nil {
+		t.Fatalf("tool result chunk failed: %v", err)
+	}
+	if err := encoder.onChunk(StreamChunk{Content: "the tool said pong"}); err != nil {
+		t.Fatalf("content chunk failed: %v", err)
+	}
+	if err := encoder.onDone(); err != nil {
+		t.Fatalf("onDone failed: %v", err)
+	}
+
+	var types []string
+	var messageIDs []string
+	var result map[string]any
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var event map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("invalid event JSON: %q (%v)", line, err)
+		}
+		types = append(types, event["type"].(string))
+		if event["type"] == "TEXT_MESSAGE_START" {
+			messageIDs = append(messageIDs, event["messageId"].(string))
+		}
+		if event["type"] == "TOOL_CALL_RESULT" {
+			result = event
+		}
+	}
+
+	toolEnd := indexOf(types, "TOOL_CALL_END")
+	toolResult := indexOf(types, "TOOL_CALL_RESULT")
+	if toolEnd == -1 || toolResult == -1 || toolEnd > toolResult {
+		t.Fatalf("tool call must be closed before its result, got: %v", types)
+	}
+	if result["toolCallId"] != "call_1" || result["toolCallName"] != "ping" || result["result"] != "pong" {
+		t.Errorf("unexpected tool result payload: %#v", result)
+	}
+	// 工具往返后的模型输出是一条新的助手消息
+	if len(messageIDs) != 2 || messageIDs[0] == messageIDs[1] {
+		t.Fatalf("expected a fresh assistant message per iteration, got IDs: %v", messageIDs)
+	}
+	if last := indexOf(types, "TOOL_CALL_RESULT"); indexOf(types, "TEXT_MESSAGE_DELTA") < last {
+		t.Errorf("next response must stream after the tool result, got: %v", types)
+	}
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxCompareModels 单次对比请求的模型数量上限
+const maxCompareModels = 8
+
+// compareChat 多模型对比：同一提示并发发给多个模型，各模型的回复落在同一
+// 对话树的不同分支上。默认返回合并 JSON；stream=true 时以 SSE 交错下发各
+// 模型的增量事件（Accept 带 application/x-ndjson 时按行输出 JSON）
+func (h *ChatHandler) compareChat(w http.ResponseWriter, r *http.Request) {
+	var req CompareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"code":  chatErrCodeInvalidRequestBody,
+			"error": "invalid request body: " + err.Error(),
+		})
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "prompt is required"})
+		return
+	}
+	if len(req.Models) < 2 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "at least two models are required"})
+		return
+	}
+	if len(req.Models) > maxCompareModels {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("too many models: max %d", maxCompareModels)})
+		return
+	}
+
+	if !req.Stream {
+		resp, err := h.chatService.CompareChat(r.Context(), &req)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	ndjson := strings.Contains(r.Header.Get("Accept"), ndjsonContentType)
+	if ndjson {
+		w.Header().Set("Content-Type", ndjsonContentType)
+	} else {
+		w.Header().Set("Content-Type", "text/event-stream")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // 禁用 nginx 缓冲
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
+		return
+	}
+
+	// sseWriter 写入带锁，各模型的 goroutine 可以安全地交错写事件
+	writer := newSSEWriter(w, flusher, ndjson)
+	err := h.chatService.CompareChatStream(r.Context(), &req, func(event CompareStreamEvent) error {
+		return writer.Event(event)
+	})
+	if err != nil {
+		_ = writer.Event(CompareStreamEvent{Type: CompareEventError, Error: err.Error()})
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/gorilla/mux"
+)
+
+// DebugGraphRunner 调试图执行接口
+type DebugGraphRunner interface {
+	RunSimpleChat(ctx context.Context, userMsg *schema.Message) (*schema.Message, error)
+}
+
+// DebugHandler 调试图 HTTP 处理器
+type DebugHandler struct {
+	graphs DebugGraphRunner
+}
+
+// NewDebugHandler creates a DebugHandler.
+func NewDebugHandler(graphs DebugGraphRunner) *DebugHandler {
+	return &DebugHandler{graphs: graphs}
+}
+
+// RegisterRoutes 注册调试路由
+func (h *DebugHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/debug/graphs/simple_chat", h.runSimpleChat).Methods(http.MethodPost)
+}
+
+// debugChatRequest 调试图请求体
+type debugChatRequest struct {
+	Content string `json:"content"`
+}
+
+// runSimpleChat 通过已编译的 simple_chat 图执行一次对话
+func (h *DebugHandler) runSimpleChat(w http.ResponseWriter, r *http.Request) {
+	var req debugChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.Content == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "content is required"})
+		return
+	}
+
+	resp, err := h.graphs.RunSimpleChat(r.Context(), &schema.Message{Role: schema.User, Content: req.Content})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
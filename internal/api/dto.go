@@ -40,18 +40,76 @@ type RunAgentInputContentPart struct {
 // ChatRequest 内部聊天请求 DTO
 type ChatRequest struct {
 	schema.Message
-	Model    string `json:"-"`
+	Model string `json:"-"`
+	// Client 指定路由到的 client；model 为空时优先用该 client 配置的默认模型
+	Client   string `json:"-"`
 	ThreadID string `json:"-"`
 	RunID    string `json:"-"`
 	Thinking *bool  `json:"-"`
+	// ThinkingBudget 思考 token 预算，透传给支持的模型
+	ThinkingBudget *int `json:"-"`
+	// ReasoningSummary 推理摘要模式（auto/concise/detailed），透传给支持的模型
+	ReasoningSummary string `json:"-"`
+	// Seed 固定采样种子，透传给支持的厂商以获得可复现的输出
+	Seed *int `json:"-"`
+	// NoCache 本次请求绕过响应缓存
+	NoCache bool `json:"-"`
+	// Store Responses API 是否允许 OpenAI 服务端保留请求数据，nil 跟随 client 配置
+	Store *bool `json:"-"`
+	// Extra 厂商自定义参数，原样合入出站请求体（需服务端开启 allow_extra_params）
+	Extra map[string]any `json:"-"`
+	// Metadata 新建对话时附加的自定义元数据（后端不解释内容）
+	Metadata json.RawMessage `json:"-"`
+	// SystemPrompt 新建对话时设置的对话级自定义指令，之后每轮都注入
+	SystemPrompt string `json:"-"`
+	// Stateless 无状态一次性请求：不建会话、不读写历史，响应不带 session ID
+	Stateless bool `json:"-"`
+	// History Stateless 模式下客户端提供的完整消息列表（含最后一条用户消息）
+	History []*schema.Message `json:"-"`
+	// Debug 随响应返回路由决策追踪（client_resolution），排查误路由用
+	Debug bool `json:"-"`
+	// APIKey / BaseURL 单次请求的上游凭证覆盖（BYO key，需 eino.allow_user_keys
+	// 开启）；绝不序列化、不写日志、不落库
+	APIKey  string `json:"-"`
+	BaseURL string `json:"-"`
 }
 
 // ChatResponse 聊天响应 DTO
 type ChatResponse struct {
 	schema.Message
-	Model     string `json:"model,omitempty"`
-	SessionID string `json:"session,omitempty"`
-	TreeID    string `json:"tree_id,omitempty"`
+	ID          int64        `json:"id,omitempty"`
+	Model       string       `json:"model,omitempty"`
+	SessionID   string       `json:"session,omitempty"`
+	TreeID      string       `json:"tree_id,omitempty"`
+	Annotations []Annotation `json:"annotations,omitempty"`
+	// Partial 流被中断时的部分保存标记，前端可展示"未完成"徽标
+	Partial   bool      `json:"partial,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	// AppliedThinking thinking 开关是否真正生效（模型不支持时为 false）
+	AppliedThinking bool `json:"applied_thinking,omitempty"`
+	// ClientResolution 路由决策追踪，仅 debug 模式下返回
+	ClientResolution *ClientResolutionInfo `json:"client_resolution,omitempty"`
+}
+
+// ClientResolutionInfo 模型 → client 路由决策追踪 DTO（debug 模式下返回）
+type ClientResolutionInfo struct {
+	// Client 最终路由到的 client 名称
+	Client string `json:"client"`
+	// Source 决策来源：explicit / override / keyword / default
+	Source string `json:"source"`
+	// Keyword source 为 keyword 时命中的关键词
+	Keyword string `json:"keyword,omitempty"`
+	// Adapter 该路由是否包装厂商 adapter
+	Adapter bool `json:"adapter"`
+}
+
+// Annotation 网页搜索/引用注释（url_citation）
+type Annotation struct {
+	Type       string `json:"type"`
+	URL        string `json:"url,omitempty"`
+	Title      string `json:"title,omitempty"`
+	StartIndex int    `json:"start_index,omitempty"`
+	EndIndex   int    `json:"end_index,omitempty"`
 }
 
 // StreamChunk 流式响应块
@@ -60,6 +118,98 @@ type StreamChunk struct {
 	ReasoningContent         string                     `json:"reasoning_content,omitempty"`
 	AssistantGenMultiContent []schema.MessageOutputPart `json:"assistant_gen_multi_content,omitempty"`
 	ToolCalls                []schema.ToolCall          `json:"tool_calls,omitempty"`
+	Annotations              []Annotation               `json:"annotations,omitempty"`
+	FinishReason             string                     `json:"finish_reason,omitempty"`
+	// ToolName/ToolCallID/ToolOutput 工具执行结果事件（agentic 循环里每个工具跑完下发一次）
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolOutput string `json:"tool_output,omitempty"`
+	// Usage 截至当前的累计 token 用量
+	Usage *schema.TokenUsage `json:"usage,omitempty"`
+	// ReasoningDone 推理→正文边界：本 chunk 是推理之后的首个正文 delta
+	ReasoningDone bool `json:"reasoning_done,omitempty"`
+	// Title 自动生成的会话标题（首轮对话结束后下发一次）
+	Title string `json:"title,omitempty"`
+	// HistoryTruncated 上下文超限重试事件：最旧的部分历史已被丢弃后重试
+	HistoryTruncated bool `json:"history_truncated,omitempty"`
+}
+
+// CompareRequest 多模型对比请求：同一提示并发发给多个模型
+type CompareRequest struct {
+	Prompt string   `json:"prompt"`
+	Models []string `json:"models"`
+	// Stream true 时以 SSE 交错下发各模型的增量事件，否则返回合并 JSON
+	Stream bool `json:"stream"`
+}
+
+// CompareResult 单个模型的对比结果；Error 非空表示该模型失败，不影响其它模型
+type CompareResult struct {
+	Model     string          `json:"model"`
+	SessionID string          `json:"session,omitempty"`
+	Message   *schema.Message `json:"message,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// CompareResponse 多模型对比响应（非流式）
+type CompareResponse struct {
+	TreeID  string          `json:"tree_id"`
+	Results []CompareResult `json:"results"`
+}
+
+// 多模型对比的流式事件类型
+const (
+	CompareEventStart      = "start"       // 对比开始，携带 tree_id
+	CompareEventModelStart = "model_start" // 单个模型开始，携带其分支 session
+	CompareEventChunk      = "chunk"       // 单个模型的增量内容
+	CompareEventDone       = "done"        // 单个模型完成
+	CompareEventError      = "error"       // 单个模型失败（或对比整体失败，此时无 model）
+	CompareEventFinish     = "finish"      // 所有模型结束
+)
+
+// CompareStreamEvent 多模型对比的流式事件，model 字段标识事件归属
+type CompareStreamEvent struct {
+	Type      string       `json:"type"`
+	Model     string       `json:"model,omitempty"`
+	TreeID    string       `json:"tree_id,omitempty"`
+	SessionID string       `json:"session,omitempty"`
+	Chunk     *StreamChunk `json:"chunk,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+// CompareEventCallback 多模型对比的流式事件回调；会被多个 goroutine 并发调用
+type CompareEventCallback func(event CompareStreamEvent) error
+
+// UploadFileRequest 文件上传请求：上传到厂商 /files 接口，
+// 返回的 file_id 可在后续请求中作为文件输入引用（PDF 分析等场景）
+type UploadFileRequest struct {
+	// Client 目标 client 名称，默认 openai
+	Client   string `json:"client,omitempty"`
+	Filename string `json:"filename"`
+	// Data 文件内容（base64）
+	Data string `json:"data"`
+	// Purpose 厂商侧的文件用途标记，默认 user_data
+	Purpose string `json:"purpose,omitempty"`
+}
+
+// UploadFileResponse 文件上传响应
+type UploadFileResponse struct {
+	FileID string `json:"file_id"`
+}
+
+// CountTokensResponse token 估算响应
+type CountTokensResponse struct {
+	Model      string `json:"model"`
+	TokenCount int    `json:"token_count"`
+	// Method 计数方式：tiktoken（模型族有对应编码器）或 heuristic（约 4 字符 1 token）
+	Method string `json:"method"`
+}
+
+// DebugPromptResponse 调试端点的响应：一次请求最终将发给模型的出站 prompt
+type DebugPromptResponse struct {
+	// Model 路由解析后的实际模型名
+	Model string `json:"model"`
+	// Messages 将要发给模型的最终消息列表（含前置的系统指令）
+	Messages []*schema.Message `json:"messages"`
 }
 
 // StreamMetaInfo 流开始时的元信息
@@ -68,6 +218,10 @@ type StreamMetaInfo struct {
 	SessionID string `json:"sessionId"`
 	RunID     string `json:"runId"`
 	IsNew     bool   `json:"isNew"`
+	// AppliedThinking thinking 开关是否真正生效（模型不支持时为 false）
+	AppliedThinking bool `json:"appliedThinking"`
+	// ClientResolution 路由决策追踪，仅 debug 模式下返回
+	ClientResolution *ClientResolutionInfo `json:"clientResolution,omitempty"`
 }
 
 // StreamStartCallback 流开始时的回调，传递元信息
@@ -82,8 +236,46 @@ type SessionInfo struct {
 	Title               string    `json:"title"`
 	LastActiveSessionID string    `json:"last_active_session_id"`
 	LastMessage         string    `json:"last_message"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	Pinned              bool      `json:"pinned"`
+	// Metadata 集成方自定义数据（后端不解释），未设置时省略
+	Metadata  json.RawMessage `json:"metadata,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	// DeletedAt 软删除时间，仅回收站列表返回
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+}
+
+// TreeSessionInfo 树内分支 session 信息 DTO（分支切换器用）
+type TreeSessionInfo struct {
+	ID string `json:"id"`
+	// MessageCount 本分支自身的消息数（不含祖先分支）
+	MessageCount int `json:"message_count"`
+	// ForkAnchor 分支点（父消息 ID），根 session 省略
+	ForkAnchor int64     `json:"fork_anchor,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ListTreeSessionsResponse 树内分支列表响应
+type ListTreeSessionsResponse struct {
+	Sessions []TreeSessionInfo `json:"sessions"`
+}
+
+// PatchSessionRequest 会话部分更新请求；仅更新出现的字段，
+// metadata 传 null 清空已有元数据
+type PatchSessionRequest struct {
+	Metadata *json.RawMessage `json:"metadata"`
+	// SystemPrompt 对话级自定义指令；空串清空，未设置不变更
+	SystemPrompt *string `json:"system_prompt"`
+}
+
+// BulkDeleteSessionsRequest 批量删除请求
+type BulkDeleteSessionsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// BulkDeleteSessionsResponse 批量删除响应：tree_id → deleted / not_found
+type BulkDeleteSessionsResponse struct {
+	Results map[string]string `json:"results"`
 }
 
 // ListSessionsResponse 会话列表响应
@@ -96,6 +288,13 @@ type GetSessionResponse struct {
 	Messages []*ChatResponse `json:"messages"`
 }
 
+// GetSessionMessagesResponse 分页读取会话消息响应
+type GetSessionMessagesResponse struct {
+	Messages []*ChatResponse `json:"messages"`
+	// HasMore 链上是否还有更晚的消息；下一页游标取本页最后一条消息的 id
+	HasMore bool `json:"has_more"`
+}
+
 // ChatService 聊天服务接口（由 service 层实现）
 type ChatService interface {
 	Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
@@ -105,6 +304,26 @@ type ChatService interface {
 		onStart StreamStartCallback,
 		onChunk StreamChunkCallback,
 	) error
+	CompareChat(ctx context.Context, req *CompareRequest) (*CompareResponse, error)
+	CompareChatStream(ctx context.Context, req *CompareRequest, onEvent CompareEventCallback) error
+	CountTokens(ctx context.Context, req *ChatRequest) (*CountTokensResponse, error)
+	DebugPrompt(ctx context.Context, req *ChatRequest) (*DebugPromptResponse, error)
+	UploadFile(ctx context.Context, client, filename string, data []byte, purpose string) (*UploadFileResponse, error)
+	ContinueSession(ctx context.Context, sessionID string) (*ChatResponse, error)
+	CancelSession(ctx context.Context, sessionID string) error
+	DeleteMessage(ctx context.Context, sessionID string, msgID int64) error
+	ForkSessionFrom(ctx context.Context, sessionID string, msgID int64) (string, error)
+	GetMessage(ctx context.Context, msgID int64) (*ChatResponse, error)
 	ListSessions(ctx context.Context) ([]SessionInfo, error)
+	ListSessionsByMetadata(ctx context.Context, key, value string) ([]SessionInfo, error)
+	ListTrash(ctx context.Context) ([]SessionInfo, error)
+	ListTreeSessions(ctx context.Context, treeID string) ([]TreeSessionInfo, error)
+	DeleteSession(ctx context.Context, id string) error
+	BulkDeleteSessions(ctx context.Context, ids []string) (map[string]string, error)
+	RestoreSession(ctx context.Context, id string) error
+	SetSessionPinned(ctx context.Context, id string, pinned bool) error
+	SetSessionMetadata(ctx context.Context, id string, meta json.RawMessage) error
+	SetSessionSystemPrompt(ctx context.Context, id, prompt string) error
 	GetSession(ctx context.Context, sessionID string) (*GetSessionResponse, error)
+	GetSessionMessages(ctx context.Context, sessionID string, afterID int64, limit int) (*GetSessionMessagesResponse, error)
 }
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"devops-backend/internal/biz"
+)
+
+// chatStreamErrorCode 将 biz 层哨兵错误与上游 HTTP 状态码映射为 AG-UI 错误码，
+// 取代按错误文案子串匹配的旧做法
+func chatStreamErrorCode(err error) string {
+	switch {
+	case errors.Is(err, biz.ErrTreeNotFound):
+		return "invalid_thread"
+	case errors.Is(err, biz.ErrSessionNotFound):
+		return "invalid_session"
+	case errors.Is(err, biz.ErrModelNotAllowed):
+		return "model_not_allowed"
+	case errors.Is(err, biz.ErrUpstreamSaturated):
+		return "upstream_saturated"
+	case errors.Is(err, biz.ErrDuplicateMessage):
+		return "duplicate_message"
+	case errors.Is(err, biz.ErrTreeTooLarge):
+		return "tree_too_large"
+	case errors.Is(err, biz.ErrEmptyResponse):
+		return "no_output"
+	case errors.Is(err, biz.ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.Is(err, biz.ErrStreamStalled),
+		errors.Is(err, biz.ErrFirstTokenTimeout),
+		errors.Is(err, biz.ErrGenerateTimeout):
+		return "timeout"
+	}
+	switch status := biz.UpstreamStatusCode(err); {
+	case status == http.StatusTooManyRequests:
+		return "upstream_rate_limited"
+	case status >= 500:
+		return "upstream_error"
+	case status >= 400:
+		return "upstream_rejected"
+	}
+	return "internal_error"
+}
+
+// isNotFoundErr 会话/树/消息不存在的统一判定
+func isNotFoundErr(err error) bool {
+	return errors.Is(err, biz.ErrSessionNotFound) ||
+		errors.Is(err, biz.ErrTreeNotFound) ||
+		errors.Is(err, biz.ErrMessageNotFound)
+}
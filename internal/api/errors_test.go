@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"devops-backend/internal/biz"
+)
+
+func TestChatStreamErrorCode_SentinelMapping(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{biz.ErrTreeNotFound, "invalid_thread"},
+		{biz.ErrSessionNotFound, "invalid_session"},
+		{biz.ErrModelNotAllowed, "model_not_allowed"},
+		{biz.ErrUpstreamSaturated, "upstream_saturated"},
+		{biz.ErrDuplicateMessage, "duplicate_message"},
+		{biz.ErrTreeTooLarge, "tree_too_large"},
+		{biz.ErrEmptyResponse, "no_output"},
+		{errors.New("something exploded"), "internal_error"},
+	}
+	for _, tc := range cases {
+		// 服务层会层层包装，映射必须穿透 %w 链
+		wrapped := fmt.Errorf("chat: %w", fmt.Errorf("run graph: %w", tc.err))
+		if got := chatStreamErrorCode(wrapped); got != tc.want {
+			t.Errorf("chatStreamErrorCode(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestChatStreamErrorCode_UpstreamStatusMapping(t *testing.T) {
+	cases := []struct {
+		status int
+		want   string
+	}{
+		{429, "upstream_rate_limited"},
+		{500, "upstream_error"},
+		{503, "upstream_error"},
+		{400, "upstream_rejected"},
+		{404, "upstream_rejected"},
+	}
+	for _, tc := range cases {
+		err := fmt.Errorf("run graph: %w", biz.WrapUpstreamStatus(tc.status, errors.New("upstream said no")))
+		if got := chatStreamErrorCode(err); got != tc.want {
+			t.Errorf("status %d: code = %q, want %q", tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestIsNotFoundErr(t *testing.T) {
+	for _, sentinel := range []error{biz.ErrSessionNotFound, biz.ErrTreeNotFound, biz.ErrMessageNotFound} {
+		if !isNotFoundErr(fmt.Errorf("get session: %w", sentinel)) {
+			t.Errorf("isNotFoundErr should match %v", sentinel)
+		}
+	}
+	if isNotFoundErr(errors.New("not found")) {
+		t.Error("isNotFoundErr must not match on error text alone")
+	}
+}
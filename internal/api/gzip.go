@@ -0,0 +1,79 @@
+package api
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// gzipMiddleware 对带 Accept-Encoding: gzip 的请求压缩响应体。
+// 流式响应（SSE / ndjson）逐事件下发，缓冲压缩会破坏实时性，
+// 按响应的 Content-Type 在首次写入时跳过
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// gzipResponseWriter 延迟到首次写入（此时 handler 已设置 Content-Type）
+// 才决定是否压缩的 ResponseWriter 包装
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz      *gzip.Writer // 非 nil 表示本响应正在压缩
+	decided bool
+}
+
+// decide 根据已设置的响应头决定是否压缩，只在首次写入时执行一次
+func (w *gzipResponseWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	ct := w.Header().Get("Content-Type")
+	if strings.HasPrefix(ct, "text/event-stream") || strings.HasPrefix(ct, ndjsonContentType) {
+		return
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		return
+	}
+	w.Header().Set("Content-Encoding", "gzip")
+	// 压缩后长度未知，预设的 Content-Length 必须去掉
+	w.Header().Del("Content-Length")
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	w.decide()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	w.decide()
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 透传给底层 Flusher；SSE handler 依赖该接口逐事件推送
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close 结束压缩流，写出 gzip 尾部
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
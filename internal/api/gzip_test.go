@@ -0,0 +1,79 @@
+package api
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestGzipMiddleware_CompressesJSON JSON 响应在客户端声明 gzip 时被压缩。
+func TestGzipMiddleware_CompressesJSON(t *testing.T) {
+	handler := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"content": strings.Repeat("long history ", 100)})
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/sessions/s1", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	gr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("body is not valid gzip: %v", err)
+	}
+	body, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatalf("failed to decompress body: %v", err)
+	}
+	if !strings.Contains(string(body), "long history") {
+		t.Errorf("decompressed body lost content: %q", body)
+	}
+}
+
+// TestGzipMiddleware_SkipsSSE SSE 响应不压缩，事件原样逐帧下发。
+func TestGzipMiddleware_SkipsSSE(t *testing.T) {
+	handler := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("wrapped writer must still expose http.Flusher for SSE")
+		}
+		io.WriteString(w, "data: {\"content\":\"hi\"}\n\n")
+		flusher.Flush()
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("SSE response must not be compressed, got Content-Encoding %q", got)
+	}
+	if got := rec.Body.String(); got != "data: {\"content\":\"hi\"}\n\n" {
+		t.Errorf("SSE body = %q, want raw event frame", got)
+	}
+}
+
+// TestGzipMiddleware_NoAcceptEncodingPassthrough 客户端未声明 gzip 时原样返回。
+func TestGzipMiddleware_NoAcceptEncodingPassthrough(t *testing.T) {
+	handler := gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"ok": "yes"})
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/sessions", nil))
+
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Fatalf("expected uncompressed response, got Content-Encoding %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "yes") {
+		t.Errorf("body = %q, want plain JSON", rec.Body.String())
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"devops-backend/internal/infra/data/provider"
+
+	"github.com/gorilla/mux"
+)
+
+// ClientHealthInfo 单个上游 client 的探活结果 DTO
+type ClientHealthInfo struct {
+	Client    string    `json:"client"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// ModelsService 模型相关服务接口（由 service 层实现）
+type ModelsService interface {
+	ClientHealth(ctx context.Context) ([]ClientHealthInfo, error)
+}
+
+// ModelsHandler 模型健康查询 handler
+type ModelsHandler struct {
+	service ModelsService
+}
+
+// NewModelsHandler 创建模型健康查询 handler
+func NewModelsHandler(service ModelsService) *ModelsHandler {
+	return &ModelsHandler{service: service}
+}
+
+// RegisterRoutes 注册路由到 mux.Router
+func (h *ModelsHandler) RegisterRoutes(r *mux.Router) {
+	r.HandleFunc("/models/health", h.clientHealth).Methods(http.MethodGet)
+	r.HandleFunc("/models/{name}/capabilities", h.modelCapabilities).Methods(http.MethodGet)
+}
+
+// modelCapabilities 返回模型的能力与上限（模态、上下文窗口、输出上限）。
+// known 为 false 表示注册表没有该模型的条目，上限为默认值
+func (h *ModelsHandler) modelCapabilities(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	registry := provider.GetModelCapabilityRegistry()
+	caps := registry.GetCapabilities(name)
+
+	resp := map[string]any{
+		"model":              name,
+		"known":              caps != nil,
+		"max_context_tokens": registry.MaxContextTokens(name),
+		"max_output_tokens":  registry.MaxOutputTokens(name),
+	}
+	if caps != nil {
+		resp["supported_modalities"] = caps.SupportedModalities
+		resp["requires_non_streaming"] = caps.RequiresNonStreaming
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// clientHealth 返回所有上游 client 的最近探活结果（dashboard 用）
+func (h *ModelsHandler) clientHealth(w http.ResponseWriter, r *http.Request) {
+	clients, err := h.service.ClientHealth(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"clients": clients,
+		"count":   len(clients),
+	})
+}
@@ -0,0 +1,329 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/google/uuid"
+)
+
+// OpenAI Chat Completions 兼容层：让现成的 OpenAI SDK / 工具直接指向本服务。
+// 请求/响应形状遵循 OpenAI 规范，内部统一走无状态聊天链路（不建会话、不落库）。
+
+// openAIChatRequest OpenAI /chat/completions 请求体
+type openAIChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []openAIChatMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+	Seed     *int                `json:"seed,omitempty"`
+	// 以下采样参数接受但忽略：内部链路不透传通用采样参数
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	MaxTokens   *int     `json:"max_tokens,omitempty"`
+}
+
+// openAIChatMessage OpenAI 消息；content 支持字符串或 text 分片数组
+type openAIChatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+	Name    string          `json:"name,omitempty"`
+}
+
+// openAIChatCompletion 非流式响应
+type openAIChatCompletion struct {
+	ID      string             `json:"id"`
+	Object  string             `json:"object"`
+	Created int64              `json:"created"`
+	Model   string             `json:"model"`
+	Choices []openAIChatChoice `json:"choices"`
+	Usage   *openAIUsage       `json:"usage,omitempty"`
+}
+
+type openAIChatChoice struct {
+	Index        int                  `json:"index"`
+	Message      *openAIChoiceMessage `json:"message,omitempty"`
+	Delta        *openAIChoiceDelta   `json:"delta,omitempty"`
+	FinishReason *string              `json:"finish_reason"`
+}
+
+type openAIChoiceMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// ReasoningContent 推理正文（DeepSeek 风格扩展字段），无推理时省略
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+type openAIChoiceDelta struct {
+	Role             string `json:"role,omitempty"`
+	Content          string `json:"content,omitempty"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// openAIError OpenAI 规范的错误响应体
+type openAIError struct {
+	Error openAIErrorDetail `json:"error"`
+}
+
+type openAIErrorDetail struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+func writeOpenAIError(w http.ResponseWriter, status int, errType, message string) {
+	writeJSON(w, status, openAIError{Error: openAIErrorDetail{Message: message, Type: errType}})
+}
+
+// openaiChatCompletions OpenAI 兼容入口：翻译为内部无状态 ChatRequest，
+// 流式时按 choices[].delta 形状下发 SSE，以 data: [DONE] 结束
+func (h *ChatHandler) openaiChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var body openAIChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", "invalid request body: "+err.Error())
+		return
+	}
+	req, err := buildChatRequestFromOpenAI(&body)
+	if err != nil {
+		writeOpenAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	completionID := "chatcmpl-" + uuid.NewString()
+	created := time.Now().Unix()
+
+	if !body.Stream {
+		resp, err := h.chatService.Chat(r.Context(), req)
+		if err != nil {
+			writeOpenAIError(w, http.StatusInternalServerError, "api_error", err.Error())
+			return
+		}
+		finish := "stop"
+		var usage *openAIUsage
+		if resp.ResponseMeta != nil {
+			if resp.ResponseMeta.FinishReason != "" {
+				finish = resp.ResponseMeta.FinishReason
+			}
+			if u := resp.ResponseMeta.Usage; u != nil {
+				usage = &openAIUsage{
+					PromptTokens:     u.PromptTokens,
+					CompletionTokens: u.CompletionTokens,
+					TotalTokens:      u.TotalTokens,
+				}
+			}
+		}
+		writeJSON(w, http.StatusOK, openAIChatCompletion{
+			ID:      completionID,
+			Object:  "chat.completion",
+			Created: created,
+			Model:   resp.Model,
+			Choices: []openAIChatChoice{{
+				Message: &openAIChoiceMessage{
+					Role:             "assistant",
+					Content:          resp.Content,
+					ReasoningContent: resp.ReasoningContent,
+				},
+				FinishReason: &finish,
+			}},
+			Usage: usage,
+		})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeOpenAIError(w, http.StatusInternalServerError, "api_error", "streaming not supported")
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	enc := &openAIStreamEncoder{
+		w:       w,
+		flusher: flusher,
+		id:      completionID,
+		created: created,
+		model:   req.Model,
+	}
+	err = h.chatService.ChatStream(r.Context(), req,
+		func(StreamMetaInfo) error { return nil },
+		enc.onChunk,
+	)
+	if err != nil {
+		// 已经开始下发流，错误只能以 error 帧形式通知客户端
+		enc.writeError(err)
+		return
+	}
+	enc.finish()
+}
+
+// openAIStreamEncoder 把内部 StreamChunk 编码为 OpenAI chat.completion.chunk 帧
+type openAIStreamEncoder struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	id      string
+	created int64
+	model   string
+
+	started      bool // 首帧已发出（携带 role: assistant）
+	finishReason string
+	usage        *openAIUsage
+}
+
+func (e *openAIStreamEncoder) onChunk(chunk StreamChunk) error {
+	if chunk.FinishReason != "" {
+		e.finishReason = chunk.FinishReason
+	}
+	if chunk.Usage != nil {
+		e.usage = &openAIUsage{
+			PromptTokens:     chunk.Usage.PromptTokens,
+			CompletionTokens: chunk.Usage.CompletionTokens,
+			TotalTokens:      chunk.Usage.TotalTokens,
+		}
+	}
+	if chunk.Content == "" && chunk.ReasoningContent == "" {
+		return nil
+	}
+	delta := &openAIChoiceDelta{
+		Content:          chunk.Content,
+		ReasoningContent: chunk.ReasoningContent,
+	}
+	if !e.started {
+		delta.Role = "assistant"
+		e.started = true
+	}
+	return e.writeFrame(openAIChatCompletion{
+		ID:      e.id,
+		Object:  "chat.completion.chunk",
+		Created: e.created,
+		Model:   e.model,
+		Choices: []openAIChatChoice{{Delta: delta, FinishReason: nil}},
+	})
+}
+
+// finish 下发携带 finish_reason（与可选 usage）的终止帧，随后 data: [DONE]
+func (e *openAIStreamEncoder) finish() {
+	finish := e.finishReason
+	if finish == "" {
+		finish = "stop"
+	}
+	_ = e.writeFrame(openAIChatCompletion{
+		ID:      e.id,
+		Object:  "chat.completion.chunk",
+		Created: e.created,
+		Model:   e.model,
+		Choices: []openAIChatChoice{{Delta: &openAIChoiceDelta{}, FinishReason: &finish}},
+		Usage:   e.usage,
+	})
+	_, _ = fmt.Fprint(e.w, "data: [DONE]\n\n")
+	e.flusher.Flush()
+}
+
+func (e *openAIStreamEncoder) writeError(err error) {
+	data, _ := json.Marshal(openAIError{Error: openAIErrorDetail{
+		Message: err.Error(),
+		Type:    "api_error",
+	}})
+	_, _ = fmt.Fprintf(e.w, "data: %s\n\n", data)
+	e.flusher.Flush()
+}
+
+func (e *openAIStreamEncoder) writeFrame(frame openAIChatCompletion) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(e.w, "data: %s\n\n", data); err != nil {
+		return err
+	}
+	e.flusher.Flush()
+	return nil
+}
+
+// buildChatRequestFromOpenAI 把 OpenAI 请求翻译为内部无状态 ChatRequest：
+// 完整消息列表作为 History，最后一条作为请求消息
+func buildChatRequestFromOpenAI(body *openAIChatRequest) (*ChatRequest, error) {
+	if len(body.Messages) == 0 {
+		return nil, fmt.Errorf("messages is required")
+	}
+	history := make([]*schema.Message, 0, len(body.Messages))
+	for i, m := range body.Messages {
+		msg, err := parseOpenAIMessage(m)
+		if err != nil {
+			return nil, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		history = append(history, msg)
+	}
+	return &ChatRequest{
+		Message:   *history[len(history)-1],
+		Model:     strings.TrimSpace(body.Model),
+		RunID:     "run_" + uuid.NewString(),
+		Seed:      body.Seed,
+		Stateless: true,
+		History:   history,
+	}, nil
+}
+
+func parseOpenAIMessage(m openAIChatMessage) (*schema.Message, error) {
+	role, err := parseOpenAIRole(m.Role)
+	if err != nil {
+		return nil, err
+	}
+	content, err := parseOpenAIContent(m.Content)
+	if err != nil {
+		return nil, err
+	}
+	return &schema.Message{Role: role, Content: content, Name: m.Name}, nil
+}
+
+func parseOpenAIRole(role string) (schema.RoleType, error) {
+	switch role {
+	case "system", "developer":
+		return schema.System, nil
+	case "user":
+		return schema.User, nil
+	case "assistant":
+		return schema.Assistant, nil
+	case "tool":
+		return schema.Tool, nil
+	default:
+		return "", fmt.Errorf("unsupported role %q", role)
+	}
+}
+
+// parseOpenAIContent content 为字符串，或 text 分片数组（多模态分片暂不支持）
+func parseOpenAIContent(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", fmt.Errorf("content must be a string or an array of content parts")
+	}
+	var sb strings.Builder
+	for _, p := range parts {
+		if p.Type != "text" {
+			return "", fmt.Errorf("unsupported content part type %q", p.Type)
+		}
+		sb.WriteString(p.Text)
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// openaiStubService 固定返回 "hello" 的 ChatService 桩，记录收到的请求
+type openaiStubService struct {
+	noopChatService
+	gotReq *ChatRequest
+}
+
+func (s *openaiStubService) Chat(_ context.Context, req *ChatRequest) (*ChatResponse, error) {
+	s.gotReq = req
+	return &ChatResponse{
+		Message: schema.Message{
+			Role:    schema.Assistant,
+			Content: "hello",
+			ResponseMeta: &schema.ResponseMeta{
+				FinishReason: "stop",
+				Usage:        &schema.TokenUsage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5},
+			},
+		},
+		Model: "gpt-4o",
+	}, nil
+}
+
+func (s *openaiStubService) ChatStream(_ context.Context, req *ChatRequest, onStart StreamStartCallback, onChunk StreamChunkCallback) error {
+	s.gotReq = req
+	if err := onStart(StreamMetaInfo{}); err != nil {
+		return err
+	}
+	for _, chunk := range []StreamChunk{
+		{Content: "he"},
+		{Content: "llo"},
+		{FinishReason: "stop", Usage: &schema.TokenUsage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5}},
+	} {
+		if err := onChunk(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestOpenAICompat_NonStreamingShape(t *testing.T) {
+	stub := &openaiStubService{}
+	handler := NewChatHandler(stub, 0)
+	body := `{"model":"gpt-4o","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/chat/completions", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	handler.openaiChatCompletions(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body: %s", recorder.Code, recorder.Body.String())
+	}
+	var resp openAIChatCompletion
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !strings.HasPrefix(resp.ID, "chatcmpl-") {
+		t.Errorf("unexpected id: %q", resp.ID)
+	}
+	if resp.Object != "chat.completion" {
+		t.Errorf("unexpected object: %q", resp.Object)
+	}
+	if resp.Model != "gpt-4o" || resp.Created == 0 {
+		t.Errorf("unexpected model/created: %q / %d", resp.Model, resp.Created)
+	}
+	if len(resp.Choices) != 1 {
+		t.Fatalf("unexpected choices length: %d", len(resp.Choices))
+	}
+	choice := resp.Choices[0]
+	if choice.Message == nil || choice.Message.Role != "assistant" || choice.Message.Content != "hello" {
+		t.Errorf("unexpected message: %#v", choice.Message)
+	}
+	if choice.FinishReason == nil || *choice.FinishReason != "stop" {
+		t.Errorf("unexpected finish_reason: %v", choice.FinishReason)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 5 {
+		t.Errorf("unexpected usage: %#v", resp.Usage)
+	}
+
+	// 翻译结果必须是无状态请求，完整消息列表进 History
+	if stub.gotReq == nil || !stub.gotReq.Stateless {
+		t.Fatalf("expected stateless chat request, got %#v", stub.gotReq)
+	}
+	if len(stub.gotReq.History) != 2 || stub.gotReq.History[0].Role != schema.System {
+		t.Errorf("unexpected history: %#v", stub.gotReq.History)
+	}
+	if stub.gotReq.Content != "hi" {
+		t.Errorf("unexpected last message content: %q", stub.gotReq.Content)
+	}
+}
+
+func TestOpenAICompat_StreamingShape(t *testing.T) {
+	stub := &openaiStubService{}
+	handler := NewChatHandler(stub, 0)
+	body := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"hi"}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/chat/completions", bytes.NewBufferString(body))
+	recorder := httptest.NewRecorder()
+	handler.openaiChatCompletions(recorder, req)
+
+	if ct := recorder.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type: %q", ct)
+	}
+
+	var frames []string
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if strings.HasPrefix(line, "data: ") {
+			frames = append(frames, strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if len(frames) != 4 {
+		t.Fatalf("unexpected frame count %d: %v", len(frames), frames)
+	}
+	if frames[len(frames)-1] != "[DONE]" {
+		t.Fatalf("stream must end with [DONE], got %q", frames[len(frames)-1])
+	}
+
+	var content string
+	for i, raw := range frames[:len(frames)-1] {
+		var chunk openAIChatCompletion
+		if err := json.Unmarshal([]byte(raw), &chunk); err != nil {
+			t.Fatalf("frame %d is not valid JSON: %v", i, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("frame %d unexpected object: %q", i, chunk.Object)
+		}
+		if len(chunk.Choices) != 1 || chunk.Choices[0].Delta == nil {
+			t.Fatalf("frame %d missing delta: %s", i, raw)
+		}
+		content += chunk.Choices[0].Delta.Content
+	}
+	if content != "hello" {
+		t.Errorf("unexpected concatenated content: %q", content)
+	}
+
+	var first, last openAIChatCompletion
+	_ = json.Unmarshal([]byte(frames[0]), &first)
+	_ = json.Unmarshal([]byte(frames[2]), &last)
+	if first.Choices[0].Delta.Role != "assistant" {
+		t.Errorf("first delta must carry role assistant: %s", frames[0])
+	}
+	if first.Choices[0].FinishReason != nil {
+		t.Errorf("non-final frame must have null finish_reason: %s", frames[0])
+	}
+	if last.Choices[0].FinishReason == nil || *last.Choices[0].FinishReason != "stop" {
+		t.Errorf("final frame must carry finish_reason stop: %s", frames[2])
+	}
+	if last.Usage == nil || last.Usage.TotalTokens != 5 {
+		t.Errorf("final frame must carry usage: %s", frames[2])
+	}
+}
+
+func TestOpenAICompat_InvalidRequest(t *testing.T) {
+	handler := NewChatHandler(&openaiStubService{}, 0)
+
+	for name, body := range map[string]string{
+		"no messages":      `{"model":"gpt-4o","messages":[]}`,
+		"bad role":         `{"messages":[{"role":"oracle","content":"hi"}]}`,
+		"bad content part": `{"messages":[{"role":"user","content":[{"type":"image_url"}]}]}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/chat/completions", bytes.NewBufferString(body))
+		recorder := httptest.NewRecorder()
+		handler.openaiChatCompletions(recorder, req)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: unexpected status %d", name, recorder.Code)
+			continue
+		}
+		var resp openAIError
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Errorf("%s: failed to decode error: %v", name, err)
+			continue
+		}
+		if resp.Error.Type != "invalid_request_error" || resp.Error.Message == "" {
+			t.Errorf("%s: unexpected error payload: %#v", name, resp.Error)
+		}
+	}
+}
+
+func TestParseOpenAIContent_TextParts(t *testing.T) {
+	got, err := parseOpenAIContent(json.RawMessage(`[{"type":"text","text":"hello "},{"type":"text","text":"world"}]`))
+	if err != nil {
+		t.Fatalf("parseOpenAIContent returned error: %v", err)
+	}
+	if got != "hello world" {
+		t.Fatalf("unexpected content: %q", got)
+	}
+}
@@ -7,9 +7,15 @@ import (
 )
 
 // NewRouter 创建路由并注册所有 handler
-func NewRouter(chatHandler *ChatHandler, authHandler *AuthHandler, authMiddleware func(http.Handler) http.Handler) *mux.Router {
+func NewRouter(chatHandler *ChatHandler, debugHandler *DebugHandler, authHandler *AuthHandler, adminHandler *AdminHandler, modelsHandler *ModelsHandler, authMiddleware, adminMiddleware func(http.Handler) http.Handler) *mux.Router {
 	r := mux.NewRouter()
 
+	// 访问日志放最外层，SSE 流结束后才记录完整时长与字节数
+	r.Use(accessLogMiddleware)
+
+	// 响应压缩：客户端声明 Accept-Encoding: gzip 时生效，流式响应自动跳过
+	r.Use(gzipMiddleware)
+
 	// Health check endpoint (public, no auth)
 	r.HandleFunc("/health", HealthCheckHandler).Methods("GET")
 
@@ -24,6 +30,24 @@ func NewRouter(chatHandler *ChatHandler, authHandler *AuthHandler, authMiddlewar
 		apiRouter.Use(authMiddleware) // Apply auth middleware
 	}
 	chatHandler.RegisterRoutes(apiRouter)
+	if debugHandler != nil {
+		debugHandler.RegisterRoutes(apiRouter)
+	}
+	if modelsHandler != nil {
+		modelsHandler.RegisterRoutes(apiRouter)
+	}
+
+	// Admin routes (auth + admin role)
+	if adminHandler != nil {
+		adminRouter := r.PathPrefix("/admin").Subrouter()
+		if authMiddleware != nil {
+			adminRouter.Use(authMiddleware)
+		}
+		if adminMiddleware != nil {
+			adminRouter.Use(adminMiddleware)
+		}
+		adminHandler.RegisterRoutes(adminRouter)
+	}
 
 	return r
 }
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// sseWriter 封装流式输出的帧编码与 flush，SSE 与 NDJSON 共用一套实现。
+// 事件统一经 json.Marshal 编码后单行输出，payload 里的换行会被转义，
+// 不会再出现裸字符串拆帧的问题；写入带锁，keepalive goroutine 可并发调用
+type sseWriter struct {
+	w       io.Writer
+	flusher http.Flusher // 测试时可为 nil
+	ndjson  bool         // true 时每个事件一行 JSON，而不是 SSE data: 帧
+	mu      sync.Mutex
+}
+
+func newSSEWriter(w io.Writer, flusher http.Flusher, ndjson bool) *sseWriter {
+	return &sseWriter{w: w, flusher: flusher, ndjson: ndjson}
+}
+
+// Event 编码并写出一个事件帧
+func (s *sseWriter) Event(data any) error {
+	return s.NamedEvent("", data)
+}
+
+// NamedEvent 编码并写出一个带 event: 名称的事件帧；name 为空时退化为纯 data: 帧，
+// NDJSON 没有事件名的概念，name 被忽略
+func (s *sseWriter) NamedEvent(name string, data any) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ndjson {
+		_, err = fmt.Fprintf(s.w, "%s\n", encoded)
+	} else if name != "" {
+		_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, encoded)
+	} else {
+		_, err = fmt.Fprintf(s.w, "data: %s\n\n", encoded)
+	}
+	if err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+// Comment 写 SSE 注释行（客户端会忽略，仅用于保活）；
+// NDJSON 没有注释帧，改发空行（按行解析的客户端会跳过空行）
+func (s *sseWriter) Comment(comment string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if s.ndjson {
+		_, err = fmt.Fprint(s.w, "\n")
+	} else {
+		_, err = fmt.Fprintf(s.w, ": %s\n\n", comment)
+	}
+	if err != nil {
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+func (s *sseWriter) flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
@@ -0,0 +1,56 @@
+package api
+
+import "fmt"
+
+// 不同前端对 SSE 事件约定不一致：默认风格每个事件一个 data: 帧，类型放在
+// payload 的 type 字段里；也有前端只订阅单一 "message" 命名事件，期望
+// {"type":"content","delta":...} 这类统一 payload。查询参数 sse_mode 在两种
+// 风格间切换，帧格式化收敛为策略函数，接新前端时不必改造事件处理逻辑。
+
+const (
+	// sseModeAGUI 默认风格：多事件、无 event: 名称，payload 自带 type
+	sseModeAGUI = "agui"
+	// sseModeMessage 统一风格：一律 event: message + 类型化 payload
+	sseModeMessage = "message"
+)
+
+// sseFrameStrategy 把一个语义事件格式化为输出帧：事件名（空 = 纯 data: 帧）
+// 与实际编码的 payload
+type sseFrameStrategy func(event any) (name string, payload any)
+
+// sseFrameStrategyFor 解析 sse_mode 查询参数为对应的帧策略；
+// 空值等同默认风格，未知取值报错
+func sseFrameStrategyFor(mode string) (sseFrameStrategy, error) {
+	switch mode {
+	case "", sseModeAGUI:
+		return aguiFrames, nil
+	case sseModeMessage:
+		return messageFrames, nil
+	default:
+		return nil, fmt.Errorf("unknown sse_mode %q (want agui or message)", mode)
+	}
+}
+
+// aguiFrames 默认风格：事件原样编码为 data: 帧
+func aguiFrames(event any) (string, any) {
+	return "", event
+}
+
+// unifiedDeltaPayload 统一风格下文本/推理增量的简化 payload
+type unifiedDeltaPayload struct {
+	Type      string `json:"type"`
+	MessageID string `json:"messageId,omitempty"`
+	Delta     string `json:"delta"`
+}
+
+// messageFrames 统一风格：所有事件都从 event: message 下发；
+// 文本与推理增量换成简化 payload，其余事件 payload 原样（type 字段仍可区分）
+func messageFrames(event any) (string, any) {
+	switch ev := event.(type) {
+	case aguiTextMessageDeltaEvent:
+		return sseModeMessage, unifiedDeltaPayload{Type: "content", MessageID: ev.MessageID, Delta: ev.Delta}
+	case aguiTextReasoningDeltaEvent:
+		return sseModeMessage, unifiedDeltaPayload{Type: "reasoning", MessageID: ev.MessageID, Delta: ev.Delta}
+	}
+	return sseModeMessage, event
+}
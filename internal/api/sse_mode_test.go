@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// runEncoderWithMode 跑一段固定的流并返回原始输出
+func runEncoderWithMode(t *testing.T, format sseFrameStrategy) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	encoder := newAGUIStreamEncoder(recorder, recorder, "t1", "r1", false, format)
+
+	if err := encoder.onStart(StreamMetaInfo{ThreadID: "t1", RunID: "r1"}); err != nil {
+		t.Fatalf("onStart failed: %v", err)
+	}
+	if err := encoder.onChunk(StreamChunk{ReasoningContent: "think"}); err != nil {
+		t.Fatalf("reasoning chunk failed: %v", err)
+	}
+	if err := encoder.onChunk(StreamChunk{Content: "answer", ReasoningDone: true}); err != nil {
+		t.Fatalf("content chunk failed: %v", err)
+	}
+	if err := encoder.onDone(); err != nil {
+		t.Fatalf("onDone failed: %v", err)
+	}
+	return recorder.Body.String()
+}
+
+func TestSSEMode_DefaultStyleHasNoEventNames(t *testing.T) {
+	out := runEncoderWithMode(t, nil)
+
+	if strings.Contains(out, "event: ") {
+		t.Errorf("default mode must not emit named events, got: %q", out)
+	}
+	if !strings.Contains(out, `"type":"TEXT_MESSAGE_DELTA"`) {
+		t.Errorf("default mode must keep AG-UI typed payloads, got: %q", out)
+	}
+}
+
+func TestSSEMode_MessageStyleUnifiesEvents(t *testing.T) {
+	out := runEncoderWithMode(t, messageFrames)
+
+	var payloads []map[string]any
+	for _, frame := range strings.Split(out, "\n\n") {
+		if frame == "" {
+			continue
+		}
+		lines := strings.Split(frame, "\n")
+		if len(lines) != 2 || lines[0] != "event: message" {
+			t.Fatalf("every frame must be a named message event, got frame: %q", frame)
+		}
+		var payload map[string]any
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &payload); err != nil {
+			t.Fatalf("invalid payload JSON: %q (%v)", lines[1], err)
+		}
+		payloads = append(payloads, payload)
+	}
+
+	var sawContent, sawReasoning bool
+	for _, p := range payloads {
+		switch p["type"] {
+		case "content":
+			sawContent = true
+			if p["delta"] != "answer" {
+				t.Errorf("content delta = %v, want answer", p["delta"])
+			}
+		case "reasoning":
+			sawReasoning = true
+			if p["delta"] != "think" {
+				t.Errorf("reasoning delta = %v, want think", p["delta"])
+			}
+		case "TEXT_MESSAGE_DELTA", "TEXT_MESSAGE_REASONING_DELTA":
+			t.Errorf("delta events must use unified payloads, got %v", p)
+		}
+	}
+	if !sawContent || !sawReasoning {
+		t.Fatalf("expected unified content and reasoning payloads, got: %v", payloads)
+	}
+	// 其余事件保留原 payload，type 字段仍可区分
+	if first := payloads[0]; first["type"] != "RUN_STARTED" {
+		t.Errorf("first payload = %v, want RUN_STARTED passthrough", first)
+	}
+}
+
+func TestChatHandler_RejectsUnknownSSEMode(t *testing.T) {
+	handler := NewChatHandler(noopChatService{}, 0)
+	body := `{"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/chat?sse_mode=bogus", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	handler.chat(recorder, req)
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400 for unknown sse_mode", recorder.Code)
+	}
+}
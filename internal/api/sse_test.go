@@ -0,0 +1,79 @@
+package api
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSSEWriter_EventFraming(t *testing.T) {
+	var buf bytes.Buffer
+	w := newSSEWriter(&buf, nil, false)
+
+	events := []any{
+		aguiTextMessageStartEvent{Type: "TEXT_MESSAGE_START", MessageID: "m1", Role: "assistant"},
+		aguiTextReasoningDeltaEvent{Type: "TEXT_MESSAGE_REASONING_DELTA", MessageID: "m1", Delta: "think"},
+		aguiTextMessageDeltaEvent{Type: "TEXT_MESSAGE_DELTA", MessageID: "m1", Delta: "hi"},
+		aguiToolCallStartEvent{Type: "TOOL_CALL_START", ToolCallID: "t1", ToolCallName: "search", ParentMsgID: "m1"},
+		aguiRunFinishedEvent{Type: "RUN_FINISHED", ThreadID: "th", RunID: "r1", Status: "done"},
+	}
+	for _, ev := range events {
+		if err := w.Event(ev); err != nil {
+			t.Fatalf("event write failed: %v", err)
+		}
+	}
+
+	want := `data: {"type":"TEXT_MESSAGE_START","messageId":"m1","role":"assistant"}` + "\n\n" +
+		`data: {"type":"TEXT_MESSAGE_REASONING_DELTA","messageId":"m1","delta":"think"}` + "\n\n" +
+		`data: {"type":"TEXT_MESSAGE_DELTA","messageId":"m1","delta":"hi"}` + "\n\n" +
+		`data: {"type":"TOOL_CALL_START","toolCallId":"t1","toolCallName":"search","parentMessageId":"m1"}` + "\n\n" +
+		`data: {"type":"RUN_FINISHED","threadId":"th","runId":"r1","status":"done"}` + "\n\n"
+	if buf.String() != want {
+		t.Errorf("frames mismatch:\ngot:  %q\nwant: %q", buf.String(), want)
+	}
+}
+
+func TestSSEWriter_NewlinesInPayloadStayOneFrame(t *testing.T) {
+	var buf bytes.Buffer
+	w := newSSEWriter(&buf, nil, false)
+
+	// 多行错误信息必须被 JSON 转义进单个 data: 帧，裸换行会拆坏 SSE 帧
+	if err := w.Event(aguiRunErrorEvent{Type: "RUN_ERROR", Code: "internal_error", Message: "line1\nline2"}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if got := strings.Count(out, "data: "); got != 1 {
+		t.Fatalf("expected a single data frame, got %d:\n%s", got, out)
+	}
+	if !strings.Contains(out, `line1\nline2`) {
+		t.Errorf("newline should be JSON-escaped, got: %q", out)
+	}
+}
+
+func TestSSEWriter_Comment(t *testing.T) {
+	var buf bytes.Buffer
+	w := newSSEWriter(&buf, nil, false)
+	if err := w.Comment("keepalive"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != ": keepalive\n\n" {
+		t.Errorf("comment frame = %q", buf.String())
+	}
+}
+
+func TestSSEWriter_NDJSONMode(t *testing.T) {
+	var buf bytes.Buffer
+	w := newSSEWriter(&buf, nil, true)
+
+	if err := w.Event(aguiTextMessageDeltaEvent{Type: "TEXT_MESSAGE_DELTA", MessageID: "m1", Delta: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Comment("keepalive"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"type":"TEXT_MESSAGE_DELTA","messageId":"m1","delta":"hi"}` + "\n" + "\n"
+	if buf.String() != want {
+		t.Errorf("ndjson output = %q, want %q", buf.String(), want)
+	}
+}
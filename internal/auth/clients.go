@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"devops-backend/internal/conf"
+)
+
+// DefaultProviderName is the name assigned to the single-provider config form.
+const DefaultProviderName = "default"
+
+// OIDCClients routes auth operations across named OIDC providers.
+// With the legacy single-provider config it holds exactly one client
+// registered under DefaultProviderName.
+type OIDCClients struct {
+	clients     map[string]*OIDCClient
+	defaultName string
+}
+
+// NewOIDCClients builds one OIDCClient per configured provider.
+// When cfg.Providers is empty it falls back to the top-level single-provider
+// fields, keeping existing configs working unchanged.
+func NewOIDCClients(ctx context.Context, cfg *conf.Auth, redirectURL string) (*OIDCClients, error) {
+	clients := make(map[string]*OIDCClient)
+
+	if len(cfg.Providers) == 0 {
+		client, err := NewOIDCClient(ctx, cfg, redirectURL)
+		if err != nil {
+			return nil, err
+		}
+		clients[DefaultProviderName] = client
+		return &OIDCClients{clients: clients, defaultName: DefaultProviderName}, nil
+	}
+
+	for name, p := range cfg.Providers {
+		sub := &conf.Auth{
+			Provider:     p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			Scopes:       p.Scopes,
+		}
+		client, err := NewOIDCClient(ctx, sub, redirectURL)
+		if err != nil {
+			return nil, fmt.Errorf("provider %q: %w", name, err)
+		}
+		clients[name] = client
+	}
+
+	defaultName := cfg.DefaultProvider
+	if defaultName == "" {
+		if len(clients) == 1 {
+			for name := range clients {
+				defaultName = name
+			}
+		} else {
+			return nil, fmt.Errorf("auth.default_provider is required with multiple providers")
+		}
+	}
+	if _, ok := clients[defaultName]; !ok {
+		return nil, fmt.Errorf("auth.default_provider %q is not a configured provider", defaultName)
+	}
+
+	return &OIDCClients{clients: clients, defaultName: defaultName}, nil
+}
+
+// Get returns the client for the named provider, falling back to the default
+// when name is empty. The resolved name is returned for state/cookie tracking.
+func (m *OIDCClients) Get(name string) (*OIDCClient, string, error) {
+	if name == "" {
+		name = m.defaultName
+	}
+	client, ok := m.clients[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown auth provider: %s", name)
+	}
+	return client, name, nil
+}
+
+// Names lists the configured provider names in stable order.
+func (m *OIDCClients) Names() []string {
+	names := make([]string, 0, len(m.clients))
+	for name := range m.clients {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// verifyIDToken verifies the token against the hinted provider when known,
+// otherwise tries every configured provider until one accepts it.
+func (m *OIDCClients) verifyIDToken(ctx context.Context, rawIDToken, providerHint string) (*UserInfo, error) {
+	if client, ok := m.clients[providerHint]; ok {
+		return verifyAndExtractClaims(ctx, client, rawIDToken)
+	}
+
+	var lastErr error
+	for _, name := range m.Names() {
+		userInfo, err := verifyAndExtractClaims(ctx, m.clients[name], rawIDToken)
+		if err == nil {
+			return userInfo, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func verifyAndExtractClaims(ctx context.Context, client *OIDCClient, rawIDToken string) (*UserInfo, error) {
+	idToken, err := client.VerifyIDToken(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	var userInfo UserInfo
+	if err := idToken.Claims(&userInfo); err != nil {
+		return nil, err
+	}
+	return &userInfo, nil
+}
@@ -10,53 +10,49 @@ import (
 const (
 	// IDTokenCookieName is the name of the ID token cookie
 	IDTokenCookieName = "id_token"
+	// ProviderCookieName stores which provider issued the ID token, so the
+	// middleware can verify against the matching verifier
+	ProviderCookieName = "id_token_provider"
+	// ProviderHeaderName is the header-based equivalent of ProviderCookieName
+	// for SPA/Mobile clients that send the token via Authorization header
+	ProviderHeaderName = "X-Auth-Provider"
 )
 
 // AuthMiddleware validates ID token for protected routes (stateless)
 // Supports both cookie-based (Web) and header-based (SPA/Mobile) auth
-func (c *OIDCClient) AuthMiddleware() func(http.Handler) http.Handler {
+func (m *OIDCClients) AuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// Try to extract ID token from cookie or Authorization header
-			idTokenString := c.extractIDToken(r)
+			idTokenString := extractIDToken(r)
 			if idTokenString == "" {
 				writeUnauthorized(w, "missing authentication token")
 				return
 			}
 
 			// Verify ID token signature and extract claims (stateless)
-			idToken, err := c.VerifyIDToken(r.Context(), idTokenString)
+			userInfo, err := m.verifyIDToken(r.Context(), idTokenString, extractProviderHint(r))
 			if err != nil {
 				writeUnauthorized(w, "invalid or expired token")
 				return
 			}
 
-			// Extract user info from token claims
-			var userInfo UserInfo
-			if err := idToken.Claims(&userInfo); err != nil {
-				writeUnauthorized(w, "failed to parse token claims")
-				return
-			}
-
 			// Add user info to context
-			ctx := context.WithValue(r.Context(), UserContextKey, &userInfo)
+			ctx := context.WithValue(r.Context(), UserContextKey, userInfo)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
 // OptionalAuthMiddleware extracts user if present but doesn't require it
-func (c *OIDCClient) OptionalAuthMiddleware() func(http.Handler) http.Handler {
+func (m *OIDCClients) OptionalAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			idTokenString := c.extractIDToken(r)
+			idTokenString := extractIDToken(r)
 			if idTokenString != "" {
-				if idToken, err := c.VerifyIDToken(r.Context(), idTokenString); err == nil {
-					var userInfo UserInfo
-					if err := idToken.Claims(&userInfo); err == nil {
-						ctx := context.WithValue(r.Context(), UserContextKey, &userInfo)
-						r = r.WithContext(ctx)
-					}
+				if userInfo, err := m.verifyIDToken(r.Context(), idTokenString, extractProviderHint(r)); err == nil {
+					ctx := context.WithValue(r.Context(), UserContextKey, userInfo)
+					r = r.WithContext(ctx)
 				}
 			}
 			next.ServeHTTP(w, r)
@@ -65,7 +61,7 @@ func (c *OIDCClient) OptionalAuthMiddleware() func(http.Handler) http.Handler {
 }
 
 // extractIDToken extracts ID token from cookie or Authorization header
-func (c *OIDCClient) extractIDToken(r *http.Request) string {
+func extractIDToken(r *http.Request) string {
 	// 1. Try cookie first (for Web applications)
 	if cookie, err := r.Cookie(IDTokenCookieName); err == nil {
 		return cookie.Value
@@ -84,6 +80,42 @@ func (c *OIDCClient) extractIDToken(r *http.Request) string {
 	return ""
 }
 
+// extractProviderHint extracts the issuing provider name from cookie or header.
+// An empty or unknown hint makes verification fall back to trying all providers.
+func extractProviderHint(r *http.Request) string {
+	if cookie, err := r.Cookie(ProviderCookieName); err == nil {
+		return cookie.Value
+	}
+	return r.Header.Get(ProviderHeaderName)
+}
+
+// AdminRole is the role claim required for operator endpoints.
+const AdminRole = "admin"
+
+// RequireRole gates a route on a role claim. It must run after AuthMiddleware
+// so the user is already in the request context.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, err := GetUserFromContext(r.Context())
+			if err != nil {
+				writeUnauthorized(w, "missing authentication token")
+				return
+			}
+			if !user.HasRole(role) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error":   "forbidden",
+					"message": "role " + role + " required",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func writeUnauthorized(w http.ResponseWriter, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusUnauthorized)
@@ -0,0 +1,57 @@
+package biz
+
+import (
+	"encoding/json"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// AnnotationsExtraKey 消息 Extra 中存放注释的键。
+// Responses API 的 url_citation 注释（web search 引用）通过该键透传。
+const AnnotationsExtraKey = "annotations"
+
+// Annotation 网页搜索/引用注释
+type Annotation struct {
+	Type       string `json:"type"`
+	URL        string `json:"url,omitempty"`
+	Title      string `json:"title,omitempty"`
+	StartIndex int    `json:"start_index,omitempty"`
+	EndIndex   int    `json:"end_index,omitempty"`
+}
+
+// AnnotationsFromMessage extracts citation annotations stored in msg.Extra.
+// It accepts both typed []Annotation values and raw JSON-decoded forms
+// ([]any of maps), since Extra round-trips through JSON persistence.
+func AnnotationsFromMessage(msg *schema.Message) []Annotation {
+	if msg == nil || msg.Extra == nil {
+		return nil
+	}
+	raw, ok := msg.Extra[AnnotationsExtraKey]
+	if !ok {
+		return nil
+	}
+	if typed, ok := raw.([]Annotation); ok {
+		return typed
+	}
+	// JSON 往返后 Extra 里是 []any/map[string]any，走序列化转换
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var annotations []Annotation
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil
+	}
+	return annotations
+}
+
+// setMessageAnnotations stores annotations into msg.Extra.
+func setMessageAnnotations(msg *schema.Message, annotations []Annotation) {
+	if len(annotations) == 0 {
+		return
+	}
+	if msg.Extra == nil {
+		msg.Extra = make(map[string]any)
+	}
+	msg.Extra[AnnotationsExtraKey] = annotations
+}
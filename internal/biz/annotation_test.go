@@ -0,0 +1,81 @@
+package biz
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestAnnotationsFromMessage_TypedAndJSONForms(t *testing.T) {
+	want := []Annotation{
+		{Type: "url_citation", URL: "https://example.com", Title: "Example", StartIndex: 3, EndIndex: 10},
+	}
+
+	typed := &schema.Message{
+		Role:  schema.Assistant,
+		Extra: map[string]any{AnnotationsExtraKey: want},
+	}
+	if got := AnnotationsFromMessage(typed); !reflect.DeepEqual(got, want) {
+		t.Fatalf("typed form: got %#v, want %#v", got, want)
+	}
+
+	// JSON 持久化往返后 Extra 里是 []any/map[string]any
+	decoded := &schema.Message{
+		Role: schema.Assistant,
+		Extra: map[string]any{
+			AnnotationsExtraKey: []any{
+				map[string]any{
+					"type":        "url_citation",
+					"url":         "https://example.com",
+					"title":       "Example",
+					"start_index": float64(3),
+					"end_index":   float64(10),
+				},
+			},
+		},
+	}
+	if got := AnnotationsFromMessage(decoded); !reflect.DeepEqual(got, want) {
+		t.Fatalf("decoded form: got %#v, want %#v", got, want)
+	}
+
+	if got := AnnotationsFromMessage(&schema.Message{Role: schema.Assistant}); got != nil {
+		t.Fatalf("expected nil for message without annotations, got %#v", got)
+	}
+}
+
+func TestConsumeStream_ForwardsAnnotations(t *testing.T) {
+	want := []Annotation{{Type: "url_citation", URL: "https://example.com"}}
+	chunkMsg := &schema.Message{
+		Role:    schema.Assistant,
+		Content: "cited",
+		Extra:   map[string]any{AnnotationsExtraKey: want},
+	}
+	stream := schema.StreamReaderFromArray([]*schema.Message{chunkMsg})
+
+	var (
+		fullContent, fullReasoning strings.Builder
+		multiContent               []schema.MessageOutputPart
+		toolCalls                  []schema.ToolCall
+		annotations                []Annotation
+		reasoningItems             []ReasoningItem
+		finishReason               string
+		usage                      schema.TokenUsage
+		usageSeen                  bool
+		received                   []Annotation
+	)
+	err := consumeStream(stream, &fullContent, &fullReasoning, &multiContent, &toolCalls, &annotations, &reasoningItems, &finishReason, &usage, &usageSeen, func(sc StreamChunk) error {
+		received = append(received, sc.Annotations...)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("consumeStream returned error: %v", err)
+	}
+	if !reflect.DeepEqual(received, want) {
+		t.Fatalf("callback annotations: got %#v, want %#v", received, want)
+	}
+	if !reflect.DeepEqual(annotations, want) {
+		t.Fatalf("accumulated annotations: got %#v, want %#v", annotations, want)
+	}
+}
@@ -0,0 +1,103 @@
+package biz
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"devops-backend/internal/auth"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// AuditEntry 一次对话的审计记录。
+// Prompt/Response 与对应的 Hash 二选一：启用全文记录时写全文，否则只写 sha256。
+type AuditEntry struct {
+	Time             time.Time `json:"time"`
+	UserSub          string    `json:"user_sub,omitempty"`
+	Model            string    `json:"model"`
+	Client           string    `json:"client,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	TotalTokens      int       `json:"total_tokens,omitempty"`
+	Prompt           string    `json:"prompt,omitempty"`
+	Response         string    `json:"response,omitempty"`
+	PromptHash       string    `json:"prompt_hash,omitempty"`
+	ResponseHash     string    `json:"response_hash,omitempty"`
+}
+
+// AuditLogger 审计日志接收器。实现不得阻塞请求路径。
+type AuditLogger interface {
+	Log(entry AuditEntry)
+}
+
+// NopAuditLogger 审计未启用时的空实现。
+type NopAuditLogger struct{}
+
+// Log implements AuditLogger.
+func (NopAuditLogger) Log(AuditEntry) {}
+
+// clientNameResolver provider 可选实现：报告模型会被路由到哪个 client（仅审计用）。
+type clientNameResolver interface {
+	ClientNameFor(modelName string) string
+}
+
+// SetAuditLogger wires an audit sink into the usecase. fullText controls
+// whether prompts/responses are recorded verbatim or only as sha256 hashes.
+func (uc *ChatUsecase) SetAuditLogger(l AuditLogger, fullText bool) {
+	uc.audit = l
+	uc.auditFullText = fullText
+}
+
+// logAudit records one completed chat round. Best-effort: missing user or
+// usage info just leaves the fields empty.
+func (uc *ChatUsecase) logAudit(ctx context.Context, modelName string, prompt []*schema.Message, response *schema.Message) {
+	if uc.audit == nil {
+		return
+	}
+
+	entry := AuditEntry{Time: time.Now(), Model: modelName}
+	if user, err := auth.GetUserFromContext(ctx); err == nil {
+		entry.UserSub = user.Sub
+	}
+	if resolver, ok := uc.provider.(clientNameResolver); ok {
+		entry.Client = resolver.ClientNameFor(modelName)
+	}
+	if response.ResponseMeta != nil && response.ResponseMeta.Usage != nil {
+		entry.PromptTokens = response.ResponseMeta.Usage.PromptTokens
+		entry.CompletionTokens = response.ResponseMeta.Usage.CompletionTokens
+		entry.TotalTokens = response.ResponseMeta.Usage.TotalTokens
+	}
+
+	promptText := concatPromptText(prompt)
+	if uc.auditFullText {
+		entry.Prompt = promptText
+		entry.Response = response.Content
+	} else {
+		entry.PromptHash = hashAuditText(promptText)
+		entry.ResponseHash = hashAuditText(response.Content)
+	}
+
+	uc.audit.Log(entry)
+}
+
+func concatPromptText(messages []*schema.Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		sb.WriteString(string(msg.Role))
+		sb.WriteString(": ")
+		sb.WriteString(msg.Content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func hashAuditText(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
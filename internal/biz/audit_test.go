@@ -0,0 +1,75 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+type captureAuditLogger struct {
+	entries []AuditEntry
+}
+
+func (l *captureAuditLogger) Log(entry AuditEntry) {
+	l.entries = append(l.entries, entry)
+}
+
+func TestLogAudit_HashesByDefault(t *testing.T) {
+	capture := &captureAuditLogger{}
+	uc := &ChatUsecase{}
+	uc.SetAuditLogger(capture, false)
+
+	prompt := []*schema.Message{{Role: schema.User, Content: "hello"}}
+	response := &schema.Message{
+		Role:    schema.Assistant,
+		Content: "hi there",
+		ResponseMeta: &schema.ResponseMeta{
+			Usage: &schema.TokenUsage{PromptTokens: 3, CompletionTokens: 5, TotalTokens: 8},
+		},
+	}
+	uc.logAudit(context.Background(), "gpt-4o-mini", prompt, response)
+
+	if len(capture.entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(capture.entries))
+	}
+	entry := capture.entries[0]
+	if entry.Model != "gpt-4o-mini" {
+		t.Fatalf("unexpected model: %q", entry.Model)
+	}
+	if entry.Prompt != "" || entry.Response != "" {
+		t.Fatalf("full text must not be recorded by default: %+v", entry)
+	}
+	if entry.PromptHash == "" || entry.ResponseHash == "" {
+		t.Fatalf("expected hashes, got %+v", entry)
+	}
+	if entry.TotalTokens != 8 || entry.PromptTokens != 3 || entry.CompletionTokens != 5 {
+		t.Fatalf("unexpected token usage: %+v", entry)
+	}
+}
+
+func TestLogAudit_FullTextWhenEnabled(t *testing.T) {
+	capture := &captureAuditLogger{}
+	uc := &ChatUsecase{}
+	uc.SetAuditLogger(capture, true)
+
+	prompt := []*schema.Message{{Role: schema.User, Content: "hello"}}
+	uc.logAudit(context.Background(), "gpt-4o-mini", prompt, &schema.Message{Role: schema.Assistant, Content: "hi"})
+
+	entry := capture.entries[0]
+	if entry.Response != "hi" {
+		t.Fatalf("expected full response text, got %+v", entry)
+	}
+	if entry.PromptHash != "" || entry.ResponseHash != "" {
+		t.Fatalf("hashes must be empty in full-text mode: %+v", entry)
+	}
+}
+
+func TestLogAudit_NopWhenDisabled(t *testing.T) {
+	// 未配置审计时 logAudit 直接返回，不 panic
+	uc := &ChatUsecase{}
+	uc.logAudit(context.Background(), "gpt-4o-mini", nil, &schema.Message{Role: schema.Assistant})
+
+	// NopAuditLogger 同样可安全调用
+	NopAuditLogger{}.Log(AuditEntry{Model: "gpt-4o-mini"})
+}
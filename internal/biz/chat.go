@@ -2,44 +2,152 @@ package biz
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"path"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"devops-backend/internal/conf"
 
 	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
 )
 
 // ChatUsecase handles AI chat execution (agent creation, inference, streaming).
 type ChatUsecase struct {
-	provider     ChatModelProvider
-	defaultModel string
+	provider           ChatModelProvider
+	defaultModel       string
+	allowedModels      []string       // 空表示不限制
+	blockedModels      []string       // 优先于 allowedModels
+	firstTokenTimeout  time.Duration  // 0 表示不限制
+	generateTimeout    time.Duration  // 0 表示不限制
+	streamStallTimeout time.Duration  // 流中 chunk 间停更看门狗，0 表示关闭
+	audit              AuditLogger    // nil 表示不记录审计
+	auditFullText      bool           // 审计记录全文而非哈希
+	upstreamSlots      chan struct{}  // 上游并发信号量，nil 表示不限制
+	slotWaitTimeout    time.Duration  // 信号量满载时的排队超时，0 表示不排队直接失败
+	dedupWindow        time.Duration  // 重复提交保护窗口，0 表示关闭
+	dedupBehavior      string         // 重复提交的处理方式：reject / replay
+	persistEmpty       bool           // 空的助手回复是否仍写入会话历史
+	titleModel         string         // 标题生成模型，空表示关闭自动标题生成
+	respCache          *responseCache // 确定性请求的响应缓存，nil 表示关闭
+	allowExtraParams   bool           // 是否放行请求级厂商自定义参数（extra）透传
+	allowUserKeys      bool           // 是否放行请求级上游凭证覆盖（BYO key）
+
+	tools             []tool.BaseTool // agent 可调用的工具，空表示纯对话
+	maxToolIterations int             // agentic 循环的生成轮数上限，<=0 用默认值
+	toolTimeout       time.Duration   // 单次工具执行的硬超时，0 表示不限制
+	contextRetries    int             // 上下文超限后截断重试的次数上限，<=0 用默认值
+
+	quotaStore  QuotaStore // nil 表示不启用 token 配额
+	quotaLimit  int64      // 全局人均配额，0 表示仅用户级覆盖生效
+	quotaWindow string     // daily / monthly，空值按 monthly
+
+	redactor Redactor // 出站消息脱敏器，nil 表示关闭（落库侧脱敏见 SessionUsecase）
+
+	modelLimits ModelLimits // 模型 token 上限来源，nil 表示不裁剪历史、不夹参数
+
+	genMu             sync.Mutex
+	activeGenerations map[string]generationEntry // sessionID → 进行中生成的取消函数与元数据
+	genSeq            uint64                     // 生成登记的单调序号，release 用它识别自己的条目
+
+	modelMu sync.RWMutex // 保护 defaultModel/clientDefaults（配置热重载时更新）
+	// clientDefaults client 名 → 该 client 的默认模型（conf.Client.DefaultModel）
+	clientDefaults map[string]string
+}
+
+// ClientDefaultModels collects the per-client default models from the config.
+func ClientDefaultModels(clients map[string]conf.Client) map[string]string {
+	defaults := make(map[string]string)
+	for name, c := range clients {
+		if c.DefaultModel != "" {
+			defaults[name] = c.DefaultModel
+		}
+	}
+	return defaults
 }
 
 // NewChatUsecase creates a ChatUsecase.
 func NewChatUsecase(provider ChatModelProvider, cfg conf.Eino) *ChatUsecase {
-	return &ChatUsecase{
-		provider:     provider,
-		defaultModel: cfg.DefaultModel,
+	uc := &ChatUsecase{
+		provider:           provider,
+		defaultModel:       cfg.DefaultModel,
+		allowedModels:      cfg.AllowedModels,
+		blockedModels:      cfg.BlockedModels,
+		firstTokenTimeout:  time.Duration(cfg.FirstTokenTimeoutSeconds) * time.Second,
+		generateTimeout:    time.Duration(cfg.GenerateTimeoutSeconds) * time.Second,
+		streamStallTimeout: time.Duration(cfg.StreamStallTimeoutSeconds) * time.Second,
+		dedupWindow:        time.Duration(cfg.DedupWindowSeconds) * time.Second,
+		dedupBehavior:      cfg.DedupBehavior,
+		persistEmpty:       cfg.PersistEmptyResponses,
+		titleModel:         cfg.TitleModel,
+		allowExtraParams:   cfg.AllowExtraParams,
+		allowUserKeys:      cfg.AllowUserKeys,
+		maxToolIterations:  cfg.MaxToolIterations,
+		toolTimeout:        time.Duration(cfg.ToolTimeoutSeconds) * time.Second,
+		contextRetries:     cfg.ContextRetryAttempts,
+		clientDefaults:     ClientDefaultModels(cfg.Clients),
+	}
+	if cfg.MaxConcurrentRequests > 0 {
+		uc.upstreamSlots = make(chan struct{}, cfg.MaxConcurrentRequests)
+		uc.slotWaitTimeout = time.Duration(cfg.ConcurrencyWaitSeconds) * time.Second
+	}
+	if cfg.ResponseCacheSize > 0 {
+		uc.respCache = newResponseCache(cfg.ResponseCacheSize)
 	}
+	return uc
 }
 
-// createAgent builds a ChatModelAgent for the given model name.
-func (uc *ChatUsecase) createAgent(ctx context.Context, modelName string) (*adk.ChatModelAgent, error) {
-	chatModel, err := uc.provider.CreateChatModel(ctx, modelName)
+// chatAgentInstruction 对话 agent 的系统提示词，token 估算时也按此计入
+const chatAgentInstruction = "你是一个友好的AI助手，请用简洁明了的方式回答用户的问题。"
+
+// createAgent builds a ChatModelAgent for the given model name. A non-empty
+// instruction overrides the default system prompt (conversation-level custom
+// instructions arrive this way).
+func (uc *ChatUsecase) createAgent(ctx context.Context, modelName string, cred ClientCredentials, instruction string) (*adk.ChatModelAgent, error) {
+	chatModel, err := uc.createChatModel(ctx, modelName, cred)
 	if err != nil {
 		return nil, err
 	}
-	return adk.NewChatModelAgent(ctx, &adk.ChatModelAgentConfig{
+	maxIter := uc.maxToolIterations
+	if maxIter <= 0 {
+		maxIter = defaultMaxToolIterations
+	}
+	if instruction == "" {
+		instruction = chatAgentInstruction
+	}
+	agentCfg := &adk.ChatModelAgentConfig{
 		Name:        "chat_assistant",
 		Description: "友好的AI聊天助手",
-		Instruction: "你是一个友好的AI助手，请用简洁明了的方式回答用户的问题。",
+		Instruction: instruction,
 		Model:       chatModel,
-	})
+		// 生成轮数上限，防止模型无限请求工具；超限由调用方映射为 ErrToolLoopExceeded
+		MaxIterations: maxIter,
+	}
+	if tools := uc.agentTools(); len(tools) > 0 {
+		agentCfg.ToolsConfig = adk.ToolsConfig{ToolsNodeConfig: compose.ToolsNodeConfig{Tools: tools}}
+	}
+	return adk.NewChatModelAgent(ctx, agentCfg)
+}
+
+// createChatModel 构造路由到的 ChatModel；携带 BYO 凭证时构造一次性 client，
+// 不影响配置的共享 client
+func (uc *ChatUsecase) createChatModel(ctx context.Context, modelName string, cred ClientCredentials) (model.ToolCallingChatModel, error) {
+	if !cred.empty() {
+		if overrider, ok := uc.provider.(CredentialOverrider); ok {
+			return overrider.CreateChatModelWithCredentials(ctx, modelName, cred)
+		}
+	}
+	return uc.provider.CreateChatModel(ctx, modelName)
 }
 
 // ChatRequest 聊天请求
@@ -48,12 +156,47 @@ type ChatRequest struct {
 	Model    string `json:"model,omitempty"`
 	ThreadID string `json:"thread_id,omitempty"`
 	Thinking *bool  `json:"thinking,omitempty"`
+	// ThinkingBudget 思考 token 预算（Gemini thinking budget / Claude budget_tokens）
+	ThinkingBudget *int `json:"thinking_budget,omitempty"`
+	// ReasoningSummary 推理摘要模式（auto/concise/detailed），仅 OpenAI Responses API 模型生效
+	ReasoningSummary string `json:"reasoning_summary,omitempty"`
+	// Seed 固定采样种子，支持的厂商可获得可复现的输出
+	Seed *int `json:"seed,omitempty"`
+	// NoCache 本次请求绕过响应缓存
+	NoCache bool `json:"no_cache,omitempty"`
+	// Store Responses API 是否允许服务端保留本次请求数据，nil 跟随 client 配置
+	Store *bool `json:"store,omitempty"`
+	// Extra 厂商自定义参数，原样合入出站请求体（需 eino.allow_extra_params 开启）
+	Extra map[string]any `json:"extra,omitempty"`
+	// APIKey / BaseURL 单次请求的上游凭证覆盖（BYO key，需 eino.allow_user_keys
+	// 开启）；绝不序列化、不写日志、不落库
+	APIKey  string `json:"-"`
+	BaseURL string `json:"-"`
+}
+
+// Params collects the per-request model knobs from the request.
+func (req *ChatRequest) Params() *RequestParams {
+	return &RequestParams{
+		Thinking:         req.Thinking,
+		ThinkingBudget:   req.ThinkingBudget,
+		ReasoningSummary: req.ReasoningSummary,
+		Seed:             req.Seed,
+		NoCache:          req.NoCache,
+		Store:            req.Store,
+		Extra:            req.Extra,
+		APIKey:           req.APIKey,
+		BaseURL:          req.BaseURL,
+	}
 }
 
 // ChatResponse 聊天响应
 type ChatResponse struct {
 	schema.Message
+	ID    int64  `json:"id,omitempty"` // 消息 ID，前端用于分支/编辑/删除定位
 	Model string `json:"model,omitempty"`
+	// Partial 流被中断（取消/断连）时的部分保存标记，前端可展示"未完成"并提供续写
+	Partial   bool      `json:"partial,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
 }
 
 // BuildUserMessage constructs a schema.Message from the request.
@@ -76,33 +219,310 @@ func BuildUserMessage(req *ChatRequest) *schema.Message {
 // resolveModel returns the requested model or falls back to the default.
 func (uc *ChatUsecase) resolveModel(reqModel string) string {
 	if reqModel == "" {
+		uc.modelMu.RLock()
+		defer uc.modelMu.RUnlock()
 		return uc.defaultModel
 	}
 	return reqModel
 }
 
-// Chat executes a non-streaming chat. It returns the assistant response and the actual model name.
-func (uc *ChatUsecase) Chat(
+// SetDefaultModel swaps the fallback model at runtime (config hot-reload).
+func (uc *ChatUsecase) SetDefaultModel(modelName string) {
+	uc.modelMu.Lock()
+	defer uc.modelMu.Unlock()
+	uc.defaultModel = modelName
+}
+
+// SetClientDefaultModels swaps the per-client default models at runtime
+// (config hot-reload).
+func (uc *ChatUsecase) SetClientDefaultModels(defaults map[string]string) {
+	uc.modelMu.Lock()
+	defer uc.modelMu.Unlock()
+	uc.clientDefaults = defaults
+}
+
+// ClientDefaultModel returns the configured default model for the named
+// client, or "" when the client has none (callers fall back to the global
+// default).
+func (uc *ChatUsecase) ClientDefaultModel(client string) string {
+	uc.modelMu.RLock()
+	defer uc.modelMu.RUnlock()
+	return uc.clientDefaults[client]
+}
+
+// UploadFile uploads a file to the provider's files endpoint and returns its
+// file_id, which later requests can reference as a file input.
+func (uc *ChatUsecase) UploadFile(ctx context.Context, clientName, filename string, data []byte, purpose string) (string, error) {
+	uploader, ok := uc.provider.(FileUploader)
+	if !ok {
+		return "", fmt.Errorf("provider does not support file uploads")
+	}
+	return uploader.UploadFile(ctx, clientName, filename, data, purpose)
+}
+
+// ExplainClientResolution returns the routing trace for the model a request
+// would use, or nil when the provider cannot explain its routing.
+func (uc *ChatUsecase) ExplainClientResolution(reqModel string) *ClientResolution {
+	resolver, ok := uc.provider.(ClientResolver)
+	if !ok {
+		return nil
+	}
+	res := resolver.ResolveClient(uc.resolveModel(reqModel))
+	return &res
+}
+
+// thinkingSupporter 适配器可选实现：上报模型是否真正支持 thinking 开关。
+type thinkingSupporter interface {
+	SupportsThinking() bool
+}
+
+// AppliedThinking reports whether the thinking flag will actually take effect
+// for the given model, so the UI can reflect a toggle that does nothing.
+func (uc *ChatUsecase) AppliedThinking(ctx context.Context, reqModel string, thinking *bool) bool {
+	if thinking == nil || !*thinking {
+		return false
+	}
+	chatModel, err := uc.provider.CreateChatModel(ctx, uc.resolveModel(reqModel))
+	if err != nil {
+		return false
+	}
+	supporter, ok := chatModel.(thinkingSupporter)
+	return ok && supporter.SupportsThinking()
+}
+
+// ErrModelNotAllowed 请求的模型被运维策略禁用。
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// checkModelAllowed enforces the configured allow/block lists. The block list
+// wins over the allow list; an empty allow list permits everything not blocked.
+func (uc *ChatUsecase) checkModelAllowed(modelName string) error {
+	for _, pattern := range uc.blockedModels {
+		if matchesModelPattern(pattern, modelName) {
+			return fmt.Errorf("%w: %s", ErrModelNotAllowed, modelName)
+		}
+	}
+	if len(uc.allowedModels) == 0 {
+		return nil
+	}
+	for _, pattern := range uc.allowedModels {
+		if matchesModelPattern(pattern, modelName) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrModelNotAllowed, modelName)
+}
+
+// sanitizeParams strips the raw extra-params passthrough and user-supplied
+// credentials unless the deployment has opted in via eino.allow_extra_params /
+// eino.allow_user_keys; everything else passes unchanged.
+func (uc *ChatUsecase) sanitizeParams(params *RequestParams) *RequestParams {
+	if params == nil {
+		return nil
+	}
+	stripExtra := !uc.allowExtraParams && len(params.Extra) > 0
+	stripCreds := !uc.allowUserKeys && (params.APIKey != "" || params.BaseURL != "")
+	if !stripExtra && !stripCreds {
+		return params
+	}
+	cloned := *params
+	if stripExtra {
+		cloned.Extra = nil
+	}
+	if stripCreds {
+		cloned.APIKey, cloned.BaseURL = "", ""
+	}
+	return &cloned
+}
+
+// takeCredentials 取出并清除参数中的单次请求凭证（BYO key）：凭证只参与
+// client 构造，不得进入出站请求参数、缓存键或日志
+func takeCredentials(params *RequestParams) (ClientCredentials, *RequestParams) {
+	if params == nil || (params.APIKey == "" && params.BaseURL == "") {
+		return ClientCredentials{}, params
+	}
+	cred := ClientCredentials{APIKey: params.APIKey, BaseURL: params.BaseURL}
+	cloned := *params
+	cloned.APIKey, cloned.BaseURL = "", ""
+	return cred, &cloned
+}
+
+// matchesModelPattern 模式匹配，支持 * 通配（如 gpt-4*）；非法模式视为不匹配。
+func matchesModelPattern(pattern, modelName string) bool {
+	ok, err := path.Match(pattern, modelName)
+	return err == nil && ok
+}
+
+// ErrUpstreamSaturated 上游并发已达上限且排队超时。
+var ErrUpstreamSaturated = errors.New("upstream concurrency limit reached")
+
+// ErrDuplicateMessage 短窗口内重复提交了完全相同的用户消息。
+var ErrDuplicateMessage = errors.New("duplicate message: identical user message was just submitted")
+
+// ErrEmptyResponse 模型没有返回任何可用输出（如安全拒答返回空内容）。
+var ErrEmptyResponse = errors.New("model returned no output")
+
+// IsEmptyAssistantMessage reports whether the assistant produced no usable
+// output: no content, reasoning, tool calls, or generated media.
+func IsEmptyAssistantMessage(msg *schema.Message) bool {
+	if msg == nil {
+		return true
+	}
+	return msg.Content == "" && msg.ReasoningContent == "" &&
+		len(msg.ToolCalls) == 0 && len(msg.AssistantGenMultiContent) == 0
+}
+
+// DedupBehaviorReject / DedupBehaviorReplay 重复提交的处理方式
+const (
+	DedupBehaviorReject = "reject"
+	DedupBehaviorReplay = "replay"
+)
+
+// titleSystemPrompt 标题生成模型的系统提示词
+const titleSystemPrompt = "根据对话内容生成一个不超过 20 个字的简短标题，直接输出标题本身，不要加引号或解释。"
+
+// titleExcerptRunes 送给标题模型的单条消息截断长度，控制生成成本
+const titleExcerptRunes = 500
+
+// TitleGenerationEnabled reports whether a title-generation model is configured.
+func (uc *ChatUsecase) TitleGenerationEnabled() bool { return uc.titleModel != "" }
+
+// GenerateTitle asks the configured title model to summarize the first
+// exchange into a short conversation title. It returns an empty string when
+// title generation is disabled.
+func (uc *ChatUsecase) GenerateTitle(ctx context.Context, userText, assistantText string) (string, error) {
+	if uc.titleModel == "" {
+		return "", nil
+	}
+	chatModel, err := uc.provider.CreateChatModel(ctx, uc.titleModel)
+	if err != nil {
+		return "", wrapError("create title model", err)
+	}
+	resp, err := chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(titleSystemPrompt),
+		schema.UserMessage(fmt.Sprintf("用户：%s\n助手：%s",
+			truncateRunes(userText, titleExcerptRunes),
+			truncateRunes(assistantText, titleExcerptRunes))),
+	})
+	if err != nil {
+		return "", wrapError("generate title", err)
+	}
+	return sanitizeTitle(resp.Content), nil
+}
+
+// truncateRunes 按 rune 截断文本
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max])
+}
+
+// sanitizeTitle 清理标题模型输出：去首尾引号、只取首行、限制长度
+func sanitizeTitle(raw string) string {
+	title := strings.TrimSpace(raw)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.Trim(title, "\"'“”‘’「」《》")
+	return strings.TrimSpace(truncateRunes(title, 30))
+}
+
+// PersistEmptyResponses reports whether blank assistant turns should still be
+// saved to the session history.
+func (uc *ChatUsecase) PersistEmptyResponses() bool { return uc.persistEmpty }
+
+// DedupPolicy returns the duplicate-submit guard window and behavior.
+// A zero window disables the guard; behavior defaults to reject.
+func (uc *ChatUsecase) DedupPolicy() (time.Duration, string) {
+	behavior := uc.dedupBehavior
+	if behavior == "" {
+		behavior = DedupBehaviorReject
+	}
+	return uc.dedupWindow, behavior
+}
+
+// acquireUpstreamSlot takes a slot from the concurrency semaphore, queueing up
+// to slotWaitTimeout when saturated. The returned release func must be called
+// when the upstream call finishes. With no limit configured it is a no-op.
+func (uc *ChatUsecase) acquireUpstreamSlot(ctx context.Context) (func(), error) {
+	if uc.upstreamSlots == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-uc.upstreamSlots }
+
+	select {
+	case uc.upstreamSlots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	if uc.slotWaitTimeout <= 0 {
+		return nil, ErrUpstreamSaturated
+	}
+
+	timer := time.NewTimer(uc.slotWaitTimeout)
+	defer timer.Stop()
+	select {
+	case uc.upstreamSlots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, ErrUpstreamSaturated
+	}
+}
+
+// chatOnce 单次非流式对话尝试；上下文超限重试由 Chat 包装
+func (uc *ChatUsecase) chatOnce(
 	ctx context.Context,
 	messages []*schema.Message,
 	reqModel string,
-	thinking *bool,
+	params *RequestParams,
 ) (*schema.Message, string, error) {
 	modelName := uc.resolveModel(reqModel)
+	if err := uc.checkModelAllowed(modelName); err != nil {
+		return nil, "", err
+	}
+	if err := uc.checkTokenQuota(ctx); err != nil {
+		return nil, "", err
+	}
+	messages = uc.redactOutbound(messages)
+	messages = uc.trimToContextBudget(messages, modelName)
+	params = uc.capParamsToModelLimits(uc.sanitizeParams(params), modelName)
+	cred, params := takeCredentials(params)
+
+	// 确定性请求（带固定 seed）命中缓存时不再调用上游；
+	// 携带 BYO 凭证的请求不读写共享缓存，避免跨凭证串答
+	var cacheKey string
+	if cred.empty() && uc.cacheableRequest(params) {
+		cacheKey = responseCacheKey(modelName, messages, params)
+		if cached, cachedModel, ok := uc.respCache.get(cacheKey); ok {
+			return cached, cachedModel, nil
+		}
+	}
+
+	release, err := uc.acquireUpstreamSlot(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
 	preparedMessages := prepareMessagesForModel(messages)
+	// 对话级自定义指令：历史最前面的 system 消息覆盖默认 agent 指令
+	preparedMessages, instruction := takeSystemInstruction(preparedMessages)
 
-	agent, err := uc.createAgent(ctx, modelName)
+	agent, err := uc.createAgent(ctx, modelName, cred, instruction)
 	if err != nil {
 		return nil, "", wrapError("create agent", err)
 	}
 
-	thinkingOpts := WithParams(&RequestParams{
-		Thinking: thinking,
-	})
 	iter := agent.Run(ctx, &adk.AgentInput{
 		Messages:        preparedMessages,
 		EnableStreaming: false,
-	}, adk.WithChatModelOptions([]model.Option{thinkingOpts}))
+	}, adk.WithChatModelOptions([]model.Option{WithParams(params)}))
 
 	var result *schema.Message
 	for {
@@ -111,6 +531,9 @@ func (uc *ChatUsecase) Chat(
 			break
 		}
 		if event.Err != nil {
+			if errors.Is(event.Err, adk.ErrExceedMaxIterations) {
+				return nil, "", ErrToolLoopExceeded
+			}
 			return nil, "", wrapError("agent run", event.Err)
 		}
 		if event.Output != nil && event.Output.MessageOutput != nil {
@@ -128,6 +551,11 @@ func (uc *ChatUsecase) Chat(
 		return nil, "", wrapError("agent run", fmt.Errorf("no response from agent"))
 	}
 
+	uc.logAudit(ctx, modelName, preparedMessages, result)
+	uc.recordTokenUsage(ctx, result)
+	if cacheKey != "" {
+		uc.respCache.put(cacheKey, result, modelName)
+	}
 	return result, modelName, nil
 }
 
@@ -137,40 +565,229 @@ type StreamChunk struct {
 	ReasoningContent         string                     `json:"reasoning_content,omitempty"`
 	AssistantGenMultiContent []schema.MessageOutputPart `json:"assistant_gen_multi_content,omitempty"`
 	ToolCalls                []schema.ToolCall          `json:"tool_calls,omitempty"`
+	Annotations              []Annotation               `json:"annotations,omitempty"`
+	// FinishReason 上游结束原因（如 stop/length/incomplete），仅在流结束 chunk 上出现
+	FinishReason string `json:"finish_reason,omitempty"`
+	// Usage 截至当前的累计 token 用量（仅在上游上报 usage 的 chunk 上出现）
+	Usage *schema.TokenUsage `json:"usage,omitempty"`
+	// ReasoningDone 推理→正文边界：本 chunk 是推理之后的首个正文 delta
+	ReasoningDone bool `json:"reasoning_done,omitempty"`
+	// ToolName/ToolCallID/ToolOutput 工具执行结果事件（agentic 循环里每个工具跑完下发一次）
+	ToolName   string `json:"tool_name,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	ToolOutput string `json:"tool_output,omitempty"`
+	// HistoryTruncated 上下文超限重试事件：最旧的部分历史已被丢弃，
+	// 本 chunk 仅携带该标记提示前端
+	HistoryTruncated bool `json:"history_truncated,omitempty"`
+}
+
+// accumulateUsage 合并流式 usage 到累计值。大多数 provider 上报的是累计快照，
+// 少数上报增量；总量变小时视为增量并累加。返回是否有新的 usage 数据。
+func accumulateUsage(total *schema.TokenUsage, seen *bool, incoming *schema.TokenUsage) bool {
+	if incoming == nil {
+		return false
+	}
+	if !*seen || incoming.TotalTokens >= total.TotalTokens {
+		*total = *incoming
+	} else {
+		total.PromptTokens += incoming.PromptTokens
+		total.CompletionTokens += incoming.CompletionTokens
+		total.TotalTokens += incoming.TotalTokens
+	}
+	*seen = true
+	return true
+}
+
+// IsTruncatedFinishReason 判断结束原因是否表示输出被截断
+// （Chat Completions 的 length / Responses API 的 incomplete·max_output_tokens）。
+func IsTruncatedFinishReason(reason string) bool {
+	switch strings.ToLower(strings.TrimSpace(reason)) {
+	case "length", "incomplete", "max_output_tokens", "max_tokens":
+		return true
+	}
+	return false
+}
+
+// ErrNotTruncated 最后一条消息不是被截断的助手回复，无法续写。
+var ErrNotTruncated = errors.New("last message is not a truncated assistant response")
+
+// MergeContinuation 把续写结果合并到被截断的助手消息上。
+// 结束原因以续写的为准（续写仍可能再次被截断）。
+func MergeContinuation(base, continuation *schema.Message) *schema.Message {
+	merged := *base
+	merged.Content += continuation.Content
+	if continuation.ReasoningContent != "" {
+		if merged.ReasoningContent != "" {
+			merged.ReasoningContent += "\n\n"
+		}
+		merged.ReasoningContent += continuation.ReasoningContent
+	}
+	if len(continuation.AssistantGenMultiContent) > 0 {
+		merged.AssistantGenMultiContent = append(
+			append([]schema.MessageOutputPart(nil), base.AssistantGenMultiContent...),
+			continuation.AssistantGenMultiContent...,
+		)
+	}
+	if len(continuation.ToolCalls) > 0 {
+		merged.ToolCalls = mergeToolCalls(base.ToolCalls, continuation.ToolCalls)
+	}
+	merged.ResponseMeta = continuation.ResponseMeta
+	return &merged
 }
 
 // StreamChunkCallback 流数据回调
 type StreamChunkCallback func(chunk StreamChunk) error
 
-// ChatStream executes a streaming chat. It returns the complete assistant response and the actual model name.
-func (uc *ChatUsecase) ChatStream(
+// ErrFirstTokenTimeout 在配置的时间内没有等到首个 token。
+var ErrFirstTokenTimeout = errors.New("timed out waiting for first token")
+
+// ErrGenerateTimeout 单次生成超过了整体超时。
+var ErrGenerateTimeout = errors.New("generation deadline exceeded")
+
+// ErrStreamStalled 流中断更：已有输出后超过 stall 窗口没有等到下一个 chunk。
+// 与首 token 超时、整体超时不同，它针对的是上游中途停更；
+// 已累计的部分内容随错误一起返回，调用方标记 partial 入库。
+var ErrStreamStalled = errors.New("stream stalled: no chunk received within stall window")
+
+// chatStreamOnce 单次流式对话尝试；上下文超限重试由 ChatStream 包装
+func (uc *ChatUsecase) chatStreamOnce(
 	ctx context.Context,
 	messages []*schema.Message,
 	reqModel string,
-	thinking *bool,
+	params *RequestParams,
 	onChunk StreamChunkCallback,
 ) (*schema.Message, string, error) {
 	modelName := uc.resolveModel(reqModel)
+	if err := uc.checkModelAllowed(modelName); err != nil {
+		return nil, "", err
+	}
+	if err := uc.checkTokenQuota(ctx); err != nil {
+		return nil, "", err
+	}
+	messages = uc.redactOutbound(messages)
+	messages = uc.trimToContextBudget(messages, modelName)
+	params = uc.capParamsToModelLimits(uc.sanitizeParams(params), modelName)
+	cred, params := takeCredentials(params)
+
+	// 确定性请求（带固定 seed）命中缓存时直接重放，整条内容作为单个 chunk 下发；
+	// 携带 BYO 凭证的请求不读写共享缓存，避免跨凭证串答
+	var cacheKey string
+	if cred.empty() && uc.cacheableRequest(params) {
+		cacheKey = responseCacheKey(modelName, messages, params)
+		if cached, cachedModel, ok := uc.respCache.get(cacheKey); ok {
+			if err := onChunk(StreamChunk{
+				Content:          cached.Content,
+				ReasoningContent: cached.ReasoningContent,
+				ToolCalls:        cached.ToolCalls,
+				Annotations:      AnnotationsFromMessage(cached),
+			}); err != nil {
+				return nil, "", err
+			}
+			return cached, cachedModel, nil
+		}
+	}
+
+	release, err := uc.acquireUpstreamSlot(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
 	preparedMessages := prepareMessagesForModel(messages)
+	// 对话级自定义指令：历史最前面的 system 消息覆盖默认 agent 指令
+	preparedMessages, instruction := takeSystemInstruction(preparedMessages)
+
+	// 超时控制：cancel 会让底层 stream 的 Recv 返回错误并关闭 HTTP body
+	runCtx, cancelRun := uc.newGenerateContext(ctx)
+	defer cancelRun()
 
-	agent, err := uc.createAgent(ctx, modelName)
+	var gotFirstToken atomic.Bool
+	var firstTokenTimedOut atomic.Bool
+	if uc.firstTokenTimeout > 0 {
+		timer := time.AfterFunc(uc.firstTokenTimeout, func() {
+			if !gotFirstToken.Load() {
+				firstTokenTimedOut.Store(true)
+				cancelRun()
+			}
+		})
+		defer timer.Stop()
+	}
+	// 停更看门狗：首个 chunk 之后，每收到一个 chunk 重置计时；
+	// 超过窗口没有新 chunk 就中止流（首 token 之前由上面的首 token 超时负责）
+	var streamStalled atomic.Bool
+	var stallTimer *time.Timer
+	if uc.streamStallTimeout > 0 {
+		stallTimer = time.AfterFunc(uc.streamStallTimeout, func() {
+			if gotFirstToken.Load() {
+				streamStalled.Store(true)
+				cancelRun()
+			}
+		})
+		defer stallTimer.Stop()
+	}
+	innerOnChunk := onChunk
+	onChunk = func(chunk StreamChunk) error {
+		gotFirstToken.Store(true)
+		if stallTimer != nil {
+			stallTimer.Reset(uc.streamStallTimeout)
+		}
+		return innerOnChunk(chunk)
+	}
+
+	// 推理→正文边界：在推理之后的首个正文 delta 上打标，前端据此收起思考面板
+	var sawReasoning, reasoningDone bool
+	boundaryInner := onChunk
+	onChunk = func(chunk StreamChunk) error {
+		if chunk.ReasoningContent != "" {
+			sawReasoning = true
+		}
+		if chunk.Content != "" && sawReasoning && !reasoningDone {
+			reasoningDone = true
+			chunk.ReasoningDone = true
+		}
+		return boundaryInner(chunk)
+	}
+
+	agent, err := uc.createAgent(runCtx, modelName, cred, instruction)
 	if err != nil {
 		return nil, "", wrapError("create agent", err)
 	}
 
-	thinkingOpts := WithParams(&RequestParams{
-		Thinking: thinking,
-	})
-	iter := agent.Run(ctx, &adk.AgentInput{
+	iter := agent.Run(runCtx, &adk.AgentInput{
 		Messages:        preparedMessages,
 		EnableStreaming: true,
-	}, adk.WithChatModelOptions([]model.Option{thinkingOpts}))
+	}, adk.WithChatModelOptions([]model.Option{WithParams(params)}))
 
 	// 收集完整回复用于保存会话
 	var fullContent strings.Builder
 	var fullReasoning strings.Builder
 	var multiContent []schema.MessageOutputPart
 	var toolCalls []schema.ToolCall
+	var annotations []Annotation
+	var reasoningItems []ReasoningItem
+	var finishReason string
+	var usage schema.TokenUsage
+	var usageSeen bool
+
+	buildAssistant := func() *schema.Message {
+		msg := &schema.Message{
+			Role:                     schema.Assistant,
+			Content:                  fullContent.String(),
+			ReasoningContent:         fullReasoning.String(),
+			AssistantGenMultiContent: multiContent,
+			ToolCalls:                toolCalls,
+		}
+		setMessageAnnotations(msg, annotations)
+		SetMessageReasoningItems(msg, reasoningItems)
+		if finishReason != "" || usageSeen {
+			// 保留结束原因和 token 用量，供截断判断与审计使用
+			msg.ResponseMeta = &schema.ResponseMeta{FinishReason: finishReason}
+			if usageSeen {
+				usageCopy := usage
+				msg.ResponseMeta.Usage = &usageCopy
+			}
+		}
+		return msg
+	}
 
 	for {
 		event, ok := iter.Next()
@@ -178,6 +795,21 @@ func (uc *ChatUsecase) ChatStream(
 			break
 		}
 		if event.Err != nil {
+			if errors.Is(event.Err, adk.ErrExceedMaxIterations) {
+				// 工具循环超限：返回已累计的部分对话，调用方决定是否入库
+				return buildAssistant(), modelName, ErrToolLoopExceeded
+			}
+			if errors.Is(context.Cause(runCtx), ErrGenerationCanceled) {
+				// 用户主动取消：返回已累计的部分内容，调用方负责保存
+				return buildAssistant(), modelName, ErrGenerationCanceled
+			}
+			if streamStalled.Load() {
+				// 上游中途停更：同样返回部分内容，调用方标记 partial 入库
+				return buildAssistant(), modelName, ErrStreamStalled
+			}
+			if terr := timeoutErr(runCtx, &firstTokenTimedOut); terr != nil {
+				return nil, "", wrapError("agent run", terr)
+			}
 			return nil, "", wrapError("agent run", event.Err)
 		}
 		if event.Output == nil || event.Output.MessageOutput == nil {
@@ -186,9 +818,26 @@ func (uc *ChatUsecase) ChatStream(
 
 		mv := event.Output.MessageOutput
 		if mv.IsStreaming {
-			if err := consumeStream(mv.MessageStream, &fullContent, &fullReasoning, &multiContent, &toolCalls, onChunk); err != nil {
+			if err := consumeStream(mv.MessageStream, &fullContent, &fullReasoning, &multiContent, &toolCalls, &annotations, &reasoningItems, &finishReason, &usage, &usageSeen, onChunk); err != nil {
+				if errors.Is(context.Cause(runCtx), ErrGenerationCanceled) {
+					// 用户主动取消：返回已累计的部分内容，调用方负责保存
+					return buildAssistant(), modelName, ErrGenerationCanceled
+				}
+				if streamStalled.Load() {
+					// 上游中途停更：同样返回部分内容，调用方标记 partial 入库
+					return buildAssistant(), modelName, ErrStreamStalled
+				}
+				if terr := timeoutErr(runCtx, &firstTokenTimedOut); terr != nil {
+					return nil, "", wrapError("recv stream", terr)
+				}
 				return nil, "", err
 			}
+		} else if mv.Message != nil && mv.Message.Role == schema.Tool {
+			// 工具执行结果：不并入助手消息，单独下发一个事件
+			chunk := StreamChunk{ToolName: mv.Message.ToolName, ToolCallID: mv.Message.ToolCallID, ToolOutput: mv.Message.Content}
+			if cbErr := onChunk(chunk); cbErr != nil {
+				return nil, "", cbErr
+			}
 		} else if mv.Message != nil {
 			streamChunk := StreamChunk{}
 
@@ -216,7 +865,29 @@ func (uc *ChatUsecase) ChatStream(
 				streamChunk.ToolCalls = mv.Message.ToolCalls
 			}
 
-			if streamChunk.Content != "" || streamChunk.ReasoningContent != "" || len(streamChunk.AssistantGenMultiContent) > 0 || len(streamChunk.ToolCalls) > 0 {
+			// 快照消息中的注释只取新增部分
+			if snapshot := AnnotationsFromMessage(mv.Message); len(snapshot) > len(annotations) {
+				delta := snapshot[len(annotations):]
+				annotations = append(annotations, delta...)
+				streamChunk.Annotations = delta
+			}
+
+			// reasoning 项只累积进最终消息，不随 chunk 下发（加密内容对客户端无意义）
+			if snapshot := ReasoningItemsFromMessage(mv.Message); len(snapshot) > len(reasoningItems) {
+				reasoningItems = append(reasoningItems, snapshot[len(reasoningItems):]...)
+			}
+
+			if mv.Message.ResponseMeta != nil && mv.Message.ResponseMeta.FinishReason != "" {
+				finishReason = mv.Message.ResponseMeta.FinishReason
+				streamChunk.FinishReason = finishReason
+			}
+
+			if mv.Message.ResponseMeta != nil && accumulateUsage(&usage, &usageSeen, mv.Message.ResponseMeta.Usage) {
+				usageCopy := usage
+				streamChunk.Usage = &usageCopy
+			}
+
+			if streamChunk.Content != "" || streamChunk.ReasoningContent != "" || len(streamChunk.AssistantGenMultiContent) > 0 || len(streamChunk.ToolCalls) > 0 || len(streamChunk.Annotations) > 0 || streamChunk.FinishReason != "" || streamChunk.Usage != nil {
 				if cbErr := onChunk(streamChunk); cbErr != nil {
 					return nil, "", cbErr
 				}
@@ -224,30 +895,79 @@ func (uc *ChatUsecase) ChatStream(
 		}
 	}
 
-	assistantMsg := &schema.Message{
-		Role:                     schema.Assistant,
-		Content:                  fullContent.String(),
-		ReasoningContent:         fullReasoning.String(),
-		AssistantGenMultiContent: multiContent,
-		ToolCalls:                toolCalls,
+	assistantMsg := buildAssistant()
+	uc.logAudit(ctx, modelName, preparedMessages, assistantMsg)
+	uc.recordTokenUsage(ctx, assistantMsg)
+	if IsEmptyAssistantMessage(assistantMsg) {
+		// 空回复单独上报，调用方决定是否入库、前端提示重试
+		if finishReason != "" {
+			return assistantMsg, modelName, fmt.Errorf("%w (finish_reason=%s)", ErrEmptyResponse, finishReason)
+		}
+		return assistantMsg, modelName, ErrEmptyResponse
+	}
+	// 工具调用参数按 index/ID 增量拼接而来，结束时校验整体是合法 JSON；
+	// 失败仍返回已累计的消息（含原始参数），由调用方决定是否入库
+	if err := validateToolCallArguments(assistantMsg.ToolCalls); err != nil {
+		return assistantMsg, modelName, err
+	}
+	if cacheKey != "" {
+		uc.respCache.put(cacheKey, assistantMsg, modelName)
 	}
 	return assistantMsg, modelName, nil
 }
 
+// newGenerateContext applies the overall generation deadline if configured.
+func (uc *ChatUsecase) newGenerateContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if uc.generateTimeout > 0 {
+		return context.WithTimeout(ctx, uc.generateTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// timeoutErr 把超时取消映射为明确的超时错误；没有超时发生时返回 nil。
+func timeoutErr(ctx context.Context, firstTokenTimedOut *atomic.Bool) error {
+	if firstTokenTimedOut.Load() {
+		return ErrFirstTokenTimeout
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrGenerateTimeout
+	}
+	return nil
+}
+
+// takeSystemInstruction 摘出历史最前面的 system 消息作为 agent 指令
+// （对话级自定义指令覆盖全局默认），没有时返回空串
+func takeSystemInstruction(messages []*schema.Message) ([]*schema.Message, string) {
+	if len(messages) > 0 && messages[0] != nil && messages[0].Role == schema.System && messages[0].Content != "" {
+		return messages[1:], messages[0].Content
+	}
+	return messages, ""
+}
+
 func prepareMessagesForModel(messages []*schema.Message) []*schema.Message {
-	prepared := make([]*schema.Message, len(messages))
-	for i, msg := range messages {
+	prepared := make([]*schema.Message, 0, len(messages))
+	for _, msg := range messages {
 		if msg == nil {
+			prepared = append(prepared, nil)
 			continue
 		}
 
 		cloned := *msg
-		prepared[i] = &cloned
+		prepared = append(prepared, &cloned)
 
 		if len(cloned.UserInputMultiContent) == 0 {
 			continue
 		}
 
+		// 多模态工具结果：文本部分留在工具消息里，图片/文件部分拆成紧随其后
+		// 的用户消息（下游适配器会把工具消息拍平成纯文本 Output）
+		if cloned.Role == schema.Tool {
+			if follow := splitToolMediaParts(&cloned); follow != nil {
+				prepared = append(prepared, follow)
+			}
+			continue
+		}
+
 		if cloned.Role != schema.User {
 			if cloned.Content == "" {
 				cloned.Content = concatInputTextParts(cloned.UserInputMultiContent)
@@ -269,6 +989,30 @@ func prepareMessagesForModel(messages []*schema.Message) []*schema.Message {
 	return prepared
 }
 
+// splitToolMediaParts 就地把工具消息收敛成纯文本输出，并把非文本部分
+// （图片/文件）作为待追加的用户消息返回；没有非文本部分时返回 nil
+func splitToolMediaParts(msg *schema.Message) *schema.Message {
+	var mediaParts []schema.MessageInputPart
+	for _, part := range msg.UserInputMultiContent {
+		if part.Type != schema.ChatMessagePartTypeText {
+			mediaParts = append(mediaParts, part)
+		}
+	}
+
+	if msg.Content == "" {
+		msg.Content = concatInputTextParts(msg.UserInputMultiContent)
+	}
+	msg.UserInputMultiContent = nil
+
+	if len(mediaParts) == 0 {
+		return nil
+	}
+	if msg.Content == "" {
+		msg.Content = "(tool returned media output, attached in the following message)"
+	}
+	return &schema.Message{Role: schema.User, UserInputMultiContent: mediaParts}
+}
+
 func hasNonTextInputParts(parts []schema.MessageInputPart) bool {
 	for _, part := range parts {
 		if part.Type != schema.ChatMessagePartTypeText {
@@ -333,6 +1077,11 @@ func consumeStream(
 	fullContent, fullReasoning *strings.Builder,
 	multiContent *[]schema.MessageOutputPart,
 	toolCalls *[]schema.ToolCall,
+	annotations *[]Annotation,
+	reasoningItems *[]ReasoningItem,
+	finishReason *string,
+	usage *schema.TokenUsage,
+	usageSeen *bool,
 	onChunk StreamChunkCallback,
 ) error {
 	defer stream.Close()
@@ -350,6 +1099,15 @@ func consumeStream(
 			ReasoningContent:         chunk.ReasoningContent,
 			AssistantGenMultiContent: chunk.AssistantGenMultiContent,
 			ToolCalls:                chunk.ToolCalls,
+			Annotations:              AnnotationsFromMessage(chunk),
+		}
+		if chunk.ResponseMeta != nil && chunk.ResponseMeta.FinishReason != "" {
+			*finishReason = chunk.ResponseMeta.FinishReason
+			sc.FinishReason = chunk.ResponseMeta.FinishReason
+		}
+		if chunk.ResponseMeta != nil && accumulateUsage(usage, usageSeen, chunk.ResponseMeta.Usage) {
+			usageCopy := *usage
+			sc.Usage = &usageCopy
 		}
 
 		if chunk.ReasoningContent != "" {
@@ -364,8 +1122,14 @@ func consumeStream(
 		if len(chunk.ToolCalls) > 0 {
 			*toolCalls = mergeToolCalls(*toolCalls, chunk.ToolCalls)
 		}
+		if len(sc.Annotations) > 0 {
+			*annotations = append(*annotations, sc.Annotations...)
+		}
+		if items := ReasoningItemsFromMessage(chunk); len(items) > 0 {
+			*reasoningItems = append(*reasoningItems, items...)
+		}
 
-		if sc.Content != "" || sc.ReasoningContent != "" || len(sc.AssistantGenMultiContent) > 0 || len(sc.ToolCalls) > 0 {
+		if sc.Content != "" || sc.ReasoningContent != "" || len(sc.AssistantGenMultiContent) > 0 || len(sc.ToolCalls) > 0 || len(sc.Annotations) > 0 || sc.FinishReason != "" || sc.Usage != nil {
 			if cbErr := onChunk(sc); cbErr != nil {
 				return cbErr
 			}
@@ -373,6 +1137,28 @@ func consumeStream(
 	}
 }
 
+// ErrMalformedToolCallArguments 流式工具调用的参数碎片拼接完成后不是合法 JSON。
+var ErrMalformedToolCallArguments = errors.New("tool call arguments are not valid JSON")
+
+// validateToolCallArguments 校验拼接完成的工具调用参数均可按 JSON 解析；
+// 空参数视为无参调用跳过。碎片丢失或拼接错位时在此兜底暴露
+func validateToolCallArguments(calls []schema.ToolCall) error {
+	for _, call := range calls {
+		args := call.Function.Arguments
+		if args == "" {
+			continue
+		}
+		if !json.Valid([]byte(args)) {
+			name := call.Function.Name
+			if call.ID != "" {
+				name = name + "/" + call.ID
+			}
+			return fmt.Errorf("%w: %s: %q", ErrMalformedToolCallArguments, name, args)
+		}
+	}
+	return nil
+}
+
 // mergeToolCalls merges streamed tool call chunks into stable tool call entries.
 func mergeToolCalls(existing, incoming []schema.ToolCall) []schema.ToolCall {
 	if len(incoming) == 0 {
@@ -481,3 +1267,38 @@ func (e *chatError) Error() string {
 func (e *chatError) Unwrap() error {
 	return e.err
 }
+
+// StatusCoder is implemented by errors that carry the HTTP status returned by
+// an upstream provider endpoint, so handlers can map 429/4xx/5xx to proper
+// responses without matching on error text.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// WrapUpstreamStatus attaches an upstream HTTP status code to err. The
+// original error stays reachable through errors.Is/As.
+func WrapUpstreamStatus(status int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &upstreamStatusError{status: status, err: err}
+}
+
+// UpstreamStatusCode extracts the upstream HTTP status from anywhere in err's
+// chain; it returns 0 when the error carries none.
+func UpstreamStatusCode(err error) int {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return sc.StatusCode()
+	}
+	return 0
+}
+
+type upstreamStatusError struct {
+	status int
+	err    error
+}
+
+func (e *upstreamStatusError) Error() string   { return e.err.Error() }
+func (e *upstreamStatusError) Unwrap() error   { return e.err }
+func (e *upstreamStatusError) StatusCode() int { return e.status }
@@ -0,0 +1,76 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// reasoningThenContentModel 先流式输出推理，再输出正文
+type reasoningThenContentModel struct{}
+
+func (reasoningThenContentModel) Generate(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	return &schema.Message{Role: schema.Assistant, ReasoningContent: "think", Content: "answer"}, nil
+}
+
+func (reasoningThenContentModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{
+		{Role: schema.Assistant, ReasoningContent: "think "},
+		{Role: schema.Assistant, ReasoningContent: "more"},
+		{Role: schema.Assistant, Content: "answer "},
+		{Role: schema.Assistant, Content: "text"},
+	}), nil
+}
+
+func (m reasoningThenContentModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type reasoningThenContentProvider struct{}
+
+func (reasoningThenContentProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return reasoningThenContentModel{}, nil
+}
+
+// TestChatStream_ReasoningDoneMarksFirstContentChunk 推理之后的首个正文 chunk
+// 必须带 ReasoningDone 标记，且整个流只打一次标。
+func TestChatStream_ReasoningDoneMarksFirstContentChunk(t *testing.T) {
+	uc := &ChatUsecase{provider: reasoningThenContentProvider{}, defaultModel: "test-model"}
+
+	var chunks []StreamChunk
+	_, _, err := uc.ChatStream(
+		context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "hi"}},
+		"", nil,
+		func(chunk StreamChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+
+	marked := -1
+	for i, chunk := range chunks {
+		if chunk.ReasoningDone {
+			if marked != -1 {
+				t.Fatalf("ReasoningDone must be set exactly once, also on chunk %d", i)
+			}
+			marked = i
+		}
+	}
+	if marked == -1 {
+		t.Fatal("expected a chunk with ReasoningDone set")
+	}
+	if chunks[marked].Content == "" {
+		t.Errorf("ReasoningDone must ride on a content chunk, got: %+v", chunks[marked])
+	}
+	for _, chunk := range chunks[:marked] {
+		if chunk.Content != "" {
+			t.Errorf("content before the boundary marker: %+v", chunk)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package biz
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// responseCache 进程内 LRU 响应缓存：相同请求直接复用上游回复，省钱省额度。
+// 只缓存确定性请求（带固定 seed），条目按最近使用淘汰。
+type responseCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 队首为最近使用
+	entries  map[string]*list.Element
+}
+
+type responseCacheEntry struct {
+	key       string
+	msg       *schema.Message
+	modelName string
+}
+
+func newResponseCache(capacity int) *responseCache {
+	return &responseCache{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// get 命中时返回缓存回复的副本（避免调用方修改污染缓存）
+func (c *responseCache) get(key string) (*schema.Message, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, "", false
+	}
+	c.order.MoveToFront(elem)
+	entry := elem.Value.(*responseCacheEntry)
+	cloned := *entry.msg
+	return &cloned, entry.modelName, true
+}
+
+func (c *responseCache) put(key string, msg *schema.Message, modelName string) {
+	if msg == nil {
+		return
+	}
+	cloned := *msg
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*responseCacheEntry).msg = &cloned
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&responseCacheEntry{key: key, msg: &cloned, modelName: modelName})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*responseCacheEntry).key)
+	}
+}
+
+// cacheableRequest 只有确定性请求（带固定 seed）且未要求绕过缓存时才读写缓存
+func (uc *ChatUsecase) cacheableRequest(params *RequestParams) bool {
+	return uc.respCache != nil && params != nil && params.Seed != nil && !params.NoCache
+}
+
+// responseCacheKey 计算缓存键：模型 + 规范化消息列表 + 影响输出的请求参数
+func responseCacheKey(modelName string, messages []*schema.Message, params *RequestParams) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	_ = enc.Encode(modelName)
+	for _, msg := range messages {
+		if msg == nil {
+			continue
+		}
+		_ = enc.Encode(struct {
+			Role       schema.RoleType
+			Content    string
+			ToolCallID string
+		}{msg.Role, msg.Content, msg.ToolCallID})
+	}
+	if params != nil {
+		_ = enc.Encode(struct {
+			Seed             *int
+			Thinking         *bool
+			ThinkingBudget   *int
+			ReasoningSummary string
+			Extra            map[string]any
+		}{params.Seed, params.Thinking, params.ThinkingBudget, params.ReasoningSummary, params.Extra})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
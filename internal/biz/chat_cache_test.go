@@ -0,0 +1,177 @@
+package biz
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// countingModel 统计上游被真正调用的次数
+type countingModel struct {
+	calls *atomic.Int32
+}
+
+func (m countingModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	m.calls.Add(1)
+	return &schema.Message{Role: schema.Assistant, Content: "hello"}, nil
+}
+
+func (m countingModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	m.calls.Add(1)
+	return schema.StreamReaderFromArray([]*schema.Message{{Role: schema.Assistant, Content: "hello"}}), nil
+}
+
+func (m countingModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type countingProvider struct {
+	calls *atomic.Int32
+}
+
+func (p countingProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return countingModel{calls: p.calls}, nil
+}
+
+func newCacheTestUsecase() (*ChatUsecase, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	uc := &ChatUsecase{
+		provider:     countingProvider{calls: calls},
+		defaultModel: "test-model",
+		respCache:    newResponseCache(4),
+	}
+	return uc, calls
+}
+
+func seededParams(seed int) *RequestParams {
+	return &RequestParams{Seed: &seed}
+}
+
+var cacheTestMessages = []*schema.Message{{Role: schema.User, Content: "hi"}}
+
+// TestChat_ResponseCacheHit 相同的确定性请求第二次直接命中缓存，不再调用上游。
+func TestChat_ResponseCacheHit(t *testing.T) {
+	uc, calls := newCacheTestUsecase()
+
+	first, _, err := uc.Chat(context.Background(), cacheTestMessages, "", seededParams(42))
+	if err != nil {
+		t.Fatalf("first chat failed: %v", err)
+	}
+	second, _, err := uc.Chat(context.Background(), cacheTestMessages, "", seededParams(42))
+	if err != nil {
+		t.Fatalf("second chat failed: %v", err)
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected 1 upstream call, got %d", calls.Load())
+	}
+	if second.Content != first.Content {
+		t.Errorf("cached reply mismatch: %q vs %q", second.Content, first.Content)
+	}
+}
+
+// TestChat_ResponseCacheMiss 不同消息或不同 seed 均不命中。
+func TestChat_ResponseCacheMiss(t *testing.T) {
+	uc, calls := newCacheTestUsecase()
+
+	if _, _, err := uc.Chat(context.Background(), cacheTestMessages, "", seededParams(42)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := uc.Chat(context.Background(), []*schema.Message{{Role: schema.User, Content: "bye"}}, "", seededParams(42)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := uc.Chat(context.Background(), cacheTestMessages, "", seededParams(7)); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls.Load() != 3 {
+		t.Fatalf("expected 3 upstream calls, got %d", calls.Load())
+	}
+}
+
+// TestChat_ResponseCacheRequiresSeed 未固定 seed 的请求不缓存（输出非确定）。
+func TestChat_ResponseCacheRequiresSeed(t *testing.T) {
+	uc, calls := newCacheTestUsecase()
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := uc.Chat(context.Background(), cacheTestMessages, "", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("expected no caching without seed, got %d upstream calls", calls.Load())
+	}
+}
+
+// TestChat_NoCacheBypassesCache no_cache 请求既不读也不写缓存。
+func TestChat_NoCacheBypassesCache(t *testing.T) {
+	uc, calls := newCacheTestUsecase()
+	seed := 42
+
+	if _, _, err := uc.Chat(context.Background(), cacheTestMessages, "", seededParams(seed)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := uc.Chat(context.Background(), cacheTestMessages, "", &RequestParams{Seed: &seed, NoCache: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls.Load() != 2 {
+		t.Fatalf("expected no_cache to bypass the cache, got %d upstream calls", calls.Load())
+	}
+}
+
+// TestChatStream_ResponseCacheReplay 流式命中缓存时整条内容作为单个 chunk 重放。
+func TestChatStream_ResponseCacheReplay(t *testing.T) {
+	uc, calls := newCacheTestUsecase()
+
+	if _, _, err := uc.ChatStream(context.Background(), cacheTestMessages, "", seededParams(42),
+		func(StreamChunk) error { return nil }); err != nil {
+		t.Fatalf("first stream failed: %v", err)
+	}
+
+	var chunks []StreamChunk
+	cached, _, err := uc.ChatStream(context.Background(), cacheTestMessages, "", seededParams(42),
+		func(chunk StreamChunk) error {
+			chunks = append(chunks, chunk)
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("second stream failed: %v", err)
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("expected replay from cache, got %d upstream calls", calls.Load())
+	}
+	if len(chunks) != 1 || chunks[0].Content != "hello" {
+		t.Fatalf("expected a single replayed chunk, got: %+v", chunks)
+	}
+	if cached == nil || cached.Content != "hello" {
+		t.Fatalf("unexpected cached message: %+v", cached)
+	}
+}
+
+// TestResponseCache_LRUEviction 超过容量时按最近使用淘汰最旧条目。
+func TestResponseCache_LRUEviction(t *testing.T) {
+	cache := newResponseCache(2)
+	cache.put("a", &schema.Message{Content: "a"}, "m")
+	cache.put("b", &schema.Message{Content: "b"}, "m")
+
+	// 访问 a 使其变为最近使用，放入 c 应淘汰 b
+	if _, _, ok := cache.get("a"); !ok {
+		t.Fatal("expected a to be cached")
+	}
+	cache.put("c", &schema.Message{Content: "c"}, "m")
+
+	if _, _, ok := cache.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if _, _, ok := cache.get("a"); !ok {
+		t.Error("expected a to survive eviction")
+	}
+	if _, _, ok := cache.get("c"); !ok {
+		t.Error("expected c to be cached")
+	}
+}
@@ -0,0 +1,76 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"devops-backend/internal/conf"
+)
+
+func TestAcquireUpstreamSlot_Unlimited(t *testing.T) {
+	uc := NewChatUsecase(nil, conf.Eino{})
+
+	release, err := uc.acquireUpstreamSlot(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error without a limit: %v", err)
+	}
+	release()
+}
+
+func TestAcquireUpstreamSlot_SaturatedFailFast(t *testing.T) {
+	uc := NewChatUsecase(nil, conf.Eino{MaxConcurrentRequests: 1})
+
+	release, err := uc.acquireUpstreamSlot(context.Background())
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+	defer release()
+
+	// 无排队超时时满载立即失败
+	if _, err := uc.acquireUpstreamSlot(context.Background()); !errors.Is(err, ErrUpstreamSaturated) {
+		t.Fatalf("expected ErrUpstreamSaturated, got %v", err)
+	}
+}
+
+func TestAcquireUpstreamSlot_QueueUntilReleased(t *testing.T) {
+	uc := NewChatUsecase(nil, conf.Eino{MaxConcurrentRequests: 1, ConcurrencyWaitSeconds: 5})
+
+	release, err := uc.acquireUpstreamSlot(context.Background())
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	// 占用者稍后释放，排队中的请求应拿到槽位而不是超时
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		release()
+	}()
+
+	release2, err := uc.acquireUpstreamSlot(context.Background())
+	if err != nil {
+		t.Fatalf("queued acquire failed: %v", err)
+	}
+	release2()
+}
+
+func TestAcquireUpstreamSlot_CanceledWhileQueued(t *testing.T) {
+	uc := NewChatUsecase(nil, conf.Eino{MaxConcurrentRequests: 1, ConcurrencyWaitSeconds: 5})
+
+	release, err := uc.acquireUpstreamSlot(context.Background())
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	if _, err := uc.acquireUpstreamSlot(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
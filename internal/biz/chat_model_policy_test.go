@@ -0,0 +1,66 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCheckModelAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		blocked []string
+		model   string
+		wantErr bool
+	}{
+		{name: "no lists allows everything", model: "gpt-4o-mini", wantErr: false},
+		{name: "prefix pattern allows", allowed: []string{"gpt-4*"}, model: "gpt-4o-mini", wantErr: false},
+		{name: "exact pattern allows", allowed: []string{"claude-sonnet-4"}, model: "claude-sonnet-4", wantErr: false},
+		{name: "not in allowlist", allowed: []string{"gpt-4*"}, model: "claude-sonnet-4", wantErr: true},
+		{name: "blocklist wins over allowlist", allowed: []string{"gpt-4*"}, blocked: []string{"gpt-4o-realtime*"}, model: "gpt-4o-realtime", wantErr: true},
+		{name: "blocklist alone", blocked: []string{"o1*"}, model: "o1-preview", wantErr: true},
+		{name: "blocklist misses", blocked: []string{"o1*"}, model: "gpt-4o-mini", wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := &ChatUsecase{allowedModels: tt.allowed, blockedModels: tt.blocked}
+			err := uc.checkModelAllowed(tt.model)
+			if tt.wantErr {
+				if !errors.Is(err, ErrModelNotAllowed) {
+					t.Fatalf("expected ErrModelNotAllowed, got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestChatEnforcesAllowlistAfterDefaulting(t *testing.T) {
+	// 未指定 model 时先回退到默认模型，再按名单校验
+	uc := &ChatUsecase{defaultModel: "gpt-4o-realtime", allowedModels: []string{"gpt-4*"}, blockedModels: []string{"gpt-4o-realtime*"}}
+	_, _, err := uc.Chat(context.Background(), nil, "", nil)
+	if !errors.Is(err, ErrModelNotAllowed) {
+		t.Fatalf("expected ErrModelNotAllowed, got %v", err)
+	}
+}
+
+// TestSanitizeParams_ExtraGate extra 透传默认被剥离，仅在配置放行后保留
+func TestSanitizeParams_ExtraGate(t *testing.T) {
+	params := &RequestParams{Extra: map[string]any{"service_tier": "flex"}}
+
+	locked := &ChatUsecase{}
+	if got := locked.sanitizeParams(params); got.Extra != nil {
+		t.Errorf("expected extra stripped by default, got: %v", got.Extra)
+	}
+	if params.Extra == nil {
+		t.Error("sanitize must not mutate the caller's params")
+	}
+
+	open := &ChatUsecase{allowExtraParams: true}
+	if got := open.sanitizeParams(params); got.Extra == nil {
+		t.Error("expected extra preserved when allow_extra_params is on")
+	}
+}
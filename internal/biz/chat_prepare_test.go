@@ -88,3 +88,56 @@ func TestPrepareMessagesForModel_NonUserClearsUserMultiContent(t *testing.T) {
 		t.Fatalf("expected user multimodal content to be cleared for non-user roles")
 	}
 }
+
+func TestPrepareMessagesForModel_ToolImageResultSplitsFollowUp(t *testing.T) {
+	got := prepareMessagesForModel([]*schema.Message{
+		{
+			Role:       schema.Tool,
+			ToolCallID: "call_1",
+			UserInputMultiContent: []schema.MessageInputPart{
+				{Type: schema.ChatMessagePartTypeText, Text: "screenshot captured"},
+				{
+					Type: schema.ChatMessagePartTypeImageURL,
+					Image: &schema.MessageInputImage{
+						MessagePartCommon: schema.MessagePartCommon{MIMEType: "image/png"},
+					},
+				},
+			},
+		},
+	})
+
+	if len(got) != 2 {
+		t.Fatalf("expected tool message plus media follow-up, got %d messages", len(got))
+	}
+	if got[0].Role != schema.Tool || got[0].Content != "screenshot captured" {
+		t.Fatalf("unexpected tool message: %+v", got[0])
+	}
+	if len(got[0].UserInputMultiContent) != 0 {
+		t.Fatal("expected tool message media parts to be split off")
+	}
+	if got[1].Role != schema.User || len(got[1].UserInputMultiContent) != 1 {
+		t.Fatalf("unexpected follow-up message: %+v", got[1])
+	}
+	if got[1].UserInputMultiContent[0].Type != schema.ChatMessagePartTypeImageURL {
+		t.Fatalf("expected image part in follow-up, got: %s", got[1].UserInputMultiContent[0].Type)
+	}
+}
+
+func TestPrepareMessagesForModel_ToolTextOnlyStaysSingle(t *testing.T) {
+	got := prepareMessagesForModel([]*schema.Message{
+		{
+			Role:       schema.Tool,
+			ToolCallID: "call_1",
+			UserInputMultiContent: []schema.MessageInputPart{
+				{Type: schema.ChatMessagePartTypeText, Text: "done"},
+			},
+		},
+	})
+
+	if len(got) != 1 {
+		t.Fatalf("expected a single message, got %d", len(got))
+	}
+	if got[0].Content != "done" || len(got[0].UserInputMultiContent) != 0 {
+		t.Fatalf("unexpected tool message: %+v", got[0])
+	}
+}
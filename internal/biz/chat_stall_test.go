@@ -0,0 +1,103 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// stallingChatModel 先吐几个 chunk，然后停更直到 ctx 被取消
+type stallingChatModel struct {
+	chunks   []string
+	interval time.Duration
+	// stall true 时发完 chunks 后挂起；false 则正常关闭流
+	stall bool
+}
+
+func (m stallingChatModel) Generate(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m stallingChatModel) Stream(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer sw.Close()
+		for _, c := range m.chunks {
+			time.Sleep(m.interval)
+			if sw.Send(&schema.Message{Role: schema.Assistant, Content: c}, nil) {
+				return
+			}
+		}
+		if m.stall {
+			<-ctx.Done()
+			sw.Send(nil, ctx.Err())
+		}
+	}()
+	return sr, nil
+}
+
+func (m stallingChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type stallingProvider struct{ model stallingChatModel }
+
+func (p stallingProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return p.model, nil
+}
+
+// TestChatStream_StallWatchdogSavesPartial 有输出后停更触发看门狗，
+// 返回 ErrStreamStalled 且带上已累计的部分内容。
+func TestChatStream_StallWatchdogSavesPartial(t *testing.T) {
+	uc := &ChatUsecase{
+		provider:           stallingProvider{model: stallingChatModel{chunks: []string{"Hel", "lo"}, interval: 5 * time.Millisecond, stall: true}},
+		defaultModel:       "test-model",
+		streamStallTimeout: 80 * time.Millisecond,
+	}
+
+	var received string
+	msg, _, err := uc.ChatStream(
+		context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "hi"}},
+		"", nil,
+		func(chunk StreamChunk) error {
+			received += chunk.Content
+			return nil
+		})
+	if !errors.Is(err, ErrStreamStalled) {
+		t.Fatalf("expected ErrStreamStalled, got: %v", err)
+	}
+	if msg == nil || msg.Content != "Hello" {
+		t.Fatalf("partial content must be returned for persistence, got %+v", msg)
+	}
+	if received != "Hello" {
+		t.Errorf("chunks before the stall must reach the client, got %q", received)
+	}
+}
+
+// TestChatStream_StallWatchdogNotTriggeredByHealthyStream chunk 间隔小于窗口时
+// 看门狗不会误伤正常收尾的流。
+func TestChatStream_StallWatchdogNotTriggeredByHealthyStream(t *testing.T) {
+	uc := &ChatUsecase{
+		provider:           stallingProvider{model: stallingChatModel{chunks: []string{"a", "b", "c", "d"}, interval: 10 * time.Millisecond}},
+		defaultModel:       "test-model",
+		streamStallTimeout: 100 * time.Millisecond,
+	}
+
+	msg, _, err := uc.ChatStream(
+		context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "hi"}},
+		"", nil,
+		func(StreamChunk) error { return nil })
+	if err != nil {
+		t.Fatalf("healthy stream must complete: %v", err)
+	}
+	if msg.Content != "abcd" {
+		t.Errorf("unexpected content: %q", msg.Content)
+	}
+}
@@ -0,0 +1,84 @@
+package biz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+)
+
+// thinkingStubModel 只用于能力查询，不会被实际调用
+type thinkingStubModel struct {
+	model.ToolCallingChatModel
+	supports bool
+}
+
+func (m *thinkingStubModel) SupportsThinking() bool { return m.supports }
+
+// plainStubModel 不实现 SupportsThinking
+type plainStubModel struct {
+	model.ToolCallingChatModel
+}
+
+type stubModelProvider struct {
+	m model.ToolCallingChatModel
+}
+
+func (p *stubModelProvider) CreateChatModel(ctx context.Context, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	return p.m, nil
+}
+
+func TestAppliedThinking(t *testing.T) {
+	enabled := true
+	disabled := false
+
+	tests := []struct {
+		name     string
+		m        model.ToolCallingChatModel
+		thinking *bool
+		want     bool
+	}{
+		{name: "nil thinking", m: &thinkingStubModel{supports: true}, thinking: nil, want: false},
+		{name: "thinking off", m: &thinkingStubModel{supports: true}, thinking: &disabled, want: false},
+		{name: "supported", m: &thinkingStubModel{supports: true}, thinking: &enabled, want: true},
+		{name: "not supported", m: &thinkingStubModel{supports: false}, thinking: &enabled, want: false},
+		{name: "adapter without capability check", m: &plainStubModel{}, thinking: &enabled, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := &ChatUsecase{provider: &stubModelProvider{m: tt.m}, defaultModel: "gpt-4o-mini"}
+			if got := uc.AppliedThinking(context.Background(), "", tt.thinking); got != tt.want {
+				t.Fatalf("AppliedThinking = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThinkingDecision(t *testing.T) {
+	enabled := true
+	disabled := false
+
+	tests := []struct {
+		name       string
+		params     *RequestParams
+		supports   bool
+		wantEnable bool
+		wantSet    bool
+	}{
+		{name: "nil params", params: nil, supports: true},
+		{name: "nil thinking", params: &RequestParams{}, supports: true},
+		{name: "unsupported model", params: &RequestParams{Thinking: &enabled}, supports: false},
+		{name: "explicit on", params: &RequestParams{Thinking: &enabled}, supports: true, wantEnable: true, wantSet: true},
+		{name: "explicit off", params: &RequestParams{Thinking: &disabled}, supports: true, wantSet: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enable, set := ThinkingDecision(tt.params, tt.supports)
+			if enable != tt.wantEnable || set != tt.wantSet {
+				t.Fatalf("ThinkingDecision = (%v, %v), want (%v, %v)", enable, set, tt.wantEnable, tt.wantSet)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// hangingChatModel 连接成功但一直不吐数据，只在 ctx 取消时返回错误
+type hangingChatModel struct{}
+
+func (hangingChatModel) Generate(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (hangingChatModel) Stream(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		<-ctx.Done()
+		sw.Send(nil, ctx.Err())
+		sw.Close()
+	}()
+	return sr, nil
+}
+
+func (m hangingChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type hangingProvider struct{}
+
+func (hangingProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return hangingChatModel{}, nil
+}
+
+func TestChatStream_FirstTokenTimeout(t *testing.T) {
+	uc := &ChatUsecase{
+		provider:          hangingProvider{},
+		defaultModel:      "test-model",
+		firstTokenTimeout: 50 * time.Millisecond,
+	}
+
+	_, _, err := uc.ChatStream(
+		context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "hi"}},
+		"", nil,
+		func(StreamChunk) error { return nil },
+	)
+	if !errors.Is(err, ErrFirstTokenTimeout) {
+		t.Fatalf("expected ErrFirstTokenTimeout, got: %v", err)
+	}
+}
+
+func TestChatStream_GenerateTimeout(t *testing.T) {
+	uc := &ChatUsecase{
+		provider:        hangingProvider{},
+		defaultModel:    "test-model",
+		generateTimeout: 50 * time.Millisecond,
+	}
+
+	_, _, err := uc.ChatStream(
+		context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "hi"}},
+		"", nil,
+		func(StreamChunk) error { return nil },
+	)
+	if !errors.Is(err, ErrGenerateTimeout) {
+		t.Fatalf("expected ErrGenerateTimeout, got: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// toolCallStreamModel 以 delta 形式分片下发工具调用参数
+type toolCallStreamModel struct {
+	frames []*schema.Message
+}
+
+func (m toolCallStreamModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return &schema.Message{Role: schema.Assistant, Content: "unused"}, nil
+}
+
+func (m toolCallStreamModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray(m.frames), nil
+}
+
+func (m toolCallStreamModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type toolCallStreamProvider struct {
+	frames []*schema.Message
+}
+
+func (p toolCallStreamProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return toolCallStreamModel{frames: p.frames}, nil
+}
+
+// toolCallDelta 构造一个参数分片：首帧携带 ID/名称，后续只带 index 和碎片
+func toolCallDelta(index int, id, name, argsFragment string) *schema.Message {
+	idx := index
+	return &schema.Message{
+		Role: schema.Assistant,
+		ToolCalls: []schema.ToolCall{{
+			Index:    &idx,
+			ID:       id,
+			Type:     "function",
+			Function: schema.FunctionCall{Name: name, Arguments: argsFragment},
+		}},
+	}
+}
+
+func newToolCallStreamUsecase(frames []*schema.Message) *ChatUsecase {
+	return &ChatUsecase{
+		provider:     toolCallStreamProvider{frames: frames},
+		defaultModel: "test-model",
+		respCache:    newResponseCache(4),
+	}
+}
+
+// TestChatStream_AssemblesFragmentedToolCallArguments 按 index 拼接参数碎片，
+// 最终助手消息携带完整可解析的工具调用。
+func TestChatStream_AssemblesFragmentedToolCallArguments(t *testing.T) {
+	uc := newToolCallStreamUsecase([]*schema.Message{
+		toolCallDelta(0, "call_1", "get_weather", `{"city":`),
+		toolCallDelta(0, "", "", `"Paris",`),
+		toolCallDelta(0, "", "", `"unit":"c"}`),
+		toolCallDelta(1, "call_2", "get_time", `{}`),
+	})
+
+	var streamed [][]schema.ToolCall
+	msg, _, err := uc.ChatStream(context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "weather?"}}, "", nil,
+		func(chunk StreamChunk) error {
+			if len(chunk.ToolCalls) > 0 {
+				streamed = append(streamed, chunk.ToolCalls)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+
+	if len(msg.ToolCalls) != 2 {
+		t.Fatalf("unexpected tool call count: %d", len(msg.ToolCalls))
+	}
+	first := msg.ToolCalls[0]
+	if first.ID != "call_1" || first.Function.Name != "get_weather" {
+		t.Errorf("unexpected first call identity: %+v", first)
+	}
+	if first.Function.Arguments != `{"city":"Paris","unit":"c"}` {
+		t.Errorf("arguments not assembled: %q", first.Function.Arguments)
+	}
+	if msg.ToolCalls[1].Function.Arguments != `{}` {
+		t.Errorf("unexpected second call arguments: %q", msg.ToolCalls[1].Function.Arguments)
+	}
+	if len(streamed) == 0 {
+		t.Error("expected tool call deltas to be forwarded to onChunk")
+	}
+}
+
+// TestChatStream_MalformedToolCallArguments 碎片拼接结果不是合法 JSON 时返回
+// ErrMalformedToolCallArguments，但仍带回已累计的消息。
+func TestChatStream_MalformedToolCallArguments(t *testing.T) {
+	uc := newToolCallStreamUsecase([]*schema.Message{
+		toolCallDelta(0, "call_1", "get_weather", `{"city":`),
+		// 缺失收尾碎片，拼接结果残缺
+	})
+
+	msg, _, err := uc.ChatStream(context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "weather?"}}, "", nil,
+		func(StreamChunk) error { return nil })
+	if !errors.Is(err, ErrMalformedToolCallArguments) {
+		t.Fatalf("expected ErrMalformedToolCallArguments, got: %v", err)
+	}
+	if msg == nil || len(msg.ToolCalls) != 1 {
+		t.Fatalf("expected accumulated message with the partial call, got: %+v", msg)
+	}
+}
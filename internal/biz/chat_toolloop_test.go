@@ -0,0 +1,230 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// loopingToolModel 无论输入什么都请求一次 ping 工具，用于验证循环上限
+type loopingToolModel struct {
+	calls *int32
+}
+
+func (m loopingToolModel) toolCallMessage() *schema.Message {
+	n := atomic.AddInt32(m.calls, 1)
+	return &schema.Message{
+		Role: schema.Assistant,
+		ToolCalls: []schema.ToolCall{{
+			ID:       "call_" + strings.Repeat("x", int(n%5)+1),
+			Type:     "function",
+			Function: schema.FunctionCall{Name: "ping", Arguments: `{}`},
+		}},
+	}
+}
+
+func (m loopingToolModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return m.toolCallMessage(), nil
+}
+
+func (m loopingToolModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{m.toolCallMessage()}), nil
+}
+
+func (m loopingToolModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type loopingToolProvider struct {
+	calls *int32
+}
+
+func (p loopingToolProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return loopingToolModel{calls: p.calls}, nil
+}
+
+// pingTool 总是返回 pong 的工具，记录执行次数
+type pingTool struct {
+	runs  *int32
+	delay time.Duration
+}
+
+func (p pingTool) Info(context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name:        "ping",
+		Desc:        "always answers pong",
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{}),
+	}, nil
+}
+
+func (p pingTool) InvokableRun(ctx context.Context, _ string, _ ...tool.Option) (string, error) {
+	if p.delay > 0 {
+		select {
+		case <-time.After(p.delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	atomic.AddInt32(p.runs, 1)
+	return "pong", nil
+}
+
+// TestChatStream_ToolLoopTerminates 总是请求工具的模型在 max_tool_iterations
+// 轮后终止，返回 tool_loop_exceeded，且每次工具执行都有对应的流式事件。
+func TestChatStream_ToolLoopTerminates(t *testing.T) {
+	var modelCalls, toolRuns int32
+	uc := &ChatUsecase{
+		provider:          loopingToolProvider{calls: &modelCalls},
+		defaultModel:      "test-model",
+		maxToolIterations: 2,
+		respCache:         newResponseCache(4),
+	}
+	uc.RegisterTools(pingTool{runs: &toolRuns})
+
+	var toolChunks []StreamChunk
+	msg, _, err := uc.ChatStream(context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "go"}}, "", nil,
+		func(chunk StreamChunk) error {
+			if chunk.ToolName != "" {
+				toolChunks = append(toolChunks, chunk)
+			}
+			return nil
+		})
+	if !errors.Is(err, ErrToolLoopExceeded) {
+		t.Fatalf("expected ErrToolLoopExceeded, got %v", err)
+	}
+	if msg == nil {
+		t.Fatal("partial assistant message must be returned for persistence")
+	}
+	if atomic.LoadInt32(&modelCalls) != 2 {
+		t.Errorf("expected exactly 2 generation cycles, got %d", modelCalls)
+	}
+	if len(toolChunks) == 0 {
+		t.Fatal("expected tool execution events on the stream")
+	}
+	for _, c := range toolChunks {
+		if c.ToolName != "ping" || c.ToolOutput != "pong" {
+			t.Errorf("unexpected tool chunk: %+v", c)
+		}
+	}
+}
+
+// TestTimeoutTool_SlowToolDoesNotHang 慢工具在超时后立刻返回错误
+func TestTimeoutTool_SlowToolDoesNotHang(t *testing.T) {
+	var runs int32
+	wrapped := &timeoutTool{
+		InvokableTool: pingTool{runs: &runs, delay: time.Second},
+		timeout:       20 * time.Millisecond,
+	}
+
+	start := time.Now()
+	_, err := wrapped.InvokableRun(context.Background(), `{}`)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("timeout not enforced, took %s", elapsed)
+	}
+}
+
+// oneShotToolModel 首轮请求 ping 工具，看到工具结果后给出最终回答
+type oneShotToolModel struct {
+	calls *int32
+}
+
+func (m oneShotToolModel) reply(messages []*schema.Message) *schema.Message {
+	atomic.AddInt32(m.calls, 1)
+	for _, msg := range messages {
+		if msg.Role == schema.Tool {
+			return &schema.Message{Role: schema.Assistant, Content: "tool said " + msg.Content}
+		}
+	}
+	return &schema.Message{
+		Role: schema.Assistant,
+		ToolCalls: []schema.ToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: schema.FunctionCall{Name: "ping", Arguments: `{}`},
+		}},
+	}
+}
+
+func (m oneShotToolModel) Generate(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	return m.reply(messages), nil
+}
+
+func (m oneShotToolModel) Stream(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{m.reply(messages)}), nil
+}
+
+func (m oneShotToolModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type oneShotToolProvider struct {
+	calls *int32
+}
+
+func (p oneShotToolProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return oneShotToolModel{calls: p.calls}, nil
+}
+
+// TestChatStream_AgenticRoundTripStreamsAllPhases 一次工具往返在同一个流上
+// 依次看到工具调用、工具结果和下一轮回答，最终消息拿到第二轮的内容。
+func TestChatStream_AgenticRoundTripStreamsAllPhases(t *testing.T) {
+	var modelCalls, toolRuns int32
+	uc := &ChatUsecase{
+		provider:     oneShotToolProvider{calls: &modelCalls},
+		defaultModel: "test-model",
+		respCache:    newResponseCache(4),
+	}
+	uc.RegisterTools(pingTool{runs: &toolRuns})
+
+	var sawToolCall, sawToolResult bool
+	var contentAfterTool strings.Builder
+	msg, _, err := uc.ChatStream(context.Background(),
+		[]*schema.Message{{Role: schema.User, Content: "go"}}, "", nil,
+		func(chunk StreamChunk) error {
+			if len(chunk.ToolCalls) > 0 {
+				sawToolCall = true
+			}
+			if chunk.ToolOutput != "" {
+				if !sawToolCall {
+					t.Error("tool result must follow the tool call event")
+				}
+				if chunk.ToolName != "ping" || chunk.ToolOutput != "pong" {
+					t.Errorf("unexpected tool result chunk: %+v", chunk)
+				}
+				sawToolResult = true
+			}
+			if chunk.Content != "" {
+				if !sawToolResult {
+					t.Error("final answer must stream after the tool result")
+				}
+				contentAfterTool.WriteString(chunk.Content)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+	if !sawToolCall || !sawToolResult {
+		t.Fatalf("missing phases: toolCall=%v toolResult=%v", sawToolCall, sawToolResult)
+	}
+	if contentAfterTool.String() != "tool said pong" {
+		t.Errorf("streamed answer = %q", contentAfterTool.String())
+	}
+	if msg.Content != "tool said pong" {
+		t.Errorf("final message content = %q", msg.Content)
+	}
+	if atomic.LoadInt32(&modelCalls) != 2 || atomic.LoadInt32(&toolRuns) != 1 {
+		t.Errorf("expected 2 model calls and 1 tool run, got %d/%d", modelCalls, toolRuns)
+	}
+}
@@ -0,0 +1,79 @@
+package biz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestIsTruncatedFinishReason(t *testing.T) {
+	for _, reason := range []string{"length", "incomplete", "max_output_tokens", "MAX_TOKENS"} {
+		if !IsTruncatedFinishReason(reason) {
+			t.Errorf("IsTruncatedFinishReason(%q) = false, want true", reason)
+		}
+	}
+	for _, reason := range []string{"", "stop", "tool_calls"} {
+		if IsTruncatedFinishReason(reason) {
+			t.Errorf("IsTruncatedFinishReason(%q) = true, want false", reason)
+		}
+	}
+}
+
+func TestMergeContinuation(t *testing.T) {
+	base := &schema.Message{
+		Role:         schema.Assistant,
+		Content:      "first half",
+		ResponseMeta: &schema.ResponseMeta{FinishReason: "length"},
+	}
+	continuation := &schema.Message{
+		Role:         schema.Assistant,
+		Content:      " second half",
+		ResponseMeta: &schema.ResponseMeta{FinishReason: "stop"},
+	}
+
+	merged := MergeContinuation(base, continuation)
+	if merged.Content != "first half second half" {
+		t.Fatalf("unexpected merged content: %q", merged.Content)
+	}
+	if merged.ResponseMeta == nil || merged.ResponseMeta.FinishReason != "stop" {
+		t.Fatalf("merged finish reason should come from continuation, got: %#v", merged.ResponseMeta)
+	}
+	if base.Content != "first half" {
+		t.Fatalf("base message must not be mutated, got: %q", base.Content)
+	}
+}
+
+func TestConsumeStream_PropagatesFinishReason(t *testing.T) {
+	stream := schema.StreamReaderFromArray([]*schema.Message{
+		{Role: schema.Assistant, Content: "partial answ"},
+		{Role: schema.Assistant, ResponseMeta: &schema.ResponseMeta{FinishReason: "incomplete"}},
+	})
+
+	var (
+		fullContent, fullReasoning strings.Builder
+		multiContent               []schema.MessageOutputPart
+		toolCalls                  []schema.ToolCall
+		annotations                []Annotation
+		reasoningItems             []ReasoningItem
+		finishReason               string
+		usage                      schema.TokenUsage
+		usageSeen                  bool
+		chunkReasons               []string
+	)
+	err := consumeStream(stream, &fullContent, &fullReasoning, &multiContent, &toolCalls, &annotations, &reasoningItems, &finishReason, &usage, &usageSeen, func(sc StreamChunk) error {
+		if sc.FinishReason != "" {
+			chunkReasons = append(chunkReasons, sc.FinishReason)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("consumeStream returned error: %v", err)
+	}
+	if finishReason != "incomplete" {
+		t.Fatalf("accumulated finish reason: got %q, want %q", finishReason, "incomplete")
+	}
+	if len(chunkReasons) != 1 || chunkReasons[0] != "incomplete" {
+		t.Fatalf("callback finish reasons: got %#v, want [incomplete]", chunkReasons)
+	}
+}
@@ -0,0 +1,73 @@
+package biz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestAccumulateUsage(t *testing.T) {
+	var total schema.TokenUsage
+	var seen bool
+
+	if accumulateUsage(&total, &seen, nil) {
+		t.Fatal("nil usage must not report new data")
+	}
+
+	// 累计快照：总量单调增长时直接替换
+	if !accumulateUsage(&total, &seen, &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}) {
+		t.Fatal("expected usage to be recorded")
+	}
+	accumulateUsage(&total, &seen, &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 20, TotalTokens: 30})
+	if total.TotalTokens != 30 || total.CompletionTokens != 20 {
+		t.Fatalf("snapshot semantics: got %+v", total)
+	}
+
+	// 增量：总量变小时视为 delta 累加
+	accumulateUsage(&total, &seen, &schema.TokenUsage{CompletionTokens: 4, TotalTokens: 4})
+	if total.TotalTokens != 34 || total.CompletionTokens != 24 || total.PromptTokens != 10 {
+		t.Fatalf("delta semantics: got %+v", total)
+	}
+}
+
+func TestConsumeStream_EmitsRunningUsage(t *testing.T) {
+	stream := schema.StreamReaderFromArray([]*schema.Message{
+		{Role: schema.Assistant, Content: "a", ResponseMeta: &schema.ResponseMeta{
+			Usage: &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 1, TotalTokens: 11},
+		}},
+		{Role: schema.Assistant, Content: "b"},
+		{Role: schema.Assistant, ResponseMeta: &schema.ResponseMeta{
+			FinishReason: "stop",
+			Usage:        &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+		}},
+	})
+
+	var (
+		fullContent, fullReasoning strings.Builder
+		multiContent               []schema.MessageOutputPart
+		toolCalls                  []schema.ToolCall
+		annotations                []Annotation
+		reasoningItems             []ReasoningItem
+		finishReason               string
+		usage                      schema.TokenUsage
+		usageSeen                  bool
+		totals                     []int
+	)
+	err := consumeStream(stream, &fullContent, &fullReasoning, &multiContent, &toolCalls, &annotations, &reasoningItems, &finishReason, &usage, &usageSeen, func(sc StreamChunk) error {
+		if sc.Usage != nil {
+			totals = append(totals, sc.Usage.TotalTokens)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("consumeStream returned error: %v", err)
+	}
+
+	if len(totals) != 2 || totals[0] != 11 || totals[1] != 12 {
+		t.Fatalf("expected running totals [11 12], got %v", totals)
+	}
+	if !usageSeen || usage.TotalTokens != 12 {
+		t.Fatalf("expected final accumulated usage 12, got %+v", usage)
+	}
+}
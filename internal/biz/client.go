@@ -3,12 +3,32 @@ package biz
 import (
 	"context"
 
+	"devops-backend/internal/conf"
+
 	"github.com/cloudwego/eino/components/model"
 )
 
 // RequestParams 请求参数
 type RequestParams struct {
 	Thinking *bool
+	// ThinkingBudget 思考 token 预算，支持的 adapter（Gemini/Claude）会透传给厂商参数
+	ThinkingBudget *int
+	// ReasoningSummary 推理摘要模式（auto/concise/detailed），覆盖 client 配置的默认值
+	ReasoningSummary string
+	// Seed 固定采样种子，支持的厂商（OpenAI 及部分兼容厂商）可获得更确定的输出
+	Seed *int
+	// NoCache 本次请求绕过响应缓存（不读也不写）
+	NoCache bool
+	// Store Responses API 是否允许 OpenAI 服务端保留本次请求数据，
+	// 覆盖 client 配置的默认值；nil 表示跟随配置
+	Store *bool
+	// Extra 原样合入出站请求体的厂商自定义参数；
+	// 属于高危透传，需 eino.allow_extra_params 开启后才生效
+	Extra map[string]any
+	// APIKey / BaseURL 单次请求的上游凭证覆盖（BYO key），仅用于构造一次性
+	// client；进入生成链路前会被剥离，需 eino.allow_user_keys 开启后才生效
+	APIKey  string
+	BaseURL string
 }
 
 // WithParams 创建请求参数选项
@@ -20,6 +40,24 @@ func WithParams(params *RequestParams) model.Option {
 		if params.Thinking != nil {
 			p.Thinking = params.Thinking
 		}
+		if params.ThinkingBudget != nil {
+			p.ThinkingBudget = params.ThinkingBudget
+		}
+		if params.ReasoningSummary != "" {
+			p.ReasoningSummary = params.ReasoningSummary
+		}
+		if params.Seed != nil {
+			p.Seed = params.Seed
+		}
+		if params.NoCache {
+			p.NoCache = true
+		}
+		if params.Store != nil {
+			p.Store = params.Store
+		}
+		if len(params.Extra) > 0 {
+			p.Extra = params.Extra
+		}
 	})
 }
 
@@ -28,7 +66,68 @@ func GetParams(opts ...model.Option) *RequestParams {
 	return model.GetImplSpecificOptions(&RequestParams{}, opts...)
 }
 
+// ThinkingDecision 集中处理请求级 thinking 开关的三态判定，各 adapter 共用：
+// 请求未显式设置、或该模型不支持 thinking 时 set=false，adapter 不注入任何选项；
+// 否则 set=true，enable 即请求的开/关
+func ThinkingDecision(params *RequestParams, supports bool) (enable, set bool) {
+	if params == nil || params.Thinking == nil || !supports {
+		return false, false
+	}
+	return *params.Thinking, true
+}
+
 // ChatModelProvider 聊天模型提供者接口
 type ChatModelProvider interface {
 	CreateChatModel(ctx context.Context, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error)
 }
+
+// ClientReloader 支持不重启进程热替换 client 配置的提供者（密钥轮换、新增厂商）
+type ClientReloader interface {
+	ReloadClients(cfg conf.Eino)
+}
+
+// ClientCredentials 单次请求的上游凭证覆盖（BYO key）。
+// 凭证只参与 client 构造，绝不写日志、不落库、不进缓存键
+type ClientCredentials struct {
+	APIKey  string
+	BaseURL string
+}
+
+// empty 是否未携带任何凭证
+func (c ClientCredentials) empty() bool { return c.APIKey == "" && c.BaseURL == "" }
+
+// FileUploader 支持把文件上传到厂商 /files 接口的提供者（Responses API 文件输入用）
+type FileUploader interface {
+	UploadFile(ctx context.Context, clientName, filename string, data []byte, purpose string) (string, error)
+}
+
+// CredentialOverrider 支持以请求提供的凭证构造一次性 ChatModel 的提供者
+type CredentialOverrider interface {
+	CreateChatModelWithCredentials(ctx context.Context, modelName string, cred ClientCredentials, opts ...model.Option) (model.ToolCallingChatModel, error)
+}
+
+// 路由决策来源（ClientResolution.Source 的取值）
+const (
+	ResolutionSourceExplicit = "explicit" // 请求显式指定 client 字段
+	ResolutionSourceOverride = "override" // model_overrides 精确匹配
+	ResolutionSourceKeyword  = "keyword"  // 模型名关键词匹配
+	ResolutionSourceDefault  = "default"  // 无任何匹配，兜底 client
+)
+
+// ClientResolution 一次模型 → client 路由决策的追踪信息，debug 模式下
+// 随响应返回，用于排查请求被路由到错误厂商的问题
+type ClientResolution struct {
+	// Client 最终路由到的 client 名称
+	Client string
+	// Source 决策来源，见 ResolutionSource* 常量
+	Source string
+	// Keyword Source 为 keyword 时命中的关键词
+	Keyword string
+	// Adapter 该路由是否会包装厂商 adapter
+	Adapter bool
+}
+
+// ClientResolver 能解释自身路由决策的提供者；MixedProvider 实现该接口
+type ClientResolver interface {
+	ResolveClient(modelName string) ClientResolution
+}
@@ -0,0 +1,128 @@
+package biz
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 上下文超限自动重试：未配置 model_capabilities（或估算偏差）时历史可能
+// 未被提前裁剪，上游会以 context_length_exceeded 类错误拒绝请求。此时
+// 自动丢弃最旧的历史消息后重试，而不是把硬错误直接抛给用户；重试次数
+// 由 context_retry_attempts 配置，默认 1 次。
+
+// defaultContextRetries 上下文超限后截断重试的默认次数
+const defaultContextRetries = 1
+
+// Chat executes a non-streaming chat. It returns the assistant response and the actual model name.
+// params carries per-request model knobs (thinking, budgets, reasoning summary); nil means defaults.
+// When the upstream rejects the request for exceeding the model's context
+// window, the oldest history is dropped and the call retried (bounded by
+// context_retry_attempts).
+func (uc *ChatUsecase) Chat(
+	ctx context.Context,
+	messages []*schema.Message,
+	reqModel string,
+	params *RequestParams,
+) (*schema.Message, string, error) {
+	result, modelName, err := uc.chatOnce(ctx, messages, reqModel, params)
+	for retries := uc.contextRetryBudget(); retries > 0 && isContextLengthError(err); retries-- {
+		trimmed, ok := dropOldestHistory(messages)
+		if !ok {
+			break
+		}
+		messages = trimmed
+		slog.Warn("context length exceeded, retrying with truncated history",
+			"model", modelName, "remaining_messages", len(messages))
+		result, modelName, err = uc.chatOnce(ctx, messages, reqModel, params)
+	}
+	return result, modelName, err
+}
+
+// ChatStream executes a streaming chat. It returns the complete assistant response and the actual model name.
+// params carries per-request model knobs (thinking, budgets, reasoning summary); nil means defaults.
+// Context-length rejections are retried like Chat; a history_truncated chunk
+// is emitted before each retry so clients can surface the truncation.
+func (uc *ChatUsecase) ChatStream(
+	ctx context.Context,
+	messages []*schema.Message,
+	reqModel string,
+	params *RequestParams,
+	onChunk StreamChunkCallback,
+) (*schema.Message, string, error) {
+	result, modelName, err := uc.chatStreamOnce(ctx, messages, reqModel, params, onChunk)
+	for retries := uc.contextRetryBudget(); retries > 0 && isContextLengthError(err); retries-- {
+		trimmed, ok := dropOldestHistory(messages)
+		if !ok {
+			break
+		}
+		messages = trimmed
+		slog.Warn("context length exceeded, retrying with truncated history",
+			"model", modelName, "remaining_messages", len(messages))
+		if onChunk != nil {
+			if cbErr := onChunk(StreamChunk{HistoryTruncated: true}); cbErr != nil {
+				return result, modelName, cbErr
+			}
+		}
+		result, modelName, err = uc.chatStreamOnce(ctx, messages, reqModel, params, onChunk)
+	}
+	return result, modelName, err
+}
+
+// contextRetryBudget 本次请求允许的截断重试次数
+func (uc *ChatUsecase) contextRetryBudget() int {
+	if uc.contextRetries <= 0 {
+		return defaultContextRetries
+	}
+	return uc.contextRetries
+}
+
+// contextLengthMarkers 各厂商没有统一的上下文超限错误码，按常见的
+// code / 消息片段匹配（OpenAI context_length_exceeded、Anthropic
+// prompt is too long 等）
+var contextLengthMarkers = []string{
+	"context_length_exceeded",
+	"context length",
+	"context window",
+	"prompt is too long",
+	"input is too long",
+	"too many tokens",
+}
+
+// isContextLengthError 判断上游错误是否属于"输入超出模型上下文窗口"
+func isContextLengthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, marker := range contextLengthMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropOldestHistory 丢弃最旧一半的中间历史，开头的 system 消息与最后
+// 一条消息（最新的用户输入）始终保留；被丢弃的工具调用对应的孤儿 tool
+// 结果一并丢弃。返回 false 表示已无可丢弃的消息
+func dropOldestHistory(messages []*schema.Message) ([]*schema.Message, bool) {
+	start := 0
+	for start < len(messages) && messages[start] != nil && messages[start].Role == schema.System {
+		start++
+	}
+	droppable := len(messages) - 1 - start
+	if droppable <= 0 {
+		return messages, false
+	}
+	drop := (droppable + 1) / 2
+	for start+drop < len(messages)-1 && messages[start+drop] != nil && messages[start+drop].Role == schema.Tool {
+		drop++
+	}
+	trimmed := make([]*schema.Message, 0, len(messages)-drop)
+	trimmed = append(trimmed, messages[:start]...)
+	trimmed = append(trimmed, messages[start+drop:]...)
+	return trimmed, true
+}
@@ -0,0 +1,182 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"devops-backend/internal/conf"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// contextLimitedModel 前 failures 次调用返回上下文超限错误，之后正常回复；
+// 记录每次调用收到的消息列表
+type contextLimitedModel struct {
+	calls    *[][]*schema.Message
+	failures *int
+	err      error
+}
+
+func (m contextLimitedModel) answer(messages []*schema.Message) (*schema.Message, error) {
+	*m.calls = append(*m.calls, messages)
+	if *m.failures > 0 {
+		*m.failures--
+		return nil, m.err
+	}
+	return &schema.Message{Role: schema.Assistant, Content: "ok"}, nil
+}
+
+func (m contextLimitedModel) Generate(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	return m.answer(messages)
+}
+
+func (m contextLimitedModel) Stream(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := m.answer(messages)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m contextLimitedModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type contextLimitedProvider struct {
+	model contextLimitedModel
+}
+
+func (p contextLimitedProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return p.model, nil
+}
+
+var errContextExceeded = errors.New(
+	"Request too large: this model's maximum context length is 8192 tokens, however you requested 9000 tokens")
+
+func newContextRetryUsecase(calls *[][]*schema.Message, failures int, cfg conf.Eino) *ChatUsecase {
+	remaining := failures
+	if cfg.DefaultModel == "" {
+		cfg.DefaultModel = "test-model"
+	}
+	return NewChatUsecase(contextLimitedProvider{model: contextLimitedModel{
+		calls:    calls,
+		failures: &remaining,
+		err:      errContextExceeded,
+	}}, cfg)
+}
+
+func contextRetryHistory() []*schema.Message {
+	return []*schema.Message{
+		schema.SystemMessage("keep me"),
+		schema.UserMessage("q1"),
+		schema.AssistantMessage("a1", nil),
+		schema.UserMessage("q2"),
+		schema.AssistantMessage("a2", nil),
+		schema.UserMessage("q3"),
+	}
+}
+
+// TestChat_RetriesOnContextLengthError 首次上游返回上下文超限时自动
+// 丢弃最旧历史重试；system 消息与最新的用户输入始终保留。
+func TestChat_RetriesOnContextLengthError(t *testing.T) {
+	var calls [][]*schema.Message
+	uc := newContextRetryUsecase(&calls, 1, conf.Eino{})
+
+	result, _, err := uc.Chat(context.Background(), contextRetryHistory(), "", nil)
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if result == nil || result.Content != "ok" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 model calls, got %d", len(calls))
+	}
+	if len(calls[1]) >= len(calls[0]) {
+		t.Errorf("retry must send fewer messages: first %d, retry %d", len(calls[0]), len(calls[1]))
+	}
+	retry := calls[1]
+	if retry[0].Role != schema.System || retry[0].Content != "keep me" {
+		t.Errorf("system prompt must survive truncation, got %#v", retry[0])
+	}
+	if last := retry[len(retry)-1]; last.Role != schema.User || last.Content != "q3" {
+		t.Errorf("latest user turn must survive truncation, got %#v", last)
+	}
+}
+
+// TestChat_ContextRetryBounded 重试次数受 context_retry_attempts 约束，
+// 上游持续超限时最终把错误抛给调用方。
+func TestChat_ContextRetryBounded(t *testing.T) {
+	var calls [][]*schema.Message
+	uc := newContextRetryUsecase(&calls, 10, conf.Eino{ContextRetryAttempts: 2})
+
+	if _, _, err := uc.Chat(context.Background(), contextRetryHistory(), "", nil); !isContextLengthError(err) {
+		t.Fatalf("expected context length error after exhausting retries, got %v", err)
+	}
+	if len(calls) != 3 {
+		t.Errorf("expected initial call + 2 retries, got %d calls", len(calls))
+	}
+}
+
+// TestChat_NoRetryOnOtherErrors 非上下文超限的上游错误不触发重试。
+func TestChat_NoRetryOnOtherErrors(t *testing.T) {
+	var calls [][]*schema.Message
+	remaining := 10
+	uc := NewChatUsecase(contextLimitedProvider{model: contextLimitedModel{
+		calls:    &calls,
+		failures: &remaining,
+		err:      errors.New("upstream unavailable"),
+	}}, conf.Eino{DefaultModel: "test-model"})
+
+	if _, _, err := uc.Chat(context.Background(), contextRetryHistory(), "", nil); err == nil {
+		t.Fatal("expected error to surface")
+	}
+	if len(calls) != 1 {
+		t.Errorf("expected no retry, got %d calls", len(calls))
+	}
+}
+
+// TestChatStream_ContextRetryEmitsTruncationNote 流式路径重试前下发
+// history_truncated chunk，让前端能提示历史被截断。
+func TestChatStream_ContextRetryEmitsTruncationNote(t *testing.T) {
+	var calls [][]*schema.Message
+	uc := newContextRetryUsecase(&calls, 1, conf.Eino{})
+
+	truncatedNotes := 0
+	content := ""
+	result, _, err := uc.ChatStream(context.Background(), contextRetryHistory(), "", nil, func(chunk StreamChunk) error {
+		if chunk.HistoryTruncated {
+			truncatedNotes++
+		}
+		content += chunk.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	if result == nil || result.Content != "ok" || content != "ok" {
+		t.Fatalf("unexpected result %#v (streamed %q)", result, content)
+	}
+	if truncatedNotes != 1 {
+		t.Errorf("expected exactly one history_truncated chunk, got %d", truncatedNotes)
+	}
+	if len(calls) != 2 {
+		t.Errorf("expected 2 model calls, got %d", len(calls))
+	}
+}
+
+// TestDropOldestHistory 无历史可丢时返回 false，避免空转重试。
+func TestDropOldestHistory(t *testing.T) {
+	if _, ok := dropOldestHistory([]*schema.Message{
+		schema.SystemMessage("sys"),
+		schema.UserMessage("q"),
+	}); ok {
+		t.Error("system + latest user turn must not be droppable")
+	}
+	trimmed, ok := dropOldestHistory(contextRetryHistory())
+	if !ok || len(trimmed) != 4 {
+		t.Fatalf("expected 4 messages after dropping oldest half, got %d (ok=%v)", len(trimmed), ok)
+	}
+}
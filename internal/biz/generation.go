@@ -0,0 +1,115 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrGenerationCanceled 生成被用户主动取消。
+var ErrGenerationCanceled = errors.New("generation canceled")
+
+// ErrNoActiveGeneration 该 session 当前没有进行中的生成。
+var ErrNoActiveGeneration = errors.New("no generation in flight for session")
+
+// ActiveGeneration describes one in-flight generation for operator visibility.
+type ActiveGeneration struct {
+	SessionID string
+	OwnerSub  string
+	Model     string
+	StartedAt time.Time
+}
+
+// generationEntry pairs the cancel func with the metadata shown to operators.
+type generationEntry struct {
+	cancel context.CancelCauseFunc
+	// token 区分同一 session 上先后登记的生成，release 只清理自己的条目
+	token uint64
+	info  ActiveGeneration
+}
+
+// TrackGeneration derives a cancelable context for an in-flight generation and
+// registers it under sessionID so CancelGeneration can stop it from another
+// request. ownerSub and model are kept as metadata for the admin listing. The
+// returned release func must be called when the generation ends.
+func (uc *ChatUsecase) TrackGeneration(ctx context.Context, sessionID, ownerSub, model string) (context.Context, func()) {
+	genCtx, cancel := context.WithCancelCause(ctx)
+
+	uc.genMu.Lock()
+	if uc.activeGenerations == nil {
+		uc.activeGenerations = make(map[string]generationEntry)
+	}
+	uc.genSeq++
+	token := uc.genSeq
+	uc.activeGenerations[sessionID] = generationEntry{
+		cancel: cancel,
+		token:  token,
+		info: ActiveGeneration{
+			SessionID: sessionID,
+			OwnerSub:  ownerSub,
+			Model:     model,
+			StartedAt: time.Now(),
+		},
+	}
+	uc.genMu.Unlock()
+
+	return genCtx, func() {
+		uc.genMu.Lock()
+		// 同一 session 上可能已有后来的生成覆盖登记，别把它的条目误删
+		if entry, ok := uc.activeGenerations[sessionID]; ok && entry.token == token {
+			delete(uc.activeGenerations, sessionID)
+		}
+		uc.genMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// CancelGeneration stops the in-flight generation for sessionID. It reports
+// whether one was actually in flight.
+func (uc *ChatUsecase) CancelGeneration(sessionID string) bool {
+	uc.genMu.Lock()
+	entry, ok := uc.activeGenerations[sessionID]
+	delete(uc.activeGenerations, sessionID)
+	uc.genMu.Unlock()
+
+	if ok {
+		entry.cancel(ErrGenerationCanceled)
+	}
+	return ok
+}
+
+// ListGenerations returns the in-flight generations, oldest first.
+func (uc *ChatUsecase) ListGenerations() []ActiveGeneration {
+	uc.genMu.Lock()
+	generations := make([]ActiveGeneration, 0, len(uc.activeGenerations))
+	for _, entry := range uc.activeGenerations {
+		generations = append(generations, entry.info)
+	}
+	uc.genMu.Unlock()
+
+	sort.Slice(generations, func(i, j int) bool {
+		if generations[i].StartedAt.Equal(generations[j].StartedAt) {
+			return generations[i].SessionID < generations[j].SessionID
+		}
+		return generations[i].StartedAt.Before(generations[j].StartedAt)
+	})
+	return generations
+}
+
+// CancelAllGenerations stops every in-flight generation (incident response when
+// an upstream is melting down) and returns how many were canceled.
+func (uc *ChatUsecase) CancelAllGenerations() int {
+	uc.genMu.Lock()
+	entries := make([]generationEntry, 0, len(uc.activeGenerations))
+	for _, entry := range uc.activeGenerations {
+		entries = append(entries, entry)
+	}
+	uc.activeGenerations = nil
+	uc.genMu.Unlock()
+
+	for _, entry := range entries {
+		entry.cancel(ErrGenerationCanceled)
+	}
+	return len(entries)
+}
@@ -0,0 +1,158 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// dribbleChatModel 先吐一个 chunk，之后挂起直到 ctx 取消
+type dribbleChatModel struct{}
+
+func (dribbleChatModel) Generate(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (dribbleChatModel) Stream(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		sw.Send(&schema.Message{Role: schema.Assistant, Content: "partial "}, nil)
+		<-ctx.Done()
+		sw.Send(nil, ctx.Err())
+		sw.Close()
+	}()
+	return sr, nil
+}
+
+func (m dribbleChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type dribbleProvider struct{}
+
+func (dribbleProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return dribbleChatModel{}, nil
+}
+
+func TestCancelGeneration(t *testing.T) {
+	uc := &ChatUsecase{}
+
+	genCtx, release := uc.TrackGeneration(context.Background(), "session-1", AnonymousOwner, "gpt-4o")
+	defer release()
+
+	if !uc.CancelGeneration("session-1") {
+		t.Fatal("expected cancel to find the in-flight generation")
+	}
+	if !errors.Is(context.Cause(genCtx), ErrGenerationCanceled) {
+		t.Fatalf("expected ErrGenerationCanceled cause, got %v", context.Cause(genCtx))
+	}
+	if uc.CancelGeneration("session-1") {
+		t.Fatal("second cancel must report no generation in flight")
+	}
+	if uc.CancelGeneration("other-session") {
+		t.Fatal("unknown session must report no generation in flight")
+	}
+}
+
+func TestChatStream_CancelReturnsPartialContent(t *testing.T) {
+	uc := &ChatUsecase{provider: dribbleProvider{}, defaultModel: "test-model"}
+
+	genCtx, release := uc.TrackGeneration(context.Background(), "session-1", AnonymousOwner, "gpt-4o")
+	defer release()
+
+	gotChunk := make(chan struct{})
+	var once bool
+	go func() {
+		<-gotChunk
+		uc.CancelGeneration("session-1")
+	}()
+
+	msg, _, err := uc.ChatStream(
+		genCtx,
+		[]*schema.Message{{Role: schema.User, Content: "hi"}},
+		"", nil,
+		func(StreamChunk) error {
+			if !once {
+				once = true
+				close(gotChunk)
+			}
+			return nil
+		},
+	)
+	if !errors.Is(err, ErrGenerationCanceled) {
+		t.Fatalf("expected ErrGenerationCanceled, got: %v", err)
+	}
+	if msg == nil || msg.Content != "partial " {
+		t.Fatalf("expected partial content to be returned, got %+v", msg)
+	}
+}
+
+func TestTrackGeneration_ReleaseRemovesEntry(t *testing.T) {
+	uc := &ChatUsecase{}
+
+	_, release := uc.TrackGeneration(context.Background(), "session-1", AnonymousOwner, "gpt-4o")
+	release()
+
+	if uc.CancelGeneration("session-1") {
+		t.Fatal("released generation must not be cancelable")
+	}
+
+	// release 之后重复调用应当无害
+	release()
+}
+
+func TestListGenerations(t *testing.T) {
+	uc := &ChatUsecase{}
+
+	if got := uc.ListGenerations(); len(got) != 0 {
+		t.Fatalf("expected empty listing, got %d entries", len(got))
+	}
+
+	_, release1 := uc.TrackGeneration(context.Background(), "session-1", "user-alice", "gpt-4o")
+	defer release1()
+	_, release2 := uc.TrackGeneration(context.Background(), "session-2", "user-bob", "gpt-4o-mini")
+	defer release2()
+
+	generations := uc.ListGenerations()
+	if len(generations) != 2 {
+		t.Fatalf("expected 2 in-flight generations, got %d", len(generations))
+	}
+	for _, g := range generations {
+		if g.StartedAt.IsZero() {
+			t.Errorf("generation %s has zero start time", g.SessionID)
+		}
+	}
+	if generations[0].OwnerSub != "user-alice" || generations[0].Model != "gpt-4o" {
+		t.Errorf("unexpected first entry: %+v", generations[0])
+	}
+
+	release1()
+	if got := uc.ListGenerations(); len(got) != 1 || got[0].SessionID != "session-2" {
+		t.Errorf("expected only session-2 after release, got %+v", got)
+	}
+}
+
+func TestCancelAllGenerations(t *testing.T) {
+	uc := &ChatUsecase{}
+
+	genCtx1, release1 := uc.TrackGeneration(context.Background(), "session-1", AnonymousOwner, "gpt-4o")
+	defer release1()
+	genCtx2, release2 := uc.TrackGeneration(context.Background(), "session-2", AnonymousOwner, "gpt-4o")
+	defer release2()
+
+	if n := uc.CancelAllGenerations(); n != 2 {
+		t.Fatalf("expected 2 canceled, got %d", n)
+	}
+	for _, genCtx := range []context.Context{genCtx1, genCtx2} {
+		if !errors.Is(context.Cause(genCtx), ErrGenerationCanceled) {
+			t.Fatalf("expected ErrGenerationCanceled cause, got %v", context.Cause(genCtx))
+		}
+	}
+	if n := uc.CancelAllGenerations(); n != 0 {
+		t.Fatalf("expected 0 canceled on second call, got %d", n)
+	}
+}
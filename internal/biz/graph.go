@@ -10,8 +10,10 @@ import (
 	"github.com/cloudwego/eino/schema"
 )
 
-// DebugGraphs 用于构建调试用的示例图
-type DebugGraphs struct{}
+// DebugGraphs 持有调试用的已编译图
+type DebugGraphs struct {
+	simpleChat compose.Runnable[*schema.Message, *schema.Message]
+}
 
 // NewDebugGraphs 创建并注册所有调试图
 func NewDebugGraphs(ctx context.Context, provider ChatModelProvider, cfg conf.Eino) (*DebugGraphs, error) {
@@ -24,21 +26,41 @@ func NewDebugGraphs(ctx context.Context, provider ChatModelProvider, cfg conf.Ei
 	return g, nil
 }
 
+// RunSimpleChat 通过已编译的 simple_chat 图执行一次对话，
+// 让 devops 可视化面板能观察到真实流量。
+func (g *DebugGraphs) RunSimpleChat(ctx context.Context, userMsg *schema.Message) (*schema.Message, error) {
+	return g.simpleChat.Invoke(ctx, userMsg)
+}
+
+// simpleChatState 单次运行的图本地状态，避免跨请求共享消息历史
+type simpleChatState struct {
+	messageHistory []*schema.Message
+}
+
 // buildSimpleChatGraph 构建简单聊天图
 func (g *DebugGraphs) buildSimpleChatGraph(ctx context.Context, provider ChatModelProvider, cfg conf.Eino) error {
-	var messageHistory []*schema.Message
-
-	graph := compose.NewGraph[*schema.Message, *schema.Message]()
+	graph := compose.NewGraph[*schema.Message, *schema.Message](
+		compose.WithGenLocalState(func(ctx context.Context) *simpleChatState {
+			return &simpleChatState{}
+		}),
+	)
 
-	// Lambda 节点：维护消息列表
+	// Lambda 节点：维护消息列表（历史存在图本地状态中，每次运行相互隔离）
 	lambda := compose.InvokableLambda(func(ctx context.Context, userMsg *schema.Message) ([]*schema.Message, error) {
-		messageHistory = append(messageHistory, userMsg)
+		var history []*schema.Message
+		if err := compose.ProcessState(ctx, func(_ context.Context, s *simpleChatState) error {
+			s.messageHistory = append(s.messageHistory, userMsg)
+			history = s.messageHistory
+			return nil
+		}); err != nil {
+			return nil, err
+		}
 
 		systemPrompt := &schema.Message{
 			Role:    schema.System,
 			Content: "你是一个友好的AI助手，请用简洁明了的方式回答用户的问题。",
 		}
-		return append([]*schema.Message{systemPrompt}, messageHistory...), nil
+		return append([]*schema.Message{systemPrompt}, history...), nil
 	})
 	if err := graph.AddLambdaNode("message_manager", lambda); err != nil {
 		return fmt.Errorf("add lambda node: %w", err)
@@ -65,10 +87,11 @@ func (g *DebugGraphs) buildSimpleChatGraph(ctx context.Context, provider ChatMod
 	}
 
 	// 编译
-	_, err = graph.Compile(ctx, compose.WithGraphName("simple_chat"))
+	runnable, err := graph.Compile(ctx, compose.WithGraphName("simple_chat"))
 	if err != nil {
 		return fmt.Errorf("compile graph: %w", err)
 	}
+	g.simpleChat = runnable
 
 	return nil
 }
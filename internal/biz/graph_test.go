@@ -0,0 +1,79 @@
+package biz
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"devops-backend/internal/conf"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// echoChatModel 把收到的全部消息拼接返回，便于断言模型看到了哪些消息
+type echoChatModel struct{}
+
+func (echoChatModel) Generate(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	var parts []string
+	for _, msg := range messages {
+		parts = append(parts, fmt.Sprintf("%s:%s", msg.Role, msg.Content))
+	}
+	return &schema.Message{Role: schema.Assistant, Content: strings.Join(parts, "|")}, nil
+}
+
+func (echoChatModel) Stream(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := echoChatModel{}.Generate(ctx, messages, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg}), nil
+}
+
+func (m echoChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type echoProvider struct{}
+
+func (echoProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return echoChatModel{}, nil
+}
+
+func TestSimpleChatGraph_ConcurrentRunsAreIsolated(t *testing.T) {
+	ctx := context.Background()
+	graphs, err := NewDebugGraphs(ctx, echoProvider{}, conf.Eino{DefaultModel: "test-model"})
+	if err != nil {
+		t.Fatalf("NewDebugGraphs failed: %v", err)
+	}
+
+	const runs = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, runs)
+
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			content := fmt.Sprintf("message-%d", i)
+			resp, err := graphs.RunSimpleChat(ctx, &schema.Message{Role: schema.User, Content: content})
+			if err != nil {
+				errs <- fmt.Errorf("run %d: %v", i, err)
+				return
+			}
+			// 每次运行应只看到 system prompt + 自己的消息
+			want := "system:你是一个友好的AI助手，请用简洁明了的方式回答用户的问题。|user:" + content
+			if resp.Content != want {
+				errs <- fmt.Errorf("run %d saw foreign history: %q", i, resp.Content)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
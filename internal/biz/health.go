@@ -0,0 +1,21 @@
+package biz
+
+import "time"
+
+// ClientHealth 单个 client 的最近一次探活结果
+type ClientHealth struct {
+	Client    string    `json:"client"`
+	Healthy   bool      `json:"healthy"`
+	LatencyMs int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+	// Error 失败原因，健康时为空
+	Error string `json:"error,omitempty"`
+}
+
+// ClientHealthReporter 后台探活结果的查询接口（由 provider 层实现）
+type ClientHealthReporter interface {
+	// ClientHealthSnapshot 返回所有已探测 client 的最近结果
+	ClientHealthSnapshot() []ClientHealth
+	// IsClientHealthy 判断 client 是否可用；未探测过的 client 视为可用
+	IsClientHealthy(name string) bool
+}
@@ -0,0 +1,58 @@
+package biz
+
+import (
+	"context"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// modelTestTimeout 单次模型连通性测试的超时；排障端点必须快速失败，
+// 不能挂在无响应的上游上
+const modelTestTimeout = 15 * time.Second
+
+// ModelTestResult 一次模型连通性测试的结果
+type ModelTestResult struct {
+	Client  string        // 路由到的 client 名称（provider 无法解释路由时为空）
+	Model   string        // 实际请求的模型
+	Adapter bool          // 该路由是否包装了厂商 adapter
+	Latency time.Duration // 上游往返耗时
+	Output  string        // 模型返回的内容
+}
+
+// TestModel issues a minimal one-token generation against the resolved
+// client so operators can diagnose credential or connectivity problems
+// without going through the full chat flow. The routing trace is filled in
+// even when the upstream call fails; the upstream error is returned
+// verbatim alongside the partial result.
+func (uc *ChatUsecase) TestModel(ctx context.Context, client, reqModel string) (*ModelTestResult, error) {
+	// 只给 client 时用该 client 的默认模型，让 {client} 一个字段就能测连通性
+	if reqModel == "" && client != "" {
+		reqModel = uc.ClientDefaultModel(client)
+	}
+	modelName := uc.resolveModel(reqModel)
+
+	result := &ModelTestResult{Model: modelName}
+	if res := uc.ExplainClientResolution(modelName); res != nil {
+		result.Client = res.Client
+		result.Adapter = res.Adapter
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, modelTestTimeout)
+	defer cancel()
+
+	chatModel, err := uc.provider.CreateChatModel(ctx, modelName)
+	if err != nil {
+		return result, err
+	}
+
+	start := time.Now()
+	resp, err := chatModel.Generate(ctx, []*schema.Message{schema.UserMessage("ping")}, model.WithMaxTokens(1))
+	result.Latency = time.Since(start)
+	if err != nil {
+		return result, err
+	}
+	result.Output = resp.Content
+	return result, nil
+}
@@ -0,0 +1,26 @@
+package biz
+
+import (
+	"github.com/cloudwego/eino/schema"
+)
+
+// ResolveOutboundPrompt runs the full message-assembly pipeline — redaction,
+// context-budget trimming, multimodal preparation and instruction injection —
+// and returns the exact message list a chat call would send upstream, without
+// calling the model. Vendor-specific rewrites applied by client adapters at
+// request time (e.g. multimodal placeholder substitution) are not included.
+func (uc *ChatUsecase) ResolveOutboundPrompt(messages []*schema.Message, reqModel string) ([]*schema.Message, string, error) {
+	modelName := uc.resolveModel(reqModel)
+	if err := uc.checkModelAllowed(modelName); err != nil {
+		return nil, "", err
+	}
+	messages = uc.redactOutbound(messages)
+	messages = uc.trimToContextBudget(messages, modelName)
+	prepared := prepareMessagesForModel(messages)
+	prepared, instruction := takeSystemInstruction(prepared)
+	if instruction == "" {
+		instruction = chatAgentInstruction
+	}
+	// adk 实际调用时会把 agent 指令前置为 system 消息，这里同样还原
+	return append([]*schema.Message{schema.SystemMessage(instruction)}, prepared...), modelName, nil
+}
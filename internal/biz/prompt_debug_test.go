@@ -0,0 +1,71 @@
+package biz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// TestResolveOutboundPrompt_InjectsInstruction 组装结果以 agent 指令开头：
+// 历史里带对话级 system 消息时用它覆盖，否则用全局默认指令。
+func TestResolveOutboundPrompt_InjectsInstruction(t *testing.T) {
+	uc := &ChatUsecase{defaultModel: "mystery-model"}
+
+	custom, _, err := uc.ResolveOutboundPrompt([]*schema.Message{
+		schema.SystemMessage("be brief"),
+		schema.UserMessage("hi"),
+	}, "")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if len(custom) != 2 || custom[0].Role != schema.System || custom[0].Content != "be brief" {
+		t.Errorf("custom instruction must lead the prompt, got %#v", custom[0])
+	}
+
+	plain, _, err := uc.ResolveOutboundPrompt([]*schema.Message{schema.UserMessage("hi")}, "")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if len(plain) != 2 || plain[0].Role != schema.System || plain[0].Content != chatAgentInstruction {
+		t.Errorf("default instruction must lead the prompt, got %#v", plain[0])
+	}
+}
+
+// TestResolveOutboundPrompt_FiltersAndTrims 多模态准备与上下文裁剪都
+// 体现在返回的消息列表里：非用户消息的多模态部分被拍平，超预算的
+// 最旧消息被丢弃。
+func TestResolveOutboundPrompt_FiltersAndTrims(t *testing.T) {
+	old := schema.UserMessage(strings.Repeat("x", 400))
+	flattened := &schema.Message{
+		Role: schema.Assistant,
+		UserInputMultiContent: []schema.MessageInputPart{
+			{Type: schema.ChatMessagePartTypeText, Text: "tool summary"},
+		},
+	}
+	latest := schema.UserMessage("latest question")
+
+	budget := trimOverhead() + msgCost(flattened) + msgCost(latest) + 1
+	uc := &ChatUsecase{
+		defaultModel: "mystery-model",
+		modelLimits:  fakeContextLimits{"mystery-model": budget},
+	}
+
+	got, modelName, err := uc.ResolveOutboundPrompt([]*schema.Message{old, flattened, latest}, "")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if modelName != "mystery-model" {
+		t.Errorf("unexpected model name %q", modelName)
+	}
+	// 指令 + 拍平的助手消息 + 最新用户消息；超预算的最旧消息已被裁掉
+	if len(got) != 3 {
+		t.Fatalf("expected 3 messages after trimming, got %d", len(got))
+	}
+	if got[1].Content != "tool summary" || got[1].UserInputMultiContent != nil {
+		t.Errorf("assistant multimodal parts must be flattened to text, got %#v", got[1])
+	}
+	if got[2].Content != "latest question" {
+		t.Errorf("latest user turn must be preserved, got %#v", got[2])
+	}
+}
@@ -0,0 +1,105 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrQuotaExceeded 用户在当前窗口内的 token 配额已用完。
+var ErrQuotaExceeded = errors.New("token quota exceeded")
+
+// QuotaWindowDaily / QuotaWindowMonthly 配额重置窗口
+const (
+	QuotaWindowDaily   = "daily"
+	QuotaWindowMonthly = "monthly"
+)
+
+// QuotaStore persists per-user token usage and per-user quota overrides.
+// Usage rows are keyed by (owner, period) so windows reset naturally when the
+// period key rolls over.
+type QuotaStore interface {
+	// AddTokenUsage 累加用户在指定窗口期的 token 用量
+	AddTokenUsage(ownerSub, period string, tokens int64) error
+	// GetTokenUsage 查询用户在指定窗口期的累计 token 用量，无记录返回 0
+	GetTokenUsage(ownerSub, period string) (int64, error)
+	// SetQuotaOverride 设置用户级配额覆盖；quota <= 0 表示删除覆盖回退全局值
+	SetQuotaOverride(ownerSub string, quota int64) error
+	// GetQuotaOverride 查询用户级配额覆盖，第二个返回值表示是否存在
+	GetQuotaOverride(ownerSub string) (int64, bool, error)
+}
+
+// SetTokenQuota wires quota enforcement: store persists usage, limit is the
+// global per-user cap (0 disables unless a per-user override exists), window
+// is daily or monthly (default monthly).
+func (uc *ChatUsecase) SetTokenQuota(store QuotaStore, limit int64, window string) {
+	uc.quotaStore = store
+	uc.quotaLimit = limit
+	uc.quotaWindow = window
+}
+
+// SetQuotaOverride 设置用户级配额覆盖（管理端热更新入口）
+func (uc *ChatUsecase) SetQuotaOverride(ownerSub string, quota int64) error {
+	if uc.quotaStore == nil {
+		return fmt.Errorf("token quota store is not configured")
+	}
+	return uc.quotaStore.SetQuotaOverride(ownerSub, quota)
+}
+
+// quotaPeriod 当前窗口的期键：daily → 2006-01-02，monthly → 2006-01
+func quotaPeriod(window string, now time.Time) string {
+	if window == QuotaWindowDaily {
+		return now.UTC().Format("2006-01-02")
+	}
+	return now.UTC().Format("2006-01")
+}
+
+// checkTokenQuota rejects the request when the caller has exhausted the token
+// quota for the current window. A per-user override wins over the global
+// limit; no store or no effective limit means no enforcement.
+func (uc *ChatUsecase) checkTokenQuota(ctx context.Context) error {
+	if uc.quotaStore == nil {
+		return nil
+	}
+	owner := OwnerSubFromContext(ctx)
+	limit := uc.quotaLimit
+	if override, ok, err := uc.quotaStore.GetQuotaOverride(owner); err == nil && ok {
+		limit = override
+	}
+	if limit <= 0 {
+		return nil
+	}
+	used, err := uc.quotaStore.GetTokenUsage(owner, quotaPeriod(uc.quotaWindow, time.Now()))
+	if err != nil {
+		// 配额读取失败不阻断生成，只可能少算
+		return nil
+	}
+	if used >= limit {
+		return fmt.Errorf("%w: used %d of %d tokens this %s", ErrQuotaExceeded, used, limit, quotaWindowName(uc.quotaWindow))
+	}
+	return nil
+}
+
+// recordTokenUsage accumulates the response usage against the caller's
+// current window. Best-effort: persistence failures never fail the chat.
+func (uc *ChatUsecase) recordTokenUsage(ctx context.Context, msg *schema.Message) {
+	if uc.quotaStore == nil || msg == nil || msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil {
+		return
+	}
+	total := int64(msg.ResponseMeta.Usage.TotalTokens)
+	if total <= 0 {
+		return
+	}
+	owner := OwnerSubFromContext(ctx)
+	_ = uc.quotaStore.AddTokenUsage(owner, quotaPeriod(uc.quotaWindow, time.Now()), total)
+}
+
+func quotaWindowName(window string) string {
+	if window == QuotaWindowDaily {
+		return "day"
+	}
+	return "month"
+}
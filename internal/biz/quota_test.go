@@ -0,0 +1,148 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"devops-backend/internal/conf"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeQuotaStore 内存版 QuotaStore，按 (owner, period) 累计
+type fakeQuotaStore struct {
+	usage     map[string]int64
+	overrides map[string]int64
+}
+
+func newFakeQuotaStore() *fakeQuotaStore {
+	return &fakeQuotaStore{usage: map[string]int64{}, overrides: map[string]int64{}}
+}
+
+func (s *fakeQuotaStore) AddTokenUsage(ownerSub, period string, tokens int64) error {
+	s.usage[ownerSub+"/"+period] += tokens
+	return nil
+}
+
+func (s *fakeQuotaStore) GetTokenUsage(ownerSub, period string) (int64, error) {
+	return s.usage[ownerSub+"/"+period], nil
+}
+
+func (s *fakeQuotaStore) SetQuotaOverride(ownerSub string, quota int64) error {
+	if quota <= 0 {
+		delete(s.overrides, ownerSub)
+		return nil
+	}
+	s.overrides[ownerSub] = quota
+	return nil
+}
+
+func (s *fakeQuotaStore) GetQuotaOverride(ownerSub string) (int64, bool, error) {
+	quota, ok := s.overrides[ownerSub]
+	return quota, ok, nil
+}
+
+func usageMessage(total int) *schema.Message {
+	return &schema.Message{
+		Role: schema.Assistant,
+		ResponseMeta: &schema.ResponseMeta{
+			Usage: &schema.TokenUsage{TotalTokens: total},
+		},
+	}
+}
+
+func TestCheckTokenQuota_TripsAtGlobalLimit(t *testing.T) {
+	uc := NewChatUsecase(nil, conf.Eino{})
+	store := newFakeQuotaStore()
+	uc.SetTokenQuota(store, 100, QuotaWindowMonthly)
+	ctx := context.Background()
+
+	if err := uc.checkTokenQuota(ctx); err != nil {
+		t.Fatalf("expected no error under the limit, got %v", err)
+	}
+
+	// 累计到限额后应拒绝
+	uc.recordTokenUsage(ctx, usageMessage(60))
+	uc.recordTokenUsage(ctx, usageMessage(40))
+	err := uc.checkTokenQuota(ctx)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+}
+
+func TestCheckTokenQuota_OverrideWinsOverGlobal(t *testing.T) {
+	uc := NewChatUsecase(nil, conf.Eino{})
+	store := newFakeQuotaStore()
+	uc.SetTokenQuota(store, 50, QuotaWindowMonthly)
+	ctx := context.Background()
+
+	uc.recordTokenUsage(ctx, usageMessage(80))
+	if err := uc.checkTokenQuota(ctx); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected global limit to trip, got %v", err)
+	}
+
+	// 用户级覆盖放宽后放行
+	if err := uc.SetQuotaOverride(AnonymousOwner, 1000); err != nil {
+		t.Fatalf("failed to set override: %v", err)
+	}
+	if err := uc.checkTokenQuota(ctx); err != nil {
+		t.Fatalf("expected override to lift the limit, got %v", err)
+	}
+
+	// 覆盖收紧后再次拒绝
+	if err := uc.SetQuotaOverride(AnonymousOwner, 10); err != nil {
+		t.Fatalf("failed to set override: %v", err)
+	}
+	if err := uc.checkTokenQuota(ctx); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected tightened override to trip, got %v", err)
+	}
+}
+
+func TestCheckTokenQuota_NoStoreOrNoLimitSkips(t *testing.T) {
+	// 未接入存储：不做任何限制
+	uc := NewChatUsecase(nil, conf.Eino{})
+	if err := uc.checkTokenQuota(context.Background()); err != nil {
+		t.Fatalf("expected no enforcement without a store, got %v", err)
+	}
+
+	// 全局限额为 0 且无覆盖：同样放行
+	uc = NewChatUsecase(nil, conf.Eino{})
+	store := newFakeQuotaStore()
+	uc.SetTokenQuota(store, 0, QuotaWindowMonthly)
+	ctx := context.Background()
+	uc.recordTokenUsage(ctx, usageMessage(1 << 20))
+	if err := uc.checkTokenQuota(ctx); err != nil {
+		t.Fatalf("expected no enforcement with quota 0, got %v", err)
+	}
+}
+
+func TestRecordTokenUsage_IgnoresMissingUsage(t *testing.T) {
+	uc := NewChatUsecase(nil, conf.Eino{})
+	store := newFakeQuotaStore()
+	uc.SetTokenQuota(store, 100, QuotaWindowMonthly)
+	ctx := context.Background()
+
+	uc.recordTokenUsage(ctx, nil)
+	uc.recordTokenUsage(ctx, &schema.Message{Role: schema.Assistant, Content: "no meta"})
+	uc.recordTokenUsage(ctx, usageMessage(0))
+
+	if len(store.usage) != 0 {
+		t.Fatalf("expected no usage recorded, got %v", store.usage)
+	}
+}
+
+func TestQuotaPeriod_WindowKeys(t *testing.T) {
+	now := time.Date(2026, 8, 29, 10, 0, 0, 0, time.UTC)
+	if got := quotaPeriod(QuotaWindowDaily, now); got != "2026-08-29" {
+		t.Fatalf("daily period: got %q", got)
+	}
+	if got := quotaPeriod(QuotaWindowMonthly, now); got != "2026-08" {
+		t.Fatalf("monthly period: got %q", got)
+	}
+	// 未配置窗口默认按月
+	if got := quotaPeriod("", now); got != "2026-08" {
+		t.Fatalf("default period: got %q", got)
+	}
+}
@@ -0,0 +1,56 @@
+package biz
+
+import (
+	"encoding/json"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ReasoningItemsExtraKey 消息 Extra 中存放 Responses API reasoning 项的键。
+// o 系列模型的推理项（item ID + 加密推理内容）通过该键随消息持久化，
+// 续轮时由 adapter 原样回放，避免为同样的思考再付一次费
+const ReasoningItemsExtraKey = "reasoning_items"
+
+// ReasoningItem Responses API 返回的 reasoning 输出项；EncryptedContent
+// 只有在 store=false 且请求了 reasoning.encrypted_content 时才会出现
+type ReasoningItem struct {
+	ID               string `json:"id"`
+	EncryptedContent string `json:"encrypted_content,omitempty"`
+}
+
+// ReasoningItemsFromMessage extracts reasoning items stored in msg.Extra.
+// Like annotations, it accepts both typed []ReasoningItem values and raw
+// JSON-decoded forms, since Extra round-trips through JSON persistence.
+func ReasoningItemsFromMessage(msg *schema.Message) []ReasoningItem {
+	if msg == nil || msg.Extra == nil {
+		return nil
+	}
+	raw, ok := msg.Extra[ReasoningItemsExtraKey]
+	if !ok {
+		return nil
+	}
+	if typed, ok := raw.([]ReasoningItem); ok {
+		return typed
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+	var items []ReasoningItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil
+	}
+	return items
+}
+
+// SetMessageReasoningItems stores reasoning items into msg.Extra. Exported
+// because the provider layer attaches captured items to inbound messages.
+func SetMessageReasoningItems(msg *schema.Message, items []ReasoningItem) {
+	if len(items) == 0 {
+		return
+	}
+	if msg.Extra == nil {
+		msg.Extra = make(map[string]any)
+	}
+	msg.Extra[ReasoningItemsExtraKey] = items
+}
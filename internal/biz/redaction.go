@@ -0,0 +1,68 @@
+package biz
+
+import (
+	"log/slog"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Redactor 敏感内容脱敏器。实现必须无副作用且可并发调用。
+type Redactor interface {
+	// Redact 返回脱敏后的文本与命中次数
+	Redact(text string) (string, int)
+}
+
+// SetRedactor wires a redactor into the storage path: every message is
+// redacted (on a copy) right before it is appended, so secrets never reach
+// the database while the in-flight model call still sees the original.
+func (uc *SessionUsecase) SetRedactor(r Redactor) {
+	uc.redactor = r
+}
+
+// SetRedactor wires a redactor into the outbound path: messages are redacted
+// (on copies) before they are sent upstream. Use this in deployments where
+// secrets must not leave the server at all.
+func (uc *ChatUsecase) SetRedactor(r Redactor) {
+	uc.redactor = r
+}
+
+// redactOutbound redacts all outbound messages before the upstream call.
+// Returns the input untouched when no redactor is configured or nothing hits.
+func (uc *ChatUsecase) redactOutbound(messages []*schema.Message) []*schema.Message {
+	if uc.redactor == nil {
+		return messages
+	}
+	var redacted []*schema.Message
+	for i, msg := range messages {
+		clone, hits := redactMessage(uc.redactor, msg)
+		if hits == 0 {
+			continue
+		}
+		if redacted == nil {
+			redacted = append([]*schema.Message(nil), messages...)
+		}
+		redacted[i] = clone
+		slog.Info("redaction applied to outbound message", "role", msg.Role, "hits", hits)
+	}
+	if redacted == nil {
+		return messages
+	}
+	return redacted
+}
+
+// redactMessage 在副本上脱敏消息正文与推理内容；hits 为 0 时返回原消息
+func redactMessage(r Redactor, msg *schema.Message) (*schema.Message, int) {
+	if msg == nil {
+		return msg, 0
+	}
+	content, contentHits := r.Redact(msg.Content)
+	reasoning, reasoningHits := r.Redact(msg.ReasoningContent)
+	hits := contentHits + reasoningHits
+	if hits == 0 {
+		return msg, 0
+	}
+	clone := *msg
+	clone.Content = content
+	clone.ReasoningContent = reasoning
+	return &clone, hits
+}
@@ -0,0 +1,83 @@
+package biz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// markRedactor 把 SECRET 替换为 [X]，便于断言命中路径
+type markRedactor struct{}
+
+func (markRedactor) Redact(text string) (string, int) {
+	count := strings.Count(text, "SECRET")
+	return strings.ReplaceAll(text, "SECRET", "[X]"), count
+}
+
+// capturingRepo 记录最后一条落库消息
+type capturingRepo struct {
+	SessionRepo
+	lastMsg *schema.Message
+}
+
+func (r *capturingRepo) AppendMessage(_ string, msg *schema.Message, _ string, _ bool) (int64, error) {
+	r.lastMsg = msg
+	return 1, nil
+}
+
+func TestAppendMessage_RedactsStoredCopyOnly(t *testing.T) {
+	repo := &capturingRepo{}
+	uc := NewSessionUsecase(repo)
+	uc.SetRedactor(markRedactor{})
+
+	original := &schema.Message{Role: schema.User, Content: "the key is SECRET", ReasoningContent: "SECRET too"}
+	if _, err := uc.AppendMessage("s1", original, "", false); err != nil {
+		t.Fatalf("AppendMessage failed: %v", err)
+	}
+
+	if repo.lastMsg.Content != "the key is [X]" || repo.lastMsg.ReasoningContent != "[X] too" {
+		t.Errorf("stored message not redacted: %+v", repo.lastMsg)
+	}
+	// 调用方持有的原消息不受影响（模型调用仍看到原文）
+	if original.Content != "the key is SECRET" || original.ReasoningContent != "SECRET too" {
+		t.Errorf("original message mutated: %+v", original)
+	}
+}
+
+func TestAppendMessage_NoRedactorPassesThrough(t *testing.T) {
+	repo := &capturingRepo{}
+	uc := NewSessionUsecase(repo)
+
+	msg := &schema.Message{Role: schema.User, Content: "has SECRET"}
+	if _, err := uc.AppendMessage("s1", msg, "", false); err != nil {
+		t.Fatalf("AppendMessage failed: %v", err)
+	}
+	if repo.lastMsg != msg {
+		t.Error("without a redactor the message must pass through unchanged")
+	}
+}
+
+func TestRedactOutbound_CopiesOnlyOnHit(t *testing.T) {
+	uc := &ChatUsecase{redactor: markRedactor{}}
+
+	clean := []*schema.Message{{Role: schema.User, Content: "nothing here"}}
+	if got := uc.redactOutbound(clean); &got[0] != &clean[0] || got[0] != clean[0] {
+		t.Error("clean messages must be returned untouched")
+	}
+
+	dirty := []*schema.Message{
+		{Role: schema.System, Content: "clean"},
+		{Role: schema.User, Content: "leak SECRET"},
+	}
+	got := uc.redactOutbound(dirty)
+	if got[1].Content != "leak [X]" {
+		t.Errorf("outbound message not redacted: %q", got[1].Content)
+	}
+	if dirty[1].Content != "leak SECRET" {
+		t.Errorf("input slice mutated: %q", dirty[1].Content)
+	}
+	if got[0] != dirty[0] {
+		t.Error("messages without hits must be shared, not cloned")
+	}
+}
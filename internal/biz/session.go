@@ -1,14 +1,32 @@
 package biz
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"time"
 
+	"devops-backend/internal/auth"
+
 	"github.com/cloudwego/eino/schema"
 )
 
 var ErrSessionNotFound = errors.New("session not found")
 var ErrTreeNotFound = errors.New("session tree not found")
+var ErrMessageNotFound = errors.New("message not found")
+var ErrTreeTooLarge = errors.New("conversation tree too large")
+
+// AnonymousOwner 认证关闭时所有会话共享的 owner
+const AnonymousOwner = "anonymous"
+
+// OwnerSubFromContext 从请求上下文取会话归属者（OIDC sub），
+// 未认证时回落到共享的 anonymous owner
+func OwnerSubFromContext(ctx context.Context) string {
+	if user, err := auth.GetUserFromContext(ctx); err == nil && user.Sub != "" {
+		return user.Sub
+	}
+	return AnonymousOwner
+}
 
 // Session 会话消息集合（完整对话链）
 type Session []*ChatResponse
@@ -19,31 +37,89 @@ type SessionTreeInfo struct {
 	Title               string // 第一条用户消息前15字
 	LastActiveSessionID string // 最后活跃的 session
 	LastMessage         string // 最新消息内容
+	Pinned              bool            // 是否置顶（列表中排在最前）
+	Metadata            json.RawMessage // 集成方自定义数据（后端不解释），nil 表示未设置
 	CreatedAt           time.Time
 	UpdatedAt           time.Time
+	DeletedAt           time.Time // 软删除时间，零值表示未删除
+}
+
+// SessionBranchInfo 树内单个分支 session 的元信息（分支切换器用）
+type SessionBranchInfo struct {
+	ID           string // session_id
+	MessageCount int    // 本分支自身的消息数（不含祖先分支）
+	ForkAnchor   int64  // 分支点（父消息 ID），0 表示根 session
+	CreatedAt    time.Time
 }
 
-// SessionRepo 会话仓库接口
+// SessionRepo 会话仓库接口。
+// 带 ownerSub 参数的方法按归属者过滤，归属不匹配与不存在同样返回
+// not found（避免向其他用户泄露会话是否存在）。
 type SessionRepo interface {
-	// NewConversation 创建新对话（tree + 首个 session）
-	NewConversation() (treeID, sessionID string)
+	// NewConversation 创建新对话（tree + 首个 session），归属 ownerSub
+	NewConversation(ownerSub string) (treeID, sessionID string)
 	// CreateBranchWithMessage 创建分支并追加首条消息（同一 tree 下新建 session）
 	CreateBranchWithMessage(parentMsgID int64, msg *schema.Message) (sessionID string, msgID int64, err error)
-	// SessionExists 检查 session 是否存在
-	SessionExists(sessionID string) bool
+	// CreateBranchFrom 从指定消息创建空分支（时间漫游），消息必须属于 treeID
+	CreateBranchFrom(parentMsgID int64, treeID string) (sessionID string, err error)
+	// SessionExists 检查 session 是否存在且归属 ownerSub
+	SessionExists(sessionID, ownerSub string) bool
 	// GetTreeID 获取 session 所属的 tree_id
-	GetTreeID(sessionID string) (string, error)
+	GetTreeID(sessionID, ownerSub string) (string, error)
 	// GetLastActiveSessionID 获取 tree 的最后活跃 session_id
-	GetLastActiveSessionID(treeID string) (string, error)
+	GetLastActiveSessionID(treeID, ownerSub string) (string, error)
 	// GetSessionMessages 获取 session 的完整消息链（包含祖先消息）
-	GetSessionMessages(sessionID string) Session
-	// AppendMessage 追加消息到 session，返回新消息 ID
+	GetSessionMessages(sessionID, ownerSub string) Session
+	// GetSessionMessagesPage 沿线性链分页读取消息（游标 afterID 之后的 limit 条），
+	// 不整棵加载；limit <= 0 表示不限制
+	GetSessionMessagesPage(sessionID, ownerSub string, afterID int64, limit int) ([]*ChatResponse, error)
+	// CountTreeMessages 统计 session 所属 tree 的消息总数（含所有分支）
+	CountTreeMessages(sessionID string) (int, error)
+	// AppendMessage 追加消息到 session，返回新消息 ID；partial 标记部分保存（流被中断）
 	// model: 使用的模型名，用户消息传空字符串
-	AppendMessage(sessionID string, msg *schema.Message, model string) (int64, error)
-	// DeleteTree 删除整个对话树（级联删除 sessions 和 messages）
-	DeleteTree(treeID string)
-	// ListTrees 列出所有对话树
-	ListTrees() ([]SessionTreeInfo, error)
+	AppendMessage(sessionID string, msg *schema.Message, model string, partial bool) (int64, error)
+	// GetLastMessage 获取 session 中最后一条消息及其 ID
+	GetLastMessage(sessionID, ownerSub string) (int64, *ChatResponse, error)
+	// GetMessage 按 ID 获取单条消息，归属不匹配或不存在返回 ErrMessageNotFound
+	GetMessage(msgID int64, ownerSub string) (*ChatResponse, error)
+	// UpdateMessage 原地覆盖已存储的消息内容（用于续写合并等场景）
+	UpdateMessage(msgID int64, msg *schema.Message) error
+	// UpdateStreamedMessage 流式增量落库专用：覆盖消息内容并同步更新
+	// model 与 partial 标记（生成中为 partial，流正常结束时清除）
+	UpdateStreamedMessage(msgID int64, msg *schema.Message, model string, partial bool) error
+	// DeleteMessage 删除消息及其所有后代（含其他分支中引用它的消息链）
+	DeleteMessage(msgID int64) error
+	// DeleteTree 软删除对话树（移入回收站）
+	DeleteTree(treeID, ownerSub string) error
+	// DeleteTrees 在单个事务中批量软删除对话树，返回每个 ID 的结果（nil 表示已删除）
+	DeleteTrees(treeIDs []string, ownerSub string) (map[string]error, error)
+	// RestoreTree 从回收站恢复对话树
+	RestoreTree(treeID, ownerSub string) error
+	// SetPinned 置顶/取消置顶对话树
+	SetPinned(treeID, ownerSub string, pinned bool) error
+	// SetTreeTitle 手动设置对话树标题；手动标题不会被自动生成覆盖
+	SetTreeTitle(treeID, ownerSub, title string) error
+	// SetTreeMetadata 设置对话树的自定义元数据（后端不解释内容），meta 为空清空
+	SetTreeMetadata(treeID, ownerSub string, meta json.RawMessage) error
+	// SetTreeSystemPrompt 设置对话树级自定义指令，每轮对话都注入；空串清空
+	SetTreeSystemPrompt(treeID, ownerSub, prompt string) error
+	// GetTreeSystemPrompt 读取对话树级自定义指令，未设置时返回空串
+	GetTreeSystemPrompt(treeID, ownerSub string) (string, error)
+	// SetGeneratedTitle 写入自动生成的标题；仅当现有标题仍是首条消息截断产物时生效，
+	// 返回是否更新成功
+	SetGeneratedTitle(treeID, ownerSub, title string) (bool, error)
+	// PurgeTree 硬删除整个对话树（级联删除 sessions 和 messages）
+	PurgeTree(treeID string) error
+	// PurgeTreesDeletedBefore 硬删除在 cutoff 之前被软删除的对话树（跨所有用户），返回清除数量
+	PurgeTreesDeletedBefore(cutoff time.Time) (int, error)
+	// ListTrees 列出 ownerSub 的所有未删除对话树
+	ListTrees(ownerSub string) ([]SessionTreeInfo, error)
+	// ListTreesByMetadata 列出 ownerSub 未删除且 metadata 顶层 key 等于 value 的对话树
+	ListTreesByMetadata(ownerSub, key, value string) ([]SessionTreeInfo, error)
+	// ListTrashTrees 列出 ownerSub 回收站中的对话树
+	ListTrashTrees(ownerSub string) ([]SessionTreeInfo, error)
+	// ListSessionsInTree 列出 tree 内的所有分支 session（根在前，按创建顺序）
+	ListSessionsInTree(treeID, ownerSub string) ([]SessionBranchInfo, error)
 	// Close 关闭仓库连接
 	Close() error
 }
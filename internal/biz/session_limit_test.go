@@ -0,0 +1,52 @@
+package biz
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// countingRepo 只实现树内消息计数与追加，其余方法不会被触达
+type countingRepo struct {
+	SessionRepo
+	count    int
+	appended int
+}
+
+func (r *countingRepo) CountTreeMessages(string) (int, error) { return r.count, nil }
+
+func (r *countingRepo) AppendMessage(string, *schema.Message, string, bool) (int64, error) {
+	r.appended++
+	r.count++
+	return int64(r.count), nil
+}
+
+func TestAppendMessage_TreeLimitEnforced(t *testing.T) {
+	repo := &countingRepo{count: 2}
+	uc := NewSessionUsecase(repo)
+	uc.SetMaxMessagesPerTree(3)
+
+	// 还差一条到上限：允许
+	if _, err := uc.AppendMessage("s1", &schema.Message{Role: schema.User, Content: "q"}, "", false); err != nil {
+		t.Fatalf("append below limit failed: %v", err)
+	}
+
+	// 已达上限：拒绝并返回哨兵错误
+	_, err := uc.AppendMessage("s1", &schema.Message{Role: schema.User, Content: "q"}, "", false)
+	if !errors.Is(err, ErrTreeTooLarge) {
+		t.Fatalf("expected ErrTreeTooLarge, got: %v", err)
+	}
+	if repo.appended != 1 {
+		t.Errorf("over-limit append must not reach the repo, appended = %d", repo.appended)
+	}
+}
+
+func TestAppendMessage_TreeLimitDisabledByDefault(t *testing.T) {
+	repo := &countingRepo{count: 1 << 20}
+	uc := NewSessionUsecase(repo)
+
+	if _, err := uc.AppendMessage("s1", &schema.Message{Role: schema.User, Content: "q"}, "", false); err != nil {
+		t.Fatalf("append without a configured limit failed: %v", err)
+	}
+}
@@ -1,12 +1,22 @@
 package biz
 
 import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
 	"github.com/cloudwego/eino/schema"
 )
 
 // SessionUsecase handles session lifecycle: CRUD, listing, and message history.
 type SessionUsecase struct {
 	repo SessionRepo
+	// maxMessagesPerTree caps tree growth: GetSessionMessages loads the whole
+	// tree into memory, so runaway loops must be stopped early. 0 = unlimited.
+	maxMessagesPerTree int
+	// redactor redacts secrets from messages before storage; nil = off
+	redactor Redactor
 }
 
 // NewSessionUsecase creates a SessionUsecase.
@@ -14,30 +24,36 @@ func NewSessionUsecase(repo SessionRepo) *SessionUsecase {
 	return &SessionUsecase{repo: repo}
 }
 
-// ResolveSession validates or creates a session.
+// SetMaxMessagesPerTree caps the number of messages a conversation tree may
+// hold across all branches; AppendMessage fails with ErrTreeTooLarge beyond it.
+func (uc *SessionUsecase) SetMaxMessagesPerTree(n int) {
+	uc.maxMessagesPerTree = n
+}
+
+// ResolveSession validates or creates a session owned by ownerSub.
 // Returns the tree ID, resolved session ID, and whether a new conversation was created.
-func (uc *SessionUsecase) ResolveSession(sessionID string) (treeID, resolvedID string, isNew bool, err error) {
+func (uc *SessionUsecase) ResolveSession(ownerSub, sessionID string) (treeID, resolvedID string, isNew bool, err error) {
 	if sessionID == "" {
-		treeID, resolvedID = uc.repo.NewConversation()
+		treeID, resolvedID = uc.repo.NewConversation(ownerSub)
 		return treeID, resolvedID, true, nil
 	}
 	resolvedID = sessionID
-	treeID, err = uc.repo.GetTreeID(sessionID)
+	treeID, err = uc.repo.GetTreeID(sessionID, ownerSub)
 	if err != nil {
 		return "", "", false, err
 	}
 	return treeID, resolvedID, false, nil
 }
 
-// ResolveThread validates or creates a thread.
+// ResolveThread validates or creates a thread owned by ownerSub.
 // Returns the thread ID(tree_id), resolved session ID, and whether a new conversation was created.
-func (uc *SessionUsecase) ResolveThread(threadID string) (resolvedThreadID, sessionID string, isNew bool, err error) {
+func (uc *SessionUsecase) ResolveThread(ownerSub, threadID string) (resolvedThreadID, sessionID string, isNew bool, err error) {
 	if threadID == "" {
-		resolvedThreadID, sessionID = uc.repo.NewConversation()
+		resolvedThreadID, sessionID = uc.repo.NewConversation(ownerSub)
 		return resolvedThreadID, sessionID, true, nil
 	}
 
-	sessionID, err = uc.repo.GetLastActiveSessionID(threadID)
+	sessionID, err = uc.repo.GetLastActiveSessionID(threadID, ownerSub)
 	if err != nil {
 		return "", "", false, err
 	}
@@ -45,32 +61,213 @@ func (uc *SessionUsecase) ResolveThread(threadID string) (resolvedThreadID, sess
 	return threadID, sessionID, false, nil
 }
 
-// AppendMessage appends a message to the session.
-func (uc *SessionUsecase) AppendMessage(sessionID string, msg *schema.Message, model string) (int64, error) {
-	return uc.repo.AppendMessage(sessionID, msg, model)
+// AppendMessage appends a message to the session. partial marks an assistant
+// message whose stream was interrupted before completion.
+func (uc *SessionUsecase) AppendMessage(sessionID string, msg *schema.Message, model string, partial bool) (int64, error) {
+	if uc.maxMessagesPerTree > 0 {
+		if count, err := uc.repo.CountTreeMessages(sessionID); err == nil && count >= uc.maxMessagesPerTree {
+			return 0, fmt.Errorf("%w: %d messages (limit %d), start a new conversation", ErrTreeTooLarge, count, uc.maxMessagesPerTree)
+		}
+	}
+	if uc.redactor != nil {
+		clone, hits := redactMessage(uc.redactor, msg)
+		if hits > 0 {
+			slog.Info("redaction applied before storage", "role", msg.Role, "hits", hits)
+			msg = clone
+		}
+	}
+	return uc.repo.AppendMessage(sessionID, msg, model, partial)
+}
+
+// GetLastMessage returns the last message of the session along with its ID.
+func (uc *SessionUsecase) GetLastMessage(ownerSub, sessionID string) (int64, *ChatResponse, error) {
+	return uc.repo.GetLastMessage(sessionID, ownerSub)
+}
+
+// GetMessage returns a single message by ID (permalinks, lazy-loading a
+// branch point).
+func (uc *SessionUsecase) GetMessage(ownerSub string, msgID int64) (*ChatResponse, error) {
+	return uc.repo.GetMessage(msgID, ownerSub)
+}
+
+// ForkFromMessage creates a new empty session branch rooted at msgID
+// (time-travel): subsequent chats on the returned session continue from that
+// historical point. The message must belong to the same tree as sessionID.
+func (uc *SessionUsecase) ForkFromMessage(ownerSub, sessionID string, msgID int64) (string, error) {
+	treeID, err := uc.repo.GetTreeID(sessionID, ownerSub)
+	if err != nil {
+		return "", err
+	}
+	return uc.repo.CreateBranchFrom(msgID, treeID)
+}
+
+// FindRecentDuplicate checks whether msg repeats the session's most recent user
+// turn within window. It returns the assistant reply that already followed the
+// duplicate, if any, so callers can replay it instead of generating again.
+func (uc *SessionUsecase) FindRecentDuplicate(ownerSub, sessionID string, msg *schema.Message, window time.Duration) (*ChatResponse, bool) {
+	if window <= 0 || msg == nil || msg.Role != schema.User || msg.Content == "" {
+		return nil, false
+	}
+	session := uc.repo.GetSessionMessages(sessionID, ownerSub)
+	if len(session) == 0 {
+		return nil, false
+	}
+
+	last := session[len(session)-1]
+	if last.Role == schema.User {
+		// 上一条就是相同的用户消息：生成还在进行或刚失败，视为重复提交
+		return nil, last.Content == msg.Content && time.Since(last.CreatedAt) <= window
+	}
+	if last.Role != schema.Assistant || len(session) < 2 {
+		return nil, false
+	}
+	prev := session[len(session)-2]
+	if prev.Role == schema.User && prev.Content == msg.Content && time.Since(prev.CreatedAt) <= window {
+		return last, true
+	}
+	return nil, false
+}
+
+// UpdateMessage overwrites a stored message in place.
+func (uc *SessionUsecase) UpdateMessage(msgID int64, msg *schema.Message) error {
+	return uc.repo.UpdateMessage(msgID, msg)
 }
 
-// GetHistory returns the message list for a session.
-func (uc *SessionUsecase) GetHistory(sessionID string) ([]*schema.Message, error) {
-	session := uc.repo.GetSessionMessages(sessionID)
+// UpdateStreamedMessage overwrites an in-progress streamed message with the
+// latest snapshot, updating the model and partial flag alongside the content.
+// Redaction mirrors AppendMessage so batched flushes never store raw secrets.
+func (uc *SessionUsecase) UpdateStreamedMessage(msgID int64, msg *schema.Message, model string, partial bool) error {
+	if uc.redactor != nil {
+		if clone, hits := redactMessage(uc.redactor, msg); hits > 0 {
+			msg = clone
+		}
+	}
+	return uc.repo.UpdateStreamedMessage(msgID, msg, model, partial)
+}
+
+// DeleteMessage removes a message and its descendants from the session's tree.
+func (uc *SessionUsecase) DeleteMessage(ownerSub, sessionID string, msgID int64) error {
+	if !uc.repo.SessionExists(sessionID, ownerSub) {
+		return ErrSessionNotFound
+	}
+	return uc.repo.DeleteMessage(msgID)
+}
+
+// GetHistory returns the message list for a session. When the conversation
+// tree carries a custom instruction it is prepended as a system message, so
+// every turn in the tree sees the same persona.
+func (uc *SessionUsecase) GetHistory(ownerSub, sessionID string) ([]*schema.Message, error) {
+	session := uc.repo.GetSessionMessages(sessionID, ownerSub)
 	if session == nil {
 		return nil, ErrSessionNotFound
 	}
-	return extractMessages(session), nil
+	messages := extractMessages(session)
+	if treeID, err := uc.repo.GetTreeID(sessionID, ownerSub); err == nil {
+		if prompt, err := uc.repo.GetTreeSystemPrompt(treeID, ownerSub); err == nil && prompt != "" {
+			messages = append([]*schema.Message{schema.SystemMessage(prompt)}, messages...)
+		}
+	}
+	return messages, nil
 }
 
 // GetSession returns the full session (with model info per message).
-func (uc *SessionUsecase) GetSession(sessionID string) (Session, error) {
-	session := uc.repo.GetSessionMessages(sessionID)
+func (uc *SessionUsecase) GetSession(ownerSub, sessionID string) (Session, error) {
+	session := uc.repo.GetSessionMessages(sessionID, ownerSub)
 	if session == nil {
 		return nil, ErrSessionNotFound
 	}
 	return session, nil
 }
 
-// ListSessions lists all conversation trees.
-func (uc *SessionUsecase) ListSessions() ([]SessionTreeInfo, error) {
-	return uc.repo.ListTrees()
+// GetSessionPage returns one page of the session's linear message chain:
+// up to limit messages whose ID is greater than afterID (0 starts from the
+// chain head). Unlike GetSession it never materializes the whole tree.
+func (uc *SessionUsecase) GetSessionPage(ownerSub, sessionID string, afterID int64, limit int) (Session, error) {
+	return uc.repo.GetSessionMessagesPage(sessionID, ownerSub, afterID, limit)
+}
+
+// ListSessions lists the owner's conversation trees not in the trash.
+func (uc *SessionUsecase) ListSessions(ownerSub string) ([]SessionTreeInfo, error) {
+	return uc.repo.ListTrees(ownerSub)
+}
+
+// ListTreeSessions lists the branch sessions within a conversation tree
+// (root first), with each branch's fork point for rendering a branch switcher.
+func (uc *SessionUsecase) ListTreeSessions(ownerSub, treeID string) ([]SessionBranchInfo, error) {
+	return uc.repo.ListSessionsInTree(treeID, ownerSub)
+}
+
+// DeleteTree soft-deletes a conversation tree (moves it to the trash).
+func (uc *SessionUsecase) DeleteTree(ownerSub, treeID string) error {
+	return uc.repo.DeleteTree(treeID, ownerSub)
+}
+
+// MaxBulkDeleteTrees 单次批量删除的树数量上限
+const MaxBulkDeleteTrees = 100
+
+// DeleteTrees soft-deletes up to MaxBulkDeleteTrees conversation trees in one
+// transaction and reports a per-ID result (nil means deleted).
+func (uc *SessionUsecase) DeleteTrees(ownerSub string, treeIDs []string) (map[string]error, error) {
+	if len(treeIDs) > MaxBulkDeleteTrees {
+		return nil, fmt.Errorf("too many tree ids: max %d", MaxBulkDeleteTrees)
+	}
+	return uc.repo.DeleteTrees(treeIDs, ownerSub)
+}
+
+// RestoreTree restores a soft-deleted conversation tree from the trash.
+func (uc *SessionUsecase) RestoreTree(ownerSub, treeID string) error {
+	return uc.repo.RestoreTree(treeID, ownerSub)
+}
+
+// SetPinned pins or unpins a conversation tree; pinned trees sort first in listings.
+func (uc *SessionUsecase) SetPinned(ownerSub, treeID string, pinned bool) error {
+	return uc.repo.SetPinned(treeID, ownerSub, pinned)
+}
+
+// SetTitle sets a manual title on a conversation tree. Manual titles are
+// never overwritten by automatic title generation.
+func (uc *SessionUsecase) SetTitle(ownerSub, treeID, title string) error {
+	return uc.repo.SetTreeTitle(treeID, ownerSub, title)
+}
+
+// SetMetadata attaches app-specific metadata to a conversation tree. The
+// backend stores it opaquely; it only requires valid JSON so metadata-based
+// listing filters work. Empty meta clears any stored metadata.
+func (uc *SessionUsecase) SetMetadata(ownerSub, treeID string, meta json.RawMessage) error {
+	if len(meta) > 0 && !json.Valid(meta) {
+		return fmt.Errorf("metadata is not valid JSON")
+	}
+	return uc.repo.SetTreeMetadata(treeID, ownerSub, meta)
+}
+
+// SetSystemPrompt stores a conversation-level custom instruction on the tree.
+// It is prepended as a system message on every subsequent turn; an empty
+// prompt clears it.
+func (uc *SessionUsecase) SetSystemPrompt(ownerSub, treeID, prompt string) error {
+	return uc.repo.SetTreeSystemPrompt(treeID, ownerSub, prompt)
+}
+
+// ListSessionsByMetadata lists the owner's conversation trees whose metadata
+// has the given top-level key equal to value.
+func (uc *SessionUsecase) ListSessionsByMetadata(ownerSub, key, value string) ([]SessionTreeInfo, error) {
+	return uc.repo.ListTreesByMetadata(ownerSub, key, value)
+}
+
+// SetGeneratedTitle stores an auto-generated title, unless the tree already
+// carries a generated or manual title. It reports whether the title was applied.
+func (uc *SessionUsecase) SetGeneratedTitle(ownerSub, treeID, title string) (bool, error) {
+	return uc.repo.SetGeneratedTitle(treeID, ownerSub, title)
+}
+
+// ListTrash lists the owner's conversation trees currently in the trash.
+func (uc *SessionUsecase) ListTrash(ownerSub string) ([]SessionTreeInfo, error) {
+	return uc.repo.ListTrashTrees(ownerSub)
+}
+
+// PurgeExpiredTrash hard-deletes trees that were soft-deleted longer than
+// retention ago. Returns the number of trees purged.
+func (uc *SessionUsecase) PurgeExpiredTrash(retention time.Duration) (int, error) {
+	return uc.repo.PurgeTreesDeletedBefore(time.Now().Add(-retention))
 }
 
 // extractMessages converts a Session into a slice of schema.Message pointers.
@@ -0,0 +1,70 @@
+package biz
+
+import (
+	"sync"
+
+	tiktoken "github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// token 计数方式
+const (
+	TokenCountTiktoken  = "tiktoken"  // 模型族有对应 BPE 编码器
+	TokenCountHeuristic = "heuristic" // 未知模型族，约 4 字符 1 token
+)
+
+var bpeLoaderOnce sync.Once
+
+// CountTokens estimates the prompt token count for one request. The message
+// list is assembled exactly as ChatStream would send it (system prompt plus
+// prepared messages). It returns the estimate, the resolved model name, and
+// which counting method was used.
+func (uc *ChatUsecase) CountTokens(messages []*schema.Message, reqModel string) (int, string, string) {
+	modelName := uc.resolveModel(reqModel)
+
+	full := append(
+		[]*schema.Message{{Role: schema.System, Content: chatAgentInstruction}},
+		prepareMessagesForModel(messages)...,
+	)
+
+	enc := encodingForModel(modelName)
+	method := TokenCountTiktoken
+	if enc == nil {
+		method = TokenCountHeuristic
+	}
+
+	total := 3 // 回复引导 tokens
+	for _, msg := range full {
+		if msg == nil {
+			continue
+		}
+		total += 4 // 每条消息的格式开销
+		text := string(msg.Role) + msg.Content
+		if enc != nil {
+			total += len(enc.Encode(text, nil, nil))
+		} else {
+			total += heuristicTokens(text)
+		}
+	}
+	return total, modelName, method
+}
+
+// encodingForModel 按模型族选择 BPE 编码器；未知模型返回 nil
+func encodingForModel(modelName string) *tiktoken.Tiktoken {
+	bpeLoaderOnce.Do(func() {
+		// 离线词表，避免运行时从网络下载 BPE 文件
+		tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+	})
+	enc, err := tiktoken.EncodingForModel(modelName)
+	if err != nil {
+		return nil
+	}
+	return enc
+}
+
+// heuristicTokens 未知模型族的粗略估算：约 4 字符 1 token
+func heuristicTokens(text string) int {
+	return (len(text) + 3) / 4
+}
@@ -0,0 +1,51 @@
+package biz
+
+import (
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestCountTokens_TiktokenForKnownModelFamily(t *testing.T) {
+	uc := &ChatUsecase{defaultModel: "gpt-4o-mini"}
+	messages := []*schema.Message{{Role: schema.User, Content: "hello world, this is a test"}}
+
+	count, modelName, method := uc.CountTokens(messages, "")
+	if method != TokenCountTiktoken {
+		t.Fatalf("expected tiktoken method for gpt-4o-mini, got %q", method)
+	}
+	if modelName != "gpt-4o-mini" {
+		t.Fatalf("expected default model to be resolved, got %q", modelName)
+	}
+	if count <= 0 {
+		t.Fatalf("expected positive token count, got %d", count)
+	}
+
+	// 同样的输入必须得到稳定的结果
+	again, _, _ := uc.CountTokens(messages, "")
+	if again != count {
+		t.Fatalf("token count not deterministic: %d vs %d", count, again)
+	}
+}
+
+func TestCountTokens_HeuristicFallbackForUnknownModel(t *testing.T) {
+	uc := &ChatUsecase{defaultModel: "gpt-4o-mini"}
+	messages := []*schema.Message{{Role: schema.User, Content: "hello world, this is a test"}}
+
+	count, modelName, method := uc.CountTokens(messages, "some-inhouse-model")
+	if method != TokenCountHeuristic {
+		t.Fatalf("expected heuristic method for unknown model, got %q", method)
+	}
+	if modelName != "some-inhouse-model" {
+		t.Fatalf("unexpected model name: %q", modelName)
+	}
+
+	// 3 引导 + 每条消息 4 开销 + ceil(len(role+content)/4)，system prompt 也计入
+	want := 3
+	for _, msg := range append([]*schema.Message{{Role: schema.System, Content: chatAgentInstruction}}, messages...) {
+		want += 4 + heuristicTokens(string(msg.Role)+msg.Content)
+	}
+	if count != want {
+		t.Fatalf("heuristic count = %d, want %d", count, want)
+	}
+}
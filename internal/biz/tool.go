@@ -0,0 +1,65 @@
+package biz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// defaultMaxToolIterations agentic 循环的默认生成轮数上限
+const defaultMaxToolIterations = 8
+
+// ErrToolLoopExceeded agentic 循环达到 max_tool_iterations 上限时模型仍在请求工具。
+var ErrToolLoopExceeded = errors.New("tool_loop_exceeded: reached max tool-call iterations")
+
+// RegisterTools 注册 agent 可调用的工具；配置了 tool_timeout_seconds 时
+// 每次执行都会套上硬超时。需在开始处理请求前调用，不做并发保护
+func (uc *ChatUsecase) RegisterTools(tools ...tool.BaseTool) {
+	uc.tools = append(uc.tools, tools...)
+}
+
+// agentTools 返回注册的工具列表，必要时带超时包装
+func (uc *ChatUsecase) agentTools() []tool.BaseTool {
+	if uc.toolTimeout <= 0 {
+		return uc.tools
+	}
+	wrapped := make([]tool.BaseTool, len(uc.tools))
+	for i, t := range uc.tools {
+		if inv, ok := t.(tool.InvokableTool); ok {
+			wrapped[i] = &timeoutTool{InvokableTool: inv, timeout: uc.toolTimeout}
+		} else {
+			wrapped[i] = t
+		}
+	}
+	return wrapped
+}
+
+// timeoutTool 给单次工具执行加上硬超时：即使工具不响应 ctx 取消也能按时返回，
+// 慢工具不会挂住整个请求（超时后泄漏的 goroutine 由工具自身收尾）
+type timeoutTool struct {
+	tool.InvokableTool
+	timeout time.Duration
+}
+
+func (t *timeoutTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := t.InvokableTool.InvokableRun(ctx, argumentsInJSON, opts...)
+		done <- result{out, err}
+	}()
+	select {
+	case r := <-done:
+		return r.out, r.err
+	case <-ctx.Done():
+		return "", fmt.Errorf("tool execution timed out after %s", t.timeout)
+	}
+}
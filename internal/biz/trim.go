@@ -0,0 +1,141 @@
+package biz
+
+import (
+	"log/slog"
+
+	tiktoken "github.com/pkoukk/tiktoken-go"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// ModelLimits 提供模型的 token 上限；provider 层的能力注册表实现该接口
+type ModelLimits interface {
+	// MaxContextTokens 返回模型上下文窗口的 token 数，0 表示未知
+	MaxContextTokens(model string) int
+	// MaxOutputTokens 返回模型单次回复的 token 上限，0 表示未知
+	MaxOutputTokens(model string) int
+}
+
+// SetModelLimits wires a per-model limit source into the usecase. When the
+// context window for a model is known, history is trimmed by estimated token
+// count (including multimodal parts) before each upstream call, so oversized
+// conversations degrade gracefully instead of failing with a context overflow;
+// request params exceeding the output limit are clamped.
+func (uc *ChatUsecase) SetModelLimits(ml ModelLimits) {
+	uc.modelLimits = ml
+}
+
+// capParamsToModelLimits 把请求参数夹到模型上限内：thinking 预算不能超过
+// 模型单次回复的 token 上限
+func (uc *ChatUsecase) capParamsToModelLimits(params *RequestParams, modelName string) *RequestParams {
+	if uc.modelLimits == nil || params == nil || params.ThinkingBudget == nil {
+		return params
+	}
+	maxOutput := uc.modelLimits.MaxOutputTokens(modelName)
+	if maxOutput <= 0 || *params.ThinkingBudget <= maxOutput {
+		return params
+	}
+	cloned := *params
+	capped := maxOutput
+	cloned.ThinkingBudget = &capped
+	return &cloned
+}
+
+// 图片 token 估算（OpenAI 风格 detail 档位启发式）：
+// low 固定 85，high/auto 按典型 4 tile 高清图估算
+const (
+	imageTokensLow     = 85
+	imageTokensDefault = 765
+)
+
+// trimToContextBudget 在估算 token 超出模型上下文窗口时从最旧的消息开始
+// 丢弃。system 消息与最后一条消息（最新的用户输入）始终保留；被丢弃的
+// 工具调用对应的 tool 结果会一并丢弃，避免出现孤儿 tool 消息
+func (uc *ChatUsecase) trimToContextBudget(messages []*schema.Message, modelName string) []*schema.Message {
+	if uc.modelLimits == nil || len(messages) == 0 {
+		return messages
+	}
+	limit := uc.modelLimits.MaxContextTokens(modelName)
+	if limit <= 0 {
+		return messages
+	}
+
+	enc := encodingForModel(modelName)
+	costs := make([]int, len(messages))
+	total := 3 // 回复引导 tokens
+	// 始终前置的基础指令也占预算
+	total += 4 + textTokens(enc, string(schema.System)+chatAgentInstruction)
+	for i, msg := range messages {
+		costs[i] = estimateMessageTokens(enc, msg)
+		total += costs[i]
+	}
+	if total <= limit {
+		return messages
+	}
+
+	dropped := make([]bool, len(messages))
+	droppedCount := 0
+	for i := 0; i < len(messages)-1; i++ {
+		msg := messages[i]
+		if msg == nil || msg.Role == schema.System {
+			continue
+		}
+		if total > limit {
+			dropped[i] = true
+			droppedCount++
+			total -= costs[i]
+			continue
+		}
+		// 预算已满足后，孤儿 tool 结果仍要跟随其调用一并丢弃
+		if msg.Role == schema.Tool && i > 0 && dropped[i-1] {
+			dropped[i] = true
+			droppedCount++
+			total -= costs[i]
+		}
+	}
+	if droppedCount == 0 {
+		return messages
+	}
+
+	trimmed := make([]*schema.Message, 0, len(messages)-droppedCount)
+	for i, msg := range messages {
+		if !dropped[i] {
+			trimmed = append(trimmed, msg)
+		}
+	}
+	slog.Info("history trimmed to context budget",
+		"model", modelName, "limit", limit, "dropped", droppedCount, "estimated_tokens", total)
+	return trimmed
+}
+
+// estimateMessageTokens 估算单条消息的 token 数，多模态图片按 detail 档位计入
+func estimateMessageTokens(enc *tiktoken.Tiktoken, msg *schema.Message) int {
+	if msg == nil {
+		return 0
+	}
+	total := 4 // 每条消息的格式开销
+	total += textTokens(enc, string(msg.Role)+msg.Content)
+	for _, part := range msg.UserInputMultiContent {
+		// 文本分片的内容已并入 Content，这里只计多模态部分
+		if part.Type == schema.ChatMessagePartTypeImageURL {
+			total += imageTokens(part.Image)
+		}
+	}
+	return total
+}
+
+// imageTokens 单张图片的 token 估算
+func imageTokens(img *schema.MessageInputImage) int {
+	if img != nil && img.Detail == schema.ImageURLDetailLow {
+		return imageTokensLow
+	}
+	return imageTokensDefault
+}
+
+// textTokens 按可用编码器计算文本 token，未知模型族退回启发式
+func textTokens(enc *tiktoken.Tiktoken, text string) int {
+	if enc != nil {
+		return len(enc.Encode(text, nil, nil))
+	}
+	return heuristicTokens(text)
+}
@@ -0,0 +1,159 @@
+package biz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// fakeContextLimits 模型名 → 上下文窗口（输出上限不设限）
+type fakeContextLimits map[string]int
+
+func (f fakeContextLimits) MaxContextTokens(model string) int { return f[model] }
+func (f fakeContextLimits) MaxOutputTokens(string) int        { return 0 }
+
+// trimOverhead 裁剪预算里与消息无关的固定开销（回复引导 + 基础指令）。
+// 用未知模型族，走确定性的启发式计数
+func trimOverhead() int {
+	return 3 + 4 + heuristicTokens(string(schema.System)+chatAgentInstruction)
+}
+
+func msgCost(msg *schema.Message) int { return estimateMessageTokens(nil, msg) }
+
+func TestTrimToContextBudget_UnderBudgetUntouched(t *testing.T) {
+	uc := &ChatUsecase{modelLimits: fakeContextLimits{"mystery-model": 1 << 20}}
+	messages := []*schema.Message{
+		{Role: schema.User, Content: "hi"},
+		{Role: schema.Assistant, Content: "hello"},
+		{Role: schema.User, Content: "more"},
+	}
+
+	if got := uc.trimToContextBudget(messages, "mystery-model"); len(got) != 3 || &got[0] != &messages[0] {
+		t.Errorf("under-budget history must be returned untouched, got %d messages", len(got))
+	}
+	// 未知模型（窗口为 0）同样不裁剪
+	if got := uc.trimToContextBudget(messages, "unlisted-model"); len(got) != 3 {
+		t.Errorf("unknown model must not be trimmed, got %d messages", len(got))
+	}
+}
+
+func TestTrimToContextBudget_DropsOldestKeepsSystemAndLatest(t *testing.T) {
+	system := &schema.Message{Role: schema.System, Content: "always keep me"}
+	oldUser := &schema.Message{Role: schema.User, Content: strings.Repeat("x", 400)}
+	oldAssistant := &schema.Message{Role: schema.Assistant, Content: strings.Repeat("y", 400)}
+	latest := &schema.Message{Role: schema.User, Content: "what now"}
+	messages := []*schema.Message{system, oldUser, oldAssistant, latest}
+
+	// 预算刚好容不下最旧的那条用户消息
+	limit := trimOverhead() + msgCost(system) + msgCost(oldAssistant) + msgCost(latest)
+	uc := &ChatUsecase{modelLimits: fakeContextLimits{"mystery-model": limit}}
+
+	got := uc.trimToContextBudget(messages, "mystery-model")
+	if len(got) != 3 || got[0] != system || got[1] != oldAssistant || got[2] != latest {
+		t.Fatalf("expected [system, oldAssistant, latest], got %d messages: %+v", len(got), got)
+	}
+}
+
+func TestTrimToContextBudget_LatestSurvivesEvenOverBudget(t *testing.T) {
+	system := &schema.Message{Role: schema.System, Content: "keep"}
+	latest := &schema.Message{Role: schema.User, Content: strings.Repeat("z", 4000)}
+	messages := []*schema.Message{
+		system,
+		{Role: schema.User, Content: strings.Repeat("a", 400)},
+		{Role: schema.Assistant, Content: strings.Repeat("b", 400)},
+		latest,
+	}
+
+	uc := &ChatUsecase{modelLimits: fakeContextLimits{"mystery-model": trimOverhead() + 10}}
+
+	got := uc.trimToContextBudget(messages, "mystery-model")
+	if len(got) != 2 || got[0] != system || got[1] != latest {
+		t.Fatalf("system and latest must survive, got %d messages: %+v", len(got), got)
+	}
+}
+
+func TestTrimToContextBudget_ImagesCountedByDetail(t *testing.T) {
+	if low := imageTokens(&schema.MessageInputImage{Detail: schema.ImageURLDetailLow}); low != imageTokensLow {
+		t.Errorf("low detail image = %d tokens, want %d", low, imageTokensLow)
+	}
+	if auto := imageTokens(&schema.MessageInputImage{Detail: schema.ImageURLDetailAuto}); auto != imageTokensDefault {
+		t.Errorf("auto detail image = %d tokens, want %d", auto, imageTokensDefault)
+	}
+
+	imageMsg := &schema.Message{
+		Role:    schema.User,
+		Content: "look at this",
+		UserInputMultiContent: []schema.MessageInputPart{
+			{Type: schema.ChatMessagePartTypeText, Text: "look at this"},
+			{Type: schema.ChatMessagePartTypeImageURL, Image: &schema.MessageInputImage{}},
+		},
+	}
+	latest := &schema.Message{Role: schema.User, Content: "describe it again"}
+	messages := []*schema.Message{imageMsg, latest}
+
+	// 文本预算充足，但算上图片后超出：图片消息必须被裁掉
+	limit := trimOverhead() + msgCost(latest) + msgCost(imageMsg) - imageTokensDefault + 50
+	uc := &ChatUsecase{modelLimits: fakeContextLimits{"mystery-model": limit}}
+
+	got := uc.trimToContextBudget(messages, "mystery-model")
+	if len(got) != 1 || got[0] != latest {
+		t.Fatalf("image message must be dropped under tight budget, got %d messages", len(got))
+	}
+}
+
+// fakeOutputLimits 固定的单次回复 token 上限（不设上下文窗口）
+type fakeOutputLimits int
+
+func (f fakeOutputLimits) MaxContextTokens(string) int { return 0 }
+func (f fakeOutputLimits) MaxOutputTokens(string) int  { return int(f) }
+
+func TestCapParamsToModelLimits_ClampsThinkingBudget(t *testing.T) {
+	uc := &ChatUsecase{modelLimits: fakeOutputLimits(8192)}
+
+	big := 100000
+	params := &RequestParams{ThinkingBudget: &big}
+	got := uc.capParamsToModelLimits(params, "some-model")
+	if *got.ThinkingBudget != 8192 {
+		t.Errorf("thinking budget = %d, want clamped to 8192", *got.ThinkingBudget)
+	}
+	if *params.ThinkingBudget != 100000 {
+		t.Errorf("original params mutated: %d", *params.ThinkingBudget)
+	}
+
+	small := 1000
+	within := &RequestParams{ThinkingBudget: &small}
+	if uc.capParamsToModelLimits(within, "some-model") != within {
+		t.Error("params within the limit must pass through unchanged")
+	}
+	if uc.capParamsToModelLimits(nil, "some-model") != nil {
+		t.Error("nil params must pass through")
+	}
+
+	// 没有上限来源时不做任何处理
+	bare := &ChatUsecase{}
+	if bare.capParamsToModelLimits(params, "some-model") != params {
+		t.Error("without model limits params must pass through")
+	}
+}
+
+func TestTrimToContextBudget_OrphanToolResultFollowsItsCall(t *testing.T) {
+	oldUser := &schema.Message{Role: schema.User, Content: strings.Repeat("q", 400)}
+	toolCall := &schema.Message{
+		Role:      schema.Assistant,
+		Content:   strings.Repeat("c", 400),
+		ToolCalls: []schema.ToolCall{{ID: "call_1"}},
+	}
+	toolResult := &schema.Message{Role: schema.Tool, ToolCallID: "call_1", Content: "42"}
+	latest := &schema.Message{Role: schema.User, Content: "so?"}
+	messages := []*schema.Message{oldUser, toolCall, toolResult, latest}
+
+	// 丢掉 oldUser 和 toolCall 后预算已满足，但孤儿 tool 结果必须跟着走
+	limit := trimOverhead() + msgCost(toolResult) + msgCost(latest) + 10
+	uc := &ChatUsecase{modelLimits: fakeContextLimits{"mystery-model": limit}}
+
+	got := uc.trimToContextBudget(messages, "mystery-model")
+	if len(got) != 1 || got[0] != latest {
+		t.Fatalf("tool result must be dropped with its call, got %d messages: %+v", len(got), got)
+	}
+}
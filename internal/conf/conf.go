@@ -1,21 +1,72 @@
 package conf
 
 import (
+	"errors"
+	"fmt"
 	"os"
+	"regexp"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config is the config structure.
 type Config struct {
-	Server Server `yaml:"server"`
-	Eino   Eino   `yaml:"eino"`
-	Auth   Auth   `yaml:"auth"`
+	Server    Server    `yaml:"server"`
+	Data      Data      `yaml:"data"`
+	Eino      Eino      `yaml:"eino"`
+	Auth      Auth      `yaml:"auth"`
+	Audit     Audit     `yaml:"audit"`
+	Redaction Redaction `yaml:"redaction"`
+}
+
+// Data is the data layer config.
+type Data struct {
+	DBPath string `yaml:"db_path"` // SQLite 数据库文件路径
+	// TrashRetentionDays 回收站保留天数，到期后台任务硬删除；未设置时默认 30，负数表示永久保留
+	TrashRetentionDays int `yaml:"trash_retention_days"`
+	// MaxMessagesPerTree 单个对话树的消息数上限，防止失控循环把整树撑到内存装不下；
+	// 0 表示不限制
+	MaxMessagesPerTree int `yaml:"max_messages_per_tree"`
+	// FallbackInMemory 数据库打不开时降级为内存存储继续服务（后台周期性重连，
+	// 重连成功后降级期间的数据丢弃）；默认 false，打不开直接拒绝启动
+	FallbackInMemory bool `yaml:"fallback_in_memory"`
+	// AutoTitleMaxLen 首条消息自动标题的最大长度（按 rune 计），超长在词/句
+	// 边界截断并追加省略号；0 表示默认 15
+	AutoTitleMaxLen int `yaml:"auto_title_max_len"`
+	// StreamFlushMs 流式回复增量落库的合帧间隔（毫秒）：生成过程中分批把
+	// 已生成内容写入数据库，断线后历史里能读到部分回复；0 表示关闭
+	// （仅在流结束时整条写入）
+	StreamFlushMs int `yaml:"stream_flush_ms"`
 }
 
 // Server is the server config.
 type Server struct {
 	BaseURL string `yaml:"base_url"`
+	// ListenAddr 监听地址（:port 形式），未设置时默认 :52538（env override: LISTEN_ADDR）
+	ListenAddr string `yaml:"listen_addr"`
+	// SSEKeepaliveSeconds 流式响应空闲时发送 SSE 注释行的间隔（秒），未设置时默认 15，负数表示禁用
+	SSEKeepaliveSeconds int `yaml:"sse_keepalive_seconds"`
+	// LogFormat 日志格式：text（默认，人类可读）或 json（日志聚合器用）
+	LogFormat string `yaml:"log_format"`
+	// LogLevel 日志级别：debug/info/warn/error，默认 info
+	LogLevel string `yaml:"log_level"`
+	// DebugPromptEndpoint 是否注册 POST /chat/debug-prompt 调试端点，
+	// 会原样返回出站 prompt（含注入的系统指令）；默认关闭
+	DebugPromptEndpoint bool `yaml:"debug_prompt_endpoint"`
+	// TLS 直接提供 HTTPS 服务（无反向代理场景）
+	TLS TLS `yaml:"tls"`
+}
+
+// TLS is the TLS config. Both files must be set to enable HTTPS.
+type TLS struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// Enabled reports whether TLS serving is configured.
+func (t *TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
 }
 
 // Eino is the eino config.
@@ -23,12 +74,126 @@ type Eino struct {
 	DefaultModel   string            `yaml:"default_model"`
 	Clients        map[string]Client `yaml:"clients"`
 	ModelOverrides map[string]string `yaml:"model_overrides"` // model name → client name, 强制导流
+	// AllowedModels 允许使用的模型名单（支持 * 通配，如 gpt-4*），为空表示不限制
+	AllowedModels []string `yaml:"allowed_models"`
+	// BlockedModels 禁止使用的模型名单（支持 * 通配），优先于 allowed_models
+	BlockedModels []string `yaml:"blocked_models"`
+	// FirstTokenTimeoutSeconds 等待首个 token 的超时（秒），0 表示不限制
+	FirstTokenTimeoutSeconds int `yaml:"first_token_timeout_seconds"`
+	// GenerateTimeoutSeconds 单次生成的整体超时（秒），0 表示不限制
+	GenerateTimeoutSeconds int `yaml:"generate_timeout_seconds"`
+	// StreamStallTimeoutSeconds 流式输出中途停更的看门狗窗口（秒）：已有输出后
+	// 超过该间隔没有新 chunk 即中止并保存 partial，0 表示关闭
+	StreamStallTimeoutSeconds int `yaml:"stream_stall_timeout_seconds"`
+	// MaxConcurrentRequests 上游并发请求上限，0 表示不限制
+	MaxConcurrentRequests int `yaml:"max_concurrent_requests"`
+	// ConcurrencyWaitSeconds 并发满载时的排队超时（秒），0 表示不排队直接失败
+	ConcurrencyWaitSeconds int `yaml:"concurrency_wait_seconds"`
+	// MaxToolIterations agentic 工具循环的生成轮数上限，超限返回 tool_loop_exceeded；<=0 用默认值 8
+	MaxToolIterations int `yaml:"max_tool_iterations"`
+	// ToolTimeoutSeconds 单次工具执行的硬超时（秒），0 表示不限制
+	ToolTimeoutSeconds int `yaml:"tool_timeout_seconds"`
+	// ContextRetryAttempts 上游报上下文超限后自动截断最旧历史并重试的
+	// 次数上限；<=0 用默认值 1
+	ContextRetryAttempts int `yaml:"context_retry_attempts"`
+	// DedupWindowSeconds 重复提交保护窗口（秒），0 表示关闭；
+	// 窗口内与上一条用户消息完全相同的提交会被去重
+	DedupWindowSeconds int `yaml:"dedup_window_seconds"`
+	// DedupBehavior 去重行为：reject 拒绝重复提交（默认），replay 直接返回已有的助手回复
+	DedupBehavior string `yaml:"dedup_behavior"`
+	// PersistEmptyResponses 是否把空的助手回复也写入会话历史，默认丢弃
+	PersistEmptyResponses bool `yaml:"persist_empty_responses"`
+	// TitleModel 标题生成模型：首轮对话后用它生成会话标题，空表示关闭
+	TitleModel string `yaml:"title_model"`
+	// ResponseCacheSize 响应缓存条目上限（LRU），0 表示关闭；
+	// 只缓存带固定 seed 的确定性请求，请求可通过 no_cache 绕过
+	ResponseCacheSize int `yaml:"response_cache_size"`
+	// HealthCheckSeconds 后台探活间隔（秒），定期对每个 client 发最小只读请求
+	// 记录可用性与延迟；0 表示关闭
+	HealthCheckSeconds int `yaml:"health_check_seconds"`
+	// AllowExtraParams 是否放行请求级厂商自定义参数（forwardedProps.extra）
+	// 原样合入出站请求体；属于高危透传，默认关闭
+	AllowExtraParams bool `yaml:"allow_extra_params"`
+	// AllowUserKeys 是否放行请求级上游凭证覆盖（BYO key，forwardedProps.api_key
+	// / base_url），凭证只用于构造一次性 client、不写日志不落库；默认关闭
+	AllowUserKeys bool `yaml:"allow_user_keys"`
+	// HTTPProxy 上游请求的出口代理（所有 client 的全局默认），
+	// client 级 http_proxy 优先；均未配置时遵循 HTTP_PROXY/HTTPS_PROXY/NO_PROXY 环境变量
+	HTTPProxy string `yaml:"http_proxy"`
+	// HTTPPool 上游连接池调优（所有 client 共享）；单厂商高并发时
+	// 提高空闲连接数可显著减少握手开销，零值沿用 Go 默认 Transport
+	HTTPPool HTTPPool `yaml:"http_pool"`
+	// TokenQuota 每用户在窗口期内的 token 配额（成本控制）；
+	// 0 表示不限制，仅管理端设置的用户级覆盖生效
+	TokenQuota int64 `yaml:"token_quota"`
+	// TokenQuotaWindow 配额重置窗口：daily / monthly（默认）
+	TokenQuotaWindow string `yaml:"token_quota_window"`
+}
+
+// HTTPPool 上游 HTTP 连接池调优；各字段为零时沿用 Go 默认值
+type HTTPPool struct {
+	// MaxIdleConns 全部 host 合计保留的空闲连接上限（Go 默认 100）
+	MaxIdleConns int `yaml:"max_idle_conns"`
+	// MaxIdleConnsPerHost 单个上游 host 保留的空闲连接上限（Go 默认仅 2，
+	// 高并发打单一厂商时是主要瓶颈）
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host"`
+	// IdleConnTimeoutSeconds 空闲连接回收时间（秒，Go 默认 90）
+	IdleConnTimeoutSeconds int `yaml:"idle_conn_timeout_seconds"`
+	// DisableHTTP2 关闭 HTTP/2 协商（默认开启；个别兼容网关的 h2 实现有问题时使用）
+	DisableHTTP2 bool `yaml:"disable_http2"`
+	// DisableKeepAlives 关闭连接复用，仅排查连接相关问题时使用
+	DisableKeepAlives bool `yaml:"disable_keep_alives"`
 }
 
 // Client 客户端配置
 type Client struct {
 	BaseURL string `yaml:"base_url"`
 	APIKey  string `yaml:"api_key"`
+	// DefaultModel 该 client 的默认模型：请求指定 client 但未指定 model 时使用，
+	// 留空回退到全局 eino.default_model
+	DefaultModel string `yaml:"default_model"`
+	// Store Responses API 的 store 默认值：false 要求 OpenAI 不在服务端保留
+	// 请求数据（合规场景）；nil 跟随厂商默认（保留）
+	Store *bool `yaml:"store"`
+	// ThinkingField OpenAI 兼容厂商的 thinking 开关请求字段名（如 enable_thinking）；
+	// 留空时 glm/kimi/minimax 走内置默认映射
+	ThinkingField string `yaml:"thinking_field"`
+	// ThinkingOn/ThinkingOff 开关字段取值（任意 YAML 值，bool 或对象），默认 true/false
+	ThinkingOn  any `yaml:"thinking_on"`
+	ThinkingOff any `yaml:"thinking_off"`
+	// ReasoningSummary OpenAI Responses API 的推理摘要模式（auto/concise/detailed），默认 detailed
+	ReasoningSummary string `yaml:"reasoning_summary"`
+	// BaseInstructions 始终前置的基础指令（如安全护栏提示词），
+	// 在所有对话的 system 指令之前注入
+	BaseInstructions string `yaml:"base_instructions"`
+	// HTTPProxy 该 client 上游请求的出口代理，覆盖 eino.http_proxy；
+	// 留空时回退到全局配置或标准代理环境变量
+	HTTPProxy string `yaml:"http_proxy"`
+	// UserAgent 上游请求的 User-Agent，部分网关要求显式携带
+	UserAgent string `yaml:"user_agent"`
+	// Headers 附加到每个上游请求的自定义头（如网关路由头），
+	// 与 SDK 自带的同名头冲突时以这里为准
+	Headers map[string]string `yaml:"headers"`
+}
+
+// Audit is the audit trail config.
+type Audit struct {
+	Enabled bool   `yaml:"enabled"`
+	Path    string `yaml:"path"` // JSONL 审计文件路径
+	// LogFullText 记录 prompt/response 全文；默认只记录 sha256 哈希
+	LogFullText bool `yaml:"log_full_text"`
+}
+
+// Redaction is the sensitive-content redaction config.
+type Redaction struct {
+	Enabled bool `yaml:"enabled"`
+	// Patterns 自定义脱敏正则；为空时使用内置默认模式（API key、银行卡号等）
+	Patterns []string `yaml:"patterns"`
+	// Replacement 命中后的替换文本，默认 [REDACTED]
+	Replacement string `yaml:"replacement"`
+	// ApplyToModel 出站消息也脱敏（上游模型看到的即是脱敏后内容）；
+	// 默认只在落库前脱敏，模型仍能看到原文
+	ApplyToModel bool `yaml:"apply_to_model"`
 }
 
 // Auth is the authentication config.
@@ -40,6 +205,31 @@ type Auth struct {
 	RedirectURL  string   `yaml:"redirect_url"` // Optional: if not set, auto-constructed from server.base_url
 	FrontendURL  string   `yaml:"frontend_url"`
 	Scopes       []string `yaml:"scopes"`
+	// CookieSecure 认证 cookie 的 Secure 标记；未设置时在 TLS 或 https base_url 下默认开启
+	CookieSecure *bool `yaml:"cookie_secure"`
+	// CookieSameSite 认证 cookie 的 SameSite 属性：lax（默认）、strict、none（需要 Secure）
+	CookieSameSite string `yaml:"cookie_samesite"`
+	// CookieDomain 认证 cookie 的 Domain 属性，留空表示仅当前域
+	CookieDomain string `yaml:"cookie_domain"`
+	// Providers 命名的多 IdP 配置；为空时使用顶层 provider/client_id 等单 IdP 字段
+	Providers map[string]OIDCProvider `yaml:"providers"`
+	// DefaultProvider 登录未指定 provider 参数时使用的 IdP 名称；
+	// providers 只有一个条目时可省略
+	DefaultProvider string `yaml:"default_provider"`
+	// StateStore 登录 state/PKCE verifier 的存储后端：memory（默认）或 sqlite；
+	// 多实例部署或希望重启不中断登录流程时选 sqlite
+	StateStore string `yaml:"state_store"`
+	// AllowedReturnHosts 登录后 return_to 允许跳转的额外主机（host[:port]）；
+	// frontend_url 的主机始终允许
+	AllowedReturnHosts []string `yaml:"allowed_return_hosts"`
+}
+
+// OIDCProvider 单个命名 IdP 的配置
+type OIDCProvider struct {
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"`
 }
 
 // GetRedirectURL returns the OIDC callback URL
@@ -52,6 +242,124 @@ func (a *Auth) GetRedirectURL(serverBaseURL string) string {
 	return serverBaseURL + "/api/auth/callback"
 }
 
+// Validate 检查已启用功能的必填字段，配置错误时在启动阶段直接失败，
+// 避免问题拖到请求时才以难排查的方式暴露。所有错误一次性汇总返回。
+func (c *Config) Validate() error {
+	var errs []error
+
+	// TLS 证书和私钥必须成对配置
+	if (c.Server.TLS.CertFile == "") != (c.Server.TLS.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("server.tls: cert_file and key_file must both be set to enable HTTPS"))
+	}
+	switch c.Server.LogFormat {
+	case "", "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("server.log_format: unknown value %q (want text or json)", c.Server.LogFormat))
+	}
+	switch c.Server.LogLevel {
+	case "", "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("server.log_level: unknown value %q (want debug, info, warn or error)", c.Server.LogLevel))
+	}
+
+	// 空的 client 条目会静默 fallback 到 openai，几乎都是配置笔误
+	for name, client := range c.Eino.Clients {
+		if client.BaseURL == "" && client.APIKey == "" {
+			errs = append(errs, fmt.Errorf("eino.clients.%s: has neither base_url nor api_key (requests would silently fall back to the openai client)", name))
+		}
+	}
+	// override 指向未配置的 client 会拿到空配置
+	for model, target := range c.Eino.ModelOverrides {
+		if _, ok := c.Eino.Clients[target]; !ok {
+			errs = append(errs, fmt.Errorf("eino.model_overrides.%s: target client %q is not configured under eino.clients", model, target))
+		}
+	}
+	switch c.Eino.DedupBehavior {
+	case "", "reject", "replay":
+	default:
+		errs = append(errs, fmt.Errorf("eino.dedup_behavior: unknown value %q (want reject or replay)", c.Eino.DedupBehavior))
+	}
+	switch c.Eino.TokenQuotaWindow {
+	case "", "daily", "monthly":
+	default:
+		errs = append(errs, fmt.Errorf("eino.token_quota_window: unknown value %q (want daily or monthly)", c.Eino.TokenQuotaWindow))
+	}
+
+	// 脱敏正则编译失败应在启动时暴露，而不是第一条消息落库时
+	if c.Redaction.Enabled {
+		for _, p := range c.Redaction.Patterns {
+			if _, err := regexp.Compile(p); err != nil {
+				errs = append(errs, fmt.Errorf("redaction.patterns: invalid pattern %q: %v", p, err))
+			}
+		}
+	}
+
+	if c.Auth.Enabled {
+		if len(c.Auth.Providers) == 0 {
+			// 单 IdP 模式：顶层 provider/client_id 必填
+			if c.Auth.Provider == "" {
+				errs = append(errs, fmt.Errorf("auth: enabled but provider is empty (set auth.provider or auth.providers)"))
+			}
+			if c.Auth.ClientID == "" {
+				errs = append(errs, fmt.Errorf("auth: enabled but client_id is empty"))
+			}
+		} else {
+			for name, p := range c.Auth.Providers {
+				if p.Issuer == "" {
+					errs = append(errs, fmt.Errorf("auth.providers.%s: issuer is empty", name))
+				}
+				if p.ClientID == "" {
+					errs = append(errs, fmt.Errorf("auth.providers.%s: client_id is empty", name))
+				}
+			}
+			if c.Auth.DefaultProvider != "" {
+				if _, ok := c.Auth.Providers[c.Auth.DefaultProvider]; !ok {
+					errs = append(errs, fmt.Errorf("auth.default_provider: %q is not defined under auth.providers", c.Auth.DefaultProvider))
+				}
+			} else if len(c.Auth.Providers) > 1 {
+				errs = append(errs, fmt.Errorf("auth: default_provider is required when multiple providers are configured"))
+			}
+		}
+		switch c.Auth.StateStore {
+		case "", "memory", "sqlite":
+		default:
+			errs = append(errs, fmt.Errorf("auth.state_store: unknown value %q (want memory or sqlite)", c.Auth.StateStore))
+		}
+		switch c.Auth.CookieSameSite {
+		case "", "lax", "strict":
+		case "none":
+			// SameSite=None 的 cookie 浏览器要求必须带 Secure
+			if c.Auth.CookieSecure != nil && !*c.Auth.CookieSecure {
+				errs = append(errs, fmt.Errorf("auth: cookie_samesite=none requires cookie_secure=true"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("auth.cookie_samesite: unknown value %q (want lax, strict or none)", c.Auth.CookieSameSite))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// envVarPattern 匹配 ${VAR} 与 ${VAR:-default}。只认花括号形式，
+// 裸 $VAR 不展开，避免误伤配置里的普通美元符号
+var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// expandEnv 在解析 YAML 前做环境变量插值：${VAR} 取环境值，
+// ${VAR:-default} 在变量未设置或为空时回退到 default，
+// 让 api_key 等敏感字段可以不落盘（如 api_key: ${OPENAI_API_KEY}）
+func expandEnv(data []byte) []byte {
+	return envVarPattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		groups := envVarPattern.FindSubmatch(match)
+		if value := os.Getenv(string(groups[1])); value != "" {
+			return []byte(value)
+		}
+		if len(groups[2]) > 0 {
+			return groups[2][len(":-"):] // 去掉 :- 前缀
+		}
+		return nil
+	})
+}
+
 // Load loads config from file.
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -60,7 +368,7 @@ func Load(path string) (*Config, error) {
 	}
 
 	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
+	if err := yaml.Unmarshal(expandEnv(data), &cfg); err != nil {
 		return nil, err
 	}
 
@@ -69,10 +377,43 @@ func Load(path string) (*Config, error) {
 		cfg.Server.BaseURL = "http://localhost:52538"
 	}
 
+	// Set default listen address if not configured
+	if cfg.Server.ListenAddr == "" {
+		cfg.Server.ListenAddr = ":52538"
+	}
+
+	// Default SSE keepalive interval: 15s keeps proxies from dropping idle streams
+	if cfg.Server.SSEKeepaliveSeconds == 0 {
+		cfg.Server.SSEKeepaliveSeconds = 15
+	}
+
+	// Set default database path if not configured
+	if cfg.Data.DBPath == "" {
+		cfg.Data.DBPath = "data/sessions.db"
+	}
+
+	// Default trash retention: purge soft-deleted trees after 30 days
+	if cfg.Data.TrashRetentionDays == 0 {
+		cfg.Data.TrashRetentionDays = 30
+	}
+
+	// Set default audit path if not configured
+	if cfg.Audit.Path == "" {
+		cfg.Audit.Path = "data/audit.jsonl"
+	}
+
 	// Override server config from env vars if present
 	if baseURL := os.Getenv("SERVER_BASE_URL"); baseURL != "" {
 		cfg.Server.BaseURL = baseURL
 	}
+	if listenAddr := os.Getenv("LISTEN_ADDR"); listenAddr != "" {
+		cfg.Server.ListenAddr = listenAddr
+	}
+
+	// Override data config from env vars if present
+	if dbPath := os.Getenv("DB_PATH"); dbPath != "" {
+		cfg.Data.DBPath = dbPath
+	}
 
 	// Override auth config from env vars if present
 	if secret := os.Getenv("OIDC_CLIENT_SECRET"); secret != "" {
@@ -88,5 +429,17 @@ func Load(path string) (*Config, error) {
 		cfg.Auth.FrontendURL = frontendURL
 	}
 
+	// Default cookie attributes: Secure follows HTTPS, SameSite defaults to lax
+	if cfg.Auth.CookieSecure == nil {
+		secure := cfg.Server.TLS.Enabled() || strings.HasPrefix(cfg.Server.BaseURL, "https://")
+		cfg.Auth.CookieSecure = &secure
+	}
+	if cfg.Auth.CookieSameSite == "" {
+		cfg.Auth.CookieSameSite = "lax"
+	}
+	if cfg.Auth.StateStore == "" {
+		cfg.Auth.StateStore = "memory"
+	}
+
 	return &cfg, nil
 }
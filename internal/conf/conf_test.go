@@ -0,0 +1,162 @@
+package conf
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate_ValidConfig(t *testing.T) {
+	cfg := &Config{
+		Eino: Eino{
+			DefaultModel: "gpt-4o",
+			Clients: map[string]Client{
+				"openai": {BaseURL: "https://api.openai.com/v1", APIKey: "sk-x"},
+			},
+			ModelOverrides: map[string]string{"gpt-4o": "openai"},
+			DedupBehavior:  "replay",
+		},
+		Auth: Auth{Enabled: true, Provider: "google", ClientID: "cid"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+}
+
+func TestValidate_AuthEnabledMissingFields(t *testing.T) {
+	cfg := &Config{Auth: Auth{Enabled: true}}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error for enabled auth without provider/client_id")
+	}
+	if !strings.Contains(err.Error(), "provider is empty") || !strings.Contains(err.Error(), "client_id is empty") {
+		t.Errorf("error should name both missing fields, got: %v", err)
+	}
+}
+
+func TestValidate_EmptyClientEntry(t *testing.T) {
+	cfg := &Config{Eino: Eino{Clients: map[string]Client{"claude": {}}}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "eino.clients.claude") {
+		t.Errorf("empty client entry should be rejected by name, got: %v", err)
+	}
+}
+
+func TestValidate_OverrideTargetMissing(t *testing.T) {
+	cfg := &Config{Eino: Eino{
+		Clients:        map[string]Client{"openai": {APIKey: "sk-x"}},
+		ModelOverrides: map[string]string{"glm-4": "zhipu"},
+	}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), `target client "zhipu"`) {
+		t.Errorf("override to unconfigured client should be rejected, got: %v", err)
+	}
+}
+
+func TestValidate_UnknownDedupBehavior(t *testing.T) {
+	cfg := &Config{Eino: Eino{DedupBehavior: "ignore"}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "dedup_behavior") {
+		t.Errorf("unknown dedup_behavior should be rejected, got: %v", err)
+	}
+}
+
+func TestValidate_MultiProviderRules(t *testing.T) {
+	base := Auth{
+		Enabled: true,
+		Providers: map[string]OIDCProvider{
+			"google": {Issuer: "https://accounts.google.com", ClientID: "a"},
+			"github": {Issuer: "https://github.com", ClientID: "b"},
+		},
+	}
+
+	// 多 IdP 必须指定 default_provider
+	cfg := &Config{Auth: base}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "default_provider is required") {
+		t.Errorf("missing default_provider should be rejected, got: %v", err)
+	}
+
+	// default_provider 必须指向已定义的 IdP
+	withDefault := base
+	withDefault.DefaultProvider = "okta"
+	cfg = &Config{Auth: withDefault}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), `"okta" is not defined`) {
+		t.Errorf("dangling default_provider should be rejected, got: %v", err)
+	}
+
+	// IdP 条目缺 issuer
+	broken := base
+	broken.DefaultProvider = "google"
+	broken.Providers = map[string]OIDCProvider{"google": {ClientID: "a"}}
+	cfg = &Config{Auth: broken}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "auth.providers.google: issuer is empty") {
+		t.Errorf("provider without issuer should be rejected, got: %v", err)
+	}
+}
+
+func TestValidate_SameSiteNoneRequiresSecure(t *testing.T) {
+	insecure := false
+	cfg := &Config{Auth: Auth{
+		Enabled: true, Provider: "google", ClientID: "cid",
+		CookieSameSite: "none", CookieSecure: &insecure,
+	}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cookie_samesite=none requires") {
+		t.Errorf("samesite=none without secure should be rejected, got: %v", err)
+	}
+}
+
+func TestValidate_MismatchedTLSPair(t *testing.T) {
+	cfg := &Config{Server: Server{TLS: TLS{CertFile: "cert.pem"}}}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cert_file and key_file") {
+		t.Errorf("half-configured TLS should be rejected, got: %v", err)
+	}
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("CONF_TEST_KEY", "sk-from-env")
+	t.Setenv("CONF_TEST_EMPTY", "")
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"present", "api_key: ${CONF_TEST_KEY}", "api_key: sk-from-env"},
+		{"absent becomes empty", "api_key: ${CONF_TEST_MISSING}", "api_key: "},
+		{"default used when absent", "model: ${CONF_TEST_MISSING:-gpt-4o}", "model: gpt-4o"},
+		{"default used when empty", "model: ${CONF_TEST_EMPTY:-gpt-4o}", "model: gpt-4o"},
+		{"default ignored when present", "key: ${CONF_TEST_KEY:-fallback}", "key: sk-from-env"},
+		{"bare dollar untouched", "note: cost is $5 and $VAR stays", "note: cost is $5 and $VAR stays"},
+		{"multiple in one line", "url: ${CONF_TEST_KEY}/${CONF_TEST_MISSING:-v1}", "url: sk-from-env/v1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := string(expandEnv([]byte(c.in))); got != c.want {
+				t.Errorf("expandEnv(%q) = %q, want %q", c.in, got, c.want)
+			}
+		})
+	}
+}
+
+func TestLoad_ExpandsEnvInAllStringFields(t *testing.T) {
+	t.Setenv("CONF_TEST_OPENAI_KEY", "sk-secret")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "eino:\n  default_model: ${CONF_TEST_MODEL:-gpt-4o}\n  clients:\n    openai:\n      base_url: https://api.openai.com/v1\n      api_key: ${CONF_TEST_OPENAI_KEY}\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if got := cfg.Eino.Clients["openai"].APIKey; got != "sk-secret" {
+		t.Errorf("api_key = %q, want value from env", got)
+	}
+	if cfg.Eino.DefaultModel != "gpt-4o" {
+		t.Errorf("default_model = %q, want default from ${VAR:-default}", cfg.Eino.DefaultModel)
+	}
+}
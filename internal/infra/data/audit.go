@@ -0,0 +1,68 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"devops-backend/internal/biz"
+)
+
+// JSONLAuditLogger 把审计记录逐行以 JSON 写入文件。
+// 写入走缓冲 channel + 后台 goroutine，Log 永不阻塞请求路径；缓冲满时丢弃记录。
+type JSONLAuditLogger struct {
+	file      *os.File
+	entries   chan biz.AuditEntry
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewJSONLAuditLogger 创建 JSONL 审计日志，追加写入 path 指向的文件
+func NewJSONLAuditLogger(path string) (*JSONLAuditLogger, error) {
+	dir := filepath.Dir(path)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+
+	l := &JSONLAuditLogger{
+		file:    file,
+		entries: make(chan biz.AuditEntry, 256),
+		done:    make(chan struct{}),
+	}
+	go l.loop()
+	return l, nil
+}
+
+// Log 实现 biz.AuditLogger；缓冲满时直接丢弃，绝不阻塞调用方
+func (l *JSONLAuditLogger) Log(entry biz.AuditEntry) {
+	select {
+	case l.entries <- entry:
+	default:
+	}
+}
+
+// Close 刷完缓冲中的记录并关闭文件
+func (l *JSONLAuditLogger) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.entries)
+		<-l.done
+	})
+	return l.file.Close()
+}
+
+func (l *JSONLAuditLogger) loop() {
+	defer close(l.done)
+	enc := json.NewEncoder(l.file)
+	for entry := range l.entries {
+		// 单条写失败不影响后续记录
+		_ = enc.Encode(entry)
+	}
+}
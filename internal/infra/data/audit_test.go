@@ -0,0 +1,74 @@
+package data
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"devops-backend/internal/biz"
+)
+
+func TestJSONLAuditLogger_WritesEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	logger, err := NewJSONLAuditLogger(path)
+	if err != nil {
+		t.Fatalf("NewJSONLAuditLogger failed: %v", err)
+	}
+
+	logger.Log(biz.AuditEntry{Time: time.Now(), UserSub: "user-1", Model: "gpt-4o-mini", PromptHash: "abc"})
+	logger.Log(biz.AuditEntry{Time: time.Now(), UserSub: "user-2", Model: "claude-sonnet-4", ResponseHash: "def"})
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open audit file: %v", err)
+	}
+	defer f.Close()
+
+	var entries []biz.AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry biz.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid JSONL line: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].UserSub != "user-1" || entries[0].Model != "gpt-4o-mini" {
+		t.Fatalf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].ResponseHash != "def" {
+		t.Fatalf("unexpected second entry: %+v", entries[1])
+	}
+}
+
+func TestJSONLAuditLogger_LogNeverBlocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logger, err := NewJSONLAuditLogger(path)
+	if err != nil {
+		t.Fatalf("NewJSONLAuditLogger failed: %v", err)
+	}
+	defer logger.Close()
+
+	// 远超缓冲容量也不能阻塞调用方
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10000; i++ {
+			logger.Log(biz.AuditEntry{Model: "gpt-4o-mini"})
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Log blocked the caller")
+	}
+}
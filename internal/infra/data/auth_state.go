@@ -0,0 +1,103 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStateStore SQLite 实现的 PKCE/CSRF state 存储。
+// 相比内存实现，多实例部署（登录跳转与回调落在不同实例）和重启后仍能消费 state。
+type SQLiteStateStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStateStore 创建 SQLite state 存储（可与会话库共用同一 dbPath）
+func NewSQLiteStateStore(dbPath string) (*SQLiteStateStore, error) {
+	// 确保目录存在
+	dir := filepath.Dir(dbPath)
+	if dir != "" && dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS auth_states (
+			state TEXT PRIMARY KEY,
+			code_verifier TEXT NOT NULL DEFAULT '',
+			return_to TEXT NOT NULL DEFAULT '',
+			provider TEXT NOT NULL DEFAULT '',
+			expires_at DATETIME NOT NULL
+		)
+	`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create auth_states table: %w", err)
+	}
+
+	return &SQLiteStateStore{db: db}, nil
+}
+
+// SaveWithVerifier stores a state with expiry, code verifier (for PKCE), return URL, and provider
+func (s *SQLiteStateStore) SaveWithVerifier(state string, duration time.Duration, codeVerifier, returnTo, provider string) {
+	// 顺带清理过期 state，避免额外的后台 goroutine
+	if _, err := s.db.Exec("DELETE FROM auth_states WHERE expires_at < ?", time.Now().UTC()); err != nil {
+		slog.Warn("failed to clean expired auth states", "error", err)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO auth_states (state, code_verifier, return_to, provider, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, state, codeVerifier, returnTo, provider, time.Now().UTC().Add(duration))
+	if err != nil {
+		slog.Error("failed to save auth state", "error", err)
+	}
+}
+
+// VerifyAndGetVerifier checks and consumes a state, returning the code verifier, return URL, and provider
+func (s *SQLiteStateStore) VerifyAndGetVerifier(state string) (string, string, string, bool) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", "", "", false
+	}
+	defer tx.Rollback()
+
+	var codeVerifier, returnTo, provider string
+	var expiresAt time.Time
+	err = tx.QueryRow(`
+		SELECT code_verifier, return_to, provider, expires_at
+		FROM auth_states WHERE state = ?
+	`, state).Scan(&codeVerifier, &returnTo, &provider, &expiresAt)
+	if err != nil {
+		return "", "", "", false
+	}
+
+	// One-time use：无论是否过期都删除
+	if _, err := tx.Exec("DELETE FROM auth_states WHERE state = ?", state); err != nil {
+		return "", "", "", false
+	}
+	if err := tx.Commit(); err != nil {
+		return "", "", "", false
+	}
+
+	if time.Now().UTC().After(expiresAt) {
+		return "", "", "", false
+	}
+	return codeVerifier, returnTo, provider, true
+}
+
+// Close 关闭数据库连接
+func (s *SQLiteStateStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,77 @@
+package data
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStateStore(t *testing.T) (*SQLiteStateStore, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	store, err := NewSQLiteStateStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create state store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, dbPath
+}
+
+func TestSQLiteStateStore_SaveAndConsume(t *testing.T) {
+	store, _ := newTestStateStore(t)
+
+	store.SaveWithVerifier("state-1", time.Minute, "verifier-1", "http://app/return", "zitadel")
+
+	codeVerifier, returnTo, provider, ok := store.VerifyAndGetVerifier("state-1")
+	if !ok {
+		t.Fatal("expected state to verify")
+	}
+	if codeVerifier != "verifier-1" || returnTo != "http://app/return" || provider != "zitadel" {
+		t.Errorf("unexpected state data: %q %q %q", codeVerifier, returnTo, provider)
+	}
+
+	// One-time use: second consume must fail
+	if _, _, _, ok := store.VerifyAndGetVerifier("state-1"); ok {
+		t.Error("expected state to be consumed after first use")
+	}
+}
+
+func TestSQLiteStateStore_UnknownState(t *testing.T) {
+	store, _ := newTestStateStore(t)
+
+	if _, _, _, ok := store.VerifyAndGetVerifier("never-saved"); ok {
+		t.Error("expected unknown state to fail verification")
+	}
+}
+
+func TestSQLiteStateStore_ExpiredState(t *testing.T) {
+	store, _ := newTestStateStore(t)
+
+	store.SaveWithVerifier("state-expired", -time.Minute, "verifier", "", "")
+
+	if _, _, _, ok := store.VerifyAndGetVerifier("state-expired"); ok {
+		t.Error("expected expired state to fail verification")
+	}
+}
+
+func TestSQLiteStateStore_SurvivesReopen(t *testing.T) {
+	store, dbPath := newTestStateStore(t)
+
+	store.SaveWithVerifier("state-persist", time.Minute, "verifier-p", "http://app", "default")
+	store.Close()
+
+	// 模拟重启或回调落在另一个实例：新连接消费同一个 state
+	reopened, err := NewSQLiteStateStore(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen state store: %v", err)
+	}
+	defer reopened.Close()
+
+	codeVerifier, returnTo, provider, ok := reopened.VerifyAndGetVerifier("state-persist")
+	if !ok {
+		t.Fatal("expected state to survive reopen")
+	}
+	if codeVerifier != "verifier-p" || returnTo != "http://app" || provider != "default" {
+		t.Errorf("unexpected state data: %q %q %q", codeVerifier, returnTo, provider)
+	}
+}
@@ -0,0 +1,234 @@
+package data
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// fallbackReconnectInterval 降级模式下重试文件库的间隔
+const fallbackReconnectInterval = 30 * time.Second
+
+// NewInMemorySessionRepo 创建纯内存会话仓库（":memory:" SQLite，与文件仓库
+// 行为完全同构），进程退出即丢失；用于存储降级兜底和测试
+func NewInMemorySessionRepo() (biz.SessionRepo, error) {
+	repo, err := NewSQLiteSessionRepo(":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// 内存库按连接隔离，连接池必须收敛到单连接才能看到同一份数据
+	repo.(*sqliteSessionRepo).db.SetMaxOpenConns(1)
+	return repo, nil
+}
+
+// NewFallbackSessionRepo 创建降级会话仓库：先用内存库顶上，后台周期性重试
+// 文件库，重连成功后切换过去。降级期间写入内存的数据随切换丢弃——这是
+// 刻意取舍：文件库恢复后以持久数据为准，而不是把两份历史缝在一起。
+// interval <= 0 时使用默认重试间隔
+func NewFallbackSessionRepo(dbPath string, interval time.Duration) (biz.SessionRepo, error) {
+	memory, err := NewInMemorySessionRepo()
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = fallbackReconnectInterval
+	}
+	f := &fallbackSessionRepo{current: memory, dbPath: dbPath, stop: make(chan struct{})}
+	go f.reconnectLoop(interval)
+	return f, nil
+}
+
+// fallbackSessionRepo 委托型仓库：降级时指向内存库，重连成功后指向文件库
+type fallbackSessionRepo struct {
+	mu      sync.RWMutex
+	current biz.SessionRepo
+	dbPath  string
+
+	// autoTitleMaxLen 记录配置值，重连切换到文件库后重新应用
+	autoTitleMaxLen int
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// SetAutoTitleMaxLen 设置自动标题最大长度，并在重连后保持生效
+func (f *fallbackSessionRepo) SetAutoTitleMaxLen(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.autoTitleMaxLen = n
+	if repo, ok := f.current.(*sqliteSessionRepo); ok {
+		repo.SetAutoTitleMaxLen(n)
+	}
+}
+
+func (f *fallbackSessionRepo) repo() biz.SessionRepo {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.current
+}
+
+func (f *fallbackSessionRepo) reconnectLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-ticker.C:
+			if f.tryReconnect() {
+				return
+			}
+		}
+	}
+}
+
+// tryReconnect 重试打开文件库，成功则切换并关闭内存库，返回是否已切换
+func (f *fallbackSessionRepo) tryReconnect() bool {
+	repo, err := NewSQLiteSessionRepo(f.dbPath)
+	if err != nil {
+		slog.Debug("session repo still unavailable", "db_path", f.dbPath, "error", err)
+		return false
+	}
+	f.mu.Lock()
+	old := f.current
+	f.current = repo
+	if f.autoTitleMaxLen > 0 {
+		if s, ok := repo.(*sqliteSessionRepo); ok {
+			s.SetAutoTitleMaxLen(f.autoTitleMaxLen)
+		}
+	}
+	f.mu.Unlock()
+	old.Close()
+	slog.Warn("session repo reconnected, discarding in-memory fallback data", "db_path", f.dbPath)
+	return true
+}
+
+func (f *fallbackSessionRepo) NewConversation(ownerSub string) (string, string) {
+	return f.repo().NewConversation(ownerSub)
+}
+
+func (f *fallbackSessionRepo) CreateBranchWithMessage(parentMsgID int64, msg *schema.Message) (string, int64, error) {
+	return f.repo().CreateBranchWithMessage(parentMsgID, msg)
+}
+
+func (f *fallbackSessionRepo) CreateBranchFrom(parentMsgID int64, treeID string) (string, error) {
+	return f.repo().CreateBranchFrom(parentMsgID, treeID)
+}
+
+func (f *fallbackSessionRepo) SessionExists(sessionID, ownerSub string) bool {
+	return f.repo().SessionExists(sessionID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) GetTreeID(sessionID, ownerSub string) (string, error) {
+	return f.repo().GetTreeID(sessionID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) GetLastActiveSessionID(treeID, ownerSub string) (string, error) {
+	return f.repo().GetLastActiveSessionID(treeID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) GetSessionMessages(sessionID, ownerSub string) biz.Session {
+	return f.repo().GetSessionMessages(sessionID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) GetSessionMessagesPage(sessionID, ownerSub string, afterID int64, limit int) ([]*biz.ChatResponse, error) {
+	return f.repo().GetSessionMessagesPage(sessionID, ownerSub, afterID, limit)
+}
+
+func (f *fallbackSessionRepo) CountTreeMessages(sessionID string) (int, error) {
+	return f.repo().CountTreeMessages(sessionID)
+}
+
+func (f *fallbackSessionRepo) AppendMessage(sessionID string, msg *schema.Message, model string, partial bool) (int64, error) {
+	return f.repo().AppendMessage(sessionID, msg, model, partial)
+}
+
+func (f *fallbackSessionRepo) GetLastMessage(sessionID, ownerSub string) (int64, *biz.ChatResponse, error) {
+	return f.repo().GetLastMessage(sessionID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) GetMessage(msgID int64, ownerSub string) (*biz.ChatResponse, error) {
+	return f.repo().GetMessage(msgID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) UpdateMessage(msgID int64, msg *schema.Message) error {
+	return f.repo().UpdateMessage(msgID, msg)
+}
+
+func (f *fallbackSessionRepo) UpdateStreamedMessage(msgID int64, msg *schema.Message, model string, partial bool) error {
+	return f.repo().UpdateStreamedMessage(msgID, msg, model, partial)
+}
+
+func (f *fallbackSessionRepo) DeleteMessage(msgID int64) error {
+	return f.repo().DeleteMessage(msgID)
+}
+
+func (f *fallbackSessionRepo) DeleteTree(treeID, ownerSub string) error {
+	return f.repo().DeleteTree(treeID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) DeleteTrees(treeIDs []string, ownerSub string) (map[string]error, error) {
+	return f.repo().DeleteTrees(treeIDs, ownerSub)
+}
+
+func (f *fallbackSessionRepo) RestoreTree(treeID, ownerSub string) error {
+	return f.repo().RestoreTree(treeID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) SetPinned(treeID, ownerSub string, pinned bool) error {
+	return f.repo().SetPinned(treeID, ownerSub, pinned)
+}
+
+func (f *fallbackSessionRepo) SetTreeTitle(treeID, ownerSub, title string) error {
+	return f.repo().SetTreeTitle(treeID, ownerSub, title)
+}
+
+func (f *fallbackSessionRepo) SetTreeMetadata(treeID, ownerSub string, meta json.RawMessage) error {
+	return f.repo().SetTreeMetadata(treeID, ownerSub, meta)
+}
+
+func (f *fallbackSessionRepo) SetTreeSystemPrompt(treeID, ownerSub, prompt string) error {
+	return f.repo().SetTreeSystemPrompt(treeID, ownerSub, prompt)
+}
+
+func (f *fallbackSessionRepo) GetTreeSystemPrompt(treeID, ownerSub string) (string, error) {
+	return f.repo().GetTreeSystemPrompt(treeID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) SetGeneratedTitle(treeID, ownerSub, title string) (bool, error) {
+	return f.repo().SetGeneratedTitle(treeID, ownerSub, title)
+}
+
+func (f *fallbackSessionRepo) PurgeTree(treeID string) error {
+	return f.repo().PurgeTree(treeID)
+}
+
+func (f *fallbackSessionRepo) PurgeTreesDeletedBefore(cutoff time.Time) (int, error) {
+	return f.repo().PurgeTreesDeletedBefore(cutoff)
+}
+
+func (f *fallbackSessionRepo) ListTrees(ownerSub string) ([]biz.SessionTreeInfo, error) {
+	return f.repo().ListTrees(ownerSub)
+}
+
+func (f *fallbackSessionRepo) ListTreesByMetadata(ownerSub, key, value string) ([]biz.SessionTreeInfo, error) {
+	return f.repo().ListTreesByMetadata(ownerSub, key, value)
+}
+
+func (f *fallbackSessionRepo) ListTrashTrees(ownerSub string) ([]biz.SessionTreeInfo, error) {
+	return f.repo().ListTrashTrees(ownerSub)
+}
+
+func (f *fallbackSessionRepo) ListSessionsInTree(treeID, ownerSub string) ([]biz.SessionBranchInfo, error) {
+	return f.repo().ListSessionsInTree(treeID, ownerSub)
+}
+
+func (f *fallbackSessionRepo) Close() error {
+	f.stopOnce.Do(func() { close(f.stop) })
+	return f.repo().Close()
+}
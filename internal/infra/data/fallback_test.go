@@ -0,0 +1,122 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func newInMemoryTestRepo(t *testing.T) biz.SessionRepo {
+	t.Helper()
+	repo, err := NewInMemorySessionRepo()
+	if err != nil {
+		t.Fatalf("failed to create in-memory repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// TestInMemorySessionRepo_ConversationFlow 内存库支撑完整对话流程：
+// 建会话、追加、分支、列表，与文件库行为一致。
+func TestInMemorySessionRepo_ConversationFlow(t *testing.T) {
+	repo := newInMemoryTestRepo(t)
+	treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+
+	appendTestMessage(t, repo, sessionID, "user", "q1")
+	branchPoint := appendTestMessage(t, repo, sessionID, "assistant", "a1")
+	appendTestMessage(t, repo, sessionID, "user", "q2")
+
+	session := repo.GetSessionMessages(sessionID, biz.AnonymousOwner)
+	if len(session) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(session))
+	}
+
+	branchID, _, err := repo.CreateBranchWithMessage(branchPoint, &schema.Message{Role: schema.User, Content: "q2-alt"})
+	if err != nil {
+		t.Fatalf("CreateBranchWithMessage failed: %v", err)
+	}
+	branch := repo.GetSessionMessages(branchID, biz.AnonymousOwner)
+	if len(branch) != 3 || branch[2].Content != "q2-alt" {
+		t.Fatalf("unexpected branch chain: %#v", branch)
+	}
+
+	trees, err := repo.ListTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrees failed: %v", err)
+	}
+	if len(trees) != 1 || trees[0].ID != treeID {
+		t.Fatalf("unexpected trees: %#v", trees)
+	}
+}
+
+// TestInMemorySessionRepo_IsolatedPerInstance 两个内存库实例互不可见。
+func TestInMemorySessionRepo_IsolatedPerInstance(t *testing.T) {
+	first := newInMemoryTestRepo(t)
+	second := newInMemoryTestRepo(t)
+
+	_, sessionID := first.NewConversation(biz.AnonymousOwner)
+	if !first.SessionExists(sessionID, biz.AnonymousOwner) {
+		t.Fatal("session must exist in its own repo")
+	}
+	if second.SessionExists(sessionID, biz.AnonymousOwner) {
+		t.Fatal("in-memory repos must not share state")
+	}
+}
+
+// TestFallbackSessionRepo_ServesWhileDegradedAndReconnects 文件库打不开时
+// 内存库顶上；路径恢复后重连切换到文件库，新数据落盘。
+func TestFallbackSessionRepo_ServesWhileDegradedAndReconnects(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "sessions.db")
+	// 占住 db 路径让文件库打不开
+	if err := os.Mkdir(dbPath, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSQLiteSessionRepo(dbPath); err == nil {
+		t.Fatal("precondition: file repo must fail to open")
+	}
+
+	repo, err := NewFallbackSessionRepo(dbPath, 0)
+	if err != nil {
+		t.Fatalf("NewFallbackSessionRepo failed: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	fallback := repo.(*fallbackSessionRepo)
+
+	// 降级模式照常服务
+	_, degradedSession := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, degradedSession, "user", "degraded")
+	if !repo.SessionExists(degradedSession, biz.AnonymousOwner) {
+		t.Fatal("degraded repo must serve sessions")
+	}
+
+	// 路径仍不可用时重连失败，保持内存库
+	if fallback.tryReconnect() {
+		t.Fatal("reconnect must fail while the path is blocked")
+	}
+
+	// 路径恢复后重连切换到文件库
+	if err := os.Remove(dbPath); err != nil {
+		t.Fatal(err)
+	}
+	if !fallback.tryReconnect() {
+		t.Fatal("reconnect must succeed once the path is available")
+	}
+	// 切换后的写入要真正落盘：另开一个文件库实例能看到
+	_, persistedSession := repo.NewConversation(biz.AnonymousOwner)
+	verify, err := NewSQLiteSessionRepo(dbPath)
+	if err != nil {
+		t.Fatalf("failed to reopen file repo: %v", err)
+	}
+	defer verify.Close()
+	if !verify.SessionExists(persistedSession, biz.AnonymousOwner) {
+		t.Fatal("post-reconnect sessions must be persisted to the file DB")
+	}
+	// 降级期间的内存数据随切换丢弃
+	if repo.SessionExists(degradedSession, biz.AnonymousOwner) {
+		t.Fatal("in-memory fallback data must be discarded after reconnect")
+	}
+}
@@ -0,0 +1,96 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"devops-backend/internal/biz"
+)
+
+func TestSetTreeMetadata_SetGetClear(t *testing.T) {
+	repo := newTestRepo(t)
+	treeID, sessionID := repo.NewConversation("alice")
+	appendTestMessage(t, repo, sessionID, "user", "hello")
+
+	meta := json.RawMessage(`{"ticket":"OPS-42","stage":"triage"}`)
+	if err := repo.SetTreeMetadata(treeID, "alice", meta); err != nil {
+		t.Fatalf("failed to set metadata: %v", err)
+	}
+
+	trees, err := repo.ListTrees("alice")
+	if err != nil {
+		t.Fatalf("failed to list trees: %v", err)
+	}
+	if len(trees) != 1 {
+		t.Fatalf("expected 1 tree, got %d", len(trees))
+	}
+	var got map[string]string
+	if err := json.Unmarshal(trees[0].Metadata, &got); err != nil {
+		t.Fatalf("metadata is not the stored JSON: %v", err)
+	}
+	if got["ticket"] != "OPS-42" || got["stage"] != "triage" {
+		t.Fatalf("unexpected metadata: %v", got)
+	}
+
+	// 空 meta 清空
+	if err := repo.SetTreeMetadata(treeID, "alice", nil); err != nil {
+		t.Fatalf("failed to clear metadata: %v", err)
+	}
+	trees, _ = repo.ListTrees("alice")
+	if trees[0].Metadata != nil {
+		t.Fatalf("expected metadata cleared, got %s", trees[0].Metadata)
+	}
+}
+
+func TestSetTreeMetadata_OwnerScopedNotFound(t *testing.T) {
+	repo := newTestRepo(t)
+	treeID, _ := repo.NewConversation("alice")
+
+	err := repo.SetTreeMetadata(treeID, "mallory", json.RawMessage(`{"x":1}`))
+	if !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound for foreign owner, got %v", err)
+	}
+	if err := repo.SetTreeMetadata("tree_missing", "alice", json.RawMessage(`{"x":1}`)); !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound for missing tree, got %v", err)
+	}
+}
+
+func TestListTreesByMetadata_FiltersByKeyValue(t *testing.T) {
+	repo := newTestRepo(t)
+
+	triageID, s1 := repo.NewConversation("alice")
+	appendTestMessage(t, repo, s1, "user", "first")
+	doneID, s2 := repo.NewConversation("alice")
+	appendTestMessage(t, repo, s2, "user", "second")
+	_, s3 := repo.NewConversation("alice")
+	appendTestMessage(t, repo, s3, "user", "third")
+
+	if err := repo.SetTreeMetadata(triageID, "alice", json.RawMessage(`{"stage":"triage"}`)); err != nil {
+		t.Fatalf("failed to set metadata: %v", err)
+	}
+	if err := repo.SetTreeMetadata(doneID, "alice", json.RawMessage(`{"stage":"done"}`)); err != nil {
+		t.Fatalf("failed to set metadata: %v", err)
+	}
+
+	trees, err := repo.ListTreesByMetadata("alice", "stage", "triage")
+	if err != nil {
+		t.Fatalf("failed to list by metadata: %v", err)
+	}
+	if len(trees) != 1 || trees[0].ID != triageID {
+		t.Fatalf("expected only the triage tree, got %+v", trees)
+	}
+
+	// 不存在的键值不匹配任何树（含未设置 metadata 的树）
+	if trees, _ := repo.ListTreesByMetadata("alice", "stage", "archived"); len(trees) != 0 {
+		t.Fatalf("expected no match, got %+v", trees)
+	}
+	if trees, _ := repo.ListTreesByMetadata("alice", "missing", ""); len(trees) != 0 {
+		t.Fatalf("expected no match for unknown key, got %+v", trees)
+	}
+
+	// 过滤同样按 owner 隔离
+	if trees, _ := repo.ListTreesByMetadata("bob", "stage", "triage"); len(trees) != 0 {
+		t.Fatalf("expected no cross-owner match, got %+v", trees)
+	}
+}
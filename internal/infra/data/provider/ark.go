@@ -14,8 +14,12 @@ import (
 
 // newArkRaw 创建原始 Ark client
 func newArkRaw(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
 	})
 }
 
@@ -60,12 +64,15 @@ func (a *arkAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatM
 	return &arkAdapter{raw: m}, nil
 }
 
+// SupportsThinking Ark 支持按请求开关 thinking
+func (a *arkAdapter) SupportsThinking() bool { return true }
+
 func (a *arkAdapter) injectOpts(opts []model.Option) []model.Option {
-	params := biz.GetParams(opts...)
-	if params.Thinking == nil {
+	enable, set := biz.ThinkingDecision(biz.GetParams(opts...), true)
+	if !set {
 		return opts
 	}
-	if *params.Thinking {
+	if enable {
 		return append(opts, ark.WithThinking(&arkModel.Thinking{Type: arkModel.ThinkingTypeEnabled}))
 	}
 	return append(opts, ark.WithThinking(&arkModel.Thinking{Type: arkModel.ThinkingTypeDisabled}))
@@ -21,8 +21,16 @@ const (
 type ModelCapabilities struct {
 	SupportedModalities  map[ModalityType]bool `json:"supported_modalities"`
 	RequiresNonStreaming bool                  `json:"requires_non_streaming"`
+	// MaxContextTokens 上下文窗口大小（token 数），0 表示未知（不做裁剪）
+	MaxContextTokens int `json:"max_context_tokens"`
+	// MaxOutputTokens 单次回复的 token 上限，0 表示未知（取默认值）
+	MaxOutputTokens int `json:"max_output_tokens"`
 }
 
+// DefaultMaxOutputTokens 未知模型的单次回复 token 上限，
+// 取一个宽松的大值，避免误伤长输出场景
+const DefaultMaxOutputTokens = 40000
+
 // ModelCapabilityRegistry 模型能力注册表
 type ModelCapabilityRegistry struct {
 	mu           sync.RWMutex
@@ -53,6 +61,16 @@ func (r *ModelCapabilityRegistry) loadHardcodedCapabilities() {
 		SupportedModalities: map[ModalityType]bool{
 			ModalityText: true, ModalityImage: false, ModalityAudio: false, ModalityVideo: false,
 		},
+		MaxContextTokens: 65536,
+		MaxOutputTokens:  8192,
+	}
+
+	r.capabilities["claude"] = &ModelCapabilities{
+		SupportedModalities: map[ModalityType]bool{
+			ModalityText: true, ModalityImage: true, ModalityAudio: false, ModalityVideo: false,
+		},
+		MaxContextTokens: 200000,
+		MaxOutputTokens:  40000,
 	}
 
 	for _, m := range []string{"gemini-3-pro-image-preview", "gemini-2.5-flash-image"} {
@@ -61,6 +79,8 @@ func (r *ModelCapabilityRegistry) loadHardcodedCapabilities() {
 				ModalityText: true, ModalityImage: true, ModalityAudio: true, ModalityVideo: true,
 			},
 			RequiresNonStreaming: true,
+			MaxContextTokens:     32768,
+			MaxOutputTokens:      8192,
 		}
 	}
 }
@@ -81,6 +101,50 @@ func (r *ModelCapabilityRegistry) loadFromConfig(configPath string) {
 	}
 }
 
+// Snapshot 返回注册表当前生效内容的深拷贝（管理端查看用）
+func (r *ModelCapabilityRegistry) Snapshot() map[string]ModelCapabilities {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make(map[string]ModelCapabilities, len(r.capabilities))
+	for name, caps := range r.capabilities {
+		clone := *caps
+		clone.SupportedModalities = make(map[ModalityType]bool, len(caps.SupportedModalities))
+		for k, v := range caps.SupportedModalities {
+			clone.SupportedModalities[k] = v
+		}
+		out[name] = clone
+	}
+	return out
+}
+
+// SetCapabilities 运行时覆盖某个模型（或关键词）的能力，立即对后续请求生效
+func (r *ModelCapabilityRegistry) SetCapabilities(name string, caps ModelCapabilities) {
+	if caps.SupportedModalities == nil {
+		caps.SupportedModalities = map[ModalityType]bool{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.capabilities[name] = &caps
+}
+
+// PersistCapability 把单个模型的能力写入外部 JSON 覆盖文件（读-改-写），
+// 让运行时调整在重启后继续生效；文件不存在时创建
+func (r *ModelCapabilityRegistry) PersistCapability(name string, caps ModelCapabilities) error {
+	ext := map[string]*ModelCapabilities{}
+	if data, err := os.ReadFile(capabilitiesConfig); err == nil {
+		// 文件损坏时从空覆盖开始，与 loadFromConfig 的容错行为一致
+		_ = json.Unmarshal(data, &ext)
+	}
+	ext[name] = &caps
+
+	data, err := json.MarshalIndent(ext, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(capabilitiesConfig, data, 0o644)
+}
+
 func (r *ModelCapabilityRegistry) GetCapabilities(name string) *ModelCapabilities {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -110,6 +174,24 @@ func (r *ModelCapabilityRegistry) SupportsModality(name string, modality Modalit
 	return caps.SupportedModalities[modality]
 }
 
+// MaxContextTokens 返回模型上下文窗口大小（token 数），未知模型返回 0
+func (r *ModelCapabilityRegistry) MaxContextTokens(modelName string) int {
+	caps := r.GetCapabilities(modelName)
+	if caps == nil {
+		return 0
+	}
+	return caps.MaxContextTokens
+}
+
+// MaxOutputTokens 返回模型单次回复的 token 上限，未知模型取宽松的默认值
+func (r *ModelCapabilityRegistry) MaxOutputTokens(modelName string) int {
+	caps := r.GetCapabilities(modelName)
+	if caps == nil || caps.MaxOutputTokens <= 0 {
+		return DefaultMaxOutputTokens
+	}
+	return caps.MaxOutputTokens
+}
+
 func (r *ModelCapabilityRegistry) RequiresNonStreamingMode(modelName string) bool {
 	caps := r.GetCapabilities(modelName)
 	if caps == nil {
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestRegistry_MaxOutputTokens(t *testing.T) {
+	registry := GetModelCapabilityRegistry()
+
+	if got := registry.MaxOutputTokens("claude-sonnet-4"); got != 40000 {
+		t.Errorf("claude max output = %d, want 40000", got)
+	}
+	if got := registry.MaxOutputTokens("deepseek-chat"); got != 8192 {
+		t.Errorf("deepseek max output = %d, want 8192", got)
+	}
+	// 未知模型取宽松默认值，避免误伤长输出场景
+	if got := registry.MaxOutputTokens("totally-unknown-model"); got != DefaultMaxOutputTokens {
+		t.Errorf("unknown model max output = %d, want %d", got, DefaultMaxOutputTokens)
+	}
+}
+
+func TestRegistry_MaxContextTokens(t *testing.T) {
+	registry := GetModelCapabilityRegistry()
+
+	if got := registry.MaxContextTokens("claude-sonnet-4"); got != 200000 {
+		t.Errorf("claude max context = %d, want 200000", got)
+	}
+	// 未知模型返回 0（表示不做上下文裁剪）
+	if got := registry.MaxContextTokens("totally-unknown-model"); got != 0 {
+		t.Errorf("unknown model max context = %d, want 0", got)
+	}
+}
+
+// TestRegistry_SetCapabilitiesAffectsFiltering 运行时覆盖能力后，
+// 多模态过滤立即按新能力执行。
+func TestRegistry_SetCapabilitiesAffectsFiltering(t *testing.T) {
+	registry := GetModelCapabilityRegistry()
+	const name = "admin-tuned-model"
+
+	b64 := "aGVsbG8="
+	messages := []*schema.Message{{
+		Role:    schema.User,
+		Content: "look at this",
+		UserInputMultiContent: []schema.MessageInputPart{{
+			Type: schema.ChatMessagePartTypeImageURL,
+			Image: &schema.MessageInputImage{MessagePartCommon: schema.MessagePartCommon{
+				Base64Data: &b64, MIMEType: "image/png",
+			}},
+		}},
+	}}
+
+	// 未注册时不做过滤，图像分片原样保留
+	filtered := FilterMultimodalContent(messages, name)
+	if len(filtered[0].UserInputMultiContent) != 1 || filtered[0].UserInputMultiContent[0].Image == nil {
+		t.Fatalf("unknown model must keep image parts: %+v", filtered[0].UserInputMultiContent)
+	}
+
+	registry.SetCapabilities(name, ModelCapabilities{
+		SupportedModalities: map[ModalityType]bool{ModalityText: true, ModalityImage: false},
+	})
+
+	filtered = FilterMultimodalContent(messages, name)
+	parts := filtered[0].UserInputMultiContent
+	if len(parts) != 1 || parts[0].Image != nil || parts[0].Text != "[Image]" {
+		t.Fatalf("expected image replaced by placeholder after update, got %+v", parts)
+	}
+
+	if snap, ok := registry.Snapshot()[name]; !ok || snap.SupportedModalities[ModalityImage] {
+		t.Fatalf("snapshot does not reflect the update: %+v", snap)
+	}
+}
+
+// TestRegistry_PersistCapability 持久化写入外部 JSON 覆盖文件并保留已有条目。
+func TestRegistry_PersistCapability(t *testing.T) {
+	orig := capabilitiesConfig
+	capabilitiesConfig = filepath.Join(t.TempDir(), "model_capabilities.json")
+	t.Cleanup(func() { capabilitiesConfig = orig })
+
+	if err := os.WriteFile(capabilitiesConfig, []byte(`{"existing-model":{"supported_modalities":{"text":true}}}`), 0o644); err != nil {
+		t.Fatalf("seed config: %v", err)
+	}
+
+	registry := GetModelCapabilityRegistry()
+	if err := registry.PersistCapability("persisted-model", ModelCapabilities{
+		SupportedModalities: map[ModalityType]bool{ModalityText: true},
+		MaxContextTokens:    4096,
+	}); err != nil {
+		t.Fatalf("persist failed: %v", err)
+	}
+
+	data, err := os.ReadFile(capabilitiesConfig)
+	if err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	var ext map[string]*ModelCapabilities
+	if err := json.Unmarshal(data, &ext); err != nil {
+		t.Fatalf("file is not valid JSON: %v", err)
+	}
+	if _, ok := ext["existing-model"]; !ok {
+		t.Error("existing entries must be preserved")
+	}
+	if got, ok := ext["persisted-model"]; !ok || got.MaxContextTokens != 4096 {
+		t.Errorf("persisted entry missing or wrong: %+v", got)
+	}
+}
@@ -15,15 +15,26 @@ import (
 
 // newClaudeRaw 创建原始 Claude client（忠实反映厂商默认行为）
 func newClaudeRaw(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return claude.NewChatModel(ctx, &claude.Config{
-		BaseURL: &cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		BaseURL: &cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
 	})
 }
 
 // newClaude 创建 Claude 模型 + thinking adapter
 func newClaude(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// 输出上限从能力注册表读取，便于按模型配置而不是到处硬编码
 	raw, err := claude.NewChatModel(ctx, &claude.Config{
-		BaseURL: &cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, MaxTokens: 40000,
+		BaseURL: &cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		MaxTokens:  GetModelCapabilityRegistry().MaxOutputTokens(modelName),
+		HTTPClient: httpClient,
 	})
 	if err != nil {
 		return nil, err
@@ -77,14 +88,27 @@ func (a *claudeAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingCh
 	return &claudeAdapter{raw: m, modelName: a.modelName}, nil
 }
 
+// SupportsThinking Claude 支持按请求开关 thinking
+func (a *claudeAdapter) SupportsThinking() bool { return true }
+
 func (a *claudeAdapter) injectOpts(opts []model.Option) []model.Option {
 	params := biz.GetParams(opts...)
-	if params.Thinking != nil && *params.Thinking {
-		opts = append(opts, claude.WithThinking(&claude.Thinking{Enable: true, BudgetTokens: 32000}))
+	if enable, set := biz.ThinkingDecision(params, true); set && enable {
+		opts = append(opts, claude.WithThinking(claudeThinking(params)))
 	}
 	return opts
 }
 
+// claudeThinking maps RequestParams to the claude thinking option,
+// overriding the default budget when ThinkingBudget is set.
+func claudeThinking(params *biz.RequestParams) *claude.Thinking {
+	budget := 32000
+	if params.ThinkingBudget != nil {
+		budget = *params.ThinkingBudget
+	}
+	return &claude.Thinking{Enable: true, BudgetTokens: budget}
+}
+
 func collectStreamToMessage(sr *schema.StreamReader[*schema.Message]) (*schema.Message, error) {
 	var full schema.Message
 	full.Role = schema.Assistant
@@ -12,15 +12,23 @@ import (
 
 // newDeepSeekRaw 创建原始 DeepSeek client
 func newDeepSeekRaw(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return deepseek.NewChatModel(ctx, &deepseek.ChatModelConfig{
-		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
 	})
 }
 
 // newDeepSeek 创建 DeepSeek 模型 + 多模态过滤 adapter
 func newDeepSeek(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	raw, err := deepseek.NewChatModel(ctx, &deepseek.ChatModelConfig{
-		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
 	})
 	if err != nil {
 		return nil, err
@@ -50,12 +58,41 @@ func (a *deepSeekAdapter) IsCallbacksEnabled() bool {
 	return true
 }
 
+// SupportsThinking DeepSeek 推理由模型自身决定，thinking 开关不生效
+func (a *deepSeekAdapter) SupportsThinking() bool { return false }
+
 func (a *deepSeekAdapter) Generate(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.Message, error) {
-	return a.raw.Generate(ctx, FilterMultimodalContent(messages, "deepseek"), opts...)
+	return a.raw.Generate(ctx, stripAssistantReasoning(FilterMultimodalContent(messages, "deepseek")), opts...)
 }
 
 func (a *deepSeekAdapter) Stream(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
-	return a.raw.Stream(ctx, FilterMultimodalContent(messages, "deepseek"), opts...)
+	return a.raw.Stream(ctx, stripAssistantReasoning(FilterMultimodalContent(messages, "deepseek")), opts...)
+}
+
+// stripAssistantReasoning 去掉历史助手消息上的 ReasoningContent 再发送：
+// deepseek-reasoner 拒绝输入里带 reasoning_content 的请求，推理内容只存库展示，
+// 多轮对话回放时不能跟着历史一起发回去。不修改入参
+func stripAssistantReasoning(messages []*schema.Message) []*schema.Message {
+	var out []*schema.Message
+	for i, msg := range messages {
+		if msg == nil || msg.Role != schema.Assistant || msg.ReasoningContent == "" {
+			if out != nil {
+				out = append(out, msg)
+			}
+			continue
+		}
+		if out == nil {
+			out = make([]*schema.Message, 0, len(messages))
+			out = append(out, messages[:i]...)
+		}
+		stripped := *msg
+		stripped.ReasoningContent = ""
+		out = append(out, &stripped)
+	}
+	if out == nil {
+		return messages
+	}
+	return out
 }
 
 func (a *deepSeekAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// reasoningCaptureModel 记录实际收到的消息列表
+type reasoningCaptureModel struct {
+	got []*schema.Message
+}
+
+func (m *reasoningCaptureModel) Generate(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	m.got = messages
+	return &schema.Message{Role: schema.Assistant, Content: "ok"}, nil
+}
+
+func (m *reasoningCaptureModel) Stream(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	m.got = messages
+	return schema.StreamReaderFromArray([]*schema.Message{{Role: schema.Assistant, Content: "ok"}}), nil
+}
+
+func (m *reasoningCaptureModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// TestDeepSeekAdapter_StripsHistoricalReasoning 多轮历史里的助手 reasoning_content
+// 发送前被去掉（deepseek-reasoner 拒绝带推理内容的输入），原消息不受影响。
+func TestDeepSeekAdapter_StripsHistoricalReasoning(t *testing.T) {
+	capture := &reasoningCaptureModel{}
+	adapter := &deepSeekAdapter{raw: capture}
+
+	history := []*schema.Message{
+		{Role: schema.User, Content: "q1"},
+		{Role: schema.Assistant, Content: "a1", ReasoningContent: "thinking about q1"},
+		{Role: schema.User, Content: "q2"},
+		{Role: schema.Assistant, Content: "a2", ReasoningContent: "thinking about q2"},
+		{Role: schema.User, Content: "q3"},
+	}
+
+	if _, err := adapter.Generate(context.Background(), history); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	if len(capture.got) != len(history) {
+		t.Fatalf("expected %d messages, got %d", len(history), len(capture.got))
+	}
+	for i, msg := range capture.got {
+		if msg.Role == schema.Assistant && msg.ReasoningContent != "" {
+			t.Errorf("message %d still carries reasoning content: %q", i, msg.ReasoningContent)
+		}
+	}
+	if capture.got[1].Content != "a1" || capture.got[3].Content != "a2" {
+		t.Error("assistant answer text must be preserved")
+	}
+	// 存储侧展示用的原消息不能被改掉
+	if history[1].ReasoningContent != "thinking about q1" || history[3].ReasoningContent != "thinking about q2" {
+		t.Error("input history must not be mutated")
+	}
+
+	// 流式路径走同一套清洗
+	if _, err := adapter.Stream(context.Background(), history); err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	if capture.got[1].ReasoningContent != "" {
+		t.Error("stream path must strip reasoning content too")
+	}
+}
+
+// TestStripAssistantReasoning_NoReasoningUntouched 没有推理内容时原切片原样返回
+func TestStripAssistantReasoning_NoReasoningUntouched(t *testing.T) {
+	messages := []*schema.Message{
+		{Role: schema.User, Content: "hi"},
+		{Role: schema.Assistant, Content: "hello"},
+	}
+	out := stripAssistantReasoning(messages)
+	if len(out) != 2 || out[0] != messages[0] || out[1] != messages[1] {
+		t.Error("messages without reasoning must pass through unchanged")
+	}
+}
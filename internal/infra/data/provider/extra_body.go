@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Responses client 没有 extra-fields 机制，请求级的厂商自定义参数（extra）
+// 通过 context 带到 HTTP 层，由 transport 在发送前合入 JSON 请求体。
+
+type extraBodyFieldsKey struct{}
+
+// withExtraBodyFields 把 extra 参数挂到 ctx，供 extraBodyTransport 读取；
+// extra 为空时原样返回 ctx
+func withExtraBodyFields(ctx context.Context, extra map[string]any) context.Context {
+	if len(extra) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, extraBodyFieldsKey{}, extra)
+}
+
+// extraBodyClient 包装 HTTP client，对携带 extra 参数的 JSON POST 请求做请求体合并；
+// base 为 nil 时基于默认 Transport 构造
+func extraBodyClient(base *http.Client) *http.Client {
+	var client http.Client
+	if base != nil {
+		client = *base
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &extraBodyTransport{base: transport}
+	return &client
+}
+
+type extraBodyTransport struct {
+	base http.RoundTripper
+}
+
+func (t *extraBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	extra, _ := req.Context().Value(extraBodyFieldsKey{}).(map[string]any)
+	if len(extra) == 0 || req.Body == nil || req.Method != http.MethodPost ||
+		!strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		return t.base.RoundTrip(req)
+	}
+
+	raw, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil {
+		// 非 JSON 对象的请求体原样发送，不做合并
+		return t.base.RoundTrip(cloneWithBody(req, raw))
+	}
+	// extra 覆盖同名字段：这是显式开启的高级透传，以调用方为准
+	for k, v := range extra {
+		body[k] = v
+	}
+	merged, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(cloneWithBody(req, merged))
+}
+
+func cloneWithBody(req *http.Request, body []byte) *http.Request {
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.ContentLength = int64(len(body))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return clone
+}
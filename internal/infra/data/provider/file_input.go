@@ -0,0 +1,215 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Responses client 把文件统一序列化成 input_file + file_url（见其 toUserMultiContent），
+// 但 OpenAI 实际还接受已上传的 file_id 以及 filename + base64 file_data。
+// client 不可改，与 extra 参数、SSE 组帧一样在 HTTP 层兜底：
+// 发送前把哨兵形式的 file_url 重写成对应字段。
+
+// UploadedFileScheme 已上传文件的哨兵 URL 前缀：把 file 部分的 URL 设为
+// "openai-file://<file_id>"，出站时会被重写为 {"type":"input_file","file_id":...}
+const UploadedFileScheme = "openai-file://"
+
+// fileInputClient 包装 HTTP client，发送前重写请求体里的哨兵 input_file 项
+func fileInputClient(base *http.Client) *http.Client {
+	var client http.Client
+	if base != nil {
+		client = *base
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &fileInputTransport{base: transport}
+	return &client
+}
+
+type fileInputTransport struct {
+	base http.RoundTripper
+}
+
+func (t *fileInputTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Method != http.MethodPost ||
+		!strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		return t.base.RoundTrip(req)
+	}
+
+	raw, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil || !rewriteFileInputs(body) {
+		// 非 JSON 或没有需要重写的文件项，原样发送
+		return t.base.RoundTrip(cloneWithBody(req, raw))
+	}
+	rewritten, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(cloneWithBody(req, rewritten))
+}
+
+// rewriteFileInputs 遍历 input[].content[] 重写哨兵 input_file 项，返回是否有改动：
+// openai-file://<id> → file_id；data URL → filename + file_data（base64 原样保留）
+func rewriteFileInputs(body map[string]any) bool {
+	input, ok := body["input"].([]any)
+	if !ok {
+		return false
+	}
+	changed := false
+	for _, item := range input {
+		m, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		content, ok := m["content"].([]any)
+		if !ok {
+			continue
+		}
+		for _, c := range content {
+			cm, ok := c.(map[string]any)
+			if !ok || cm["type"] != "input_file" {
+				continue
+			}
+			fu, ok := cm["file_url"].(map[string]any)
+			if !ok {
+				continue
+			}
+			url, _ := fu["url"].(string)
+			switch {
+			case strings.HasPrefix(url, UploadedFileScheme):
+				delete(cm, "file_url")
+				cm["file_id"] = strings.TrimPrefix(url, UploadedFileScheme)
+				changed = true
+			case strings.HasPrefix(url, "data:"):
+				delete(cm, "file_url")
+				cm["filename"] = dataURLFilename(url)
+				cm["file_data"] = stripDataURLName(url)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// dataURLFilename 从 data URL 的 name 参数取文件名
+// （如 data:application/pdf;name=report.pdf;base64,...），
+// 未携带时按 MIME 子类型兜底（upload.pdf）
+func dataURLFilename(url string) string {
+	meta, _, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok {
+		return "upload"
+	}
+	mediaType := ""
+	for i, param := range strings.Split(meta, ";") {
+		if i == 0 {
+			mediaType = param
+			continue
+		}
+		if name, ok := strings.CutPrefix(param, "name="); ok && name != "" {
+			return name
+		}
+	}
+	if _, subtype, ok := strings.Cut(mediaType, "/"); ok && subtype != "" {
+		return "upload." + subtype
+	}
+	return "upload"
+}
+
+// stripDataURLName 去掉 data URL 里的 name 参数，保留标准形式的 file_data
+func stripDataURLName(url string) string {
+	meta, data, ok := strings.Cut(strings.TrimPrefix(url, "data:"), ",")
+	if !ok {
+		return url
+	}
+	params := strings.Split(meta, ";")
+	kept := params[:0]
+	for _, param := range params {
+		if strings.HasPrefix(param, "name=") {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	return "data:" + strings.Join(kept, ";") + "," + data
+}
+
+// UploadFile 把文件上传到指定 client 的 /files 接口并返回 file_id，
+// 供后续请求以 openai-file://<file_id> 引用（PDF 分析等场景）。
+// clientName 为空时用 openai，purpose 为空时用 user_data
+func (m *MixedProvider) UploadFile(ctx context.Context, clientName, filename string, data []byte, purpose string) (string, error) {
+	if clientName == "" {
+		clientName = "openai"
+	}
+	if purpose == "" {
+		purpose = "user_data"
+	}
+	cfg := m.clientConfig(clientName)
+	if cfg.BaseURL == "" && cfg.APIKey == "" {
+		return "", fmt.Errorf("client %q is not configured in eino.clients", clientName)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.WriteField("purpose", purpose); err != nil {
+		return "", err
+	}
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(cfg.BaseURL, "/")+"/files", &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return "", err
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("upload file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return "", fmt.Errorf("read upload response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("upload file: status %d, body: %s", resp.StatusCode, respBody)
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(respBody, &out); err != nil || out.ID == "" {
+		return "", fmt.Errorf("upload file: unexpected response: %s", respBody)
+	}
+	return out.ID, nil
+}
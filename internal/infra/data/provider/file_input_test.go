@@ -0,0 +1,178 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"devops-backend/internal/conf"
+)
+
+// captureTransport 记录最终发出的请求体
+type captureTransport struct {
+	body []byte
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		t.body, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader("{}")),
+	}, nil
+}
+
+// sendThroughTransport 把 JSON 请求体送过指定重写 transport，返回实际发出的请求体
+func sendThroughTransport(t *testing.T, wrap func(http.RoundTripper) http.RoundTripper, body string) map[string]any {
+	t.Helper()
+	capture := &captureTransport{}
+	client := &http.Client{Transport: wrap(capture)}
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.openai.com/v1/responses", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var sent map[string]any
+	if err := json.Unmarshal(capture.body, &sent); err != nil {
+		t.Fatalf("sent body is not JSON: %v", err)
+	}
+	return sent
+}
+
+func fileWrap(rt http.RoundTripper) http.RoundTripper { return &fileInputTransport{base: rt} }
+
+func firstContentItem(t *testing.T, body map[string]any) map[string]any {
+	t.Helper()
+	content := body["input"].([]any)[0].(map[string]any)["content"].([]any)
+	return content[0].(map[string]any)
+}
+
+// TestFileInputTransport_Base64FileData base64 data URL 重写为 filename + file_data。
+func TestFileInputTransport_Base64FileData(t *testing.T) {
+	sent := sendThroughTransport(t, fileWrap, `{
+		"model": "gpt-5",
+		"input": [{"role":"user","content":[
+			{"type":"input_file","file_url":{"url":"data:application/pdf;name=report.pdf;base64,JVBERi0="}},
+			{"type":"input_text","text":"summarize this"}
+		]}]
+	}`)
+
+	item := firstContentItem(t, sent)
+	if item["type"] != "input_file" {
+		t.Fatalf("unexpected item type: %v", item["type"])
+	}
+	if _, ok := item["file_url"]; ok {
+		t.Error("file_url must be removed after rewrite")
+	}
+	if item["filename"] != "report.pdf" {
+		t.Errorf("unexpected filename: %v", item["filename"])
+	}
+	if item["file_data"] != "data:application/pdf;base64,JVBERi0=" {
+		t.Errorf("unexpected file_data: %v", item["file_data"])
+	}
+}
+
+// TestFileInputTransport_UploadedFileID openai-file:// 哨兵重写为 file_id。
+func TestFileInputTransport_UploadedFileID(t *testing.T) {
+	sent := sendThroughTransport(t, fileWrap, `{
+		"input": [{"role":"user","content":[
+			{"type":"input_file","file_url":{"url":"openai-file://file-abc123"}}
+		]}]
+	}`)
+
+	item := firstContentItem(t, sent)
+	if item["file_id"] != "file-abc123" {
+		t.Errorf("unexpected file_id: %v", item["file_id"])
+	}
+	if _, ok := item["file_url"]; ok {
+		t.Error("file_url must be removed after rewrite")
+	}
+}
+
+// TestFileInputTransport_PlainURLUntouched 普通 https 文件 URL 原样透传。
+func TestFileInputTransport_PlainURLUntouched(t *testing.T) {
+	sent := sendThroughTransport(t, fileWrap, `{
+		"input": [{"role":"user","content":[
+			{"type":"input_file","file_url":{"url":"https://example.com/report.pdf"}}
+		]}]
+	}`)
+
+	item := firstContentItem(t, sent)
+	fu, ok := item["file_url"].(map[string]any)
+	if !ok || fu["url"] != "https://example.com/report.pdf" {
+		t.Errorf("plain file_url must stay untouched, got %v", item)
+	}
+}
+
+func TestDataURLFilename(t *testing.T) {
+	cases := map[string]string{
+		"data:application/pdf;name=report.pdf;base64,AAAA": "report.pdf",
+		"data:application/pdf;base64,AAAA":                 "upload.pdf",
+		"data:;base64,AAAA":                                "upload",
+	}
+	for url, want := range cases {
+		if got := dataURLFilename(url); got != want {
+			t.Errorf("dataURLFilename(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
+
+// TestUploadFile 上传走 multipart 到 <base_url>/files，返回厂商分配的 file_id。
+func TestUploadFile(t *testing.T) {
+	var gotPurpose, gotFilename, gotAuth string
+	var gotData []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/files" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		gotAuth = r.Header.Get("Authorization")
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse multipart: %v", err)
+		}
+		gotPurpose = r.FormValue("purpose")
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("form file: %v", err)
+		} else {
+			gotFilename = header.Filename
+			gotData, _ = io.ReadAll(file)
+			file.Close()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"file-xyz789","object":"file"}`))
+	}))
+	defer srv.Close()
+
+	m := NewMixedProvider(conf.Eino{Clients: map[string]conf.Client{
+		"openai": {BaseURL: srv.URL, APIKey: "sk-test"},
+	}})
+
+	fileID, err := m.UploadFile(context.Background(), "", "report.pdf", []byte("%PDF-"), "")
+	if err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if fileID != "file-xyz789" {
+		t.Errorf("unexpected file id: %q", fileID)
+	}
+	if gotPurpose != "user_data" || gotFilename != "report.pdf" || string(gotData) != "%PDF-" {
+		t.Errorf("unexpected upload form: purpose=%q filename=%q data=%q", gotPurpose, gotFilename, gotData)
+	}
+	if gotAuth != "Bearer sk-test" {
+		t.Errorf("unexpected auth header: %q", gotAuth)
+	}
+}
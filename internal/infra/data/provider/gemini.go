@@ -3,7 +3,9 @@ package provider
 import (
 	"context"
 	"fmt"
-	"strings"
+	"net/http"
+	"sync"
+	"time"
 
 	"devops-backend/internal/biz"
 	"devops-backend/internal/conf"
@@ -14,15 +16,68 @@ import (
 	"google.golang.org/genai"
 )
 
-// newGeminiRaw 创建原始 Gemini client（忠实反映厂商默认行为）
-func newGeminiRaw(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+// geminiClients 按配置指纹复用 genai.Client：每次请求新建会泄漏
+// HTTP 连接与文件描述符，genai.Client 本身并发安全、可跨请求共享
+var geminiClients = &geminiClientCache{}
+
+type geminiClientCache struct {
+	mu      sync.Mutex
+	entries map[string]*geminiClientEntry
+}
+
+type geminiClientEntry struct {
+	client     *genai.Client
+	httpClient *http.Client // nil 表示 SDK 默认 client
+}
+
+// get 返回该配置对应的共享 genai.Client，没有则创建并缓存
+func (c *geminiClientCache) get(ctx context.Context, cfg conf.Client) (*genai.Client, error) {
+	// map 的 %v 输出按 key 排序，headers 顺序不影响指纹
+	key := fmt.Sprintf("%s\x00%s\x00%s\x00%s\x00%v", cfg.APIKey, cfg.BaseURL, cfg.HTTPProxy, cfg.UserAgent, cfg.Headers)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[key]; ok {
+		return entry.client, nil
+	}
+
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	gc, err := genai.NewClient(ctx, &genai.ClientConfig{
 		APIKey:      cfg.APIKey,
 		HTTPOptions: genai.HTTPOptions{BaseURL: cfg.BaseURL},
+		HTTPClient:  httpClient,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("create gemini client: %w", err)
 	}
+	if c.entries == nil {
+		c.entries = make(map[string]*geminiClientEntry)
+	}
+	c.entries[key] = &geminiClientEntry{client: gc, httpClient: httpClient}
+	return gc, nil
+}
+
+// closeAll 释放所有缓存 client 持有的空闲连接并清空缓存（优雅关停用）
+func (c *geminiClientCache) closeAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range c.entries {
+		if entry.httpClient != nil {
+			entry.httpClient.CloseIdleConnections()
+		}
+	}
+	c.entries = nil
+}
+
+// newGeminiRaw 创建原始 Gemini client（忠实反映厂商默认行为）
+func newGeminiRaw(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	gc, err := geminiClients.get(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
 	return gemini.NewChatModel(ctx, &gemini.Config{
 		Client: gc, Model: modelName,
 	})
@@ -30,12 +85,9 @@ func newGeminiRaw(ctx context.Context, cfg conf.Client, modelName string, opts .
 
 // newGemini 创建 Gemini 模型 + thinking/fallback adapter
 func newGemini(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
-	gc, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:      cfg.APIKey,
-		HTTPOptions: genai.HTTPOptions{BaseURL: cfg.BaseURL},
-	})
+	gc, err := geminiClients.get(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("create gemini client: %w", err)
+		return nil, err
 	}
 	raw, err := gemini.NewChatModel(ctx, &gemini.Config{
 		Client: gc, Model: modelName,
@@ -75,7 +127,7 @@ func (a *geminiAdapter) Generate(ctx context.Context, messages []*schema.Message
 	if err != nil {
 		return nil, err
 	}
-	if params.Thinking != nil && !*params.Thinking {
+	if enable, set := biz.ThinkingDecision(params, true); set && !enable {
 		resp.ReasoningContent = ""
 	}
 	return resp, nil
@@ -105,6 +157,9 @@ func (a *geminiAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingCh
 	return &geminiAdapter{raw: m, modelName: a.modelName}, nil
 }
 
+// SupportsThinking Gemini 支持按请求配置 thinking
+func (a *geminiAdapter) SupportsThinking() bool { return true }
+
 func (a *geminiAdapter) injectThinkingConfig(opts []model.Option) []model.Option {
 	params := biz.GetParams(opts...)
 	// adapter 负责设置所有可能的 response modalities
@@ -113,27 +168,38 @@ func (a *geminiAdapter) injectThinkingConfig(opts []model.Option) []model.Option
 		gemini.GeminiResponseModalityImage,
 		gemini.GeminiResponseModalityAudio,
 	}))
-	if GetModelCapabilityRegistry().RequiresNonStreamingMode(a.modelName) {
-		include := params.Thinking != nil && *params.Thinking
+	nonStreaming := GetModelCapabilityRegistry().RequiresNonStreamingMode(a.modelName)
+	return append(opts, gemini.WithThinkingConfig(geminiThinkingConfig(params, nonStreaming)))
+}
+
+// geminiThinkingConfig maps RequestParams to the genai thinking config.
+// An explicit ThinkingBudget takes precedence over the coarse level mapping.
+func geminiThinkingConfig(params *biz.RequestParams, nonStreaming bool) *genai.ThinkingConfig {
+	enable, set := biz.ThinkingDecision(params, true)
+	include := !set || enable
+	if nonStreaming {
+		include = set && enable
+	}
+
+	if params.ThinkingBudget != nil {
+		budget := int32(*params.ThinkingBudget)
+		return &genai.ThinkingConfig{IncludeThoughts: include, ThinkingBudget: &budget}
+	}
+
+	if nonStreaming {
 		budget := int32(0)
-		return append(opts, gemini.WithThinkingConfig(&genai.ThinkingConfig{
-			IncludeThoughts: include, ThinkingBudget: &budget,
-		}))
-	}
-	var include bool
-	var level genai.ThinkingLevel
-	if params.Thinking == nil || *params.Thinking {
-		include, level = true, genai.ThinkingLevelHigh
-	} else {
-		include, level = false, genai.ThinkingLevelMinimal
-	}
-	return append(opts, gemini.WithThinkingConfig(&genai.ThinkingConfig{
-		IncludeThoughts: include, ThinkingLevel: level,
-	}))
+		return &genai.ThinkingConfig{IncludeThoughts: include, ThinkingBudget: &budget}
+	}
+
+	level := genai.ThinkingLevelHigh
+	if !include {
+		level = genai.ThinkingLevelMinimal
+	}
+	return &genai.ThinkingConfig{IncludeThoughts: include, ThinkingLevel: level}
 }
 
 func wrapHideThinking(sr *schema.StreamReader[*schema.Message], params *biz.RequestParams) *schema.StreamReader[*schema.Message] {
-	if params == nil || params.Thinking == nil || *params.Thinking {
+	if enable, set := biz.ThinkingDecision(params, true); !set || enable {
 		return sr
 	}
 	return schema.StreamReaderWithConvert(sr, func(m *schema.Message) (*schema.Message, error) {
@@ -149,25 +215,43 @@ func wrapHideThinking(sr *schema.StreamReader[*schema.Message], params *biz.Requ
 	})
 }
 
+// simulatedChunkRunes 模拟流式的单个 chunk 大小（按 rune 切分，保留原始格式）
+const simulatedChunkRunes = 64
+
+// simulatedChunkInterval 模拟流式的出字节奏；0 表示不限速（测试用）
+var simulatedChunkInterval = 20 * time.Millisecond
+
 func createSimulatedStreamReader(resp *schema.Message) *schema.StreamReader[*schema.Message] {
-	return schema.StreamReaderFromArray(splitResponseToChunks(resp))
+	chunks := splitResponseToChunks(resp)
+	if simulatedChunkInterval <= 0 {
+		return schema.StreamReaderFromArray(chunks)
+	}
+
+	// 按配置的节奏吐 chunk，让非流式 fallback 看起来接近真实流式
+	sr, sw := schema.Pipe[*schema.Message](len(chunks))
+	go func() {
+		defer sw.Close()
+		for i, chunk := range chunks {
+			if i > 0 {
+				time.Sleep(simulatedChunkInterval)
+			}
+			if sw.Send(chunk, nil) {
+				return // reader 已关闭
+			}
+		}
+	}()
+	return sr
 }
 
+// splitResponseToChunks 把一次性响应切成模拟流式 chunk。
+// reasoning 按固定 rune 窗口切分，原样保留换行与列表格式；content 同理。
 func splitResponseToChunks(resp *schema.Message) []*schema.Message {
 	var chunks []*schema.Message
-	if resp.ReasoningContent != "" {
-		paras := strings.Split(resp.ReasoningContent, "\n\n")
-		if len(paras) <= 1 {
-			paras = strings.Split(resp.ReasoningContent, "\n")
-		}
-		for _, p := range paras {
-			if p = strings.TrimSpace(p); p != "" {
-				chunks = append(chunks, &schema.Message{Role: schema.Assistant, ReasoningContent: p + "\n"})
-			}
-		}
+	for _, piece := range splitByRuneWindow(resp.ReasoningContent, simulatedChunkRunes) {
+		chunks = append(chunks, &schema.Message{Role: schema.Assistant, ReasoningContent: piece})
 	}
-	if resp.Content != "" {
-		chunks = append(chunks, &schema.Message{Role: schema.Assistant, Content: resp.Content})
+	for _, piece := range splitByRuneWindow(resp.Content, simulatedChunkRunes) {
+		chunks = append(chunks, &schema.Message{Role: schema.Assistant, Content: piece})
 	}
 	if len(resp.AssistantGenMultiContent) > 0 {
 		chunks = append(chunks, &schema.Message{Role: schema.Assistant, AssistantGenMultiContent: resp.AssistantGenMultiContent})
@@ -177,3 +261,42 @@ func splitResponseToChunks(resp *schema.Message) []*schema.Message {
 	}
 	return chunks
 }
+
+// splitByRuneWindow 按固定 rune 数切分文本，拼接后与原文完全一致。
+// 窗口边界尽量落在换行或空格之后，避免把词从中间切断。
+func splitByRuneWindow(text string, window int) []string {
+	if text == "" {
+		return nil
+	}
+
+	runes := []rune(text)
+	var pieces []string
+	for start := 0; start < len(runes); {
+		end := start + window
+		if end >= len(runes) {
+			pieces = append(pieces, string(runes[start:]))
+			break
+		}
+
+		// 在窗口内从后往前找自然断点（换行优先，其次空格）
+		cut := end
+		for i := end; i > start; i-- {
+			if runes[i-1] == '\n' {
+				cut = i
+				break
+			}
+		}
+		if cut == end {
+			for i := end; i > start; i-- {
+				if runes[i-1] == ' ' {
+					cut = i
+					break
+				}
+			}
+		}
+
+		pieces = append(pieces, string(runes[start:cut]))
+		start = cut
+	}
+	return pieces
+}
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"devops-backend/internal/conf"
+)
+
+// TestGeminiClientCache_ReusesClientPerConfig 相同配置复用同一个 genai.Client，
+// 反复创建模型不会无限增长连接/fd。
+func TestGeminiClientCache_ReusesClientPerConfig(t *testing.T) {
+	cache := &geminiClientCache{}
+	cfg := conf.Client{APIKey: "test-key", BaseURL: "https://gemini.example.com"}
+
+	first, err := cache.get(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("first get failed: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		got, err := cache.get(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("get %d failed: %v", i, err)
+		}
+		if got != first {
+			t.Fatal("expected the cached client to be reused")
+		}
+	}
+	if len(cache.entries) != 1 {
+		t.Fatalf("cache must not grow for identical configs, got %d entries", len(cache.entries))
+	}
+
+	// 不同凭证是另一个上游身份，必须是独立 client
+	other, err := cache.get(context.Background(), conf.Client{APIKey: "other-key", BaseURL: "https://gemini.example.com"})
+	if err != nil {
+		t.Fatalf("get with other key failed: %v", err)
+	}
+	if other == first {
+		t.Fatal("different credentials must not share a client")
+	}
+	if len(cache.entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(cache.entries))
+	}
+
+	cache.closeAll()
+	if len(cache.entries) != 0 {
+		t.Fatalf("closeAll must clear the cache, got %d entries", len(cache.entries))
+	}
+}
@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+)
+
+// healthProbeTimeout 单次探活的超时；探活只打只读端点，必须快速失败
+const healthProbeTimeout = 5 * time.Second
+
+// StartHealthChecks 启动后台探活：立即探测一轮，之后每隔 interval 轮询所有
+// 配置了 base_url 的 client，ctx 取消时退出
+func (m *MixedProvider) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			m.probeClients(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// probeClients 探测所有已配置的 client 并记录结果
+func (m *MixedProvider) probeClients(ctx context.Context) {
+	m.mu.RLock()
+	clients := make(map[string]conf.Client, len(m.clients))
+	for name, cfg := range m.clients {
+		clients[name] = cfg
+	}
+	m.mu.RUnlock()
+	for name, cfg := range clients {
+		if cfg.BaseURL == "" {
+			continue
+		}
+		result := probeClient(ctx, name, cfg)
+		m.healthMu.Lock()
+		if m.health == nil {
+			m.health = make(map[string]biz.ClientHealth)
+		}
+		m.health[name] = result
+		m.healthMu.Unlock()
+	}
+}
+
+// probeClient 发送一个最小的只读请求（GET /models），不消耗生成额度。
+// 5xx 或网络错误视为不健康；4xx（如部分厂商不支持该端点）仍证明可达
+func probeClient(ctx context.Context, name string, cfg conf.Client) biz.ClientHealth {
+	result := biz.ClientHealth{Client: name, CheckedAt: time.Now()}
+
+	probeCtx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(probeCtx, http.MethodGet,
+		strings.TrimSuffix(cfg.BaseURL, "/")+"/models", nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.APIKey)
+	}
+
+	client, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	result.LatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		result.Error = resp.Status
+		return result
+	}
+	result.Healthy = true
+	return result
+}
+
+// ClientHealthSnapshot 返回所有已探测 client 的最近结果，按名称排序
+func (m *MixedProvider) ClientHealthSnapshot() []biz.ClientHealth {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	snapshot := make([]biz.ClientHealth, 0, len(m.health))
+	for _, h := range m.health {
+		snapshot = append(snapshot, h)
+	}
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Client < snapshot[j].Client })
+	return snapshot
+}
+
+// IsClientHealthy 判断 client 是否可用；未探测过（或未启用探活）时视为可用，
+// fallback 逻辑可据此跳过已知不可用的 client
+func (m *MixedProvider) IsClientHealthy(name string) bool {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	h, ok := m.health[name]
+	if !ok {
+		return true
+	}
+	return h.Healthy
+}
@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"devops-backend/internal/conf"
+)
+
+// tunedTransport 按 eino.http_pool 调优的共享上游 Transport；
+// nil 表示未配置调优，沿用 Go 默认 Transport
+var tunedTransport atomic.Pointer[http.Transport]
+
+// ConfigureUpstreamTransport 应用 eino.http_pool 连接池调优，启动与热重载时调用。
+// 流式（SSE）请求依赖长时间挂起的响应体，这里刻意不设置
+// ResponseHeaderTimeout / 整体超时，首 token 超时由 biz 层控制
+func ConfigureUpstreamTransport(pool conf.HTTPPool) {
+	if pool == (conf.HTTPPool{}) {
+		return
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if pool.MaxIdleConns > 0 {
+		t.MaxIdleConns = pool.MaxIdleConns
+	}
+	if pool.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = pool.MaxIdleConnsPerHost
+	}
+	if pool.IdleConnTimeoutSeconds > 0 {
+		t.IdleConnTimeout = time.Duration(pool.IdleConnTimeoutSeconds) * time.Second
+	}
+	t.DisableKeepAlives = pool.DisableKeepAlives
+	t.ForceAttemptHTTP2 = !pool.DisableHTTP2
+	tunedTransport.Store(t)
+}
+
+// baseUpstreamTransport 所有上游 client 的基础 Transport：
+// 配置了连接池调优时用调优版本，否则用 Go 默认
+func baseUpstreamTransport() *http.Transport {
+	if t := tunedTransport.Load(); t != nil {
+		return t
+	}
+	return http.DefaultTransport.(*http.Transport)
+}
+
+// upstreamHTTPClient 根据 client 配置构造上游请求用的 HTTP client：
+// http_proxy 指定出口代理，user_agent/headers 注入自定义请求头，
+// eino.http_pool 决定共享连接池参数。均未配置时返回 nil，
+// 各组件使用自身默认 client（默认 Transport 已经通过
+// ProxyFromEnvironment 兜底识别 HTTP_PROXY/HTTPS_PROXY/NO_PROXY）。
+func upstreamHTTPClient(cfg conf.Client) (*http.Client, error) {
+	var transport http.RoundTripper
+	if cfg.HTTPProxy != "" {
+		proxyURL, err := url.Parse(cfg.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid http_proxy %q: %w", cfg.HTTPProxy, err)
+		}
+		t := baseUpstreamTransport().Clone()
+		t.Proxy = http.ProxyURL(proxyURL)
+		transport = t
+	}
+	if cfg.UserAgent != "" || len(cfg.Headers) > 0 {
+		base := transport
+		if base == nil {
+			base = http.RoundTripper(baseUpstreamTransport())
+		}
+		transport = &headerTransport{base: base, userAgent: cfg.UserAgent, headers: cfg.Headers}
+	}
+	if transport == nil {
+		// 无 client 级定制时也要让调优后的连接池生效，避免回落到 SDK 默认 client
+		if t := tunedTransport.Load(); t != nil {
+			return &http.Client{Transport: t}, nil
+		}
+		return nil, nil
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// headerTransport 给每个出站请求注入固定请求头
+type headerTransport struct {
+	base      http.RoundTripper
+	userAgent string
+	headers   map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	if t.userAgent != "" {
+		clone.Header.Set("User-Agent", t.userAgent)
+	}
+	// headers 最后注入，同名头（包括 User-Agent）以配置为准
+	for k, v := range t.headers {
+		clone.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(clone)
+}
@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"devops-backend/internal/conf"
+)
+
+// resetTunedTransport 清掉包级调优状态，避免影响同包其它测试
+func resetTunedTransport(t testing.TB) {
+	t.Helper()
+	t.Cleanup(func() { tunedTransport.Store(nil) })
+}
+
+func TestConfigureUpstreamTransport_AppliesPoolSettings(t *testing.T) {
+	resetTunedTransport(t)
+
+	ConfigureUpstreamTransport(conf.HTTPPool{
+		MaxIdleConns:           128,
+		MaxIdleConnsPerHost:    32,
+		IdleConnTimeoutSeconds: 30,
+		DisableHTTP2:           true,
+	})
+
+	tr := baseUpstreamTransport()
+	if tr.MaxIdleConns != 128 || tr.MaxIdleConnsPerHost != 32 {
+		t.Errorf("pool sizes not applied: %d / %d", tr.MaxIdleConns, tr.MaxIdleConnsPerHost)
+	}
+	if tr.IdleConnTimeout != 30*time.Second {
+		t.Errorf("idle timeout not applied: %v", tr.IdleConnTimeout)
+	}
+	if tr.ForceAttemptHTTP2 {
+		t.Error("disable_http2 not applied")
+	}
+	// SSE 依赖长时间挂起的响应，调优不得引入响应头超时
+	if tr.ResponseHeaderTimeout != 0 {
+		t.Errorf("tuned transport must not set ResponseHeaderTimeout, got %v", tr.ResponseHeaderTimeout)
+	}
+
+	// 即使 client 没有代理/请求头定制，也要返回带调优 Transport 的 client
+	client, err := upstreamHTTPClient(conf.Client{})
+	if err != nil {
+		t.Fatalf("upstreamHTTPClient returned error: %v", err)
+	}
+	if client == nil || client.Transport != tr {
+		t.Fatalf("expected client carrying the tuned transport, got %#v", client)
+	}
+}
+
+func TestConfigureUpstreamTransport_ZeroPoolKeepsDefault(t *testing.T) {
+	resetTunedTransport(t)
+
+	ConfigureUpstreamTransport(conf.HTTPPool{})
+
+	if tunedTransport.Load() != nil {
+		t.Fatal("zero pool config must not install a tuned transport")
+	}
+	client, err := upstreamHTTPClient(conf.Client{})
+	if err != nil {
+		t.Fatalf("upstreamHTTPClient returned error: %v", err)
+	}
+	if client != nil {
+		t.Fatalf("expected nil client (SDK default) without any tuning, got %#v", client)
+	}
+}
+
+// BenchmarkUpstreamTransportPool 对比默认连接池（单 host 仅 2 个空闲连接）与
+// 调优连接池在并发打同一上游时的差距；TLS server 放大每次新建连接的握手成本。
+func BenchmarkUpstreamTransportPool(b *testing.B) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	run := func(b *testing.B, tr *http.Transport) {
+		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client := &http.Client{Transport: tr}
+		defer tr.CloseIdleConnections()
+
+		b.SetParallelism(8)
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		})
+	}
+
+	b.Run("default", func(b *testing.B) {
+		run(b, http.DefaultTransport.(*http.Transport).Clone())
+	})
+
+	b.Run("tuned", func(b *testing.B) {
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.MaxIdleConns = 128
+		tr.MaxIdleConnsPerHost = 32
+		run(b, tr)
+	})
+}
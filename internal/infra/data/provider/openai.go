@@ -2,13 +2,17 @@ package provider
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"devops-backend/internal/biz"
 	"devops-backend/internal/conf"
 
 	"github.com/cloudwego/eino-ext/components/model/openai"
+	aclopenai "github.com/cloudwego/eino-ext/libs/acl/openai"
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 
@@ -17,29 +21,42 @@ import (
 
 // newOpenAIRaw 创建原始 OpenAI client（忠实反映厂商默认行为，始终使用 Chat Completions API）
 func newOpenAIRaw(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return openai.NewChatModel(ctx, &openai.ChatModelConfig{
-		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
 	})
 }
 
 // newOpenAI 创建 OpenAI 模型，自动选择 Responses API 或 Chat Completions API
 func newOpenAI(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	if shouldUseResponsesAPI(modelName) {
+		// Responses client 的 SSE 解析器只认单 data: 行事件，HTTP 层重新组帧兜底；
+		// extra 参数同样在 HTTP 层合入请求体（client 自身没有 extra-fields 机制），
+		// 哨兵形式的文件输入（file_id / base64 file_data）、历史助手消息上的
+		// 工具调用（function_call 项）与加密推理项（reasoning 项）也在 HTTP 层重写
 		raw, err := openairesponse.NewChatModel(ctx, &openairesponse.Config{
 			BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+			HTTPClient: extraBodyClient(fileInputClient(toolCallInputClient(reasoningInputClient(sseNormalizingClient(httpClient))))),
 		})
 		if err != nil {
 			return nil, err
 		}
-		return &openAIResponseAdapter{raw: raw, modelName: modelName}, nil
+		return &openAIResponseAdapter{raw: raw, modelName: modelName, reasoningSummary: cfg.ReasoningSummary, baseInstructions: cfg.BaseInstructions, store: cfg.Store}, nil
 	}
 	raw, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
-		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &openAIAdapter{raw: raw, modelName: modelName}, nil
+	return &openAIAdapter{raw: raw, modelName: modelName, baseInstructions: cfg.BaseInstructions}, nil
 }
 
 func shouldUseResponsesAPI(modelName string) bool {
@@ -55,6 +72,8 @@ func shouldUseResponsesAPI(modelName string) bool {
 type openAIAdapter struct {
 	raw       model.ToolCallingChatModel
 	modelName string
+	// baseInstructions client 级基础指令，始终作为最前面的 system 消息注入
+	baseInstructions string
 }
 
 func (a *openAIAdapter) GetType() string {
@@ -72,11 +91,11 @@ func (a *openAIAdapter) IsCallbacksEnabled() bool {
 }
 
 func (a *openAIAdapter) Generate(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.Message, error) {
-	return a.raw.Generate(ctx, messages, a.injectOpts(opts)...)
+	return a.raw.Generate(ctx, prependBaseInstructions(messages, a.baseInstructions), a.injectOpts(opts)...)
 }
 
 func (a *openAIAdapter) Stream(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
-	return a.raw.Stream(ctx, messages, a.injectOpts(opts)...)
+	return a.raw.Stream(ctx, prependBaseInstructions(messages, a.baseInstructions), a.injectOpts(opts)...)
 }
 
 func (a *openAIAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
@@ -84,15 +103,34 @@ func (a *openAIAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingCh
 	if err != nil {
 		return nil, err
 	}
-	return &openAIAdapter{raw: m, modelName: a.modelName}, nil
+	return &openAIAdapter{raw: m, modelName: a.modelName, baseInstructions: a.baseInstructions}, nil
 }
 
+// SupportsThinking 只有支持 reasoning effort 的模型才会真正应用 thinking 开关
+func (a *openAIAdapter) SupportsThinking() bool { return supportsReasoningEffort(a.modelName) }
+
 func (a *openAIAdapter) injectOpts(opts []model.Option) []model.Option {
 	params := biz.GetParams(opts...)
-	if params.Thinking == nil || !supportsReasoningEffort(a.modelName) {
+	extraFields := make(map[string]any, len(params.Extra)+1)
+	for k, v := range params.Extra {
+		extraFields[k] = v
+	}
+	if params.Seed != nil {
+		extraFields["seed"] = *params.Seed
+	}
+	if len(extraFields) > 0 {
+		opts = append(opts, openai.WithExtraFields(extraFields))
+	}
+	// 抓取响应里的 system_fingerprint，客户端可据此发现后端实现变化
+	opts = append(opts,
+		aclopenai.WithResponseMessageModifier(captureSystemFingerprint),
+		aclopenai.WithResponseChunkMessageModifier(captureSystemFingerprintChunk),
+	)
+	enable, set := biz.ThinkingDecision(params, supportsReasoningEffort(a.modelName))
+	if !set {
 		return opts
 	}
-	if *params.Thinking {
+	if enable {
 		return append(opts, openai.WithReasoningEffort(openai.ReasoningEffortLevelHigh))
 	}
 	if isGPT51OrLater(a.modelName) {
@@ -106,6 +144,14 @@ func (a *openAIAdapter) injectOpts(opts []model.Option) []model.Option {
 type openAIResponseAdapter struct {
 	raw       model.BaseChatModel
 	modelName string
+	// reasoningSummary client 配置的默认摘要模式，空值回退到 detailed
+	reasoningSummary string
+	// baseInstructions client 级基础指令；Responses client 会把 system 消息
+	// 合并进 instructions，前置注入即可保证它排在派生指令之前
+	baseInstructions string
+	// store client 配置的 store 默认值（false = 不让 OpenAI 服务端保留请求数据）；
+	// client 本身没有这个参数，在 HTTP 层合入请求体
+	store *bool
 }
 
 func (a *openAIResponseAdapter) GetType() string {
@@ -123,11 +169,21 @@ func (a *openAIResponseAdapter) IsCallbacksEnabled() bool {
 }
 
 func (a *openAIResponseAdapter) Generate(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.Message, error) {
-	return a.raw.Generate(ctx, messages, a.injectOpts(opts)...)
+	ctx = withExtraBodyFields(ctx, a.extraWithEncryptedReasoning(a.extraWithStore(biz.GetParams(opts...))))
+	msg, err := a.raw.Generate(ctx, encodeAssistantToolCalls(encodeReasoningItems(prependBaseInstructions(messages, a.baseInstructions))), a.injectOpts(opts)...)
+	if err != nil {
+		return nil, recoverUpstreamStatus(err)
+	}
+	return decodeReasoningMessage(msg), nil
 }
 
 func (a *openAIResponseAdapter) Stream(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
-	return a.raw.Stream(ctx, messages, a.injectOpts(opts)...)
+	ctx = withExtraBodyFields(ctx, a.extraWithEncryptedReasoning(a.extraWithStore(biz.GetParams(opts...))))
+	sr, err := a.raw.Stream(ctx, encodeAssistantToolCalls(encodeReasoningItems(prependBaseInstructions(messages, a.baseInstructions))), a.injectOpts(opts)...)
+	if err != nil {
+		return nil, recoverUpstreamStatus(err)
+	}
+	return decodeReasoningStream(sr), nil
 }
 
 func (a *openAIResponseAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
@@ -136,7 +192,7 @@ func (a *openAIResponseAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolC
 		if err != nil {
 			return nil, err
 		}
-		return &openAIResponseAdapter{raw: m, modelName: a.modelName}, nil
+		return &openAIResponseAdapter{raw: m, modelName: a.modelName, reasoningSummary: a.reasoningSummary, baseInstructions: a.baseInstructions, store: a.store}, nil
 	}
 	if len(tools) > 0 {
 		return nil, fmt.Errorf("openAIResponseAdapter: underlying model does not support tool calling")
@@ -144,19 +200,128 @@ func (a *openAIResponseAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolC
 	return a, nil
 }
 
+// SupportsThinking 只有支持 Responses API reasoning 的模型才会真正应用 thinking 开关
+func (a *openAIResponseAdapter) SupportsThinking() bool {
+	return supportsResponsesAPIReasoning(a.modelName)
+}
+
 func (a *openAIResponseAdapter) injectOpts(opts []model.Option) []model.Option {
 	params := biz.GetParams(opts...)
-	if params.Thinking == nil || !supportsResponsesAPIReasoning(a.modelName) {
+	enable, set := biz.ThinkingDecision(params, supportsResponsesAPIReasoning(a.modelName))
+	if !set {
 		return opts
 	}
-	if *params.Thinking {
-		return append(opts, openairesponse.WithReasoningEffort(openairesponse.ReasoningEffortHigh), openairesponse.WithReasoningSummary(openairesponse.ReasoningSummaryDetailed))
+	if enable {
+		return append(opts, openairesponse.WithReasoningEffort(openairesponse.ReasoningEffortHigh), openairesponse.WithReasoningSummary(reasoningSummaryMode(a.reasoningSummary, params)))
 	}
 	return append(opts, openairesponse.WithReasoningEffort(openairesponse.ReasoningEffortLow))
 }
 
+// extraWithStore 按优先级解析 store 取值并合入出站 extra 字段：
+// 请求级 Store > extra 透传里的 store > client 配置默认值。
+// previous_response_id 续写依赖服务端存储，配置级默认值不去关它（显式请求仍尊重）
+func (a *openAIResponseAdapter) extraWithStore(params *biz.RequestParams) map[string]any {
+	if params.Store != nil {
+		return withExtraField(params.Extra, "store", *params.Store)
+	}
+	if a.store == nil {
+		return params.Extra
+	}
+	if _, ok := params.Extra["store"]; ok {
+		return params.Extra
+	}
+	if _, ok := params.Extra["previous_response_id"]; ok {
+		return params.Extra
+	}
+	return withExtraField(params.Extra, "store", *a.store)
+}
+
+// withExtraField 不修改原 map 地附加一个字段
+func withExtraField(extra map[string]any, key string, value any) map[string]any {
+	merged := make(map[string]any, len(extra)+1)
+	for k, v := range extra {
+		merged[k] = v
+	}
+	merged[key] = value
+	return merged
+}
+
+// reasoningSummaryMode 解析推理摘要模式：请求级覆盖优先，其次 client 配置；
+// 未配置或取值非法时保持原有 detailed 行为
+func reasoningSummaryMode(configured string, params *biz.RequestParams) openairesponse.ReasoningSummary {
+	mode := configured
+	if params != nil && params.ReasoningSummary != "" {
+		mode = params.ReasoningSummary
+	}
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "auto":
+		return openairesponse.ReasoningSummaryAuto
+	case "concise":
+		return openairesponse.ReasoningSummaryConcise
+	default:
+		return openairesponse.ReasoningSummaryDetailed
+	}
+}
+
+// responsesStatusPattern 匹配 Responses client 的两种固定错误文案：
+// "api error (status %d): ..." 与 "http error: status %d, body: ..."
+var responsesStatusPattern = regexp.MustCompile(`\bstatus (\d{3})\b`)
+
+// recoverUpstreamStatus 从 Responses client 的错误文案里找回上游 HTTP 状态码
+// 并附着为类型化错误（client 只返回字符串错误，状态码信息到这里就丢了）；
+// 文案不含状态码时原样返回
+func recoverUpstreamStatus(err error) error {
+	m := responsesStatusPattern.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	status, convErr := strconv.Atoi(m[1])
+	if convErr != nil || status < 100 || status > 599 {
+		return err
+	}
+	return biz.WrapUpstreamStatus(status, err)
+}
+
 // --- helpers ---
 
+// captureSystemFingerprint 从原始响应体提取 system_fingerprint 写入消息 Extra，
+// 固定 seed 的调用方可据此发现后端实现是否发生了变化
+func captureSystemFingerprint(_ context.Context, msg *schema.Message, rawBody []byte) (*schema.Message, error) {
+	if msg == nil || len(rawBody) == 0 {
+		return msg, nil
+	}
+	var resp struct {
+		SystemFingerprint string `json:"system_fingerprint"`
+	}
+	if err := json.Unmarshal(rawBody, &resp); err != nil || resp.SystemFingerprint == "" {
+		return msg, nil
+	}
+	if msg.Extra == nil {
+		msg.Extra = make(map[string]any)
+	}
+	msg.Extra["system_fingerprint"] = resp.SystemFingerprint
+	return msg, nil
+}
+
+// captureSystemFingerprintChunk 流式路径的 system_fingerprint 抓取（end 时无数据）
+func captureSystemFingerprintChunk(ctx context.Context, msg *schema.Message, rawBody []byte, end bool) (*schema.Message, error) {
+	if end {
+		return msg, nil
+	}
+	return captureSystemFingerprint(ctx, msg, rawBody)
+}
+
+// prependBaseInstructions 把 client 级基础指令作为最前面的 system 消息注入，
+// 排在用户自带的 system 指令之前（Responses client 合并 instructions 时同样保序）
+func prependBaseInstructions(messages []*schema.Message, base string) []*schema.Message {
+	if base == "" {
+		return messages
+	}
+	prepended := make([]*schema.Message, 0, len(messages)+1)
+	prepended = append(prepended, schema.SystemMessage(base))
+	return append(prepended, messages...)
+}
+
 func supportsReasoningEffort(name string) bool {
 	m := strings.ToLower(name)
 	return strings.HasPrefix(m, "o1") || strings.HasPrefix(m, "o3") || strings.Contains(m, "gpt-5") || strings.Contains(m, "gpt-6") || strings.Contains(m, "gpt-7")
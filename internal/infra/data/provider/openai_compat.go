@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// thinkingFieldMapping OpenAI 兼容厂商的 thinking 开关字段映射
+type thinkingFieldMapping struct {
+	Field string
+	On    any
+	Off   any
+}
+
+// defaultThinkingFields 内置的厂商映射，client 配置（thinking_field 等）可覆盖；
+// 新厂商优先通过配置接入，不需要改代码
+var defaultThinkingFields = map[string]thinkingFieldMapping{
+	"glm":     {Field: "thinking", On: map[string]any{"type": "enabled"}, Off: map[string]any{"type": "disabled"}},
+	"kimi":    {Field: "enable_thinking", On: true, Off: false},
+	"minimax": {Field: "enable_thinking", On: true, Off: false},
+}
+
+// thinkingMappingFor 解析 client 的 thinking 字段映射：配置优先，内置默认兜底
+func thinkingMappingFor(clientName string, cfg conf.Client) thinkingFieldMapping {
+	mapping := defaultThinkingFields[clientName]
+	if cfg.ThinkingField != "" {
+		mapping.Field = cfg.ThinkingField
+		mapping.On, mapping.Off = true, false
+		if cfg.ThinkingOn != nil {
+			mapping.On = cfg.ThinkingOn
+		}
+		if cfg.ThinkingOff != nil {
+			mapping.Off = cfg.ThinkingOff
+		}
+	}
+	return mapping
+}
+
+// thinkingExtraFields 根据 Thinking 开关生成注入请求体的额外字段；
+// thinking 未指定或无映射时返回 nil（保持厂商默认行为）
+func thinkingExtraFields(mapping thinkingFieldMapping, params *biz.RequestParams) map[string]any {
+	enable, set := biz.ThinkingDecision(params, mapping.Field != "")
+	if !set {
+		return nil
+	}
+	value := mapping.Off
+	if enable {
+		value = mapping.On
+	}
+	return map[string]any{mapping.Field: value}
+}
+
+// newOpenAICompatWithThinking 返回带 thinking 注入的 OpenAI 兼容工厂
+// （glm/kimi/minimax 等通过 extra fields 支持 thinking 开关的厂商）
+func newOpenAICompatWithThinking(clientName string) createFunc {
+	return func(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+		httpClient, err := upstreamHTTPClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := openai.NewChatModel(ctx, &openai.ChatModelConfig{
+			BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// mapping 可能为空（无 thinking 开关的厂商），adapter 仍负责 seed 等透传
+		return &openAICompatAdapter{raw: raw, modelName: modelName, mapping: thinkingMappingFor(clientName, cfg)}, nil
+	}
+}
+
+// openAICompatAdapter 给 OpenAI 兼容厂商注入 thinking 开关字段
+type openAICompatAdapter struct {
+	raw       model.ToolCallingChatModel
+	modelName string
+	mapping   thinkingFieldMapping
+}
+
+func (a *openAICompatAdapter) GetType() string {
+	if c, ok := a.raw.(interface{ GetType() string }); ok {
+		return c.GetType()
+	}
+	return "OpenAICompat"
+}
+
+func (a *openAICompatAdapter) IsCallbacksEnabled() bool {
+	if c, ok := a.raw.(interface{ IsCallbacksEnabled() bool }); ok {
+		return c.IsCallbacksEnabled()
+	}
+	return true
+}
+
+func (a *openAICompatAdapter) Generate(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	return a.raw.Generate(ctx, messages, a.injectOpts(opts)...)
+}
+
+func (a *openAICompatAdapter) Stream(ctx context.Context, messages []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return a.raw.Stream(ctx, messages, a.injectOpts(opts)...)
+}
+
+func (a *openAICompatAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	m, err := a.raw.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &openAICompatAdapter{raw: m, modelName: a.modelName, mapping: a.mapping}, nil
+}
+
+// SupportsThinking 有字段映射即可按请求开关 thinking
+func (a *openAICompatAdapter) SupportsThinking() bool { return a.mapping.Field != "" }
+
+func (a *openAICompatAdapter) injectOpts(opts []model.Option) []model.Option {
+	params := biz.GetParams(opts...)
+	// extra 先注入，thinking/seed 等一等公民参数覆盖同名字段
+	extraFields := make(map[string]any, len(params.Extra)+2)
+	for k, v := range params.Extra {
+		extraFields[k] = v
+	}
+	for k, v := range thinkingExtraFields(a.mapping, params) {
+		extraFields[k] = v
+	}
+	if params.Seed != nil {
+		extraFields["seed"] = *params.Seed
+	}
+	if len(extraFields) == 0 {
+		return opts
+	}
+	return append(opts, openai.WithExtraFields(extraFields))
+}
@@ -13,8 +13,12 @@ import (
 
 // newOpenRouterRaw 创建原始 OpenRouter client
 func newOpenRouterRaw(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return openrouter.NewChatModel(ctx, &openrouter.Config{
-		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
 	})
 }
 
@@ -59,12 +63,15 @@ func (a *openRouterAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCalli
 	return &openRouterAdapter{raw: m}, nil
 }
 
+// SupportsThinking OpenRouter 统一通过 reasoning 参数开关 thinking
+func (a *openRouterAdapter) SupportsThinking() bool { return true }
+
 func (a *openRouterAdapter) injectOpts(opts []model.Option) []model.Option {
-	params := biz.GetParams(opts...)
-	if params.Thinking == nil {
+	enable, set := biz.ThinkingDecision(biz.GetParams(opts...), true)
+	if !set {
 		return opts
 	}
-	if *params.Thinking {
+	if enable {
 		return append(opts, openrouter.WithReasoning(&openrouter.Reasoning{
 			Effort: openrouter.EffortOfHigh, Summary: openrouter.SummaryOfDetailed,
 		}))
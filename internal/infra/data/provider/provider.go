@@ -2,8 +2,11 @@ package provider
 
 import (
 	"context"
+	"fmt"
 	"strings"
+	"sync"
 
+	"devops-backend/internal/biz"
 	"devops-backend/internal/conf"
 
 	"github.com/cloudwego/eino/components/model"
@@ -21,17 +24,26 @@ type providerEntry struct {
 
 // MixedProvider 混合提供者，按模型名自动路由
 type MixedProvider struct {
-	entries   []providerEntry
+	entries  []providerEntry
+	fallback createFunc
+
+	// mu 保护 clients/overrides/httpProxy，热重载时整体换新
+	mu        sync.RWMutex
 	clients   map[string]conf.Client
 	overrides map[string]string // model name → client name
-	fallback  createFunc
+	httpProxy string            // 全局出口代理，client 级 http_proxy 优先
+
+	healthMu sync.Mutex
+	health   map[string]biz.ClientHealth // client name → 最近一次探活结果
 }
 
 // NewMixedProvider 创建混合提供者
 func NewMixedProvider(cfg conf.Eino) *MixedProvider {
+	ConfigureUpstreamTransport(cfg.HTTPPool)
 	return &MixedProvider{
 		clients:   cfg.Clients,
 		overrides: cfg.ModelOverrides,
+		httpProxy: cfg.HTTPProxy,
 		fallback:  newOpenAICompatible,
 		entries: []providerEntry{
 			// 前缀匹配优先（避免被通用关键词抢走）
@@ -46,9 +58,9 @@ func NewMixedProvider(cfg conf.Eino) *MixedProvider {
 			{"grok", []string{"grok"}, newOpenAICompatible, nil},
 			{"qianfan", []string{"ernie", "qianfan"}, newQianfan, nil},
 			{"qwen", []string{"qwen"}, newQwen, newQwenRaw},
-			{"glm", []string{"glm"}, newOpenAICompatible, nil},
-			{"kimi", []string{"kimi"}, newOpenAICompatible, nil},
-			{"minimax", []string{"minimax"}, newOpenAICompatible, nil},
+			{"glm", []string{"glm"}, newOpenAICompatWithThinking("glm"), newOpenAICompatible},
+			{"kimi", []string{"kimi"}, newOpenAICompatWithThinking("kimi"), newOpenAICompatible},
+			{"minimax", []string{"minimax"}, newOpenAICompatWithThinking("minimax"), newOpenAICompatible},
 		},
 	}
 }
@@ -56,18 +68,86 @@ func NewMixedProvider(cfg conf.Eino) *MixedProvider {
 // CreateChatModel 根据 modelName 自动路由，带 adapter
 func (m *MixedProvider) CreateChatModel(ctx context.Context, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
 	create, cfg := m.resolve(modelName, false)
+	if err := checkClientConfigured(modelName, m.ClientNameFor(modelName), cfg); err != nil {
+		return nil, err
+	}
+	return create(ctx, cfg, modelName, opts...)
+}
+
+// CreateChatModelWithCredentials 以请求提供的凭证构造一次性 ChatModel（BYO key）。
+// 路由与 adapter 选择和 CreateChatModel 一致，仅覆盖 client 的 api_key / base_url
+func (m *MixedProvider) CreateChatModelWithCredentials(ctx context.Context, modelName string, cred biz.ClientCredentials, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	create, cfg := m.resolve(modelName, false)
+	if cred.APIKey != "" {
+		cfg.APIKey = cred.APIKey
+	}
+	if cred.BaseURL != "" {
+		cfg.BaseURL = cred.BaseURL
+	}
+	if err := checkClientConfigured(modelName, m.ClientNameFor(modelName), cfg); err != nil {
+		return nil, err
+	}
 	return create(ctx, cfg, modelName, opts...)
 }
 
 // CreateRawChatModel 根据 modelName 自动路由，返回原始 client（不包装 adapter）
 func (m *MixedProvider) CreateRawChatModel(ctx context.Context, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
 	create, cfg := m.resolve(modelName, true)
+	if err := checkClientConfigured(modelName, m.ClientNameFor(modelName), cfg); err != nil {
+		return nil, err
+	}
 	return create(ctx, cfg, modelName, opts...)
 }
 
+// ValidateDefaultModel 校验默认模型能路由到已配置的 client；启动时调用，
+// 避免拖到首个请求才暴露晦涩的 "unknown client" 类错误
+func (m *MixedProvider) ValidateDefaultModel(defaultModel string) error {
+	if defaultModel == "" {
+		return nil
+	}
+	_, cfg := m.resolve(defaultModel, false)
+	return checkClientConfigured(defaultModel, m.ClientNameFor(defaultModel), cfg)
+}
+
+// checkClientConfigured 路由命中的 client 没有任何配置时给出指名道姓的错误
+func checkClientConfigured(modelName, clientName string, cfg conf.Client) error {
+	if cfg.BaseURL == "" && cfg.APIKey == "" {
+		return fmt.Errorf("model %q resolves to client %q which is not configured in eino.clients", modelName, clientName)
+	}
+	return nil
+}
+
+// ReloadClients 原子替换 client 配置、model override 路由表与全局代理（热重载用）；
+// 进行中的请求继续持有换出前创建的 ChatModel，不受影响
+func (m *MixedProvider) ReloadClients(cfg conf.Eino) {
+	// 连接池调优同步热更新；已创建的 ChatModel 继续持有旧 Transport
+	ConfigureUpstreamTransport(cfg.HTTPPool)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients = cfg.Clients
+	m.overrides = cfg.ModelOverrides
+	m.httpProxy = cfg.HTTPProxy
+}
+
+// Close 释放池化的厂商 client（当前只有 Gemini 会缓存 client），优雅关停时调用
+func (m *MixedProvider) Close() {
+	geminiClients.closeAll()
+	if t := tunedTransport.Load(); t != nil {
+		t.CloseIdleConnections()
+	}
+}
+
+// overrideFor 查询 model override 路由表
+func (m *MixedProvider) overrideFor(modelName string) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	target, ok := m.overrides[modelName]
+	return target, ok
+}
+
 func (m *MixedProvider) resolve(modelName string, raw bool) (createFunc, conf.Client) {
 	// override 优先：精确匹配 model name → 强制导流到指定 client
-	if target, ok := m.overrides[modelName]; ok {
+	if target, ok := m.overrideFor(modelName); ok {
 		for _, e := range m.entries {
 			if e.clientName == target {
 				fn := e.create
@@ -96,12 +176,65 @@ func (m *MixedProvider) resolve(modelName string, raw bool) (createFunc, conf.Cl
 	return m.fallback, m.clientConfig("openai")
 }
 
+// ResolveClient 返回模型路由决策的追踪信息（与 resolve 同样的优先级），
+// debug 模式下随响应返回，用于排查关键词匹配导致的误路由
+func (m *MixedProvider) ResolveClient(modelName string) biz.ClientResolution {
+	if target, ok := m.overrideFor(modelName); ok {
+		for _, e := range m.entries {
+			if e.clientName == target {
+				return biz.ClientResolution{
+					Client:  target,
+					Source:  biz.ResolutionSourceOverride,
+					Adapter: e.createRaw != nil,
+				}
+			}
+		}
+		// override 指向注册表外的 client：fallback 创建，无 adapter
+		return biz.ClientResolution{Client: target, Source: biz.ResolutionSourceOverride}
+	}
+	modelLower := strings.ToLower(modelName)
+	for _, e := range m.entries {
+		for _, kw := range e.keywords {
+			if strings.Contains(modelLower, strings.ToLower(kw)) {
+				return biz.ClientResolution{
+					Client:  e.clientName,
+					Source:  biz.ResolutionSourceKeyword,
+					Keyword: kw,
+					Adapter: e.createRaw != nil,
+				}
+			}
+		}
+	}
+	return biz.ClientResolution{Client: "openai", Source: biz.ResolutionSourceDefault}
+}
+
+// ClientNameFor 返回模型会被路由到的 client 名称（审计用，与 resolve 同样的优先级）
+func (m *MixedProvider) ClientNameFor(modelName string) string {
+	if target, ok := m.overrideFor(modelName); ok {
+		return target
+	}
+	modelLower := strings.ToLower(modelName)
+	for _, e := range m.entries {
+		for _, kw := range e.keywords {
+			if strings.Contains(modelLower, strings.ToLower(kw)) {
+				return e.clientName
+			}
+		}
+	}
+	return "openai"
+}
+
 func (m *MixedProvider) clientConfig(name string) conf.Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	cfg, ok := m.clients[name]
 	if !ok || (name != "openai" && cfg.BaseURL == "" && cfg.APIKey == "") {
 		if oc, exists := m.clients["openai"]; exists && (oc.BaseURL != "" || oc.APIKey != "") {
-			return oc
+			cfg = oc
 		}
 	}
+	if cfg.HTTPProxy == "" {
+		cfg.HTTPProxy = m.httpProxy
+	}
 	return cfg
 }
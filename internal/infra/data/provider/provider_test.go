@@ -3,11 +3,13 @@ package provider
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 
@@ -16,6 +18,8 @@ import (
 
 	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
+
+	openairesponse "github.com/Vickko/eino-openai-response"
 )
 
 // funcName 获取函数名用于断言
@@ -106,6 +110,41 @@ func TestResolve_Override(t *testing.T) {
 	}
 }
 
+func TestResolveClient_Trace(t *testing.T) {
+	m := NewMixedProvider(conf.Eino{
+		Clients: map[string]conf.Client{
+			"openai": {BaseURL: "https://api.openai.com/v1", APIKey: "sk-test"},
+			"claude": {BaseURL: "https://api.anthropic.com", APIKey: "sk-ant"},
+		},
+		ModelOverrides: map[string]string{
+			"my-claude-proxy": "openai",
+			"my-finetune":     "custom-gw", // 注册表外的 client
+		},
+	})
+
+	tests := []struct {
+		model string
+		want  biz.ClientResolution
+	}{
+		// 关键词匹配要报出命中的关键词与 adapter 包装情况
+		{"gpt-4o", biz.ClientResolution{Client: "openai", Source: biz.ResolutionSourceKeyword, Keyword: "gpt", Adapter: true}},
+		{"claude-sonnet-4-5", biz.ClientResolution{Client: "claude", Source: biz.ResolutionSourceKeyword, Keyword: "claude", Adapter: true}},
+		{"grok-3", biz.ClientResolution{Client: "grok", Source: biz.ResolutionSourceKeyword, Keyword: "grok", Adapter: false}},
+		// override 精确匹配优先于关键词
+		{"my-claude-proxy", biz.ClientResolution{Client: "openai", Source: biz.ResolutionSourceOverride, Adapter: true}},
+		// override 指向注册表外的 client：fallback 创建，无 adapter
+		{"my-finetune", biz.ClientResolution{Client: "custom-gw", Source: biz.ResolutionSourceOverride, Adapter: false}},
+		// 无任何匹配走兜底
+		{"unknown-model", biz.ClientResolution{Client: "openai", Source: biz.ResolutionSourceDefault}},
+	}
+
+	for _, tt := range tests {
+		if got := m.ResolveClient(tt.model); got != tt.want {
+			t.Errorf("ResolveClient(%q) = %+v, want %+v", tt.model, got, tt.want)
+		}
+	}
+}
+
 func TestResolve_RawVsAdapted(t *testing.T) {
 	m := NewMixedProvider(conf.Eino{
 		Clients: map[string]conf.Client{
@@ -126,6 +165,33 @@ func TestResolve_RawVsAdapted(t *testing.T) {
 	}
 }
 
+func TestCreateChatModelWithCredentials_OverridesClientConfig(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"test","object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`))
+	}))
+	defer srv.Close()
+
+	m := NewMixedProvider(conf.Eino{Clients: map[string]conf.Client{
+		"openai": {BaseURL: "https://configured.example.invalid/v1", APIKey: "sk-server"},
+	}})
+
+	cm, err := m.CreateChatModelWithCredentials(context.Background(), "gpt-4o",
+		biz.ClientCredentials{APIKey: "sk-user", BaseURL: srv.URL + "/v1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cm.Generate(context.Background(), testMessages); err != nil {
+		t.Fatal(err)
+	}
+	// 请求必须打到覆盖的 base_url，且携带用户提供的 key 而非服务端配置的
+	if gotAuth != "Bearer sk-user" {
+		t.Errorf("Authorization = %q, want Bearer sk-user", gotAuth)
+	}
+}
+
 func TestResolve_FallbackToOpenAIConfig(t *testing.T) {
 	m := NewMixedProvider(conf.Eino{
 		Clients: map[string]conf.Client{
@@ -327,3 +393,969 @@ func TestOpenRouterAdapter_ThinkingInjectsReasoning(t *testing.T) {
 		t.Errorf("reasoning.effort = %v, want high", reasoningMap["effort"])
 	}
 }
+
+func TestAdapters_SupportsThinking(t *testing.T) {
+	tests := []struct {
+		name    string
+		adapter interface{ SupportsThinking() bool }
+		want    bool
+	}{
+		{"openai without reasoning effort", &openAIAdapter{modelName: "gpt-4o-mini"}, false},
+		{"openai with reasoning effort", &openAIAdapter{modelName: "gpt-5"}, true},
+		{"responses api reasoning", &openAIResponseAdapter{modelName: "o3-mini"}, true},
+		{"ark", &arkAdapter{}, true},
+		{"claude", &claudeAdapter{}, true},
+		{"qwen", &qwenAdapter{}, true},
+		{"openrouter", &openRouterAdapter{}, true},
+		{"deepseek ignores toggle", &deepSeekAdapter{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.adapter.SupportsThinking(); got != tt.want {
+				t.Errorf("SupportsThinking() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// --- thinking budget 测试 ---
+
+func TestGeminiThinkingConfig_Budget(t *testing.T) {
+	boolPtr := func(b bool) *bool { return &b }
+	intPtr := func(i int) *int { return &i }
+
+	// 显式 budget 优先于 level 映射
+	cfg := geminiThinkingConfig(&biz.RequestParams{Thinking: boolPtr(true), ThinkingBudget: intPtr(8192)}, false)
+	if cfg.ThinkingBudget == nil || *cfg.ThinkingBudget != 8192 {
+		t.Fatalf("expected budget 8192, got %+v", cfg.ThinkingBudget)
+	}
+	if !cfg.IncludeThoughts {
+		t.Error("expected thoughts included with thinking enabled")
+	}
+	if cfg.ThinkingLevel != "" {
+		t.Errorf("expected no level when budget set, got %v", cfg.ThinkingLevel)
+	}
+
+	// 非流式模式下同样透传 budget
+	cfg = geminiThinkingConfig(&biz.RequestParams{Thinking: boolPtr(true), ThinkingBudget: intPtr(1024)}, true)
+	if cfg.ThinkingBudget == nil || *cfg.ThinkingBudget != 1024 {
+		t.Fatalf("expected budget 1024 in non-streaming mode, got %+v", cfg.ThinkingBudget)
+	}
+
+	// 未设置 budget 保持原有 level 行为
+	cfg = geminiThinkingConfig(&biz.RequestParams{Thinking: boolPtr(false)}, false)
+	if cfg.ThinkingBudget != nil {
+		t.Errorf("expected no budget by default, got %v", *cfg.ThinkingBudget)
+	}
+	if cfg.IncludeThoughts {
+		t.Error("expected thoughts excluded with thinking disabled")
+	}
+}
+
+func TestClaudeThinking_BudgetOverride(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	if got := claudeThinking(&biz.RequestParams{}); got.BudgetTokens != 32000 {
+		t.Errorf("expected default budget 32000, got %d", got.BudgetTokens)
+	}
+	if got := claudeThinking(&biz.RequestParams{ThinkingBudget: intPtr(4096)}); got.BudgetTokens != 4096 {
+		t.Errorf("expected overridden budget 4096, got %d", got.BudgetTokens)
+	}
+}
+
+func TestReasoningSummaryMode_OverrideOrder(t *testing.T) {
+	// 默认保持 detailed
+	if got := reasoningSummaryMode("", &biz.RequestParams{}); got != openairesponse.ReasoningSummaryDetailed {
+		t.Errorf("expected default detailed, got %q", got)
+	}
+	// client 配置生效
+	if got := reasoningSummaryMode("concise", &biz.RequestParams{}); got != openairesponse.ReasoningSummaryConcise {
+		t.Errorf("expected configured concise, got %q", got)
+	}
+	// 请求级覆盖优先于 client 配置
+	if got := reasoningSummaryMode("concise", &biz.RequestParams{ReasoningSummary: "auto"}); got != openairesponse.ReasoningSummaryAuto {
+		t.Errorf("expected per-request auto, got %q", got)
+	}
+	// 非法取值回退 detailed
+	if got := reasoningSummaryMode("verbose", nil); got != openairesponse.ReasoningSummaryDetailed {
+		t.Errorf("expected fallback detailed, got %q", got)
+	}
+}
+
+// --- 模拟流式切分测试 ---
+
+func collectSimulatedChunks(t *testing.T, resp *schema.Message) (reasoning, content string, n int) {
+	t.Helper()
+	sr := createSimulatedStreamReader(resp)
+	defer sr.Close()
+	for {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			return reasoning, content, n
+		}
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		reasoning += chunk.ReasoningContent
+		content += chunk.Content
+		n++
+	}
+}
+
+func TestSplitResponseToChunks_PreservesMultiParagraphReasoning(t *testing.T) {
+	origInterval := simulatedChunkInterval
+	simulatedChunkInterval = 0
+	defer func() { simulatedChunkInterval = origInterval }()
+
+	reasoningText := "First paragraph of reasoning.\n\nSecond paragraph with more detail.\n\nThird."
+	resp := &schema.Message{
+		Role:             schema.Assistant,
+		ReasoningContent: reasoningText,
+		Content:          "Final answer.",
+	}
+
+	reasoning, content, _ := collectSimulatedChunks(t, resp)
+	if reasoning != reasoningText {
+		t.Errorf("reasoning mangled:\nwant %q\ngot  %q", reasoningText, reasoning)
+	}
+	if content != "Final answer." {
+		t.Errorf("content mangled: %q", content)
+	}
+}
+
+func TestSplitResponseToChunks_PreservesNumberedList(t *testing.T) {
+	origInterval := simulatedChunkInterval
+	simulatedChunkInterval = 0
+	defer func() { simulatedChunkInterval = origInterval }()
+
+	reasoningText := "Plan:\n1. Parse the input carefully\n2. Validate each field against the schema\n3. Emit the result\nDone."
+	resp := &schema.Message{Role: schema.Assistant, ReasoningContent: reasoningText}
+
+	reasoning, _, _ := collectSimulatedChunks(t, resp)
+	if reasoning != reasoningText {
+		t.Errorf("numbered list mangled:\nwant %q\ngot  %q", reasoningText, reasoning)
+	}
+}
+
+func TestSplitResponseToChunks_ContentOnly(t *testing.T) {
+	origInterval := simulatedChunkInterval
+	simulatedChunkInterval = 0
+	defer func() { simulatedChunkInterval = origInterval }()
+
+	resp := &schema.Message{Role: schema.Assistant, Content: "Just content, no reasoning."}
+	reasoning, content, n := collectSimulatedChunks(t, resp)
+	if reasoning != "" {
+		t.Errorf("expected no reasoning, got %q", reasoning)
+	}
+	if content != "Just content, no reasoning." {
+		t.Errorf("content mangled: %q", content)
+	}
+	if n == 0 {
+		t.Error("expected at least one chunk")
+	}
+}
+
+func TestSplitByRuneWindow_LongTextRoundTrip(t *testing.T) {
+	long := strings.Repeat("word boundary test 以及一些中文内容 ", 40)
+	pieces := splitByRuneWindow(long, simulatedChunkRunes)
+	if len(pieces) < 2 {
+		t.Fatalf("expected multiple pieces, got %d", len(pieces))
+	}
+	if strings.Join(pieces, "") != long {
+		t.Error("pieces do not reassemble to the original text")
+	}
+	for i, p := range pieces {
+		if len([]rune(p)) > simulatedChunkRunes && i < len(pieces)-1 {
+			t.Errorf("piece %d exceeds window: %d runes", i, len([]rune(p)))
+		}
+	}
+}
+
+// --- OpenAI 兼容厂商 thinking 映射测试 ---
+
+func TestThinkingExtraFields_ProviderDefaults(t *testing.T) {
+	on := true
+	off := false
+
+	tests := []struct {
+		client  string
+		field   string
+		onVal   any
+		offVal  any
+	}{
+		{"glm", "thinking", map[string]any{"type": "enabled"}, map[string]any{"type": "disabled"}},
+		{"kimi", "enable_thinking", true, false},
+		{"minimax", "enable_thinking", true, false},
+	}
+	for _, tt := range tests {
+		mapping := thinkingMappingFor(tt.client, conf.Client{})
+		if mapping.Field != tt.field {
+			t.Errorf("%s: field = %q, want %q", tt.client, mapping.Field, tt.field)
+			continue
+		}
+
+		got := thinkingExtraFields(mapping, &biz.RequestParams{Thinking: &on})
+		if !reflect.DeepEqual(got[tt.field], tt.onVal) {
+			t.Errorf("%s on: got %v, want %v", tt.client, got[tt.field], tt.onVal)
+		}
+		got = thinkingExtraFields(mapping, &biz.RequestParams{Thinking: &off})
+		if !reflect.DeepEqual(got[tt.field], tt.offVal) {
+			t.Errorf("%s off: got %v, want %v", tt.client, got[tt.field], tt.offVal)
+		}
+	}
+}
+
+func TestThinkingExtraFields_ConfigOverrideAndUnset(t *testing.T) {
+	on := true
+
+	// 配置覆盖内置映射，新厂商无需改代码
+	mapping := thinkingMappingFor("custom", conf.Client{
+		ThinkingField: "reasoning_mode",
+		ThinkingOn:    "on",
+		ThinkingOff:   "off",
+	})
+	got := thinkingExtraFields(mapping, &biz.RequestParams{Thinking: &on})
+	if got["reasoning_mode"] != "on" {
+		t.Errorf("expected configured on value, got %v", got)
+	}
+
+	// 未配置也无内置映射：不注入
+	if fields := thinkingExtraFields(thinkingMappingFor("grok", conf.Client{}), &biz.RequestParams{Thinking: &on}); fields != nil {
+		t.Errorf("expected no injection without mapping, got %v", fields)
+	}
+
+	// thinking 未指定：保持厂商默认
+	if fields := thinkingExtraFields(thinkingMappingFor("glm", conf.Client{}), &biz.RequestParams{}); fields != nil {
+		t.Errorf("expected no injection without thinking flag, got %v", fields)
+	}
+}
+
+// --- 基础指令（base_instructions）测试 ---
+
+func TestPrependBaseInstructions_Precedence(t *testing.T) {
+	msgs := []*schema.Message{
+		{Role: schema.System, Content: "per-conversation prompt"},
+		{Role: schema.User, Content: "hi"},
+	}
+
+	got := prependBaseInstructions(msgs, "safety preamble")
+	if len(got) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(got))
+	}
+	if got[0].Role != schema.System || got[0].Content != "safety preamble" {
+		t.Fatalf("base instructions must come first, got: %+v", got[0])
+	}
+	if got[1].Content != "per-conversation prompt" || got[2].Content != "hi" {
+		t.Fatal("original messages must keep their order after the base prompt")
+	}
+
+	// 未配置时不注入
+	if got := prependBaseInstructions(msgs, ""); len(got) != 2 {
+		t.Fatalf("expected no injection without base instructions, got %d messages", len(got))
+	}
+}
+
+func TestOpenAIAdapter_BaseInstructionsInjected(t *testing.T) {
+	srv, cap := mockServer(t)
+	defer srv.Close()
+
+	cfg := conf.Client{BaseURL: srv.URL + "/v1", APIKey: "test", BaseInstructions: "always be safe"}
+	cm, err := newOpenAI(context.Background(), cfg, "gpt-4o")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cm.Generate(context.Background(), testMessages); err != nil {
+		t.Fatal(err)
+	}
+
+	body := cap.bodyMap()
+	msgs, ok := body["messages"].([]any)
+	if !ok || len(msgs) != 2 {
+		t.Fatalf("unexpected messages in request body: %v", body["messages"])
+	}
+	first, _ := msgs[0].(map[string]any)
+	if first["role"] != "system" || first["content"] != "always be safe" {
+		t.Errorf("expected base instructions as leading system message, got: %v", first)
+	}
+}
+
+// --- seed / system_fingerprint 测试 ---
+
+func seedOpts(seed int) []model.Option {
+	return []model.Option{biz.WithParams(&biz.RequestParams{Seed: &seed})}
+}
+
+func TestOpenAIAdapter_SeedReachesRequestBody(t *testing.T) {
+	srv, cap := mockServer(t)
+	defer srv.Close()
+
+	cfg := conf.Client{BaseURL: srv.URL + "/v1", APIKey: "test"}
+	cm, err := newOpenAI(context.Background(), cfg, "gpt-4o")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cm.Generate(context.Background(), testMessages, seedOpts(42)...); err != nil {
+		t.Fatal(err)
+	}
+
+	body := cap.bodyMap()
+	if seed, ok := body["seed"]; !ok {
+		t.Error("expected seed in request body, not found")
+	} else if seed != float64(42) {
+		t.Errorf("seed = %v, want 42", seed)
+	}
+}
+
+func TestOpenAICompatAdapter_SeedMergedWithThinkingFields(t *testing.T) {
+	srv, cap := mockServer(t)
+	defer srv.Close()
+
+	cfg := conf.Client{BaseURL: srv.URL + "/v1", APIKey: "test"}
+	cm, err := newOpenAICompatWithThinking("kimi")(context.Background(), cfg, "kimi-k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed := 7
+	thinking := true
+	if _, err := cm.Generate(context.Background(), testMessages,
+		biz.WithParams(&biz.RequestParams{Thinking: &thinking, Seed: &seed})); err != nil {
+		t.Fatal(err)
+	}
+
+	body := cap.bodyMap()
+	if body["seed"] != float64(7) {
+		t.Errorf("seed = %v, want 7", body["seed"])
+	}
+	if body["enable_thinking"] != true {
+		t.Errorf("enable_thinking = %v, want true", body["enable_thinking"])
+	}
+}
+
+func TestCaptureSystemFingerprint(t *testing.T) {
+	msg := &schema.Message{Role: schema.Assistant, Content: "ok"}
+	got, err := captureSystemFingerprint(context.Background(), msg,
+		[]byte(`{"id":"x","system_fingerprint":"fp_abc123"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Extra["system_fingerprint"] != "fp_abc123" {
+		t.Errorf("expected fingerprint in Extra, got: %v", got.Extra)
+	}
+
+	// 响应不带 fingerprint 时不污染 Extra
+	got, err = captureSystemFingerprint(context.Background(), &schema.Message{}, []byte(`{"id":"x"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Extra) != 0 {
+		t.Errorf("expected empty Extra, got: %v", got.Extra)
+	}
+}
+
+func TestUpstreamHTTPClient_ProxyFunctionSet(t *testing.T) {
+	client, err := upstreamHTTPClient(conf.Client{HTTPProxy: "http://proxy.internal:3128"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok || transport.Proxy == nil {
+		t.Fatalf("expected transport with proxy function, got: %#v", client.Transport)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", nil)
+	proxyURL, err := transport.Proxy(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxyURL == nil || proxyURL.Host != "proxy.internal:3128" {
+		t.Errorf("proxy = %v, want proxy.internal:3128", proxyURL)
+	}
+}
+
+func TestUpstreamHTTPClient_EmptyAndInvalid(t *testing.T) {
+	// 未配置代理时返回 nil，组件走默认 client（ProxyFromEnvironment 兜底）
+	client, err := upstreamHTTPClient(conf.Client{})
+	if err != nil || client != nil {
+		t.Errorf("expected nil client for empty proxy, got: %v, %v", client, err)
+	}
+
+	if _, err := upstreamHTTPClient(conf.Client{HTTPProxy: ":"}); err == nil {
+		t.Error("expected error for invalid proxy URL")
+	}
+}
+
+func TestClientConfig_GlobalProxyFallback(t *testing.T) {
+	m := NewMixedProvider(conf.Eino{
+		HTTPProxy: "http://global.proxy:8080",
+		Clients: map[string]conf.Client{
+			"openai": {BaseURL: "https://api.openai.com/v1", APIKey: "sk-test"},
+			"claude": {BaseURL: "https://api.anthropic.com", APIKey: "sk-ant", HTTPProxy: "http://claude.proxy:8080"},
+		},
+	})
+
+	if got := m.clientConfig("openai").HTTPProxy; got != "http://global.proxy:8080" {
+		t.Errorf("openai proxy = %q, want global fallback", got)
+	}
+	if got := m.clientConfig("claude").HTTPProxy; got != "http://claude.proxy:8080" {
+		t.Errorf("claude proxy = %q, want per-client override", got)
+	}
+}
+
+func TestUpstreamHTTPClient_CustomHeadersEmitted(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	client, err := upstreamHTTPClient(conf.Client{
+		UserAgent: "devops-backend/1.0",
+		Headers:   map[string]string{"X-Route-Group": "canary"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got.Get("User-Agent") != "devops-backend/1.0" {
+		t.Errorf("User-Agent = %q, want devops-backend/1.0", got.Get("User-Agent"))
+	}
+	if got.Get("X-Route-Group") != "canary" {
+		t.Errorf("X-Route-Group = %q, want canary", got.Get("X-Route-Group"))
+	}
+}
+
+// --- SSE 组帧测试 ---
+
+func rewriteSSE(t *testing.T, input string) string {
+	t.Helper()
+	out, err := io.ReadAll(newSSEEventRewriter(io.NopCloser(strings.NewReader(input)), 0))
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestSSEEventRewriter_MultiLineData(t *testing.T) {
+	input := "event: response.output_text.delta\n" +
+		"data: {\"delta\":\n" +
+		"data: \"hi\"}\n" +
+		"\n"
+
+	want := "event: response.output_text.delta\ndata: {\"delta\":\"hi\"}\n\n"
+	if got := rewriteSSE(t, input); got != want {
+		t.Errorf("rewritten = %q, want %q", got, want)
+	}
+}
+
+func TestSSEEventRewriter_ReorderedFields(t *testing.T) {
+	// data 在 event 之前、夹杂 id/注释行，重组后仍是 event → data 的规范顺序
+	input := "data: {\"delta\":\"hi\"}\n" +
+		"id: 42\n" +
+		": keepalive\n" +
+		"event: response.output_text.delta\n" +
+		"\n"
+
+	want := "event: response.output_text.delta\ndata: {\"delta\":\"hi\"}\n\n"
+	if got := rewriteSSE(t, input); got != want {
+		t.Errorf("rewritten = %q, want %q", got, want)
+	}
+}
+
+func TestSSEEventRewriter_CanonicalPassthrough(t *testing.T) {
+	input := "event: response.completed\ndata: {\"id\":\"r1\"}\n\ndata: [DONE]\n\n"
+	if got := rewriteSSE(t, input); got != input {
+		t.Errorf("canonical frames must pass through unchanged, got %q", got)
+	}
+}
+
+func TestSSENormalizingTransport_OnlyWrapsEventStreams(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sse" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "data: a\ndata: b\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "{\"ok\":true}")
+	}))
+	defer srv.Close()
+
+	client := sseNormalizingClient(nil)
+
+	resp, err := client.Get(srv.URL + "/sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "data: ab\n\n" {
+		t.Errorf("sse body = %q, want re-framed single data line", body)
+	}
+
+	resp, err = client.Get(srv.URL + "/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "{\"ok\":true}" {
+		t.Errorf("json body = %q, want untouched", body)
+	}
+}
+
+func TestSSEEventRewriter_OversizedLineRejected(t *testing.T) {
+	input := "data: " + strings.Repeat("x", 4096) + "\n\n"
+	r := newSSEEventRewriter(io.NopCloser(strings.NewReader(input)), 1024)
+
+	_, err := io.ReadAll(r)
+	if err == nil || !strings.Contains(err.Error(), "exceeds 1024 bytes") {
+		t.Errorf("expected max-line error, got: %v", err)
+	}
+}
+
+func TestResponsesAdapter_ExtraFieldsReachRequestBody(t *testing.T) {
+	srv, cap := newResponsesServer(t)
+
+	cfg := conf.Client{BaseURL: srv.URL, APIKey: "test"}
+	cm, err := newOpenAI(context.Background(), cfg, "o3-mini")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = cm.Generate(context.Background(), testMessages,
+		biz.WithParams(&biz.RequestParams{Extra: map[string]any{"service_tier": "flex"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := cap.bodyMap()
+	if body["service_tier"] != "flex" {
+		t.Errorf("service_tier = %v, want flex (body: %s)", body["service_tier"], cap.body)
+	}
+	if body["model"] != "o3-mini" {
+		t.Errorf("model = %v, merge must preserve original fields", body["model"])
+	}
+}
+
+func TestOpenAIAdapter_ExtraFieldsMergedWithSeed(t *testing.T) {
+	srv, cap := mockServer(t)
+	defer srv.Close()
+
+	cfg := conf.Client{BaseURL: srv.URL + "/v1", APIKey: "test"}
+	cm, err := newOpenAI(context.Background(), cfg, "gpt-4o")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seed := 7
+	_, err = cm.Generate(context.Background(), testMessages,
+		biz.WithParams(&biz.RequestParams{Seed: &seed, Extra: map[string]any{"logprobs": true}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := cap.bodyMap()
+	if body["logprobs"] != true {
+		t.Errorf("logprobs = %v, want true", body["logprobs"])
+	}
+	if body["seed"] != float64(7) {
+		t.Errorf("seed = %v, want 7", body["seed"])
+	}
+}
+
+func TestProbeClients_RecordsHealthPerClient(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/models" {
+			t.Errorf("probe path = %s, want /models", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer k1" {
+			t.Errorf("Authorization = %q, want Bearer k1", got)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer healthy.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	m := NewMixedProvider(conf.Eino{Clients: map[string]conf.Client{
+		"openai": {BaseURL: healthy.URL, APIKey: "k1"},
+		"claude": {BaseURL: broken.URL, APIKey: "k2"},
+		"gemini": {}, // 未配置 base_url，应跳过
+	}})
+	m.probeClients(context.Background())
+
+	snapshot := m.ClientHealthSnapshot()
+	if len(snapshot) != 2 {
+		t.Fatalf("snapshot has %d entries, want 2: %+v", len(snapshot), snapshot)
+	}
+	// snapshot 按名称排序：claude 在前
+	if snapshot[0].Client != "claude" || snapshot[0].Healthy {
+		t.Errorf("claude entry = %+v, want unhealthy", snapshot[0])
+	}
+	if snapshot[0].Error == "" {
+		t.Error("unhealthy probe should record the upstream status as error")
+	}
+	if snapshot[1].Client != "openai" || !snapshot[1].Healthy {
+		t.Errorf("openai entry = %+v, want healthy", snapshot[1])
+	}
+	if snapshot[1].LatencyMs < 0 {
+		t.Errorf("latency_ms = %d, want >= 0", snapshot[1].LatencyMs)
+	}
+
+	if m.IsClientHealthy("claude") {
+		t.Error("IsClientHealthy(claude) = true after a failed probe")
+	}
+	if !m.IsClientHealthy("openai") {
+		t.Error("IsClientHealthy(openai) = false after a successful probe")
+	}
+	if !m.IsClientHealthy("qwen") {
+		t.Error("IsClientHealthy should treat unprobed clients as available")
+	}
+}
+
+func TestProbeClient_ClientErrorStillProvesReachability(t *testing.T) {
+	// 部分厂商没有 /models 端点，4xx 只说明端点不存在，不代表服务不可用
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	result := probeClient(context.Background(), "qwen", conf.Client{BaseURL: srv.URL})
+	if !result.Healthy {
+		t.Errorf("probe result = %+v, want healthy on 4xx", result)
+	}
+}
+
+func TestResponsesAdapter_GenerateHermetic(t *testing.T) {
+	cfg, cap := responsesTestClient(t)
+	cm, err := newOpenAI(context.Background(), cfg, "o3-mini")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := cm.Generate(context.Background(), testMessages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg == nil || msg.Content == "" {
+		t.Fatalf("expected non-empty assistant message, got: %+v", msg)
+	}
+	if cap != nil {
+		// mock 模式才能断言请求细节
+		if msg.Content != "ok" {
+			t.Errorf("content = %q, want canned %q", msg.Content, "ok")
+		}
+		if cap.path != "/responses" {
+			t.Errorf("request path = %q, want /responses", cap.path)
+		}
+	}
+}
+
+func TestResponsesAdapter_StreamHermetic(t *testing.T) {
+	cfg, cap := responsesTestClient(t)
+	cm, err := newOpenAI(context.Background(), cfg, "o3-mini")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sr, err := cm.Stream(context.Background(), testMessages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+
+	var content strings.Builder
+	for {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content.WriteString(chunk.Content)
+	}
+	if content.Len() == 0 {
+		t.Fatal("stream produced no content")
+	}
+	if cap != nil {
+		if content.String() != "hello" {
+			t.Errorf("streamed content = %q, want %q", content.String(), "hello")
+		}
+		if body := cap.bodyMap(); body["stream"] != true {
+			t.Errorf("request body stream = %v, want true", body["stream"])
+		}
+	}
+}
+
+// --- 上游状态码找回测试 ---
+
+func TestRecoverUpstreamStatus_KnownFormats(t *testing.T) {
+	cases := []struct {
+		msg  string
+		want int
+	}{
+		{"api error (status 429): rate limit exceeded", 429},
+		{"http error: status 503, body: upstream unavailable", 503},
+		{"api error (status 400): invalid model", 400},
+		{"connection refused", 0},
+		{"weird status 999 marker", 0},
+	}
+	for _, tc := range cases {
+		got := biz.UpstreamStatusCode(recoverUpstreamStatus(errors.New(tc.msg)))
+		if got != tc.want {
+			t.Errorf("recoverUpstreamStatus(%q): status = %d, want %d", tc.msg, got, tc.want)
+		}
+	}
+}
+
+func TestResponsesAdapter_GenerateSurfacesUpstreamStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error": {"message": "rate limit exceeded", "type": "rate_limit_error"}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cm, err := newOpenAI(context.Background(), conf.Client{BaseURL: srv.URL, APIKey: "test-key"}, "o3-mini")
+	if err != nil {
+		t.Fatalf("newOpenAI failed: %v", err)
+	}
+
+	_, err = cm.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if err == nil {
+		t.Fatal("expected an error from a 429 upstream")
+	}
+	if got := biz.UpstreamStatusCode(err); got != http.StatusTooManyRequests {
+		t.Errorf("UpstreamStatusCode = %d, want 429 (err: %v)", got, err)
+	}
+}
+
+// --- 配置热重载测试 ---
+
+func TestReloadClients_AddsClientAndUpdatesRouting(t *testing.T) {
+	m := NewMixedProvider(conf.Eino{Clients: map[string]conf.Client{
+		"openai": {BaseURL: "https://old.example.com/v1", APIKey: "k-old"},
+	}})
+
+	// 重载前：glm 未配置，回退到 openai 的配置
+	if got := m.clientConfig("glm").BaseURL; got != "https://old.example.com/v1" {
+		t.Fatalf("pre-reload glm BaseURL = %q, want openai fallback", got)
+	}
+
+	m.ReloadClients(conf.Eino{
+		Clients: map[string]conf.Client{
+			"openai": {BaseURL: "https://old.example.com/v1", APIKey: "k-rotated"},
+			"glm":    {BaseURL: "https://glm.example.com/v1", APIKey: "k-glm"},
+		},
+		ModelOverrides: map[string]string{"my-finetune": "glm"},
+	})
+
+	if got := m.clientConfig("glm").BaseURL; got != "https://glm.example.com/v1" {
+		t.Errorf("post-reload glm BaseURL = %q, want the newly added client", got)
+	}
+	if got := m.clientConfig("openai").APIKey; got != "k-rotated" {
+		t.Errorf("post-reload openai APIKey = %q, want rotated key", got)
+	}
+	if got := m.ClientNameFor("my-finetune"); got != "glm" {
+		t.Errorf("post-reload override routing = %q, want glm", got)
+	}
+}
+
+func TestValidateDefaultModel(t *testing.T) {
+	m := NewMixedProvider(conf.Eino{Clients: map[string]conf.Client{
+		"openai": {BaseURL: "https://api.example.com/v1", APIKey: "k"},
+	}})
+
+	if err := m.ValidateDefaultModel("gpt-4o"); err != nil {
+		t.Errorf("configured default model should validate, got: %v", err)
+	}
+	if err := m.ValidateDefaultModel(""); err != nil {
+		t.Errorf("empty default model is allowed, got: %v", err)
+	}
+
+	// claude 未配置且 openai 兜底可用 → 仍然有效
+	if err := m.ValidateDefaultModel("claude-sonnet-4-5"); err != nil {
+		t.Errorf("fallback to openai config should validate, got: %v", err)
+	}
+
+	// 没有任何可用配置 → 指名道姓的错误
+	empty := NewMixedProvider(conf.Eino{})
+	err := empty.ValidateDefaultModel("claude-sonnet-4-5")
+	if err == nil {
+		t.Fatal("expected an error for an unconfigured default model")
+	}
+	if !strings.Contains(err.Error(), "claude-sonnet-4-5") || !strings.Contains(err.Error(), `"claude"`) {
+		t.Errorf("error should name the model and resolved client, got: %v", err)
+	}
+}
+
+func TestCreateChatModel_UnconfiguredClientError(t *testing.T) {
+	m := NewMixedProvider(conf.Eino{})
+	_, err := m.CreateChatModel(context.Background(), "gemini-2.5-pro")
+	if err == nil {
+		t.Fatal("expected an error when no client is configured")
+	}
+	if !strings.Contains(err.Error(), "gemini-2.5-pro") || !strings.Contains(err.Error(), `"gemini"`) {
+		t.Errorf("error should name the model and resolved client, got: %v", err)
+	}
+}
+
+// --- store 隐私默认值测试 ---
+
+func TestResponsesAdapter_StoreDefaultPropagates(t *testing.T) {
+	srv, cap := newResponsesServer(t)
+	storeOff := false
+	cfg := conf.Client{BaseURL: srv.URL, APIKey: "test-key", Store: &storeOff}
+
+	cm, err := newOpenAI(context.Background(), cfg, "o3-mini")
+	if err != nil {
+		t.Fatalf("newOpenAI failed: %v", err)
+	}
+	if _, err := cm.Generate(context.Background(), testMessages); err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	if got, ok := cap.bodyMap()["store"]; !ok || got != false {
+		t.Errorf("store = %v (present=%v), want false from client config", got, ok)
+	}
+}
+
+func TestResponsesAdapter_StoreRequestOverrideWins(t *testing.T) {
+	srv, cap := newResponsesServer(t)
+	storeOff := false
+	cfg := conf.Client{BaseURL: srv.URL, APIKey: "test-key", Store: &storeOff}
+
+	cm, err := newOpenAI(context.Background(), cfg, "o3-mini")
+	if err != nil {
+		t.Fatalf("newOpenAI failed: %v", err)
+	}
+	storeOn := true
+	_, err = cm.Generate(context.Background(), testMessages,
+		biz.WithParams(&biz.RequestParams{Store: &storeOn}))
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	if got, ok := cap.bodyMap()["store"]; !ok || got != true {
+		t.Errorf("store = %v (present=%v), want request-level true to win", got, ok)
+	}
+}
+
+func TestResponsesAdapter_StoreDefaultSkipsContinuation(t *testing.T) {
+	srv, cap := newResponsesServer(t)
+	storeOff := false
+	cfg := conf.Client{BaseURL: srv.URL, APIKey: "test-key", Store: &storeOff}
+
+	cm, err := newOpenAI(context.Background(), cfg, "o3-mini")
+	if err != nil {
+		t.Fatalf("newOpenAI failed: %v", err)
+	}
+	// previous_response_id 续写依赖服务端存储，配置级默认值不应关掉它
+	_, err = cm.Generate(context.Background(), testMessages,
+		biz.WithParams(&biz.RequestParams{Extra: map[string]any{"previous_response_id": "resp_1"}}))
+	if err != nil {
+		t.Fatalf("generate failed: %v", err)
+	}
+
+	body := cap.bodyMap()
+	if _, ok := body["store"]; ok {
+		t.Errorf("store should not be injected alongside previous_response_id, body: %v", body)
+	}
+	if body["previous_response_id"] != "resp_1" {
+		t.Errorf("previous_response_id lost: %v", body)
+	}
+}
+
+// --- function_call 事件流测试 ---
+
+// functionCallSSEFixture 完整的 function_call 事件序列：output_item.added 携带
+// 工具名，后续 arguments.delta 只带 output_index（无 call_id），最后 done + completed
+const functionCallSSEFixture = "event: response.created\n" +
+	"data: {\"response\":{\"id\":\"r1\",\"status\":\"in_progress\"}}\n\n" +
+	"event: response.output_item.added\n" +
+	"data: {\"output_index\":0,\"item\":{\"type\":\"function_call\",\"id\":\"fc_1\",\"call_id\":\"call_123\",\"name\":\"get_weather\",\"arguments\":\"\"}}\n\n" +
+	"event: response.function_call_arguments.delta\n" +
+	"data: {\"output_index\":0,\"delta\":\"{\\\"city\\\":\"}\n\n" +
+	"event: response.function_call_arguments.delta\n" +
+	"data: {\"output_index\":0,\"delta\":\"\\\"SF\\\"}\"}\n\n" +
+	"event: response.output_item.done\n" +
+	"data: {\"output_index\":0,\"item\":{\"type\":\"function_call\",\"id\":\"fc_1\",\"call_id\":\"call_123\",\"name\":\"get_weather\",\"arguments\":\"{\\\"city\\\":\\\"SF\\\"}\"}}\n\n" +
+	"event: response.completed\n" +
+	"data: {\"response\":{\"id\":\"r1\",\"status\":\"completed\",\"usage\":{\"input_tokens\":3,\"output_tokens\":2,\"total_tokens\":5}}}\n\n"
+
+func TestResponsesAdapter_StreamEmitsToolNameBeforeArguments(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(functionCallSSEFixture))
+	}))
+	t.Cleanup(srv.Close)
+
+	cm, err := newOpenAI(context.Background(), conf.Client{BaseURL: srv.URL, APIKey: "test-key"}, "o3-mini")
+	if err != nil {
+		t.Fatalf("newOpenAI failed: %v", err)
+	}
+	sr, err := cm.Stream(context.Background(), testMessages)
+	if err != nil {
+		t.Fatalf("stream failed: %v", err)
+	}
+	defer sr.Close()
+
+	var toolChunks []schema.ToolCall
+	for {
+		msg, recvErr := sr.Recv()
+		if recvErr == io.EOF {
+			break
+		}
+		if recvErr != nil {
+			t.Fatalf("recv failed: %v", recvErr)
+		}
+		if len(msg.ToolCalls) > 0 {
+			toolChunks = append(toolChunks, msg.ToolCalls...)
+		}
+	}
+
+	if len(toolChunks) < 3 {
+		t.Fatalf("expected early announcement + 2 argument deltas, got %d chunks: %+v", len(toolChunks), toolChunks)
+	}
+	// 首个 chunk 必须在任何参数之前带上工具名和 call ID
+	first := toolChunks[0]
+	if first.Function.Name != "get_weather" || first.ID != "call_123" {
+		t.Errorf("first chunk = %+v, want name get_weather and id call_123", first)
+	}
+	if first.Function.Arguments != "" {
+		t.Errorf("first chunk must not carry arguments yet, got %q", first.Function.Arguments)
+	}
+	// 后续参数增量要能按宣告的 call ID 归并（上游 delta 不带 call_id，由补全而来）
+	var args string
+	for _, chunk := range toolChunks[1 : len(toolChunks)-1] {
+		if chunk.ID != "call_123" {
+			t.Errorf("argument delta not correlated to call_123: %+v", chunk)
+		}
+		args += chunk.Function.Arguments
+	}
+	if args != `{"city":"SF"}` {
+		t.Errorf("accumulated arguments = %q, want {\"city\":\"SF\"}", args)
+	}
+}
+
+func TestSSEEventRewriter_NonFunctionCallItemUntouched(t *testing.T) {
+	// message 类型的 output_item.added 不触发合成事件
+	input := "event: response.output_item.added\n" +
+		"data: {\"output_index\":0,\"item\":{\"type\":\"message\",\"role\":\"assistant\"}}\n" +
+		"\n"
+	if got := rewriteSSE(t, input); got != input {
+		t.Errorf("non-function_call item must pass through unchanged, got %q", got)
+	}
+}
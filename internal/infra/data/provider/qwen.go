@@ -13,8 +13,12 @@ import (
 
 // newQwenRaw 创建原始 Qwen client
 func newQwenRaw(ctx context.Context, cfg conf.Client, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	httpClient, err := upstreamHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
 	return qwen.NewChatModel(ctx, &qwen.ChatModelConfig{
-		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName,
+		BaseURL: cfg.BaseURL, APIKey: cfg.APIKey, Model: modelName, HTTPClient: httpClient,
 	})
 }
 
@@ -59,10 +63,13 @@ func (a *qwenAdapter) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChat
 	return &qwenAdapter{raw: m}, nil
 }
 
+// SupportsThinking Qwen 支持按请求开关 thinking
+func (a *qwenAdapter) SupportsThinking() bool { return true }
+
 func (a *qwenAdapter) injectOpts(opts []model.Option) []model.Option {
-	params := biz.GetParams(opts...)
-	if params.Thinking == nil {
+	enable, set := biz.ThinkingDecision(biz.GetParams(opts...), true)
+	if !set {
 		return opts
 	}
-	return append(opts, qwen.WithEnableThinking(*params.Thinking))
+	return append(opts, qwen.WithEnableThinking(enable))
 }
@@ -0,0 +1,318 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// o 系列模型的 reasoning 输出项携带 item ID 与（store=false 时的）加密推理
+// 内容，下一轮原样回放即可续用思考、不再重复付费。Responses client 的
+// toSchemaMessage 只取摘要文本，ID 和 encrypted_content 到它那里就丢了。
+// client 不可改，沿用工具调用的哨兵方案双向兜底：
+//   - 入站：HTTP 层把 reasoning 项改写成哨兵摘要文本混进 ReasoningContent，
+//     adapter 收到后剥离哨兵、存进消息 Extra（随会话持久化）；
+//   - 出站：adapter 把 Extra 里的 reasoning 项编码进哨兵 Content，
+//     HTTP 层再展开成标准 reasoning 输入项。
+
+// reasoningItemSentinel 入站哨兵前缀，后跟单个 biz.ReasoningItem JSON
+const reasoningItemSentinel = "\x00devops-reasoning-item\x00"
+
+// assistantReasoningSentinel 出站哨兵 Content 前缀，后跟 assistantReasoningPayload JSON
+const assistantReasoningSentinel = "\x00devops-assistant-reasoning\x00"
+
+// assistantReasoningPayload 出站哨兵 Content 携带的数据：原始文本 + reasoning 项
+type assistantReasoningPayload struct {
+	Content string              `json:"content,omitempty"`
+	Items   []biz.ReasoningItem `json:"items"`
+}
+
+// encodeReasoningItems 把 Extra 里带 reasoning 项的历史助手消息改写成哨兵
+// Content 形式，其余消息原样保留；不修改入参。需要在 encodeAssistantToolCalls
+// 之前调用，这样工具调用哨兵包在外层、与 HTTP 层的展开顺序对应
+func encodeReasoningItems(messages []*schema.Message) []*schema.Message {
+	var out []*schema.Message
+	for i, msg := range messages {
+		items := biz.ReasoningItemsFromMessage(msg)
+		if msg == nil || msg.Role != schema.Assistant || len(items) == 0 {
+			if out != nil {
+				out = append(out, msg)
+			}
+			continue
+		}
+		if out == nil {
+			out = make([]*schema.Message, 0, len(messages))
+			out = append(out, messages[:i]...)
+		}
+		raw, err := json.Marshal(assistantReasoningPayload{Content: msg.Content, Items: items})
+		if err != nil {
+			out = append(out, msg)
+			continue
+		}
+		encoded := *msg
+		encoded.Content = assistantReasoningSentinel + string(raw)
+		out = append(out, &encoded)
+	}
+	if out == nil {
+		return messages
+	}
+	return out
+}
+
+// reasoningInputClient 包装 HTTP client：发送前把哨兵助手消息展开成 reasoning
+// 输入项，收到非流式响应时把 reasoning 输出项改写成哨兵摘要（流式路径的
+// 改写在 SSE 组帧层，见 rewriteReasoningItemEvents）
+func reasoningInputClient(base *http.Client) *http.Client {
+	var client http.Client
+	if base != nil {
+		client = *base
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &reasoningInputTransport{base: transport}
+	return &client
+}
+
+type reasoningInputTransport struct {
+	base http.RoundTripper
+}
+
+func (t *reasoningInputTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Method == http.MethodPost &&
+		strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		raw, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		var body map[string]any
+		if err := json.Unmarshal(raw, &body); err != nil || !rewriteReasoningInputs(body) {
+			req = cloneWithBody(req, raw)
+		} else {
+			rewritten, err := json.Marshal(body)
+			if err != nil {
+				return nil, err
+			}
+			req = cloneWithBody(req, rewritten)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp == nil ||
+		!strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return resp, err
+	}
+	// 非流式响应：把 reasoning 输出项改写成哨兵摘要文本
+	raw, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readErr != nil {
+		return nil, readErr
+	}
+	var body map[string]any
+	if jsonErr := json.Unmarshal(raw, &body); jsonErr == nil && captureReasoningOutput(body) {
+		if rewritten, marshalErr := json.Marshal(body); marshalErr == nil {
+			raw = rewritten
+		}
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	resp.ContentLength = int64(len(raw))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+	return resp, nil
+}
+
+// rewriteReasoningInputs 把 input[] 里的哨兵助手消息展开成若干 reasoning 项
+// 加（可选的）正常助手消息，返回是否有改动
+func rewriteReasoningInputs(body map[string]any) bool {
+	input, ok := body["input"].([]any)
+	if !ok {
+		return false
+	}
+	changed := false
+	rewritten := make([]any, 0, len(input))
+	for _, item := range input {
+		m, ok := item.(map[string]any)
+		if !ok || m["role"] != "assistant" {
+			rewritten = append(rewritten, item)
+			continue
+		}
+		content, ok := m["content"].(string)
+		if !ok || !strings.HasPrefix(content, assistantReasoningSentinel) {
+			rewritten = append(rewritten, item)
+			continue
+		}
+		var payload assistantReasoningPayload
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(content, assistantReasoningSentinel)), &payload); err != nil {
+			rewritten = append(rewritten, item)
+			continue
+		}
+		for _, it := range payload.Items {
+			reasoning := map[string]any{
+				"type":    "reasoning",
+				"id":      it.ID,
+				"summary": []any{},
+			}
+			if it.EncryptedContent != "" {
+				reasoning["encrypted_content"] = it.EncryptedContent
+			}
+			rewritten = append(rewritten, reasoning)
+		}
+		if payload.Content != "" {
+			rewritten = append(rewritten, map[string]any{
+				"type": "message", "role": "assistant", "content": payload.Content,
+			})
+		}
+		changed = true
+	}
+	if changed {
+		body["input"] = rewritten
+	}
+	return changed
+}
+
+// captureReasoningOutput 给 output[] 里带 ID 的 reasoning 项追加一条哨兵摘要，
+// 让 client 把 ID 和 encrypted_content 混进 ReasoningContent 带出来，
+// 返回是否有改动
+func captureReasoningOutput(body map[string]any) bool {
+	output, ok := body["output"].([]any)
+	if !ok {
+		return false
+	}
+	changed := false
+	for _, item := range output {
+		m, ok := item.(map[string]any)
+		if !ok || m["type"] != "reasoning" {
+			continue
+		}
+		text, ok := reasoningItemSentinelText(m)
+		if !ok {
+			continue
+		}
+		summary, _ := m["summary"].([]any)
+		m["summary"] = append(summary, map[string]any{"type": "summary_text", "text": text})
+		changed = true
+	}
+	return changed
+}
+
+// reasoningItemSentinelText 把 reasoning 输出项编码成哨兵摘要文本；
+// 项没有 ID 时无法回放，返回 false
+func reasoningItemSentinelText(m map[string]any) (string, bool) {
+	id, _ := m["id"].(string)
+	if id == "" {
+		return "", false
+	}
+	encrypted, _ := m["encrypted_content"].(string)
+	raw, err := json.Marshal(biz.ReasoningItem{ID: id, EncryptedContent: encrypted})
+	if err != nil {
+		return "", false
+	}
+	return reasoningItemSentinel + string(raw), true
+}
+
+// rewriteReasoningItemEvents 流式路径的 reasoning 项抓取：对 reasoning 的
+// output_item.done 合成一条哨兵摘要 delta（client 会把它当普通推理增量解析），
+// 原事件原样保留；其余事件不动
+func (r *sseEventRewriter) rewriteReasoningItemEvents(eventType, data string) []sseEvent {
+	passthrough := []sseEvent{{eventType, data}}
+	if eventType != "response.output_item.done" {
+		return passthrough
+	}
+	var event struct {
+		Item map[string]any `json:"item"`
+	}
+	if err := json.Unmarshal([]byte(data), &event); err != nil || event.Item["type"] != "reasoning" {
+		return passthrough
+	}
+	text, ok := reasoningItemSentinelText(event.Item)
+	if !ok {
+		return passthrough
+	}
+	synthetic, err := json.Marshal(map[string]any{"delta": text})
+	if err != nil {
+		return passthrough
+	}
+	return []sseEvent{
+		{eventType, data},
+		{"response.reasoning_summary_text.delta", string(synthetic)},
+	}
+}
+
+// decodeReasoningText 从 ReasoningContent 里剥离哨兵段并解析 reasoning 项；
+// 没有哨兵时原样返回
+func decodeReasoningText(s string) (string, []biz.ReasoningItem) {
+	if !strings.Contains(s, reasoningItemSentinel) {
+		return s, nil
+	}
+	var items []biz.ReasoningItem
+	var clean strings.Builder
+	for {
+		idx := strings.Index(s, reasoningItemSentinel)
+		if idx < 0 {
+			clean.WriteString(s)
+			break
+		}
+		clean.WriteString(s[:idx])
+		rest := s[idx+len(reasoningItemSentinel):]
+		dec := json.NewDecoder(strings.NewReader(rest))
+		var item biz.ReasoningItem
+		if err := dec.Decode(&item); err != nil {
+			// 哨兵后不是合法 JSON：只能整段保留，避免吞掉真实摘要
+			clean.WriteString(s[idx : idx+len(reasoningItemSentinel)])
+			s = rest
+			continue
+		}
+		items = append(items, item)
+		s = rest[dec.InputOffset():]
+	}
+	// 哨兵摘要与真实摘要之间由 "\n\n" 连接，剥离后收敛残留的空白连接符
+	text := strings.ReplaceAll(clean.String(), "\n\n\n\n", "\n\n")
+	return strings.Trim(text, "\n"), items
+}
+
+// decodeReasoningMessage 剥离消息 ReasoningContent 里的哨兵段并把解析出的
+// reasoning 项写入 Extra；没有哨兵时原样返回
+func decodeReasoningMessage(msg *schema.Message) *schema.Message {
+	if msg == nil {
+		return msg
+	}
+	text, items := decodeReasoningText(msg.ReasoningContent)
+	if len(items) == 0 {
+		return msg
+	}
+	out := *msg
+	out.ReasoningContent = text
+	biz.SetMessageReasoningItems(&out, items)
+	return &out
+}
+
+// decodeReasoningStream 对流式 chunk 逐个做哨兵剥离
+func decodeReasoningStream(sr *schema.StreamReader[*schema.Message]) *schema.StreamReader[*schema.Message] {
+	return schema.StreamReaderWithConvert(sr, func(m *schema.Message) (*schema.Message, error) {
+		return decodeReasoningMessage(m), nil
+	})
+}
+
+// extraWithEncryptedReasoning 在 store=false 时请求带回加密推理内容
+// （OpenAI 只在不落库的请求上返回 encrypted_content）；调用方已显式
+// 设置 include 时不覆盖
+func (a *openAIResponseAdapter) extraWithEncryptedReasoning(extra map[string]any) map[string]any {
+	if !supportsResponsesAPIReasoning(a.modelName) {
+		return extra
+	}
+	if stored, ok := extra["store"].(bool); !ok || stored {
+		return extra
+	}
+	if _, ok := extra["include"]; ok {
+		return extra
+	}
+	return withExtraField(extra, "include", []string{"reasoning.encrypted_content"})
+}
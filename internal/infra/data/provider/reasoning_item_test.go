@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func reasoningWrap(rt http.RoundTripper) http.RoundTripper { return &reasoningInputTransport{base: rt} }
+
+// TestReasoningItems_RoundTrip 完整往返：上游 reasoning 输出项 → 哨兵摘要 →
+// adapter 剥离进 Extra → 出站哨兵 Content → HTTP 层展开回 reasoning 输入项。
+func TestReasoningItems_RoundTrip(t *testing.T) {
+	// 入站：响应改写追加哨兵摘要
+	body := map[string]any{
+		"output": []any{
+			map[string]any{
+				"type":              "reasoning",
+				"id":                "rs_1",
+				"encrypted_content": "gAAA-opaque",
+				"summary":           []any{map[string]any{"type": "summary_text", "text": "thought hard"}},
+			},
+		},
+	}
+	if !captureReasoningOutput(body) {
+		t.Fatal("expected capture to rewrite the reasoning item")
+	}
+
+	// client 把 summary 文本用 "\n\n" 拼进 ReasoningContent，adapter 再剥离哨兵
+	summary := body["output"].([]any)[0].(map[string]any)["summary"].([]any)
+	var parts []string
+	for _, s := range summary {
+		parts = append(parts, s.(map[string]any)["text"].(string))
+	}
+	msg := decodeReasoningMessage(&schema.Message{
+		Role:             schema.Assistant,
+		Content:          "The answer is 4.",
+		ReasoningContent: strings.Join(parts, "\n\n"),
+	})
+	if msg.ReasoningContent != "thought hard" {
+		t.Errorf("reasoning content = %q, want sentinel stripped", msg.ReasoningContent)
+	}
+	items := biz.ReasoningItemsFromMessage(msg)
+	if len(items) != 1 || items[0].ID != "rs_1" || items[0].EncryptedContent != "gAAA-opaque" {
+		t.Fatalf("captured items = %#v", items)
+	}
+
+	// 出站：Extra 里的项编码进哨兵 Content，HTTP 层展开成 reasoning 输入项
+	encoded := encodeReasoningItems([]*schema.Message{msg})
+	if encoded[0] == msg {
+		t.Fatal("expected a copied message with sentinel content")
+	}
+	sent := sendThroughTransport(t, reasoningWrap, fmt.Sprintf(
+		`{"model":"o3","input":[{"type":"message","role":"assistant","content":%s}]}`,
+		jsonString(t, encoded[0].Content)))
+
+	input := sent["input"].([]any)
+	if len(input) != 2 {
+		t.Fatalf("expected reasoning + message items, got %d: %#v", len(input), input)
+	}
+	reasoning := input[0].(map[string]any)
+	if reasoning["type"] != "reasoning" || reasoning["id"] != "rs_1" || reasoning["encrypted_content"] != "gAAA-opaque" {
+		t.Errorf("reasoning item = %#v", reasoning)
+	}
+	if _, ok := reasoning["summary"].([]any); !ok {
+		t.Error("replayed reasoning item must carry a summary array")
+	}
+	message := input[1].(map[string]any)
+	if message["role"] != "assistant" || message["content"] != "The answer is 4." {
+		t.Errorf("assistant message = %#v", message)
+	}
+}
+
+// TestRewriteReasoningItemEvents_SynthesizesSentinelDelta 流式 output_item.done
+// 合成哨兵摘要 delta，原事件保留。
+func TestRewriteReasoningItemEvents_SynthesizesSentinelDelta(t *testing.T) {
+	r := &sseEventRewriter{}
+	events := r.rewriteReasoningItemEvents("response.output_item.done",
+		`{"item":{"type":"reasoning","id":"rs_9","encrypted_content":"abc","summary":[]}}`)
+	if len(events) != 2 {
+		t.Fatalf("expected original + synthetic events, got %d", len(events))
+	}
+	if events[1].eventType != "response.reasoning_summary_text.delta" {
+		t.Fatalf("synthetic event type = %q", events[1].eventType)
+	}
+
+	var delta struct {
+		Delta string `json:"delta"`
+	}
+	if err := json.Unmarshal([]byte(events[1].data), &delta); err != nil {
+		t.Fatalf("synthetic event data is not JSON: %v", err)
+	}
+	text, items := decodeReasoningText(delta.Delta)
+	if text != "" || len(items) != 1 || items[0].ID != "rs_9" || items[0].EncryptedContent != "abc" {
+		t.Errorf("decoded delta: text=%q items=%#v", text, items)
+	}
+}
+
+// TestRewriteReasoningItemEvents_IgnoresOtherItems 非 reasoning 项不合成事件。
+func TestRewriteReasoningItemEvents_IgnoresOtherItems(t *testing.T) {
+	r := &sseEventRewriter{}
+	events := r.rewriteReasoningItemEvents("response.output_item.done",
+		`{"item":{"type":"function_call","call_id":"call_1","name":"f","arguments":"{}"}}`)
+	if len(events) != 1 {
+		t.Fatalf("expected passthrough only, got %d events", len(events))
+	}
+}
+
+// TestEncodeReasoningItems_NoItemsUntouched 没有 reasoning 项时原样返回同一切片。
+func TestEncodeReasoningItems_NoItemsUntouched(t *testing.T) {
+	messages := []*schema.Message{
+		schema.UserMessage("hi"),
+		{Role: schema.Assistant, Content: "hello"},
+	}
+	if got := encodeReasoningItems(messages); &got[0] != &messages[0] {
+		t.Error("expected the original slice to be returned unchanged")
+	}
+}
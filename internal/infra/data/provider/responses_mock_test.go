@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"devops-backend/internal/conf"
+)
+
+func (c *capturedRequest) record(r *http.Request) []byte {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	c.body = body
+	c.path = r.URL.Path
+	c.mu.Unlock()
+	return body
+}
+
+// Responses API 的本地 mock：测试默认打这里，全程不出网。
+// 设置 RESPONSES_TEST_BASE_URL（可选 RESPONSES_TEST_API_KEY）后改打真实端点，
+// 用于手动对照验证；此时 capturedRequest 为 nil，请求体断言会被跳过。
+
+// responsesCompletedJSON 非流式 Responses API 的固定应答
+const responsesCompletedJSON = `{"id":"r1","object":"response","status":"completed","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"ok"}]}]}`
+
+// responsesSSEFixture 流式应答：两个文本 delta + 带 usage 的 completed 事件
+const responsesSSEFixture = "event: response.created\n" +
+	"data: {\"response\":{\"id\":\"r1\",\"status\":\"in_progress\"}}\n\n" +
+	"event: response.output_text.delta\n" +
+	"data: {\"output_index\":0,\"delta\":\"hel\"}\n\n" +
+	"event: response.output_text.delta\n" +
+	"data: {\"output_index\":0,\"delta\":\"lo\"}\n\n" +
+	"event: response.completed\n" +
+	"data: {\"response\":{\"id\":\"r1\",\"status\":\"completed\",\"usage\":{\"input_tokens\":3,\"output_tokens\":2,\"total_tokens\":5}}}\n\n"
+
+// newResponsesServer 启动返回固定 Responses API 应答的本地服务，
+// 请求体带 stream:true 时吐 SSE，否则返回 JSON
+func newResponsesServer(t *testing.T) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	cap := &capturedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := cap.record(r)
+		if strings.Contains(string(body), `"stream":true`) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(responsesSSEFixture))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(responsesCompletedJSON))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, cap
+}
+
+// responsesTestClient 返回测试用的 client 配置：默认指向本地 mock，
+// 设置了 RESPONSES_TEST_BASE_URL 时指向真实端点（cap 为 nil）
+func responsesTestClient(t *testing.T) (conf.Client, *capturedRequest) {
+	t.Helper()
+	if base := os.Getenv("RESPONSES_TEST_BASE_URL"); base != "" {
+		return conf.Client{BaseURL: base, APIKey: os.Getenv("RESPONSES_TEST_API_KEY")}, nil
+	}
+	srv, cap := newResponsesServer(t)
+	return conf.Client{BaseURL: srv.URL, APIKey: "test-key"}, cap
+}
@@ -0,0 +1,88 @@
+package provider
+
+import "encoding/json"
+
+// Responses client 忽略 response.output_item.added，但 function_call 的工具名
+// 只随该事件下发，后面的 function_call_arguments.delta 又只带 output_index
+// （call_id 常缺失），于是下游要等到 output_item.done 才知道在调用哪个工具。
+// 这里在 HTTP 层补两件事：
+//  1. 对 function_call 的 output_item.added 合成一个参数为空的 output_item.done，
+//     让下游第一时间拿到工具名和 call ID（"calling <tool>" 可以立即展示）；
+//  2. 记录 output_index → call_id，给缺 call_id 的 arguments.delta 补上归并键，
+//     保证增量正确归并进前面宣告的那次调用。
+
+// sseEvent 一个待下发的 SSE 事件
+type sseEvent struct {
+	eventType string
+	data      string
+}
+
+// rewriteFunctionCallEvents 对 function_call 相关事件做上述修正；
+// 其余事件原样返回。解析失败时不做任何改写
+func (r *sseEventRewriter) rewriteFunctionCallEvents(eventType, data string) []sseEvent {
+	passthrough := []sseEvent{{eventType, data}}
+
+	switch eventType {
+	case "response.output_item.added":
+		var event struct {
+			OutputIndex int `json:"output_index"`
+			Item        *struct {
+				Type   string `json:"type"`
+				CallID string `json:"call_id"`
+				Name   string `json:"name"`
+			} `json:"item"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil ||
+			event.Item == nil || event.Item.Type != "function_call" || event.Item.Name == "" {
+			return passthrough
+		}
+		if r.callIDsByIndex == nil {
+			r.callIDsByIndex = make(map[int]string)
+		}
+		r.callIDsByIndex[event.OutputIndex] = event.Item.CallID
+
+		synthetic, err := json.Marshal(map[string]any{
+			"output_index": event.OutputIndex,
+			"item": map[string]any{
+				"type":      "function_call",
+				"call_id":   event.Item.CallID,
+				"name":      event.Item.Name,
+				"arguments": "",
+			},
+		})
+		if err != nil {
+			return passthrough
+		}
+		// 原事件保留下发（解析器忽略它，无副作用），合成事件先行
+		return []sseEvent{
+			{"response.output_item.done", string(synthetic)},
+			{eventType, data},
+		}
+
+	case "response.function_call_arguments.delta":
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(data), &fields); err != nil {
+			return passthrough
+		}
+		if callID, ok := fields["call_id"].(string); ok && callID != "" {
+			return passthrough
+		}
+		// JSON 数字解码为 float64
+		idx, ok := fields["output_index"].(float64)
+		if !ok {
+			return passthrough
+		}
+		callID, ok := r.callIDsByIndex[int(idx)]
+		if !ok || callID == "" {
+			return passthrough
+		}
+		fields["call_id"] = callID
+		patched, err := json.Marshal(fields)
+		if err != nil {
+			return passthrough
+		}
+		return []sseEvent{{eventType, string(patched)}}
+	}
+
+	return passthrough
+}
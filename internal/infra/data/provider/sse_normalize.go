@@ -0,0 +1,160 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Responses client 的 SSE 解析器假定每个 event: 行后紧跟单个 data: 行，
+// 但 SSE 规范允许一个事件携带多个 data: 行、字段顺序也不固定，
+// 合规但非典型的上游会让解析器失步。这里在 HTTP 层把事件流重新组帧成
+// 解析器期望的规范形式：先按规范缓冲完整事件（空行为界），再以
+// "event: X\ndata: Y\n\n" 的形式下发。
+
+// sseNormalizingClient 包装 HTTP client，对 text/event-stream 响应做重新组帧；
+// base 为 nil 时基于默认 Transport 构造
+func sseNormalizingClient(base *http.Client) *http.Client {
+	var client http.Client
+	if base != nil {
+		client = *base
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &sseNormalizingTransport{base: transport}
+	return &client
+}
+
+type sseNormalizingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *sseNormalizingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		resp.Body = newSSEEventRewriter(resp.Body, 0)
+	}
+	return resp, nil
+}
+
+// sseDefaultMaxLineBytes 单行上限的默认值；base64 图像 delta 也远小于此，
+// 超限基本可以断定是畸形或恶意的流
+const sseDefaultMaxLineBytes = 10 << 20 // 10 MiB
+
+// sseEventRewriter 按 SSE 规范逐事件缓冲并重新组帧的响应体
+type sseEventRewriter struct {
+	reader *bufio.Reader
+	closer io.Closer
+	out    bytes.Buffer
+	err    error
+	// maxLineBytes 单行字节数上限，超限返回错误而不是无限扩张缓冲
+	maxLineBytes int
+	// callIDsByIndex function_call 的 output_index → call_id 映射，
+	// 用于给缺 call_id 的 arguments.delta 事件补全归并键（见 sse_function_call.go）
+	callIDsByIndex map[int]string
+}
+
+// newSSEEventRewriter 创建重新组帧的响应体；maxLineBytes <= 0 时使用默认上限
+func newSSEEventRewriter(body io.ReadCloser, maxLineBytes int) *sseEventRewriter {
+	if maxLineBytes <= 0 {
+		maxLineBytes = sseDefaultMaxLineBytes
+	}
+	return &sseEventRewriter{reader: bufio.NewReader(body), closer: body, maxLineBytes: maxLineBytes}
+}
+
+func (r *sseEventRewriter) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.nextEvent()
+	}
+	return r.out.Read(p)
+}
+
+func (r *sseEventRewriter) Close() error {
+	return r.closer.Close()
+}
+
+// readLine 读取一行（含换行符），行长超过 maxLineBytes 时立即报错，
+// 不会为病态的超长行无限扩张缓冲
+func (r *sseEventRewriter) readLine() (string, error) {
+	var buf []byte
+	for {
+		frag, err := r.reader.ReadSlice('\n')
+		buf = append(buf, frag...)
+		if len(buf) > r.maxLineBytes {
+			return "", fmt.Errorf("sse line exceeds %d bytes", r.maxLineBytes)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return string(buf), err
+	}
+}
+
+// nextEvent 读取一个完整事件（到空行或 EOF）并以规范形式写入输出缓冲
+func (r *sseEventRewriter) nextEvent() {
+	var eventType string
+	var dataLines []string
+
+	for {
+		line, err := r.readLine()
+		if err != nil {
+			r.err = err
+			break
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		// 空行是事件边界
+		if line == "" {
+			break
+		}
+		// 注释行跳过
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if idx := strings.IndexByte(line, ':'); idx >= 0 {
+			field = line[:idx]
+			value = strings.TrimPrefix(line[idx+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			dataLines = append(dataLines, value)
+		}
+		// id/retry 等字段对下游解析器无意义，丢弃
+	}
+
+	if len(dataLines) == 0 {
+		return
+	}
+	// 多个 data: 行按规范以换行拼接；该端点的 payload 是 JSON，
+	// 字符串内不会出现裸换行，拼接处的换行只能是 token 间空白（或上游
+	// 从文档中间切开的产物），去掉后才能放进单个 data: 行且不改变语义
+	data := strings.ReplaceAll(strings.Join(dataLines, "\n"), "\n", "")
+	for _, ev := range r.rewriteFunctionCallEvents(eventType, data) {
+		for _, rev := range r.rewriteReasoningItemEvents(ev.eventType, ev.data) {
+			r.emitEvent(rev.eventType, rev.data)
+		}
+	}
+}
+
+// emitEvent 以解析器期望的规范形式写出一个事件
+func (r *sseEventRewriter) emitEvent(eventType, data string) {
+	if eventType != "" {
+		fmt.Fprintf(&r.out, "event: %s\n", eventType)
+	}
+	fmt.Fprintf(&r.out, "data: %s\n\n", data)
+}
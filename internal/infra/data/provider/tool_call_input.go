@@ -0,0 +1,157 @@
+package provider
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// Responses client 的 toAssistantInputItem 只看 Content，历史助手消息上的
+// ToolCalls 会被静默丢掉——多轮工具调用对话回放时模型看不到自己先前的调用，
+// function_call_output 的 call_id 也就悬空了。client 不可改，分两步兜底：
+// 发送前把 ToolCalls 编码进哨兵 Content（encodeAssistantToolCalls），
+// HTTP 层再把哨兵项展开成标准 function_call 输入项。
+
+// assistantToolCallSentinel 哨兵 Content 前缀，后跟 assistantToolCallPayload JSON
+const assistantToolCallSentinel = "\x00devops-assistant-tool-calls\x00"
+
+// assistantToolCallPayload 哨兵 Content 携带的数据：原始文本 + 工具调用列表
+type assistantToolCallPayload struct {
+	Content   string                  `json:"content,omitempty"`
+	ToolCalls []assistantToolCallItem `json:"tool_calls"`
+}
+
+type assistantToolCallItem struct {
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// encodeAssistantToolCalls 把带 ToolCalls 的历史助手消息改写成哨兵 Content 形式，
+// 其余消息原样保留；不修改入参
+func encodeAssistantToolCalls(messages []*schema.Message) []*schema.Message {
+	var out []*schema.Message
+	for i, msg := range messages {
+		if msg == nil || msg.Role != schema.Assistant || len(msg.ToolCalls) == 0 {
+			if out != nil {
+				out = append(out, msg)
+			}
+			continue
+		}
+		if out == nil {
+			out = make([]*schema.Message, 0, len(messages))
+			out = append(out, messages[:i]...)
+		}
+		payload := assistantToolCallPayload{Content: msg.Content}
+		for _, tc := range msg.ToolCalls {
+			payload.ToolCalls = append(payload.ToolCalls, assistantToolCallItem{
+				CallID:    tc.ID,
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			})
+		}
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			// 理论上不可达（纯字符串字段），保守起见原样保留
+			out = append(out, msg)
+			continue
+		}
+		encoded := *msg
+		encoded.Content = assistantToolCallSentinel + string(raw)
+		encoded.ToolCalls = nil
+		out = append(out, &encoded)
+	}
+	if out == nil {
+		return messages
+	}
+	return out
+}
+
+// toolCallInputClient 包装 HTTP client，发送前把哨兵助手消息展开成 function_call 项
+func toolCallInputClient(base *http.Client) *http.Client {
+	var client http.Client
+	if base != nil {
+		client = *base
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client.Transport = &toolCallInputTransport{base: transport}
+	return &client
+}
+
+type toolCallInputTransport struct {
+	base http.RoundTripper
+}
+
+func (t *toolCallInputTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Method != http.MethodPost ||
+		!strings.HasPrefix(req.Header.Get("Content-Type"), "application/json") {
+		return t.base.RoundTrip(req)
+	}
+
+	raw, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]any
+	if err := json.Unmarshal(raw, &body); err != nil || !rewriteToolCallInputs(body) {
+		return t.base.RoundTrip(cloneWithBody(req, raw))
+	}
+	rewritten, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(cloneWithBody(req, rewritten))
+}
+
+// rewriteToolCallInputs 把 input[] 里的哨兵助手消息展开成（可选的）正常助手
+// 消息加若干 function_call 项，返回是否有改动
+func rewriteToolCallInputs(body map[string]any) bool {
+	input, ok := body["input"].([]any)
+	if !ok {
+		return false
+	}
+	changed := false
+	rewritten := make([]any, 0, len(input))
+	for _, item := range input {
+		m, ok := item.(map[string]any)
+		if !ok || m["role"] != "assistant" {
+			rewritten = append(rewritten, item)
+			continue
+		}
+		content, ok := m["content"].(string)
+		if !ok || !strings.HasPrefix(content, assistantToolCallSentinel) {
+			rewritten = append(rewritten, item)
+			continue
+		}
+		var payload assistantToolCallPayload
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(content, assistantToolCallSentinel)), &payload); err != nil {
+			rewritten = append(rewritten, item)
+			continue
+		}
+		if payload.Content != "" {
+			rewritten = append(rewritten, map[string]any{
+				"type": "message", "role": "assistant", "content": payload.Content,
+			})
+		}
+		for _, tc := range payload.ToolCalls {
+			rewritten = append(rewritten, map[string]any{
+				"type":      "function_call",
+				"call_id":   tc.CallID,
+				"name":      tc.Name,
+				"arguments": tc.Arguments,
+			})
+		}
+		changed = true
+	}
+	if changed {
+		body["input"] = rewritten
+	}
+	return changed
+}
@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func toolCallWrap(rt http.RoundTripper) http.RoundTripper {
+	return &toolCallInputTransport{base: rt}
+}
+
+// jsonString 按 JSON 规则转义字符串（哨兵前缀含 NUL，%q 的 Go 转义不是合法 JSON）
+func jsonString(t *testing.T, s string) string {
+	t.Helper()
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+// TestAssistantToolCalls_RoundTrip 带工具调用的历史助手消息经过哨兵编码、
+// 再按 client 的序列化方式进入请求体后，HTTP 层展开为标准 function_call 项
+func TestAssistantToolCalls_RoundTrip(t *testing.T) {
+	encoded := encodeAssistantToolCalls([]*schema.Message{
+		{Role: schema.User, Content: "what's the weather in Paris?"},
+		{
+			Role:    schema.Assistant,
+			Content: "let me check",
+			ToolCalls: []schema.ToolCall{
+				{ID: "call_1", Function: schema.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_2", Function: schema.FunctionCall{Name: "get_time", Arguments: `{}`}},
+			},
+		},
+		{Role: schema.Tool, ToolCallID: "call_1", Content: "sunny"},
+	})
+
+	if len(encoded) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(encoded))
+	}
+	if !strings.HasPrefix(encoded[1].Content, assistantToolCallSentinel) {
+		t.Fatal("assistant message with tool calls must be sentinel-encoded")
+	}
+	if len(encoded[1].ToolCalls) != 0 {
+		t.Error("encoded message must not carry ToolCalls (client would drop them anyway)")
+	}
+
+	// 模拟 client 的序列化：助手消息 Content 原样进入 input 项
+	body := fmt.Sprintf(`{
+		"model": "gpt-5",
+		"input": [
+			{"type":"message","role":"user","content":%s},
+			{"type":"message","role":"assistant","content":%s},
+			{"type":"function_call_output","call_id":"call_1","output":"sunny"}
+		]
+	}`, jsonString(t, encoded[0].Content), jsonString(t, encoded[1].Content))
+
+	sent := sendThroughTransport(t, toolCallWrap, body)
+	input := sent["input"].([]any)
+	if len(input) != 5 {
+		t.Fatalf("expected 5 input items after expansion, got %d: %v", len(input), input)
+	}
+
+	assistant := input[1].(map[string]any)
+	if assistant["role"] != "assistant" || assistant["content"] != "let me check" {
+		t.Errorf("assistant text not preserved: %v", assistant)
+	}
+	call1 := input[2].(map[string]any)
+	if call1["type"] != "function_call" || call1["call_id"] != "call_1" ||
+		call1["name"] != "get_weather" || call1["arguments"] != `{"city":"Paris"}` {
+		t.Errorf("unexpected first function_call item: %v", call1)
+	}
+	call2 := input[3].(map[string]any)
+	if call2["call_id"] != "call_2" || call2["name"] != "get_time" {
+		t.Errorf("unexpected second function_call item: %v", call2)
+	}
+	output := input[4].(map[string]any)
+	if output["type"] != "function_call_output" || output["call_id"] != "call_1" {
+		t.Errorf("function_call_output must stay untouched: %v", output)
+	}
+}
+
+// TestAssistantToolCalls_ContentOnlyDropsMessageItem 没有文本只有工具调用时
+// 不生成空的助手消息项
+func TestAssistantToolCalls_ContentOnlyDropsMessageItem(t *testing.T) {
+	encoded := encodeAssistantToolCalls([]*schema.Message{{
+		Role:      schema.Assistant,
+		ToolCalls: []schema.ToolCall{{ID: "call_9", Function: schema.FunctionCall{Name: "f", Arguments: `{}`}}},
+	}})
+
+	body := fmt.Sprintf(`{"input":[{"type":"message","role":"assistant","content":%s}]}`, jsonString(t, encoded[0].Content))
+	sent := sendThroughTransport(t, toolCallWrap, body)
+	input := sent["input"].([]any)
+	if len(input) != 1 {
+		t.Fatalf("expected single function_call item, got %d: %v", len(input), input)
+	}
+	if item := input[0].(map[string]any); item["type"] != "function_call" || item["call_id"] != "call_9" {
+		t.Errorf("unexpected item: %v", item)
+	}
+}
+
+// TestEncodeAssistantToolCalls_NoToolCallsUntouched 无工具调用时原切片原样返回
+func TestEncodeAssistantToolCalls_NoToolCallsUntouched(t *testing.T) {
+	messages := []*schema.Message{
+		{Role: schema.User, Content: "hi"},
+		{Role: schema.Assistant, Content: "hello"},
+	}
+	encoded := encodeAssistantToolCalls(messages)
+	if len(encoded) != 2 || encoded[0] != messages[0] || encoded[1] != messages[1] {
+		t.Error("messages without tool calls must pass through unchanged")
+	}
+	if messages[1].Content != "hello" {
+		t.Error("input slice must not be mutated")
+	}
+}
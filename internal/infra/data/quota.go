@@ -0,0 +1,101 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ensureQuotaTables 创建 token 配额相关表：
+// token_usage 按 (用户, 窗口期) 累计用量，窗口期键滚动即自然重置；
+// quota_overrides 存用户级配额覆盖
+func ensureQuotaTables(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS token_usage (
+			owner_sub TEXT NOT NULL,
+			period TEXT NOT NULL,
+			tokens INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (owner_sub, period)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create token_usage table: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS quota_overrides (
+			owner_sub TEXT PRIMARY KEY,
+			quota INTEGER NOT NULL,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create quota_overrides table: %w", err)
+	}
+	return nil
+}
+
+// AddTokenUsage 累加用户在指定窗口期的 token 用量
+func (r *sqliteSessionRepo) AddTokenUsage(ownerSub, period string, tokens int64) error {
+	_, err := r.db.Exec(`
+		INSERT INTO token_usage (owner_sub, period, tokens, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(owner_sub, period)
+		DO UPDATE SET tokens = tokens + excluded.tokens, updated_at = CURRENT_TIMESTAMP
+	`, ownerSub, period, tokens)
+	if err != nil {
+		return fmt.Errorf("failed to add token usage: %w", err)
+	}
+	return nil
+}
+
+// GetTokenUsage 查询用户在指定窗口期的累计 token 用量，无记录返回 0
+func (r *sqliteSessionRepo) GetTokenUsage(ownerSub, period string) (int64, error) {
+	var tokens int64
+	err := r.db.QueryRow(
+		"SELECT tokens FROM token_usage WHERE owner_sub = ? AND period = ?",
+		ownerSub, period,
+	).Scan(&tokens)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get token usage: %w", err)
+	}
+	return tokens, nil
+}
+
+// SetQuotaOverride 设置用户级配额覆盖；quota <= 0 删除覆盖回退全局值
+func (r *sqliteSessionRepo) SetQuotaOverride(ownerSub string, quota int64) error {
+	if quota <= 0 {
+		if _, err := r.db.Exec("DELETE FROM quota_overrides WHERE owner_sub = ?", ownerSub); err != nil {
+			return fmt.Errorf("failed to delete quota override: %w", err)
+		}
+		return nil
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO quota_overrides (owner_sub, quota, updated_at)
+		VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(owner_sub)
+		DO UPDATE SET quota = excluded.quota, updated_at = CURRENT_TIMESTAMP
+	`, ownerSub, quota)
+	if err != nil {
+		return fmt.Errorf("failed to set quota override: %w", err)
+	}
+	return nil
+}
+
+// GetQuotaOverride 查询用户级配额覆盖，第二个返回值表示是否存在
+func (r *sqliteSessionRepo) GetQuotaOverride(ownerSub string) (int64, bool, error) {
+	var quota int64
+	err := r.db.QueryRow(
+		"SELECT quota FROM quota_overrides WHERE owner_sub = ?", ownerSub,
+	).Scan(&quota)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get quota override: %w", err)
+	}
+	return quota, true, nil
+}
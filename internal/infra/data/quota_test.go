@@ -0,0 +1,82 @@
+package data
+
+import (
+	"testing"
+
+	"devops-backend/internal/biz"
+)
+
+func newTestQuotaStore(t *testing.T) biz.QuotaStore {
+	t.Helper()
+	store, ok := newTestRepo(t).(biz.QuotaStore)
+	if !ok {
+		t.Fatal("sqlite repo must implement biz.QuotaStore")
+	}
+	return store
+}
+
+func TestTokenUsage_AccumulatesPerPeriod(t *testing.T) {
+	store := newTestQuotaStore(t)
+
+	if err := store.AddTokenUsage("alice", "2026-08", 100); err != nil {
+		t.Fatalf("failed to add usage: %v", err)
+	}
+	if err := store.AddTokenUsage("alice", "2026-08", 50); err != nil {
+		t.Fatalf("failed to add usage: %v", err)
+	}
+	// 其他用户、其他窗口期互不影响
+	if err := store.AddTokenUsage("alice", "2026-09", 7); err != nil {
+		t.Fatalf("failed to add usage: %v", err)
+	}
+	if err := store.AddTokenUsage("bob", "2026-08", 9); err != nil {
+		t.Fatalf("failed to add usage: %v", err)
+	}
+
+	used, err := store.GetTokenUsage("alice", "2026-08")
+	if err != nil {
+		t.Fatalf("failed to get usage: %v", err)
+	}
+	if used != 150 {
+		t.Fatalf("expected accumulated usage 150, got %d", used)
+	}
+	if used, _ := store.GetTokenUsage("alice", "2026-09"); used != 7 {
+		t.Fatalf("expected new period to start fresh at 7, got %d", used)
+	}
+	if used, _ := store.GetTokenUsage("bob", "2026-08"); used != 9 {
+		t.Fatalf("expected bob's usage 9, got %d", used)
+	}
+	if used, _ := store.GetTokenUsage("carol", "2026-08"); used != 0 {
+		t.Fatalf("expected unknown user usage 0, got %d", used)
+	}
+}
+
+func TestQuotaOverride_SetGetDelete(t *testing.T) {
+	store := newTestQuotaStore(t)
+
+	if _, ok, err := store.GetQuotaOverride("alice"); err != nil || ok {
+		t.Fatalf("expected no override initially, got ok=%v err=%v", ok, err)
+	}
+
+	if err := store.SetQuotaOverride("alice", 5000); err != nil {
+		t.Fatalf("failed to set override: %v", err)
+	}
+	if quota, ok, _ := store.GetQuotaOverride("alice"); !ok || quota != 5000 {
+		t.Fatalf("expected override 5000, got ok=%v quota=%d", ok, quota)
+	}
+
+	// 重复设置覆盖旧值
+	if err := store.SetQuotaOverride("alice", 8000); err != nil {
+		t.Fatalf("failed to update override: %v", err)
+	}
+	if quota, ok, _ := store.GetQuotaOverride("alice"); !ok || quota != 8000 {
+		t.Fatalf("expected updated override 8000, got ok=%v quota=%d", ok, quota)
+	}
+
+	// quota <= 0 删除覆盖
+	if err := store.SetQuotaOverride("alice", 0); err != nil {
+		t.Fatalf("failed to delete override: %v", err)
+	}
+	if _, ok, _ := store.GetQuotaOverride("alice"); ok {
+		t.Fatal("expected override to be deleted")
+	}
+}
@@ -0,0 +1,19 @@
+package data
+
+import (
+	"testing"
+
+	"devops-backend/internal/biz"
+)
+
+// TestSessionRepoConformance 同一套契约断言跑在文件库和内存库上，
+// 证明两个实现可以互换（降级兜底依赖这个前提）。
+func TestSessionRepoConformance(t *testing.T) {
+	factories := map[string]func(t *testing.T) biz.SessionRepo{
+		"sqlite":   newTestRepo,
+		"inmemory": newInMemoryTestRepo,
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) { RunSessionRepoTests(t, factory) })
+	}
+}
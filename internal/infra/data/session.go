@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
 
 	"devops-backend/internal/biz"
 
@@ -16,9 +18,15 @@ import (
 	_ "modernc.org/sqlite"
 )
 
+// defaultAutoTitleMaxLen 自动标题的默认最大长度（按 rune 计）
+const defaultAutoTitleMaxLen = 15
+
 // sqliteSessionRepo SQLite 实现的会话仓库（三层模型）
 type sqliteSessionRepo struct {
 	db *sql.DB
+
+	// autoTitleMaxLen 自动标题最大长度，见 SetAutoTitleMaxLen
+	autoTitleMaxLen int
 }
 
 // NewSQLiteSessionRepo 创建 SQLite 会话仓库
@@ -47,8 +55,14 @@ func NewSQLiteSessionRepo(dbPath string) (biz.SessionRepo, error) {
 		CREATE TABLE IF NOT EXISTS session_trees (
 			id TEXT PRIMARY KEY,
 			title TEXT,
+			owner_sub TEXT NOT NULL DEFAULT 'anonymous',
+			title_source TEXT NOT NULL DEFAULT 'auto',
+			pinned INTEGER NOT NULL DEFAULT 0,
+			metadata TEXT,
+			system_prompt TEXT,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			deleted_at DATETIME
 		)
 	`)
 	if err != nil {
@@ -62,6 +76,7 @@ func NewSQLiteSessionRepo(dbPath string) (biz.SessionRepo, error) {
 			id TEXT PRIMARY KEY,
 			tree_id TEXT NOT NULL,
 			message_count INTEGER DEFAULT 0,
+			fork_anchor INTEGER,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (tree_id) REFERENCES session_trees(id) ON DELETE CASCADE
 		)
@@ -79,6 +94,7 @@ func NewSQLiteSessionRepo(dbPath string) (biz.SessionRepo, error) {
 			parent_id INTEGER,
 			role TEXT NOT NULL,
 			model TEXT,
+			partial INTEGER NOT NULL DEFAULT 0,
 			message_data TEXT NOT NULL,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
@@ -95,12 +111,67 @@ func NewSQLiteSessionRepo(dbPath string) (biz.SessionRepo, error) {
 		return nil, err
 	}
 
+	// 兼容旧库：老的 messages 表可能没有 partial 列（部分保存标记）
+	if err := ensureMessagesPartialColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 兼容旧库：老的 session_trees 表可能没有 deleted_at 列（软删除）
+	if err := ensureTreesDeletedAtColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 兼容旧库：老的 session_trees 表可能没有 pinned 列（会话置顶）
+	if err := ensureTreesPinnedColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 兼容旧库：老的 session_trees 表可能没有 title_source 列（标题来源）
+	if err := ensureTreesTitleSourceColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 兼容旧库：老的 session_trees 表可能没有 owner_sub 列（按用户隔离）
+	if err := ensureTreesOwnerSubColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 兼容旧库：老的 session_trees 表可能没有 metadata 列（集成方自定义数据）
+	if err := ensureTreesMetadataColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 兼容旧库：老的 session_trees 表可能没有 system_prompt 列（对话级自定义指令）
+	if err := ensureTreesSystemPromptColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// 兼容旧库：老的 sessions 表可能没有 fork_anchor 列（时间漫游分支锚点）
+	if err := ensureSessionsForkAnchorColumn(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// token 配额表（用量按窗口期累计 + 用户级配额覆盖）
+	if err := ensureQuotaTables(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
 	// 创建索引
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_sessions_tree_id ON sessions(tree_id)")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_session_id ON messages(session_id)")
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_messages_parent_id ON messages(parent_id)")
+	db.Exec("CREATE INDEX IF NOT EXISTS idx_session_trees_owner_sub ON session_trees(owner_sub)")
 
-	return &sqliteSessionRepo{db: db}, nil
+	return &sqliteSessionRepo{db: db, autoTitleMaxLen: defaultAutoTitleMaxLen}, nil
 }
 
 func ensureMessagesModelColumn(db *sql.DB) error {
@@ -140,13 +211,304 @@ func ensureMessagesModelColumn(db *sql.DB) error {
 	return nil
 }
 
-// NewConversation 创建新对话（tree + 首个 session）
-func (r *sqliteSessionRepo) NewConversation() (treeID, sessionID string) {
+func ensureMessagesPartialColumn(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(messages)")
+	if err != nil {
+		return fmt.Errorf("failed to query messages schema: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+	hasPartial := false
+	for rows.Next() {
+		if scanErr := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); scanErr != nil {
+			continue
+		}
+		if name == "partial" {
+			hasPartial = true
+			break
+		}
+	}
+	if hasPartial {
+		return nil
+	}
+
+	// 存量消息都视为完整保存
+	if _, err := db.Exec("ALTER TABLE messages ADD COLUMN partial INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to migrate messages table (add partial column): %w", err)
+	}
+	return nil
+}
+
+func ensureSessionsForkAnchorColumn(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(sessions)")
+	if err != nil {
+		return fmt.Errorf("failed to query sessions schema: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+	hasForkAnchor := false
+	for rows.Next() {
+		if scanErr := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); scanErr != nil {
+			continue
+		}
+		if name == "fork_anchor" {
+			hasForkAnchor = true
+			break
+		}
+	}
+	if hasForkAnchor {
+		return nil
+	}
+
+	// 存量 session 都不是时间漫游分支，锚点保持 NULL
+	if _, err := db.Exec("ALTER TABLE sessions ADD COLUMN fork_anchor INTEGER"); err != nil {
+		return fmt.Errorf("failed to migrate sessions table (add fork_anchor column): %w", err)
+	}
+	return nil
+}
+
+func ensureTreesDeletedAtColumn(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(session_trees)")
+	if err != nil {
+		return fmt.Errorf("failed to query session_trees schema: %w", err)
+	}
+	defer rows.Close()
+
+	// PRAGMA table_info 返回列：cid, name, type, notnull, dflt_value, pk
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+	hasDeletedAt := false
+	for rows.Next() {
+		if scanErr := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); scanErr != nil {
+			continue
+		}
+		if name == "deleted_at" {
+			hasDeletedAt = true
+			break
+		}
+	}
+	if hasDeletedAt {
+		return nil
+	}
+
+	// 可空列，NULL 表示未删除
+	if _, err := db.Exec("ALTER TABLE session_trees ADD COLUMN deleted_at DATETIME"); err != nil {
+		return fmt.Errorf("failed to migrate session_trees table (add deleted_at column): %w", err)
+	}
+	return nil
+}
+
+func ensureTreesPinnedColumn(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(session_trees)")
+	if err != nil {
+		return fmt.Errorf("failed to query session_trees schema: %w", err)
+	}
+	defer rows.Close()
+
+	// PRAGMA table_info 返回列：cid, name, type, notnull, dflt_value, pk
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+	hasPinned := false
+	for rows.Next() {
+		if scanErr := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); scanErr != nil {
+			continue
+		}
+		if name == "pinned" {
+			hasPinned = true
+			break
+		}
+	}
+	if hasPinned {
+		return nil
+	}
+
+	if _, err := db.Exec("ALTER TABLE session_trees ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return fmt.Errorf("failed to migrate session_trees table (add pinned column): %w", err)
+	}
+	return nil
+}
+
+func ensureTreesTitleSourceColumn(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(session_trees)")
+	if err != nil {
+		return fmt.Errorf("failed to query session_trees schema: %w", err)
+	}
+	defer rows.Close()
+
+	// PRAGMA table_info 返回列：cid, name, type, notnull, dflt_value, pk
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+	hasTitleSource := false
+	for rows.Next() {
+		if scanErr := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); scanErr != nil {
+			continue
+		}
+		if name == "title_source" {
+			hasTitleSource = true
+			break
+		}
+	}
+	if hasTitleSource {
+		return nil
+	}
+
+	// 存量标题都是首条消息截断产物，按 auto 处理
+	if _, err := db.Exec("ALTER TABLE session_trees ADD COLUMN title_source TEXT NOT NULL DEFAULT 'auto'"); err != nil {
+		return fmt.Errorf("failed to migrate session_trees table (add title_source column): %w", err)
+	}
+	return nil
+}
+
+func ensureTreesOwnerSubColumn(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(session_trees)")
+	if err != nil {
+		return fmt.Errorf("failed to query session_trees schema: %w", err)
+	}
+	defer rows.Close()
+
+	// PRAGMA table_info 返回列：cid, name, type, notnull, dflt_value, pk
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+	hasOwnerSub := false
+	for rows.Next() {
+		if scanErr := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); scanErr != nil {
+			continue
+		}
+		if name == "owner_sub" {
+			hasOwnerSub = true
+			break
+		}
+	}
+	if hasOwnerSub {
+		return nil
+	}
+
+	// 存量会话归入共享的 anonymous owner
+	if _, err := db.Exec("ALTER TABLE session_trees ADD COLUMN owner_sub TEXT NOT NULL DEFAULT 'anonymous'"); err != nil {
+		return fmt.Errorf("failed to migrate session_trees table (add owner_sub column): %w", err)
+	}
+	return nil
+}
+
+func ensureTreesMetadataColumn(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(session_trees)")
+	if err != nil {
+		return fmt.Errorf("failed to query session_trees schema: %w", err)
+	}
+	defer rows.Close()
+
+	// PRAGMA table_info 返回列：cid, name, type, notnull, dflt_value, pk
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+	hasMetadata := false
+	for rows.Next() {
+		if scanErr := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); scanErr != nil {
+			continue
+		}
+		if name == "metadata" {
+			hasMetadata = true
+			break
+		}
+	}
+	if hasMetadata {
+		return nil
+	}
+
+	if _, err := db.Exec("ALTER TABLE session_trees ADD COLUMN metadata TEXT"); err != nil {
+		return fmt.Errorf("failed to migrate session_trees table (add metadata column): %w", err)
+	}
+	return nil
+}
+
+func ensureTreesSystemPromptColumn(db *sql.DB) error {
+	rows, err := db.Query("PRAGMA table_info(session_trees)")
+	if err != nil {
+		return fmt.Errorf("failed to query session_trees schema: %w", err)
+	}
+	defer rows.Close()
+
+	var (
+		cid       int
+		name      string
+		colType   string
+		notNull   int
+		dfltValue sql.NullString
+		pk        int
+	)
+	hasSystemPrompt := false
+	for rows.Next() {
+		if scanErr := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); scanErr != nil {
+			continue
+		}
+		if name == "system_prompt" {
+			hasSystemPrompt = true
+			break
+		}
+	}
+	if hasSystemPrompt {
+		return nil
+	}
+
+	// 可空列，NULL/空串都表示未设置对话级指令
+	if _, err := db.Exec("ALTER TABLE session_trees ADD COLUMN system_prompt TEXT"); err != nil {
+		return fmt.Errorf("failed to migrate session_trees table (add system_prompt column): %w", err)
+	}
+	return nil
+}
+
+// NewConversation 创建新对话（tree + 首个 session），归属 ownerSub
+func (r *sqliteSessionRepo) NewConversation(ownerSub string) (treeID, sessionID string) {
 	treeID = r.generateID("tree_")
 	sessionID = r.generateID("session_")
 
 	// 创建 tree
-	r.db.Exec("INSERT INTO session_trees (id) VALUES (?)", treeID)
+	r.db.Exec("INSERT INTO session_trees (id, owner_sub) VALUES (?, ?)", treeID, ownerSub)
 	// 创建首个 session
 	r.db.Exec("INSERT INTO sessions (id, tree_id) VALUES (?, ?)", sessionID, treeID)
 
@@ -198,17 +560,49 @@ func (r *sqliteSessionRepo) CreateBranchWithMessage(parentMsgID int64, msg *sche
 	return sessionID, msgID, nil
 }
 
-// SessionExists 检查 session 是否存在
-func (r *sqliteSessionRepo) SessionExists(sessionID string) bool {
-	var exists int
-	err := r.db.QueryRow("SELECT 1 FROM sessions WHERE id = ?", sessionID).Scan(&exists)
-	return err == nil
+// CreateBranchFrom 从指定消息创建空分支（时间漫游）：只记录 fork 锚点，
+// 不立即追加消息；历史从锚点回放，后续首条消息的 parent_id 指向锚点
+func (r *sqliteSessionRepo) CreateBranchFrom(parentMsgID int64, treeID string) (string, error) {
+	// 校验消息属于目标 tree，防止跨树挂接
+	var msgTreeID string
+	err := r.db.QueryRow(`
+		SELECT s.tree_id FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		WHERE m.id = ?
+	`, parentMsgID).Scan(&msgTreeID)
+	if err != nil || msgTreeID != treeID {
+		return "", fmt.Errorf("%w: %d", biz.ErrMessageNotFound, parentMsgID)
+	}
+
+	sessionID := r.generateID("session_")
+	if _, err := r.db.Exec(
+		"INSERT INTO sessions (id, tree_id, fork_anchor) VALUES (?, ?, ?)",
+		sessionID, treeID, parentMsgID,
+	); err != nil {
+		return "", fmt.Errorf("failed to create branch: %w", err)
+	}
+	return sessionID, nil
+}
+
+// SessionExists 检查 session 是否存在且归属 ownerSub
+func (r *sqliteSessionRepo) SessionExists(sessionID, ownerSub string) bool {
+	var exists int
+	err := r.db.QueryRow(`
+		SELECT 1 FROM sessions s
+		JOIN session_trees st ON s.tree_id = st.id
+		WHERE s.id = ? AND st.owner_sub = ?
+	`, sessionID, ownerSub).Scan(&exists)
+	return err == nil
 }
 
 // GetTreeID 获取 session 所属的 tree_id
-func (r *sqliteSessionRepo) GetTreeID(sessionID string) (string, error) {
+func (r *sqliteSessionRepo) GetTreeID(sessionID, ownerSub string) (string, error) {
 	var treeID string
-	err := r.db.QueryRow("SELECT tree_id FROM sessions WHERE id = ?", sessionID).Scan(&treeID)
+	err := r.db.QueryRow(`
+		SELECT s.tree_id FROM sessions s
+		JOIN session_trees st ON s.tree_id = st.id
+		WHERE s.id = ? AND st.owner_sub = ?
+	`, sessionID, ownerSub).Scan(&treeID)
 	if err != nil {
 		return "", fmt.Errorf("%w: %s", biz.ErrSessionNotFound, sessionID)
 	}
@@ -216,17 +610,18 @@ func (r *sqliteSessionRepo) GetTreeID(sessionID string) (string, error) {
 }
 
 // GetLastActiveSessionID 获取 tree 的最后活跃 session_id
-func (r *sqliteSessionRepo) GetLastActiveSessionID(treeID string) (string, error) {
+func (r *sqliteSessionRepo) GetLastActiveSessionID(treeID, ownerSub string) (string, error) {
 	var sessionID string
 	err := r.db.QueryRow(`
 		SELECT s.id
 		FROM sessions s
-		WHERE s.tree_id = ?
+		JOIN session_trees st ON s.tree_id = st.id
+		WHERE s.tree_id = ? AND st.owner_sub = ?
 		ORDER BY (
 			SELECT MAX(m.id) FROM messages m WHERE m.session_id = s.id
 		) DESC, s.id DESC
 		LIMIT 1
-	`, treeID).Scan(&sessionID)
+	`, treeID, ownerSub).Scan(&sessionID)
 	if err != nil {
 		return "", fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
 	}
@@ -234,15 +629,19 @@ func (r *sqliteSessionRepo) GetLastActiveSessionID(treeID string) (string, error
 }
 
 // GetSessionMessages 获取 session 的完整消息链
-func (r *sqliteSessionRepo) GetSessionMessages(sessionID string) biz.Session {
-	// 一次查询获取该 session 所属 tree 的所有消息
+func (r *sqliteSessionRepo) GetSessionMessages(sessionID, ownerSub string) biz.Session {
+	// 一次查询获取该 session 所属 tree 的所有消息（仅限 ownerSub 自己的 tree）
 	rows, err := r.db.Query(`
-		SELECT m.id, m.session_id, m.parent_id, m.model, m.message_data
+		SELECT m.id, m.session_id, m.parent_id, m.model, m.partial, m.message_data, m.created_at
 		FROM messages m
 		JOIN sessions s ON m.session_id = s.id
-		WHERE s.tree_id = (SELECT tree_id FROM sessions WHERE id = ?)
+		WHERE s.tree_id = (
+			SELECT s2.tree_id FROM sessions s2
+			JOIN session_trees st ON s2.tree_id = st.id
+			WHERE s2.id = ? AND st.owner_sub = ?
+		)
 		ORDER BY m.id
-	`, sessionID)
+	`, sessionID, ownerSub)
 	if err != nil {
 		return nil
 	}
@@ -254,14 +653,16 @@ func (r *sqliteSessionRepo) GetSessionMessages(sessionID string) biz.Session {
 		sessionID string
 		parentID  sql.NullInt64
 		model     sql.NullString
+		partial   bool
 		data      string
+		createdAt time.Time
 	}
 	msgMap := make(map[int64]*msgNode)
 	var currentSessionMsgs []*msgNode
 
 	for rows.Next() {
 		node := &msgNode{}
-		if err := rows.Scan(&node.id, &node.sessionID, &node.parentID, &node.model, &node.data); err != nil {
+		if err := rows.Scan(&node.id, &node.sessionID, &node.parentID, &node.model, &node.partial, &node.data, &node.createdAt); err != nil {
 			continue
 		}
 		msgMap[node.id] = node
@@ -270,15 +671,26 @@ func (r *sqliteSessionRepo) GetSessionMessages(sessionID string) biz.Session {
 		}
 	}
 
-	// 如果 session 没有消息，返回空
-	if len(currentSessionMsgs) == 0 {
-		return nil
+	// 回溯起点：当前 session 首条消息的 parent；没有消息时看 fork_anchor
+	// （时间漫游 fork 出的空分支从锚点回放历史，锚点本身也属于历史）
+	var startID int64
+	if len(currentSessionMsgs) > 0 {
+		if currentSessionMsgs[0].parentID.Valid {
+			startID = currentSessionMsgs[0].parentID.Int64
+		}
+	} else {
+		var anchor sql.NullInt64
+		_ = r.db.QueryRow("SELECT fork_anchor FROM sessions WHERE id = ?", sessionID).Scan(&anchor)
+		if !anchor.Valid {
+			return nil
+		}
+		startID = anchor.Int64
 	}
 
-	// 应用层回溯祖先：从第一条消息的 parent_id 沿 map 查找
+	// 应用层回溯祖先：沿 parent_id 在 map 中逐级查找
 	var ancestors []*biz.ChatResponse
-	if currentSessionMsgs[0].parentID.Valid {
-		parentID := currentSessionMsgs[0].parentID.Int64
+	if startID > 0 {
+		parentID := startID
 		for parentID > 0 {
 			node, ok := msgMap[parentID]
 			if !ok {
@@ -286,7 +698,7 @@ func (r *sqliteSessionRepo) GetSessionMessages(sessionID string) biz.Session {
 			}
 			var msg schema.Message
 			if json.Unmarshal([]byte(node.data), &msg) == nil {
-				ancestors = append([]*biz.ChatResponse{{Message: msg, Model: node.model.String}}, ancestors...)
+				ancestors = append([]*biz.ChatResponse{{Message: msg, ID: node.id, Model: node.model.String, Partial: node.partial, CreatedAt: node.createdAt}}, ancestors...)
 			}
 			if node.parentID.Valid {
 				parentID = node.parentID.Int64
@@ -301,7 +713,7 @@ func (r *sqliteSessionRepo) GetSessionMessages(sessionID string) biz.Session {
 	for _, node := range currentSessionMsgs {
 		var msg schema.Message
 		if json.Unmarshal([]byte(node.data), &msg) == nil {
-			currentMsgs = append(currentMsgs, &biz.ChatResponse{Message: msg, Model: node.model.String})
+			currentMsgs = append(currentMsgs, &biz.ChatResponse{Message: msg, ID: node.id, Model: node.model.String, Partial: node.partial, CreatedAt: node.createdAt})
 		}
 	}
 
@@ -309,16 +721,125 @@ func (r *sqliteSessionRepo) GetSessionMessages(sessionID string) biz.Session {
 	return append(ancestors, currentMsgs...)
 }
 
+// GetSessionMessagesPage 按线性链分页读取消息，避免整棵 tree 进内存。
+// 链 = 祖先段（root→各分支点）+ 当前 session 段；段内消息线性且 id 递增，
+// 跨段 id 同样递增（子消息必然晚于分支点插入），afterID 直接作为全链游标。
+// limit <= 0 表示不限制。归属不匹配与不存在同样返回 not found
+func (r *sqliteSessionRepo) GetSessionMessagesPage(sessionID, ownerSub string, afterID int64, limit int) ([]*biz.ChatResponse, error) {
+	var treeID string
+	err := r.db.QueryRow(`
+		SELECT s.tree_id FROM sessions s
+		JOIN session_trees st ON s.tree_id = st.id
+		WHERE s.id = ? AND st.owner_sub = ?
+	`, sessionID, ownerSub).Scan(&treeID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", biz.ErrSessionNotFound, sessionID)
+	}
+
+	// 从当前 session 逐级回溯分支点，得到 root→current 的链段；
+	// 每段是一个 session 内 id <= upTo 的线性前缀（upTo 0 表示整段）
+	type chainSegment struct {
+		sessionID string
+		upTo      int64
+	}
+	segments := []chainSegment{{sessionID: sessionID}}
+	visited := map[string]bool{sessionID: true}
+	cur := sessionID
+	for {
+		// 当前段首条消息的 parent 即上一段的分支点；空段看 fork_anchor
+		var branchPoint sql.NullInt64
+		err := r.db.QueryRow("SELECT parent_id FROM messages WHERE session_id = ? ORDER BY id LIMIT 1", cur).Scan(&branchPoint)
+		if err == sql.ErrNoRows {
+			err = r.db.QueryRow("SELECT fork_anchor FROM sessions WHERE id = ?", cur).Scan(&branchPoint)
+		}
+		if err != nil || !branchPoint.Valid || branchPoint.Int64 <= 0 {
+			break
+		}
+		var parentSession string
+		if err := r.db.QueryRow("SELECT session_id FROM messages WHERE id = ?", branchPoint.Int64).Scan(&parentSession); err != nil || visited[parentSession] {
+			break
+		}
+		visited[parentSession] = true
+		segments = append([]chainSegment{{sessionID: parentSession, upTo: branchPoint.Int64}}, segments...)
+		cur = parentSession
+	}
+
+	var page []*biz.ChatResponse
+	for _, seg := range segments {
+		if limit > 0 && len(page) >= limit {
+			break
+		}
+		query := "SELECT id, model, partial, message_data, created_at FROM messages WHERE session_id = ? AND id > ?"
+		args := []any{seg.sessionID, afterID}
+		if seg.upTo > 0 {
+			query += " AND id <= ?"
+			args = append(args, seg.upTo)
+		}
+		query += " ORDER BY id"
+		if limit > 0 {
+			query += " LIMIT ?"
+			args = append(args, limit-len(page))
+		}
+		rows, err := r.db.Query(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to page messages: %w", err)
+		}
+		for rows.Next() {
+			var (
+				id        int64
+				model     sql.NullString
+				partial   bool
+				data      string
+				createdAt time.Time
+			)
+			if err := rows.Scan(&id, &model, &partial, &data, &createdAt); err != nil {
+				continue
+			}
+			var msg schema.Message
+			if json.Unmarshal([]byte(data), &msg) == nil {
+				page = append(page, &biz.ChatResponse{Message: msg, ID: id, Model: model.String, Partial: partial, CreatedAt: createdAt})
+			}
+		}
+		rows.Close()
+	}
+	return page, nil
+}
+
+// CountTreeMessages 统计 session 所属 tree 的消息总数（含所有分支）
+func (r *sqliteSessionRepo) CountTreeMessages(sessionID string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		WHERE s.tree_id = (SELECT tree_id FROM sessions WHERE id = ?)
+	`, sessionID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tree messages: %w", err)
+	}
+	return count, nil
+}
+
+// sessionExists 内部存在性检查（不区分归属者）
+func (r *sqliteSessionRepo) sessionExists(sessionID string) bool {
+	var exists int
+	err := r.db.QueryRow("SELECT 1 FROM sessions WHERE id = ?", sessionID).Scan(&exists)
+	return err == nil
+}
+
 // AppendMessage 追加消息到 session
-func (r *sqliteSessionRepo) AppendMessage(sessionID string, msg *schema.Message, model string) (int64, error) {
+func (r *sqliteSessionRepo) AppendMessage(sessionID string, msg *schema.Message, model string, partial bool) (int64, error) {
 	// 检查 session 是否存在
-	if !r.SessionExists(sessionID) {
+	if !r.sessionExists(sessionID) {
 		return 0, fmt.Errorf("%w: %s", biz.ErrSessionNotFound, sessionID)
 	}
 
-	// 获取该 session 最后一条消息作为 parent
+	// 获取该 session 最后一条消息作为 parent；
+	// 空 session（时间漫游 fork 的新分支）挂到 fork 锚点下
 	var parentID sql.NullInt64
 	r.db.QueryRow("SELECT MAX(id) FROM messages WHERE session_id = ?", sessionID).Scan(&parentID)
+	if !parentID.Valid {
+		r.db.QueryRow("SELECT fork_anchor FROM sessions WHERE id = ?", sessionID).Scan(&parentID)
+	}
 
 	// 序列化消息
 	messageData, err := json.Marshal(msg)
@@ -330,13 +851,13 @@ func (r *sqliteSessionRepo) AppendMessage(sessionID string, msg *schema.Message,
 	var result sql.Result
 	if parentID.Valid {
 		result, err = r.db.Exec(
-			"INSERT INTO messages (session_id, parent_id, role, model, message_data) VALUES (?, ?, ?, ?, ?)",
-			sessionID, parentID.Int64, string(msg.Role), model, string(messageData),
+			"INSERT INTO messages (session_id, parent_id, role, model, partial, message_data) VALUES (?, ?, ?, ?, ?, ?)",
+			sessionID, parentID.Int64, string(msg.Role), model, partial, string(messageData),
 		)
 	} else {
 		result, err = r.db.Exec(
-			"INSERT INTO messages (session_id, role, model, message_data) VALUES (?, ?, ?, ?)",
-			sessionID, string(msg.Role), model, string(messageData),
+			"INSERT INTO messages (session_id, role, model, partial, message_data) VALUES (?, ?, ?, ?, ?)",
+			sessionID, string(msg.Role), model, partial, string(messageData),
 		)
 	}
 	if err != nil {
@@ -351,6 +872,170 @@ func (r *sqliteSessionRepo) AppendMessage(sessionID string, msg *schema.Message,
 	return newMsgID, nil
 }
 
+// GetLastMessage 获取 session 中最后一条消息及其 ID
+func (r *sqliteSessionRepo) GetLastMessage(sessionID, ownerSub string) (int64, *biz.ChatResponse, error) {
+	var (
+		msgID     int64
+		model     sql.NullString
+		partial   bool
+		data      string
+		createdAt time.Time
+	)
+	err := r.db.QueryRow(`
+		SELECT m.id, m.model, m.partial, m.message_data, m.created_at FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		JOIN session_trees st ON s.tree_id = st.id
+		WHERE m.session_id = ? AND st.owner_sub = ?
+		ORDER BY m.id DESC LIMIT 1
+	`, sessionID, ownerSub).Scan(&msgID, &model, &partial, &data, &createdAt)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %s", biz.ErrMessageNotFound, sessionID)
+	}
+
+	var msg schema.Message
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return 0, nil, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+	return msgID, &biz.ChatResponse{Message: msg, ID: msgID, Model: model.String, Partial: partial, CreatedAt: createdAt}, nil
+}
+
+// GetMessage 按 ID 获取单条消息；通过所属 tree 的 owner_sub 校验归属，
+// 归属不匹配与不存在同样返回 ErrMessageNotFound
+func (r *sqliteSessionRepo) GetMessage(msgID int64, ownerSub string) (*biz.ChatResponse, error) {
+	var (
+		model     sql.NullString
+		partial   bool
+		data      string
+		createdAt time.Time
+	)
+	err := r.db.QueryRow(`
+		SELECT m.model, m.partial, m.message_data, m.created_at FROM messages m
+		JOIN sessions s ON m.session_id = s.id
+		JOIN session_trees st ON s.tree_id = st.id
+		WHERE m.id = ? AND st.owner_sub = ?
+	`, msgID, ownerSub).Scan(&model, &partial, &data, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %d", biz.ErrMessageNotFound, msgID)
+	}
+
+	var msg schema.Message
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+	return &biz.ChatResponse{Message: msg, ID: msgID, Model: model.String, Partial: partial, CreatedAt: createdAt}, nil
+}
+
+// UpdateMessage 原地覆盖已存储的消息内容
+func (r *sqliteSessionRepo) UpdateMessage(msgID int64, msg *schema.Message) error {
+	messageData, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	result, err := r.db.Exec(
+		"UPDATE messages SET role = ?, message_data = ? WHERE id = ?",
+		string(msg.Role), string(messageData), msgID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update message: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %d", biz.ErrMessageNotFound, msgID)
+	}
+	return nil
+}
+
+// UpdateStreamedMessage 覆盖流式增量落库的消息内容，并同步更新 model 与
+// partial 标记（生成中为 partial，流正常结束时清除）。
+func (r *sqliteSessionRepo) UpdateStreamedMessage(msgID int64, msg *schema.Message, model string, partial bool) error {
+	messageData, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	result, err := r.db.Exec(
+		"UPDATE messages SET role = ?, model = ?, partial = ?, message_data = ? WHERE id = ?",
+		string(msg.Role), model, partial, string(messageData), msgID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update streamed message: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %d", biz.ErrMessageNotFound, msgID)
+	}
+	return nil
+}
+
+// DeleteMessage 删除消息及其所有后代。
+// 后代通过 parent_id 跨 session 引用（分支点的子消息可能在别的 session），
+// 不级联删除会留下孤链破坏回溯，所以整个子树在一个事务里删除。
+func (r *sqliteSessionRepo) DeleteMessage(msgID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 统计每个 session 被删除的消息数，用于回调 message_count
+	rows, err := tx.Query(`
+		WITH RECURSIVE descendants(id) AS (
+			SELECT id FROM messages WHERE id = ?
+			UNION ALL
+			SELECT m.id FROM messages m JOIN descendants d ON m.parent_id = d.id
+		)
+		SELECT session_id, COUNT(*)
+		FROM messages
+		WHERE id IN (SELECT id FROM descendants)
+		GROUP BY session_id
+	`, msgID)
+	if err != nil {
+		return fmt.Errorf("failed to collect descendants: %w", err)
+	}
+
+	deletedPerSession := make(map[string]int)
+	total := 0
+	for rows.Next() {
+		var sessionID string
+		var count int
+		if err := rows.Scan(&sessionID, &count); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan descendant count: %w", err)
+		}
+		deletedPerSession[sessionID] = count
+		total += count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate descendant counts: %w", err)
+	}
+	if total == 0 {
+		return fmt.Errorf("%w: %d", biz.ErrMessageNotFound, msgID)
+	}
+
+	_, err = tx.Exec(`
+		WITH RECURSIVE descendants(id) AS (
+			SELECT id FROM messages WHERE id = ?
+			UNION ALL
+			SELECT m.id FROM messages m JOIN descendants d ON m.parent_id = d.id
+		)
+		DELETE FROM messages WHERE id IN (SELECT id FROM descendants)
+	`, msgID)
+	if err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+
+	for sessionID, count := range deletedPerSession {
+		if _, err := tx.Exec(
+			"UPDATE sessions SET message_count = MAX(message_count - ?, 0) WHERE id = ?",
+			count, sessionID,
+		); err != nil {
+			return fmt.Errorf("failed to update message count: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
 // updateMetadataAfterAppend 追加消息后更新元数据
 func (r *sqliteSessionRepo) updateMetadataAfterAppend(sessionID string, msg *schema.Message) {
 	// 获取 tree_id
@@ -366,33 +1051,266 @@ func (r *sqliteSessionRepo) updateMetadataAfterAppend(sessionID string, msg *sch
 
 	// 如果 title 为空且是用户消息，设置 title
 	if (!currentTitle.Valid || currentTitle.String == "") && msg.Role == schema.User && msg.Content != "" {
-		runes := []rune(msg.Content)
-		var newTitle string
-		if len(runes) > 15 {
-			newTitle = string(runes[:15]) + "..."
-		} else {
-			newTitle = msg.Content
+		if newTitle := autoTitleFromContent(msg.Content, r.autoTitleMaxLen); newTitle != "" {
+			r.db.Exec("UPDATE session_trees SET title = ? WHERE id = ?", newTitle, treeID)
 		}
-		r.db.Exec("UPDATE session_trees SET title = ? WHERE id = ?", newTitle, treeID)
 	}
 
 	// 更新 updated_at（message_count 和其他字段通过 ListTrees 联合查询获取）
 	r.db.Exec("UPDATE session_trees SET updated_at = CURRENT_TIMESTAMP WHERE id = ?", treeID)
 }
 
-// DeleteTree 删除整个对话树
-func (r *sqliteSessionRepo) DeleteTree(treeID string) {
-	// CASCADE 会自动删除关联的 sessions 和 messages
-	r.db.Exec("DELETE FROM session_trees WHERE id = ?", treeID)
+// SetAutoTitleMaxLen 设置自动标题最大长度（按 rune 计），n <= 0 时恢复默认
+func (r *sqliteSessionRepo) SetAutoTitleMaxLen(n int) {
+	if n <= 0 {
+		n = defaultAutoTitleMaxLen
+	}
+	r.autoTitleMaxLen = n
+}
+
+// autoTitleFromContent 从首条用户消息生成自动标题：压掉换行和多余空白，
+// 超长时优先在词/句边界截断（边界太靠前则硬切，CJK 无空格文本走硬切），
+// 并追加省略号
+func autoTitleFromContent(content string, maxLen int) string {
+	if maxLen <= 0 {
+		maxLen = defaultAutoTitleMaxLen
+	}
+	collapsed := strings.Join(strings.Fields(content), " ")
+	runes := []rune(collapsed)
+	if len(runes) <= maxLen {
+		return collapsed
+	}
+	// 在后半段找最近的边界截断；找不到就在 maxLen 处硬切
+	cut := maxLen
+	for i := maxLen - 1; i >= maxLen/2; i-- {
+		if isTitleBoundary(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRight(string(runes[:cut]), " ") + "..."
+}
+
+// isTitleBoundary 判断是否适合作为标题截断点（空白或中英文句读）
+func isTitleBoundary(r rune) bool {
+	switch r {
+	case '.', ',', ';', ':', '!', '?', '。', '，', '；', '：', '！', '？', '、':
+		return true
+	}
+	return unicode.IsSpace(r)
+}
+
+// DeleteTree 软删除对话树（移入回收站），数据保留直到恢复或清除
+func (r *sqliteSessionRepo) DeleteTree(treeID, ownerSub string) error {
+	result, err := r.db.Exec(
+		"UPDATE session_trees SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND owner_sub = ? AND deleted_at IS NULL",
+		treeID, ownerSub,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete tree: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+	return nil
 }
 
-// ListTrees 列出所有对话树（通过联合查询获取最新消息信息）
-func (r *sqliteSessionRepo) ListTrees() ([]biz.SessionTreeInfo, error) {
+// SetTreeTitle 手动设置对话树标题；手动标题不会被自动生成覆盖
+func (r *sqliteSessionRepo) SetTreeTitle(treeID, ownerSub, title string) error {
+	result, err := r.db.Exec(
+		"UPDATE session_trees SET title = ?, title_source = 'manual' WHERE id = ? AND owner_sub = ? AND deleted_at IS NULL",
+		title, treeID, ownerSub,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set tree title: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+	return nil
+}
+
+// SetGeneratedTitle 写入自动生成的标题；仅当现有标题仍是首条消息截断产物
+// （title_source = auto）时生效，返回是否更新成功
+func (r *sqliteSessionRepo) SetGeneratedTitle(treeID, ownerSub, title string) (bool, error) {
+	result, err := r.db.Exec(
+		"UPDATE session_trees SET title = ?, title_source = 'generated' WHERE id = ? AND owner_sub = ? AND deleted_at IS NULL AND title_source = 'auto'",
+		title, treeID, ownerSub,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to set generated title: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
+
+// DeleteTrees 在单个事务中批量软删除对话树，返回每个 ID 的结果（nil 表示已删除）
+func (r *sqliteSessionRepo) DeleteTrees(treeIDs []string, ownerSub string) (map[string]error, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	results := make(map[string]error, len(treeIDs))
+	for _, treeID := range treeIDs {
+		result, err := tx.Exec(
+			"UPDATE session_trees SET deleted_at = CURRENT_TIMESTAMP WHERE id = ? AND owner_sub = ? AND deleted_at IS NULL",
+			treeID, ownerSub,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete tree %s: %w", treeID, err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			results[treeID] = fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+		} else {
+			results[treeID] = nil
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit bulk delete: %w", err)
+	}
+	return results, nil
+}
+
+// SetPinned 置顶/取消置顶对话树
+func (r *sqliteSessionRepo) SetPinned(treeID, ownerSub string, pinned bool) error {
+	result, err := r.db.Exec(
+		"UPDATE session_trees SET pinned = ? WHERE id = ? AND owner_sub = ? AND deleted_at IS NULL",
+		pinned, treeID, ownerSub,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set pinned: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+	return nil
+}
+
+// SetTreeMetadata 设置对话树的自定义元数据（后端不解释内容）；
+// meta 为空时清空为 NULL
+func (r *sqliteSessionRepo) SetTreeMetadata(treeID, ownerSub string, meta json.RawMessage) error {
+	var value any
+	if len(meta) > 0 {
+		value = string(meta)
+	}
+	result, err := r.db.Exec(
+		"UPDATE session_trees SET metadata = ? WHERE id = ? AND owner_sub = ? AND deleted_at IS NULL",
+		value, treeID, ownerSub,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set tree metadata: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+	return nil
+}
+
+// SetTreeSystemPrompt 设置对话树级自定义指令；空串清空
+func (r *sqliteSessionRepo) SetTreeSystemPrompt(treeID, ownerSub, prompt string) error {
+	var value any
+	if prompt != "" {
+		value = prompt
+	}
+	result, err := r.db.Exec(
+		"UPDATE session_trees SET system_prompt = ? WHERE id = ? AND owner_sub = ? AND deleted_at IS NULL",
+		value, treeID, ownerSub,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set tree system prompt: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+	return nil
+}
+
+// GetTreeSystemPrompt 读取对话树级自定义指令，未设置时返回空串
+func (r *sqliteSessionRepo) GetTreeSystemPrompt(treeID, ownerSub string) (string, error) {
+	var prompt sql.NullString
+	err := r.db.QueryRow(
+		"SELECT system_prompt FROM session_trees WHERE id = ? AND owner_sub = ? AND deleted_at IS NULL",
+		treeID, ownerSub,
+	).Scan(&prompt)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+	return prompt.String, nil
+}
+
+// RestoreTree 从回收站恢复对话树
+func (r *sqliteSessionRepo) RestoreTree(treeID, ownerSub string) error {
+	result, err := r.db.Exec(
+		"UPDATE session_trees SET deleted_at = NULL WHERE id = ? AND owner_sub = ? AND deleted_at IS NOT NULL",
+		treeID, ownerSub,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to restore tree: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+	return nil
+}
+
+// PurgeTree 硬删除整个对话树（CASCADE 会自动删除关联的 sessions 和 messages）
+func (r *sqliteSessionRepo) PurgeTree(treeID string) error {
+	result, err := r.db.Exec("DELETE FROM session_trees WHERE id = ?", treeID)
+	if err != nil {
+		return fmt.Errorf("failed to purge tree: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+	return nil
+}
+
+// PurgeTreesDeletedBefore 硬删除在 cutoff 之前被软删除的对话树，返回清除数量
+func (r *sqliteSessionRepo) PurgeTreesDeletedBefore(cutoff time.Time) (int, error) {
+	result, err := r.db.Exec(
+		"DELETE FROM session_trees WHERE deleted_at IS NOT NULL AND deleted_at < ?",
+		cutoff.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired trees: %w", err)
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// ListTrees 列出 ownerSub 的所有未删除对话树（通过联合查询获取最新消息信息）
+func (r *sqliteSessionRepo) ListTrees(ownerSub string) ([]biz.SessionTreeInfo, error) {
+	return r.listTrees(false, ownerSub, "", "")
+}
+
+// ListTreesByMetadata 列出 ownerSub 未删除且 metadata 顶层 key 等于 value 的对话树
+func (r *sqliteSessionRepo) ListTreesByMetadata(ownerSub, key, value string) ([]biz.SessionTreeInfo, error) {
+	return r.listTrees(false, ownerSub, key, value)
+}
+
+// ListTrashTrees 列出 ownerSub 回收站中的对话树
+func (r *sqliteSessionRepo) ListTrashTrees(ownerSub string) ([]biz.SessionTreeInfo, error) {
+	return r.listTrees(true, ownerSub, "", "")
+}
+
+func (r *sqliteSessionRepo) listTrees(trash bool, ownerSub, metaKey, metaValue string) ([]biz.SessionTreeInfo, error) {
+	filter := "st.deleted_at IS NULL"
+	if trash {
+		filter = "st.deleted_at IS NOT NULL"
+	}
+	args := []any{ownerSub}
+	if metaKey != "" {
+		// 按 metadata 顶层 key 过滤（json_extract 对非 JSON 值返回 NULL，自然排除）
+		filter += " AND json_extract(st.metadata, '$.' || ?) = ?"
+		args = append(args, metaKey, metaValue)
+	}
 	rows, err := r.db.Query(`
 		SELECT
-			st.id, st.title, st.created_at, st.updated_at,
+			st.id, st.title, st.pinned, st.metadata, st.created_at, st.updated_at, st.deleted_at,
 			latest.session_id AS last_active_session_id,
-			json_extract(latest.message_data, '$.content') AS last_message_content
+			latest.message_data AS last_message_data
 		FROM session_trees st
 		LEFT JOIN (
 			SELECT s.tree_id, m.session_id, m.message_data
@@ -405,8 +1323,9 @@ func (r *sqliteSessionRepo) ListTrees() ([]biz.SessionTreeInfo, error) {
 				GROUP BY s2.tree_id
 			)
 		) latest ON st.id = latest.tree_id
-		ORDER BY st.updated_at DESC
-	`)
+		WHERE st.owner_sub = ? AND ` + filter + `
+		ORDER BY st.pinned DESC, st.updated_at DESC
+	`, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query session trees: %w", err)
 	}
@@ -415,27 +1334,141 @@ func (r *sqliteSessionRepo) ListTrees() ([]biz.SessionTreeInfo, error) {
 	var trees []biz.SessionTreeInfo
 	for rows.Next() {
 		var id string
-		var title, lastActiveSessionID, lastMsgContent sql.NullString
+		var title, metadata, lastActiveSessionID, lastMsgData sql.NullString
+		var pinned bool
 		var createdAt, updatedAt time.Time
+		var deletedAt sql.NullTime
 
-		if err := rows.Scan(&id, &title, &createdAt, &updatedAt,
-			&lastActiveSessionID, &lastMsgContent); err != nil {
+		if err := rows.Scan(&id, &title, &pinned, &metadata, &createdAt, &updatedAt, &deletedAt,
+			&lastActiveSessionID, &lastMsgData); err != nil {
 			return nil, fmt.Errorf("failed to scan session tree: %w", err)
 		}
 
-		trees = append(trees, biz.SessionTreeInfo{
+		info := biz.SessionTreeInfo{
 			ID:                  id,
 			Title:               title.String,
 			LastActiveSessionID: lastActiveSessionID.String,
-			LastMessage:         lastMsgContent.String,
+			LastMessage:         lastMessagePreview(lastMsgData.String),
+			Pinned:              pinned,
 			CreatedAt:           createdAt,
 			UpdatedAt:           updatedAt,
-		})
+			DeletedAt:           deletedAt.Time,
+		}
+		if metadata.Valid {
+			info.Metadata = json.RawMessage(metadata.String)
+		}
+		trees = append(trees, info)
 	}
 
 	return trees, nil
 }
 
+// lastMessagePreview 从末条消息算列表预览：文本内容优先；多模态消息取首个
+// 非空文本分片，纯媒体消息按类型给占位符（与降级提示词一致）；工具消息
+// 标记工具名而不是展示原始输出
+func lastMessagePreview(data string) string {
+	if data == "" {
+		return ""
+	}
+	var msg schema.Message
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return ""
+	}
+	if msg.Role == schema.Tool {
+		if msg.ToolName != "" {
+			return "[Tool: " + msg.ToolName + "]"
+		}
+		return "[Tool Result]"
+	}
+	if msg.Content != "" {
+		return msg.Content
+	}
+
+	var placeholder string
+	notePart := func(t schema.ChatMessagePartType) {
+		if placeholder != "" {
+			return
+		}
+		switch t {
+		case schema.ChatMessagePartTypeImageURL:
+			placeholder = "[Image]"
+		case schema.ChatMessagePartTypeAudioURL:
+			placeholder = "[Audio]"
+		case schema.ChatMessagePartTypeVideoURL:
+			placeholder = "[Video]"
+		case schema.ChatMessagePartTypeFileURL:
+			placeholder = "[File]"
+		}
+	}
+	for _, part := range msg.UserInputMultiContent {
+		if part.Type == schema.ChatMessagePartTypeText && part.Text != "" {
+			return part.Text
+		}
+		notePart(part.Type)
+	}
+	for _, part := range msg.AssistantGenMultiContent {
+		if part.Type == schema.ChatMessagePartTypeText && part.Text != "" {
+			return part.Text
+		}
+		notePart(part.Type)
+	}
+	for _, part := range msg.MultiContent {
+		if part.Type == schema.ChatMessagePartTypeText && part.Text != "" {
+			return part.Text
+		}
+		notePart(part.Type)
+	}
+	return placeholder
+}
+
+// ListSessionsInTree 列出 tree 内的所有分支 session（根在前，按创建顺序）
+func (r *sqliteSessionRepo) ListSessionsInTree(treeID, ownerSub string) ([]biz.SessionBranchInfo, error) {
+	var exists int
+	if err := r.db.QueryRow(
+		"SELECT 1 FROM session_trees WHERE id = ? AND owner_sub = ?",
+		treeID, ownerSub,
+	).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("%w: %s", biz.ErrTreeNotFound, treeID)
+	}
+
+	// message_count 列是尽力维护的冗余值，这里按 messages 表实时统计；
+	// 分支点优先取首条消息的 parent，空分支（时间漫游）退回 fork_anchor
+	rows, err := r.db.Query(`
+		SELECT s.id, s.created_at,
+			(SELECT COUNT(*) FROM messages m WHERE m.session_id = s.id) AS message_count,
+			COALESCE(
+				(SELECT m.parent_id FROM messages m WHERE m.session_id = s.id ORDER BY m.id LIMIT 1),
+				s.fork_anchor
+			) AS branch_point
+		FROM sessions s
+		WHERE s.tree_id = ?
+		ORDER BY s.rowid
+	`, treeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tree sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var branches []biz.SessionBranchInfo
+	for rows.Next() {
+		var id string
+		var createdAt time.Time
+		var msgCount int
+		var branchPoint sql.NullInt64
+		if err := rows.Scan(&id, &createdAt, &msgCount, &branchPoint); err != nil {
+			return nil, fmt.Errorf("failed to scan tree session: %w", err)
+		}
+		branches = append(branches, biz.SessionBranchInfo{
+			ID:           id,
+			MessageCount: msgCount,
+			ForkAnchor:   branchPoint.Int64,
+			CreatedAt:    createdAt,
+		})
+	}
+
+	return branches, nil
+}
+
 // Close 关闭数据库连接
 func (r *sqliteSessionRepo) Close() error {
 	return r.db.Close()
@@ -0,0 +1,126 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// pageAll 用给定页大小把整条链翻完
+func pageAll(t *testing.T, repo biz.SessionRepo, sessionID string, pageSize int) []*biz.ChatResponse {
+	t.Helper()
+	var all []*biz.ChatResponse
+	var after int64
+	for {
+		page, err := repo.GetSessionMessagesPage(sessionID, biz.AnonymousOwner, after, pageSize)
+		if err != nil {
+			t.Fatalf("GetSessionMessagesPage returned error: %v", err)
+		}
+		if len(page) == 0 {
+			return all
+		}
+		all = append(all, page...)
+		after = page[len(page)-1].ID
+	}
+}
+
+func assertSameChain(t *testing.T, full biz.Session, paged []*biz.ChatResponse) {
+	t.Helper()
+	if len(paged) != len(full) {
+		t.Fatalf("paged chain has %d messages, full load has %d", len(paged), len(full))
+	}
+	for i := range full {
+		if paged[i].ID != full[i].ID || paged[i].Content != full[i].Content {
+			t.Errorf("message %d mismatch: paged (%d, %q) vs full (%d, %q)",
+				i, paged[i].ID, paged[i].Content, full[i].ID, full[i].Content)
+		}
+	}
+}
+
+// TestGetSessionMessagesPage_MatchesFullLoad 分页拼接结果与整链加载一致
+func TestGetSessionMessagesPage_MatchesFullLoad(t *testing.T) {
+	repo := newTestRepo(t)
+	_, sessionID := repo.NewConversation(biz.AnonymousOwner)
+	for i := 0; i < 7; i++ {
+		appendTestMessage(t, repo, sessionID, "user", fmt.Sprintf("q%d", i))
+		appendTestMessage(t, repo, sessionID, "assistant", fmt.Sprintf("a%d", i))
+	}
+
+	full := repo.GetSessionMessages(sessionID, biz.AnonymousOwner)
+	for _, pageSize := range []int{1, 3, 5, 100} {
+		assertSameChain(t, full, pageAll(t, repo, sessionID, pageSize))
+	}
+}
+
+// TestGetSessionMessagesPage_AcrossBranchPoints 分支 session 的链包含祖先段，
+// 分页读取跨分支点仍与整链加载一致
+func TestGetSessionMessagesPage_AcrossBranchPoints(t *testing.T) {
+	repo := newTestRepo(t)
+	_, rootSession := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, rootSession, "user", "q1")
+	branchPoint := appendTestMessage(t, repo, rootSession, "assistant", "a1")
+	appendTestMessage(t, repo, rootSession, "user", "q2-main")
+
+	// 从 a1 分叉出一条新分支，再从分支内再次分叉（两级分支点）
+	branch1, _, err := repo.CreateBranchWithMessage(branchPoint, &schema.Message{Role: schema.User, Content: "q2-branch"})
+	if err != nil {
+		t.Fatalf("CreateBranchWithMessage returned error: %v", err)
+	}
+	branchPoint2 := appendTestMessage(t, repo, branch1, "assistant", "a2-branch")
+	appendTestMessage(t, repo, branch1, "user", "q3-branch")
+	branch2, _, err := repo.CreateBranchWithMessage(branchPoint2, &schema.Message{Role: schema.User, Content: "q3-alt"})
+	if err != nil {
+		t.Fatalf("CreateBranchWithMessage returned error: %v", err)
+	}
+	appendTestMessage(t, repo, branch2, "assistant", "a3-alt")
+
+	for _, sessionID := range []string{rootSession, branch1, branch2} {
+		full := repo.GetSessionMessages(sessionID, biz.AnonymousOwner)
+		if len(full) == 0 {
+			t.Fatalf("full load of %s is empty", sessionID)
+		}
+		for _, pageSize := range []int{1, 2, 100} {
+			assertSameChain(t, full, pageAll(t, repo, sessionID, pageSize))
+		}
+	}
+
+	// 主干 session 不应看到分支消息
+	for _, m := range pageAll(t, repo, rootSession, 2) {
+		if m.Content == "q2-branch" || m.Content == "q3-alt" {
+			t.Errorf("main chain leaked branch message %q", m.Content)
+		}
+	}
+}
+
+// TestGetSessionMessagesPage_EmptyFork 时间漫游 fork 出的空分支从锚点回放历史
+func TestGetSessionMessagesPage_EmptyFork(t *testing.T) {
+	repo := newTestRepo(t)
+	treeID, rootSession := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, rootSession, "user", "q1")
+	anchor := appendTestMessage(t, repo, rootSession, "assistant", "a1")
+	appendTestMessage(t, repo, rootSession, "user", "q2")
+
+	forked, err := repo.CreateBranchFrom(anchor, treeID)
+	if err != nil {
+		t.Fatalf("CreateBranchFrom returned error: %v", err)
+	}
+
+	full := repo.GetSessionMessages(forked, biz.AnonymousOwner)
+	assertSameChain(t, full, pageAll(t, repo, forked, 1))
+}
+
+// TestGetSessionMessagesPage_NotFound 他人/不存在的 session 返回 not found
+func TestGetSessionMessagesPage_NotFound(t *testing.T) {
+	repo := newTestRepo(t)
+	_, sessionID := repo.NewConversation("owner-a")
+
+	if _, err := repo.GetSessionMessagesPage(sessionID, "owner-b", 0, 10); err == nil {
+		t.Error("expected not-found error for another owner's session")
+	}
+	if _, err := repo.GetSessionMessagesPage("missing", biz.AnonymousOwner, 0, 10); err == nil {
+		t.Error("expected not-found error for unknown session")
+	}
+}
@@ -0,0 +1,637 @@
+package data
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func newTestRepo(t *testing.T) biz.SessionRepo {
+	t.Helper()
+	repo, err := NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "sessions.db"))
+	if err != nil {
+		t.Fatalf("failed to create repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+func appendTestMessage(t *testing.T, repo biz.SessionRepo, sessionID, role, content string) int64 {
+	t.Helper()
+	msgID, err := repo.AppendMessage(sessionID, &schema.Message{Role: schema.RoleType(role), Content: content}, "", false)
+	if err != nil {
+		t.Fatalf("failed to append message: %v", err)
+	}
+	return msgID
+}
+
+func TestDeleteMessage_Linear(t *testing.T) {
+	repo := newTestRepo(t)
+	_, sessionID := repo.NewConversation(biz.AnonymousOwner)
+
+	appendTestMessage(t, repo, sessionID, "user", "q1")
+	appendTestMessage(t, repo, sessionID, "assistant", "a1")
+	midID := appendTestMessage(t, repo, sessionID, "user", "q2")
+	appendTestMessage(t, repo, sessionID, "assistant", "a2")
+
+	if err := repo.DeleteMessage(midID); err != nil {
+		t.Fatalf("DeleteMessage returned error: %v", err)
+	}
+
+	session := repo.GetSessionMessages(sessionID, biz.AnonymousOwner)
+	if len(session) != 2 {
+		t.Fatalf("expected 2 remaining messages, got %d", len(session))
+	}
+	if session[0].Content != "q1" || session[1].Content != "a1" {
+		t.Fatalf("unexpected remaining messages: %q, %q", session[0].Content, session[1].Content)
+	}
+}
+
+func TestDeleteMessage_BranchedDescendants(t *testing.T) {
+	repo := newTestRepo(t)
+	_, sessionID := repo.NewConversation(biz.AnonymousOwner)
+
+	appendTestMessage(t, repo, sessionID, "user", "q1")
+	branchPointID := appendTestMessage(t, repo, sessionID, "assistant", "a1")
+	appendTestMessage(t, repo, sessionID, "user", "q2")
+
+	// 从 a1 创建分支：分支首条消息的 parent 指向分支点
+	branchSessionID, _, err := repo.CreateBranchWithMessage(branchPointID, &schema.Message{Role: schema.User, Content: "q2-branch"})
+	if err != nil {
+		t.Fatalf("CreateBranchWithMessage returned error: %v", err)
+	}
+
+	// 删除分支点：两个 session 中它之后的消息链都应被删除
+	if err := repo.DeleteMessage(branchPointID); err != nil {
+		t.Fatalf("DeleteMessage returned error: %v", err)
+	}
+
+	session := repo.GetSessionMessages(sessionID, biz.AnonymousOwner)
+	if len(session) != 1 || session[0].Content != "q1" {
+		t.Fatalf("expected only q1 to remain in original session, got %d messages", len(session))
+	}
+	if branch := repo.GetSessionMessages(branchSessionID, biz.AnonymousOwner); branch != nil {
+		t.Fatalf("expected branch session to be empty, got %d messages", len(branch))
+	}
+}
+
+func TestDeleteMessage_NotFound(t *testing.T) {
+	repo := newTestRepo(t)
+	if err := repo.DeleteMessage(12345); !errors.Is(err, biz.ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound, got: %v", err)
+	}
+}
+
+func TestDeleteTree_SoftDeleteAndRestore(t *testing.T) {
+	repo := newTestRepo(t)
+	treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, sessionID, "user", "q1")
+
+	if err := repo.DeleteTree(treeID, biz.AnonymousOwner); err != nil {
+		t.Fatalf("DeleteTree returned error: %v", err)
+	}
+
+	// 软删除后不再出现在正常列表，但出现在回收站
+	trees, err := repo.ListTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrees returned error: %v", err)
+	}
+	if len(trees) != 0 {
+		t.Fatalf("expected no trees after soft delete, got %d", len(trees))
+	}
+	trash, err := repo.ListTrashTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrashTrees returned error: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != treeID {
+		t.Fatalf("expected tree %s in trash, got %+v", treeID, trash)
+	}
+	if trash[0].DeletedAt.IsZero() {
+		t.Fatalf("expected deleted_at to be set in trash listing")
+	}
+
+	// 恢复后消息完好
+	if err := repo.RestoreTree(treeID, biz.AnonymousOwner); err != nil {
+		t.Fatalf("RestoreTree returned error: %v", err)
+	}
+	trees, err = repo.ListTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrees returned error: %v", err)
+	}
+	if len(trees) != 1 || trees[0].ID != treeID {
+		t.Fatalf("expected tree %s after restore, got %+v", treeID, trees)
+	}
+	if session := repo.GetSessionMessages(sessionID, biz.AnonymousOwner); len(session) != 1 {
+		t.Fatalf("expected 1 message after restore, got %d", len(session))
+	}
+
+	// 再次恢复应报未找到
+	if err := repo.RestoreTree(treeID, biz.AnonymousOwner); !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound on double restore, got: %v", err)
+	}
+}
+
+func TestDeleteTrees_MixedResults(t *testing.T) {
+	repo := newTestRepo(t)
+	treeA, sessionA := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, sessionA, "user", "a")
+	treeB, sessionB := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, sessionB, "user", "b")
+
+	results, err := repo.DeleteTrees([]string{treeA, treeB, "missing"}, biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("DeleteTrees returned error: %v", err)
+	}
+	if results[treeA] != nil || results[treeB] != nil {
+		t.Fatalf("expected both existing trees deleted, got %+v", results)
+	}
+	if !errors.Is(results["missing"], biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound for missing id, got: %v", results["missing"])
+	}
+
+	trees, err := repo.ListTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrees returned error: %v", err)
+	}
+	if len(trees) != 0 {
+		t.Fatalf("expected no trees after bulk delete, got %d", len(trees))
+	}
+	trash, err := repo.ListTrashTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrashTrees returned error: %v", err)
+	}
+	if len(trash) != 2 {
+		t.Fatalf("expected 2 trees in trash, got %d", len(trash))
+	}
+}
+
+func TestSetPinned_PinnedTreesSortFirst(t *testing.T) {
+	repo := newTestRepo(t)
+	oldTreeID, oldSessionID := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, oldSessionID, "user", "old")
+	newTreeID, newSessionID := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, newSessionID, "user", "new")
+
+	// 让未置顶的树拥有更新的活跃时间
+	db := repo.(*sqliteSessionRepo).db
+	if _, err := db.Exec("UPDATE session_trees SET updated_at = ? WHERE id = ?",
+		time.Now().Add(-time.Hour), oldTreeID); err != nil {
+		t.Fatalf("failed to backdate tree: %v", err)
+	}
+	if _, err := db.Exec("UPDATE session_trees SET updated_at = ? WHERE id = ?",
+		time.Now(), newTreeID); err != nil {
+		t.Fatalf("failed to touch tree: %v", err)
+	}
+
+	if err := repo.SetPinned(oldTreeID, biz.AnonymousOwner, true); err != nil {
+		t.Fatalf("SetPinned returned error: %v", err)
+	}
+
+	trees, err := repo.ListTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrees returned error: %v", err)
+	}
+	if len(trees) != 2 {
+		t.Fatalf("expected 2 trees, got %d", len(trees))
+	}
+	if trees[0].ID != oldTreeID || !trees[0].Pinned {
+		t.Fatalf("pinned tree must sort first regardless of update time, got %+v", trees)
+	}
+
+	// 取消置顶后恢复按活跃时间排序
+	if err := repo.SetPinned(oldTreeID, biz.AnonymousOwner, false); err != nil {
+		t.Fatalf("unpin returned error: %v", err)
+	}
+	trees, err = repo.ListTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrees returned error: %v", err)
+	}
+	if trees[0].ID != newTreeID {
+		t.Fatalf("expected recency order after unpin, got %+v", trees)
+	}
+
+	// 不存在或归属不符的树报未找到
+	if err := repo.SetPinned("missing", biz.AnonymousOwner, true); !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound, got: %v", err)
+	}
+}
+
+func TestDeleteTree_Purge(t *testing.T) {
+	repo := newTestRepo(t)
+	treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, sessionID, "user", "q1")
+
+	if err := repo.DeleteTree(treeID, biz.AnonymousOwner); err != nil {
+		t.Fatalf("DeleteTree returned error: %v", err)
+	}
+	if err := repo.PurgeTree(treeID); err != nil {
+		t.Fatalf("PurgeTree returned error: %v", err)
+	}
+
+	// 硬删除级联移除 sessions 和 messages，无法恢复
+	if repo.SessionExists(sessionID, biz.AnonymousOwner) {
+		t.Fatalf("expected session to be gone after purge")
+	}
+	if session := repo.GetSessionMessages(sessionID, biz.AnonymousOwner); session != nil {
+		t.Fatalf("expected no messages after purge, got %d", len(session))
+	}
+	if err := repo.RestoreTree(treeID, biz.AnonymousOwner); !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound after purge, got: %v", err)
+	}
+	trash, err := repo.ListTrashTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrashTrees returned error: %v", err)
+	}
+	if len(trash) != 0 {
+		t.Fatalf("expected empty trash after purge, got %d", len(trash))
+	}
+}
+
+func TestPurgeTreesDeletedBefore(t *testing.T) {
+	repo := newTestRepo(t)
+	expiredTree, _ := repo.NewConversation(biz.AnonymousOwner)
+	freshTree, _ := repo.NewConversation(biz.AnonymousOwner)
+
+	if err := repo.DeleteTree(expiredTree, biz.AnonymousOwner); err != nil {
+		t.Fatalf("DeleteTree returned error: %v", err)
+	}
+	if err := repo.DeleteTree(freshTree, biz.AnonymousOwner); err != nil {
+		t.Fatalf("DeleteTree returned error: %v", err)
+	}
+
+	// 将其中一棵树的删除时间拨回到保留期之外
+	sqlRepo := repo.(*sqliteSessionRepo)
+	if _, err := sqlRepo.db.Exec(
+		"UPDATE session_trees SET deleted_at = datetime('now', '-40 days') WHERE id = ?",
+		expiredTree,
+	); err != nil {
+		t.Fatalf("failed to backdate deleted_at: %v", err)
+	}
+
+	purged, err := repo.PurgeTreesDeletedBefore(time.Now().Add(-30 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeTreesDeletedBefore returned error: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("expected 1 tree purged, got %d", purged)
+	}
+
+	trash, err := repo.ListTrashTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrashTrees returned error: %v", err)
+	}
+	if len(trash) != 1 || trash[0].ID != freshTree {
+		t.Fatalf("expected only fresh tree %s in trash, got %+v", freshTree, trash)
+	}
+}
+
+func TestOwnerScoping_TwoUsers(t *testing.T) {
+	repo := newTestRepo(t)
+	aliceTree, aliceSession := repo.NewConversation("user-alice")
+	_, bobSession := repo.NewConversation("user-bob")
+	appendTestMessage(t, repo, aliceSession, "user", "alice secret")
+	appendTestMessage(t, repo, bobSession, "user", "bob secret")
+
+	// 列表只包含自己的会话树
+	aliceTrees, err := repo.ListTrees("user-alice")
+	if err != nil {
+		t.Fatalf("ListTrees returned error: %v", err)
+	}
+	if len(aliceTrees) != 1 || aliceTrees[0].ID != aliceTree {
+		t.Fatalf("expected alice to see only her tree, got %+v", aliceTrees)
+	}
+
+	// 其他用户按 ID 访问与不存在一样返回 not found
+	if session := repo.GetSessionMessages(aliceSession, "user-bob"); session != nil {
+		t.Fatalf("expected bob to see no messages in alice's session, got %d", len(session))
+	}
+	if _, err := repo.GetTreeID(aliceSession, "user-bob"); !errors.Is(err, biz.ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound for foreign session, got: %v", err)
+	}
+	if _, err := repo.GetLastActiveSessionID(aliceTree, "user-bob"); !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound for foreign tree, got: %v", err)
+	}
+	if repo.SessionExists(aliceSession, "user-bob") {
+		t.Fatalf("expected foreign session to report not existing")
+	}
+
+	// 其他用户无法删除或恢复
+	if err := repo.DeleteTree(aliceTree, "user-bob"); !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound on foreign delete, got: %v", err)
+	}
+	if err := repo.DeleteTree(aliceTree, "user-alice"); err != nil {
+		t.Fatalf("DeleteTree returned error: %v", err)
+	}
+	if err := repo.RestoreTree(aliceTree, "user-bob"); !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound on foreign restore, got: %v", err)
+	}
+	trash, err := repo.ListTrashTrees("user-bob")
+	if err != nil {
+		t.Fatalf("ListTrashTrees returned error: %v", err)
+	}
+	if len(trash) != 0 {
+		t.Fatalf("expected bob's trash to be empty, got %d", len(trash))
+	}
+}
+
+func TestAppendMessage_PartialFlag(t *testing.T) {
+	repo := newTestRepo(t)
+	_, sessionID := repo.NewConversation(biz.AnonymousOwner)
+
+	appendTestMessage(t, repo, sessionID, "user", "q1")
+	if _, err := repo.AppendMessage(sessionID, &schema.Message{Role: schema.Assistant, Content: "partial answer"}, "gpt-4o", true); err != nil {
+		t.Fatalf("failed to append partial message: %v", err)
+	}
+
+	msgs := repo.GetSessionMessages(sessionID, biz.AnonymousOwner)
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+	if msgs[0].Partial {
+		t.Error("user message must not be marked partial")
+	}
+	if !msgs[1].Partial {
+		t.Error("interrupted assistant message must be marked partial")
+	}
+
+	_, last, err := repo.GetLastMessage(sessionID, biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("failed to get last message: %v", err)
+	}
+	if !last.Partial {
+		t.Error("GetLastMessage must carry the partial flag")
+	}
+}
+
+func TestSetGeneratedTitle_TitleSourceGuard(t *testing.T) {
+	repo := newTestRepo(t)
+	treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, sessionID, "user", "how do I rebase a branch")
+
+	// 自动截断标题可以被生成标题覆盖
+	applied, err := repo.SetGeneratedTitle(treeID, biz.AnonymousOwner, "Git rebase 教程")
+	if err != nil {
+		t.Fatalf("SetGeneratedTitle returned error: %v", err)
+	}
+	if !applied {
+		t.Fatal("expected generated title to apply over the auto-truncated title")
+	}
+
+	trees, err := repo.ListTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrees returned error: %v", err)
+	}
+	if len(trees) != 1 || trees[0].Title != "Git rebase 教程" {
+		t.Fatalf("unexpected trees after generated title: %+v", trees)
+	}
+
+	// 已生成过的标题不再被第二次生成覆盖（每棵树只生成一次）
+	applied, err = repo.SetGeneratedTitle(treeID, biz.AnonymousOwner, "another title")
+	if err != nil {
+		t.Fatalf("SetGeneratedTitle returned error: %v", err)
+	}
+	if applied {
+		t.Fatal("expected second generated title to be rejected")
+	}
+}
+
+func TestSetGeneratedTitle_DoesNotOverwriteManualTitle(t *testing.T) {
+	repo := newTestRepo(t)
+	treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, sessionID, "user", "q1")
+
+	if err := repo.SetTreeTitle(treeID, biz.AnonymousOwner, "我的标题"); err != nil {
+		t.Fatalf("SetTreeTitle returned error: %v", err)
+	}
+
+	applied, err := repo.SetGeneratedTitle(treeID, biz.AnonymousOwner, "generated title")
+	if err != nil {
+		t.Fatalf("SetGeneratedTitle returned error: %v", err)
+	}
+	if applied {
+		t.Fatal("expected generated title to be rejected for a manually titled tree")
+	}
+
+	trees, err := repo.ListTrees(biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("ListTrees returned error: %v", err)
+	}
+	if len(trees) != 1 || trees[0].Title != "我的标题" {
+		t.Fatalf("manual title must survive, got: %+v", trees)
+	}
+
+	if err := repo.SetTreeTitle("no-such-tree", biz.AnonymousOwner, "x"); !errors.Is(err, biz.ErrTreeNotFound) {
+		t.Fatalf("expected ErrTreeNotFound, got: %v", err)
+	}
+}
+
+func TestGetMessage_FoundAndNotFound(t *testing.T) {
+	repo := newTestRepo(t)
+	_, sessionID := repo.NewConversation(biz.AnonymousOwner)
+
+	appendTestMessage(t, repo, sessionID, "user", "q1")
+	msgID, err := repo.AppendMessage(sessionID, &schema.Message{Role: schema.Assistant, Content: "a1"}, "gpt-4o", false)
+	if err != nil {
+		t.Fatalf("failed to append message: %v", err)
+	}
+
+	msg, err := repo.GetMessage(msgID, biz.AnonymousOwner)
+	if err != nil {
+		t.Fatalf("GetMessage returned error: %v", err)
+	}
+	if msg.ID != msgID || msg.Role != schema.Assistant || msg.Content != "a1" || msg.Model != "gpt-4o" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if msg.CreatedAt.IsZero() {
+		t.Error("expected CreatedAt to be set")
+	}
+
+	if _, err := repo.GetMessage(12345, biz.AnonymousOwner); !errors.Is(err, biz.ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound for missing id, got: %v", err)
+	}
+
+	// 其他用户不可见：归属不匹配与不存在同样返回 not found
+	if _, err := repo.GetMessage(msgID, "someone-else"); !errors.Is(err, biz.ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound for foreign owner, got: %v", err)
+	}
+}
+
+func TestCreateBranchFrom_TimeTravelAtVariousDepths(t *testing.T) {
+	repo := newTestRepo(t)
+	treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+
+	q1 := appendTestMessage(t, repo, sessionID, "user", "q1")
+	appendTestMessage(t, repo, sessionID, "assistant", "a1")
+	q2 := appendTestMessage(t, repo, sessionID, "user", "q2")
+	a2 := appendTestMessage(t, repo, sessionID, "assistant", "a2")
+
+	cases := []struct {
+		name      string
+		anchor    int64
+		wantDepth int
+		wantLast  string
+	}{
+		{"fork at first message", q1, 1, "q1"},
+		{"fork mid-conversation", q2, 3, "q2"},
+		{"fork at latest message", a2, 4, "a2"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			forkID, err := repo.CreateBranchFrom(tc.anchor, treeID)
+			if err != nil {
+				t.Fatalf("fork failed: %v", err)
+			}
+
+			// 新分支尚无消息，历史应从锚点回放（含锚点自身）
+			history := repo.GetSessionMessages(forkID, biz.AnonymousOwner)
+			if len(history) != tc.wantDepth {
+				t.Fatalf("history depth = %d, want %d", len(history), tc.wantDepth)
+			}
+			if got := history[len(history)-1].Content; got != tc.wantLast {
+				t.Errorf("last history message = %q, want %q", got, tc.wantLast)
+			}
+
+			// 续聊：新消息应挂在锚点下，不影响原 session
+			appendTestMessage(t, repo, forkID, "user", "fork-q")
+			forked := repo.GetSessionMessages(forkID, biz.AnonymousOwner)
+			if len(forked) != tc.wantDepth+1 {
+				t.Fatalf("after append depth = %d, want %d", len(forked), tc.wantDepth+1)
+			}
+			if forked[len(forked)-1].Content != "fork-q" {
+				t.Errorf("appended message missing from fork history")
+			}
+		})
+	}
+
+	// 原 session 的消息链保持不变
+	original := repo.GetSessionMessages(sessionID, biz.AnonymousOwner)
+	if len(original) != 4 {
+		t.Errorf("original session depth = %d, want 4", len(original))
+	}
+}
+
+func TestCreateBranchFrom_RejectsForeignMessage(t *testing.T) {
+	repo := newTestRepo(t)
+	_, sessionA := repo.NewConversation(biz.AnonymousOwner)
+	treeB, _ := repo.NewConversation(biz.AnonymousOwner)
+
+	msgA := appendTestMessage(t, repo, sessionA, "user", "hello")
+	if _, err := repo.CreateBranchFrom(msgA, treeB); !errors.Is(err, biz.ErrMessageNotFound) {
+		t.Errorf("forking across trees should fail with ErrMessageNotFound, got: %v", err)
+	}
+	if _, err := repo.CreateBranchFrom(99999, treeB); !errors.Is(err, biz.ErrMessageNotFound) {
+		t.Errorf("forking from a nonexistent message should fail, got: %v", err)
+	}
+}
+
+// TestListTrees_LastMessagePreview 末条消息预览对多模态/工具消息给出
+// 可读占位而不是空白
+func TestListTrees_LastMessagePreview(t *testing.T) {
+	repo := newTestRepo(t)
+
+	lastPreview := func(t *testing.T) string {
+		t.Helper()
+		trees, err := repo.ListTrees(biz.AnonymousOwner)
+		if err != nil || len(trees) != 1 {
+			t.Fatalf("ListTrees = %#v, %v", trees, err)
+		}
+		return trees[0].LastMessage
+	}
+
+	_, sessionID := repo.NewConversation(biz.AnonymousOwner)
+
+	// 纯图片用户消息：content 为空，预览给占位符
+	if _, err := repo.AppendMessage(sessionID, &schema.Message{
+		Role: schema.User,
+		UserInputMultiContent: []schema.MessageInputPart{
+			{Type: schema.ChatMessagePartTypeImageURL},
+		},
+	}, "", false); err != nil {
+		t.Fatalf("AppendMessage failed: %v", err)
+	}
+	if got := lastPreview(t); got != "[Image]" {
+		t.Errorf("image-only preview = %q, want [Image]", got)
+	}
+
+	// 图文混合：预览取首个文本分片
+	if _, err := repo.AppendMessage(sessionID, &schema.Message{
+		Role: schema.User,
+		UserInputMultiContent: []schema.MessageInputPart{
+			{Type: schema.ChatMessagePartTypeImageURL},
+			{Type: schema.ChatMessagePartTypeText, Text: "what is this?"},
+		},
+	}, "", false); err != nil {
+		t.Fatalf("AppendMessage failed: %v", err)
+	}
+	if got := lastPreview(t); got != "what is this?" {
+		t.Errorf("mixed preview = %q, want first text part", got)
+	}
+
+	// 工具消息：标记工具名而不是原始输出
+	if _, err := repo.AppendMessage(sessionID, &schema.Message{
+		Role:     schema.Tool,
+		ToolName: "get_weather",
+		Content:  `{"temp": 21}`,
+	}, "", false); err != nil {
+		t.Fatalf("AppendMessage failed: %v", err)
+	}
+	if got := lastPreview(t); got != "[Tool: get_weather]" {
+		t.Errorf("tool preview = %q, want [Tool: get_weather]", got)
+	}
+
+	// 普通文本消息照旧
+	appendTestMessage(t, repo, sessionID, "assistant", "sunny, 21C")
+	if got := lastPreview(t); got != "sunny, 21C" {
+		t.Errorf("text preview = %q", got)
+	}
+}
+
+// TestAutoTitleFromContent 自动标题截断：CJK 硬切、Latin 词边界、多行压缩
+func TestAutoTitleFromContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		maxLen  int
+		want    string
+	}{
+		{"short passthrough", "hello", 15, "hello"},
+		{"latin word boundary", "explain git rebase to me", 15, "explain git..."},
+		{"latin no boundary in range", "please summarize this document", 15, "please summariz..."},
+		{"cjk hard cut", "请帮我总结一下这份很长的技术文档的主要内容", 15, "请帮我总结一下这份很长的技术文..."},
+		{"cjk sentence boundary", "请问杭州今天的天气，适合出门跑步吗", 15, "请问杭州今天的天气..."},
+		{"multiline collapsed", "first line\nsecond line\n\nthird", 15, "first line..."},
+		{"whitespace collapsed", "  lots   of\t\tspace  ", 15, "lots of space"},
+		{"whitespace only", "   \n\t  ", 15, ""},
+		{"custom max len", "explain git rebase to me", 30, "explain git rebase to me"},
+		{"zero uses default", "explain git rebase to me", 0, "explain git..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := autoTitleFromContent(tt.content, tt.maxLen); got != tt.want {
+				t.Errorf("autoTitleFromContent(%q, %d) = %q, want %q", tt.content, tt.maxLen, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetAutoTitleMaxLen 配置的标题长度在追加路径上生效
+func TestSetAutoTitleMaxLen(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.(interface{ SetAutoTitleMaxLen(int) }).SetAutoTitleMaxLen(40)
+
+	_, sessionID := repo.NewConversation(biz.AnonymousOwner)
+	appendTestMessage(t, repo, sessionID, "user", "explain git rebase to me please")
+
+	trees, err := repo.ListTrees(biz.AnonymousOwner)
+	if err != nil || len(trees) != 1 {
+		t.Fatalf("ListTrees = %#v, %v", trees, err)
+	}
+	if trees[0].Title != "explain git rebase to me please" {
+		t.Errorf("title = %q, want full message under the raised limit", trees[0].Title)
+	}
+}
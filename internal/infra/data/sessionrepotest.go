@@ -0,0 +1,204 @@
+package data
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// RunSessionRepoTests 对任意 biz.SessionRepo 实现跑一遍契约断言：
+// 会话创建、父链追加、分支与祖先重建、列表排序与末条消息提取、
+// 标题规则、删除级联。新实现（如未来的 Postgres 仓库）通过这套
+// 断言即可认为与现有实现行为一致。factory 每次调用返回一个全新
+// 的空仓库，清理由调用方通过 t.Cleanup 负责。
+func RunSessionRepoTests(t *testing.T, factory func(t *testing.T) biz.SessionRepo) {
+	mustAppend := func(t *testing.T, repo biz.SessionRepo, sessionID, role, content string) int64 {
+		t.Helper()
+		msgID, err := repo.AppendMessage(sessionID, &schema.Message{Role: schema.RoleType(role), Content: content}, "", false)
+		if err != nil {
+			t.Fatalf("failed to append message: %v", err)
+		}
+		return msgID
+	}
+
+	t.Run("IDPrefixes", func(t *testing.T) {
+		repo := factory(t)
+		treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+		if !strings.HasPrefix(treeID, "tree_") || !strings.HasPrefix(sessionID, "session_") {
+			t.Errorf("unexpected ID format: tree=%q session=%q", treeID, sessionID)
+		}
+	})
+
+	t.Run("LinearChain", func(t *testing.T) {
+		repo := factory(t)
+		_, sessionID := repo.NewConversation(biz.AnonymousOwner)
+		mustAppend(t, repo, sessionID, "user", "q1")
+		mustAppend(t, repo, sessionID, "assistant", "a1")
+		mustAppend(t, repo, sessionID, "user", "q2")
+
+		session := repo.GetSessionMessages(sessionID, biz.AnonymousOwner)
+		if len(session) != 3 || session[0].Content != "q1" || session[2].Content != "q2" {
+			t.Fatalf("unexpected chain: %#v", session)
+		}
+
+		_, last, err := repo.GetLastMessage(sessionID, biz.AnonymousOwner)
+		if err != nil || last.Content != "q2" {
+			t.Fatalf("GetLastMessage = %#v, %v", last, err)
+		}
+	})
+
+	t.Run("BranchAncestorReconstruction", func(t *testing.T) {
+		repo := factory(t)
+		treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+		mustAppend(t, repo, sessionID, "user", "q1")
+		branchPoint := mustAppend(t, repo, sessionID, "assistant", "a1")
+		mustAppend(t, repo, sessionID, "user", "q2")
+
+		branchID, _, err := repo.CreateBranchWithMessage(branchPoint, &schema.Message{Role: schema.User, Content: "q2-alt"})
+		if err != nil {
+			t.Fatalf("CreateBranchWithMessage failed: %v", err)
+		}
+		// 分支的历史由祖先回溯重建：分支点之前的消息来自主链
+		branch := repo.GetSessionMessages(branchID, biz.AnonymousOwner)
+		if len(branch) != 3 || branch[0].Content != "q1" || branch[1].Content != "a1" || branch[2].Content != "q2-alt" {
+			t.Fatalf("unexpected branch chain: %#v", branch)
+		}
+		if main := repo.GetSessionMessages(sessionID, biz.AnonymousOwner); len(main) != 3 || main[2].Content != "q2" {
+			t.Fatalf("branching must not disturb the main chain: %#v", main)
+		}
+
+		// 空分支（时间漫游）：首条消息挂到 fork 锚点下
+		forkID, err := repo.CreateBranchFrom(branchPoint, treeID)
+		if err != nil {
+			t.Fatalf("CreateBranchFrom failed: %v", err)
+		}
+		mustAppend(t, repo, forkID, "user", "q2-fork")
+		if fork := repo.GetSessionMessages(forkID, biz.AnonymousOwner); len(fork) != 3 || fork[2].Content != "q2-fork" {
+			t.Fatalf("unexpected fork chain: %#v", fork)
+		}
+
+		branches, err := repo.ListSessionsInTree(treeID, biz.AnonymousOwner)
+		if err != nil || len(branches) != 3 {
+			t.Fatalf("ListSessionsInTree = %#v, %v", branches, err)
+		}
+	})
+
+	t.Run("AutoAndGeneratedTitle", func(t *testing.T) {
+		repo := factory(t)
+		treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+		mustAppend(t, repo, sessionID, "user", "please summarize this very long document for me")
+
+		trees, err := repo.ListTrees(biz.AnonymousOwner)
+		if err != nil || len(trees) != 1 {
+			t.Fatalf("ListTrees = %#v, %v", trees, err)
+		}
+		if trees[0].Title != "please summariz..." {
+			t.Fatalf("auto title = %q, want first 15 runes + ellipsis", trees[0].Title)
+		}
+
+		// 自动标题可被生成标题替换，生成/手动标题不再被覆盖
+		if ok, err := repo.SetGeneratedTitle(treeID, biz.AnonymousOwner, "Document summary"); err != nil || !ok {
+			t.Fatalf("SetGeneratedTitle on auto title = %v, %v", ok, err)
+		}
+		if ok, _ := repo.SetGeneratedTitle(treeID, biz.AnonymousOwner, "Other"); ok {
+			t.Fatal("generated title must not be overwritten again")
+		}
+	})
+
+	t.Run("ListTreesLastMessage", func(t *testing.T) {
+		repo := factory(t)
+		treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+		mustAppend(t, repo, sessionID, "user", "q1")
+		branchPoint := mustAppend(t, repo, sessionID, "assistant", "a1")
+
+		// 末条消息跟随整棵树最新的写入，分支后指向分支
+		branchID, _, err := repo.CreateBranchWithMessage(branchPoint, &schema.Message{Role: schema.User, Content: "q2-alt"})
+		if err != nil {
+			t.Fatalf("CreateBranchWithMessage failed: %v", err)
+		}
+		trees, err := repo.ListTrees(biz.AnonymousOwner)
+		if err != nil || len(trees) != 1 {
+			t.Fatalf("ListTrees = %#v, %v", trees, err)
+		}
+		if trees[0].ID != treeID || trees[0].LastMessage != "q2-alt" || trees[0].LastActiveSessionID != branchID {
+			t.Fatalf("unexpected last message extraction: %#v", trees[0])
+		}
+	})
+
+	t.Run("ListTreesPinnedFirst", func(t *testing.T) {
+		repo := factory(t)
+		repo.NewConversation(biz.AnonymousOwner)
+		pinnedTree, _ := repo.NewConversation(biz.AnonymousOwner)
+		repo.NewConversation(biz.AnonymousOwner)
+
+		if err := repo.SetPinned(pinnedTree, biz.AnonymousOwner, true); err != nil {
+			t.Fatalf("SetPinned failed: %v", err)
+		}
+		trees, err := repo.ListTrees(biz.AnonymousOwner)
+		if err != nil || len(trees) != 3 {
+			t.Fatalf("ListTrees = %#v, %v", trees, err)
+		}
+		if trees[0].ID != pinnedTree || !trees[0].Pinned {
+			t.Errorf("pinned tree must sort first, got %#v", trees[0])
+		}
+	})
+
+	t.Run("TrashLifecycle", func(t *testing.T) {
+		repo := factory(t)
+		treeID, _ := repo.NewConversation(biz.AnonymousOwner)
+
+		if err := repo.DeleteTree(treeID, biz.AnonymousOwner); err != nil {
+			t.Fatalf("DeleteTree failed: %v", err)
+		}
+		if trees, _ := repo.ListTrees(biz.AnonymousOwner); len(trees) != 0 {
+			t.Fatalf("deleted tree must leave the list: %#v", trees)
+		}
+		trash, err := repo.ListTrashTrees(biz.AnonymousOwner)
+		if err != nil || len(trash) != 1 || trash[0].ID != treeID {
+			t.Fatalf("ListTrashTrees = %#v, %v", trash, err)
+		}
+		if err := repo.RestoreTree(treeID, biz.AnonymousOwner); err != nil {
+			t.Fatalf("RestoreTree failed: %v", err)
+		}
+		if trees, _ := repo.ListTrees(biz.AnonymousOwner); len(trees) != 1 {
+			t.Fatalf("restored tree must be listed again: %#v", trees)
+		}
+	})
+
+	t.Run("PurgeTreeCascade", func(t *testing.T) {
+		repo := factory(t)
+		treeID, sessionID := repo.NewConversation(biz.AnonymousOwner)
+		msgID := mustAppend(t, repo, sessionID, "user", "q1")
+
+		// 硬删除级联清掉 sessions 和 messages
+		if err := repo.PurgeTree(treeID); err != nil {
+			t.Fatalf("PurgeTree failed: %v", err)
+		}
+		if repo.SessionExists(sessionID, biz.AnonymousOwner) {
+			t.Error("purged session must not exist")
+		}
+		if _, err := repo.GetMessage(msgID, biz.AnonymousOwner); !errors.Is(err, biz.ErrMessageNotFound) {
+			t.Errorf("GetMessage after purge = %v, want ErrMessageNotFound", err)
+		}
+	})
+
+	t.Run("OwnerIsolation", func(t *testing.T) {
+		repo := factory(t)
+		_, sessionID := repo.NewConversation("user-a")
+		msgID := mustAppend(t, repo, sessionID, "user", "secret")
+
+		if repo.SessionExists(sessionID, "user-b") {
+			t.Error("session must not be visible to another owner")
+		}
+		if _, err := repo.GetTreeID(sessionID, "user-b"); !errors.Is(err, biz.ErrSessionNotFound) {
+			t.Errorf("GetTreeID for wrong owner = %v, want ErrSessionNotFound", err)
+		}
+		if _, err := repo.GetMessage(msgID, "user-b"); !errors.Is(err, biz.ErrMessageNotFound) {
+			t.Errorf("GetMessage for wrong owner = %v, want ErrMessageNotFound", err)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package redact
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// 合规部署需要在消息落库（可选地在发给上游模型前）把 API key、银行卡号
+// 这类敏感内容抹掉。本包提供默认的正则脱敏实现，模式可由配置覆盖。
+
+// DefaultReplacement 未配置替换文本时使用的占位符
+const DefaultReplacement = "[REDACTED]"
+
+// DefaultPatterns 默认脱敏模式：常见 API key 形态与银行卡号
+var DefaultPatterns = []string{
+	`\bsk-[A-Za-z0-9_-]{20,}\b`,            // OpenAI 风格 secret key
+	`\bAKIA[0-9A-Z]{16}\b`,                 // AWS access key ID
+	`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{16,}`, // Authorization bearer token
+	`\b(?:\d{4}[ -]?){3}\d{4}\b`,           // 银行卡号（含空格/连字符分隔）
+}
+
+// RegexRedactor 基于正则的脱敏器
+type RegexRedactor struct {
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+// NewRegexRedactor 创建正则脱敏器；patterns 为空时使用 DefaultPatterns，
+// replacement 为空时使用 DefaultReplacement。任一模式编译失败即报错
+func NewRegexRedactor(patterns []string, replacement string) (*RegexRedactor, error) {
+	if len(patterns) == 0 {
+		patterns = DefaultPatterns
+	}
+	if replacement == "" {
+		replacement = DefaultReplacement
+	}
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", p, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return &RegexRedactor{patterns: compiled, replacement: replacement}, nil
+}
+
+// Redact 替换文本中所有命中的敏感内容，返回脱敏后的文本与命中次数
+func (r *RegexRedactor) Redact(text string) (string, int) {
+	hits := 0
+	for _, re := range r.patterns {
+		text = re.ReplaceAllStringFunc(text, func(string) string {
+			hits++
+			return r.replacement
+		})
+	}
+	return text, hits
+}
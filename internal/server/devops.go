@@ -2,16 +2,81 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/cloudwego/eino-ext/devops"
 )
 
 // InitDevops initializes the eino devops server with an optional router.
 // The router will be mounted at /api prefix.
-func InitDevops(ctx context.Context, router http.Handler) error {
+// listenAddr is a ":port" style address; the underlying devops server only
+// takes a port and always binds all interfaces.
+func InitDevops(ctx context.Context, router http.Handler, listenAddr string) error {
+	port, err := portFromListenAddr(listenAddr)
+	if err != nil {
+		return err
+	}
+
 	if router == nil {
-		return devops.Init(ctx)
+		return devops.Init(ctx, devops.WithDevServerPort(port))
+	}
+	return devops.Init(ctx, devops.WithDevServerPort(port), devops.WithHandler("/api", router))
+}
+
+// ServeTLS serves the router over HTTPS with our own *http.Server, mirroring
+// the /api mount point used by InitDevops. The devops server only speaks plain
+// HTTP, so its debug routes are not available in this mode.
+// The certificate and listener are set up synchronously to surface config
+// errors at startup; serving itself runs in the background like devops.Init.
+func ServeTLS(ctx context.Context, router http.Handler, listenAddr, certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	ln, err := net.Listen("tcp", strings.TrimSpace(listenAddr))
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", listenAddr, err)
+	}
+
+	serveMux := http.NewServeMux()
+	if router != nil {
+		serveMux.Handle("/api/", http.StripPrefix("/api", router))
+	}
+
+	srv := &http.Server{
+		Handler:   serveMux,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+	go srv.ServeTLS(ln, "", "")
+	return nil
+}
+
+// portFromListenAddr extracts the port from a listen address like ":52538" or
+// "0.0.0.0:52538". Addresses naming a specific host are rejected because the
+// devops server cannot bind a single interface.
+func portFromListenAddr(listenAddr string) (string, error) {
+	addr := strings.TrimSpace(listenAddr)
+	if addr == "" {
+		return "", fmt.Errorf("listen address is empty")
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", listenAddr, err)
+	}
+	if host != "" && host != "0.0.0.0" && host != "::" {
+		return "", fmt.Errorf("listen address %q: binding a specific host is not supported, use :port", listenAddr)
+	}
+	if port == "" {
+		return "", fmt.Errorf("listen address %q: port is required", listenAddr)
 	}
-	return devops.Init(ctx, devops.WithHandler("/api", router))
+	return port, nil
 }
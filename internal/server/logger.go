@@ -0,0 +1,34 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+)
+
+// NewLogger 按配置构建进程日志器：format 为 json 时输出结构化日志
+// （ELK/Loki 等聚合器直接可解析），text（默认）保持人类可读；
+// level 支持 debug/info/warn/error，默认 info
+func NewLogger(format, level string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLogLevel(level)}
+
+	var handler slog.Handler
+	if format == "json" {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+	return slog.New(handler)
+}
+
+func parseLogLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
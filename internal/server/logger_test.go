@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestNewLogger_HandlerType(t *testing.T) {
+	if _, ok := NewLogger("json", "").Handler().(*slog.JSONHandler); !ok {
+		t.Errorf("format json: handler = %T, want *slog.JSONHandler", NewLogger("json", "").Handler())
+	}
+	if _, ok := NewLogger("text", "").Handler().(*slog.TextHandler); !ok {
+		t.Errorf("format text: handler = %T, want *slog.TextHandler", NewLogger("text", "").Handler())
+	}
+	// 未配置时保持原有 text 行为
+	if _, ok := NewLogger("", "").Handler().(*slog.TextHandler); !ok {
+		t.Errorf("empty format: handler = %T, want *slog.TextHandler", NewLogger("", "").Handler())
+	}
+}
+
+func TestNewLogger_Level(t *testing.T) {
+	ctx := context.Background()
+
+	debug := NewLogger("text", "debug").Handler()
+	if !debug.Enabled(ctx, slog.LevelDebug) {
+		t.Error("level debug: debug records should be enabled")
+	}
+
+	warn := NewLogger("text", "warn").Handler()
+	if warn.Enabled(ctx, slog.LevelInfo) {
+		t.Error("level warn: info records should be suppressed")
+	}
+	if !warn.Enabled(ctx, slog.LevelWarn) {
+		t.Error("level warn: warn records should be enabled")
+	}
+
+	// 默认 info
+	def := NewLogger("text", "").Handler()
+	if def.Enabled(ctx, slog.LevelDebug) {
+		t.Error("default level: debug records should be suppressed")
+	}
+	if !def.Enabled(ctx, slog.LevelInfo) {
+		t.Error("default level: info records should be enabled")
+	}
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data/provider"
+)
+
+// adminService 管理端服务实现
+type adminService struct {
+	chatUsecase *biz.ChatUsecase
+	confPath    string             // 配置文件路径，热重载时重新读取
+	reloader    biz.ClientReloader // nil 表示不支持热重载
+}
+
+// NewAdminService 创建管理端服务
+func NewAdminService(chat *biz.ChatUsecase, confPath string, reloader biz.ClientReloader) api.AdminService {
+	return &adminService{chatUsecase: chat, confPath: confPath, reloader: reloader}
+}
+
+// ListGenerations 列出进行中的生成
+func (s *adminService) ListGenerations(ctx context.Context) ([]api.GenerationInfo, error) {
+	generations := s.chatUsecase.ListGenerations()
+	infos := make([]api.GenerationInfo, 0, len(generations))
+	for _, g := range generations {
+		infos = append(infos, api.GenerationInfo{
+			SessionID: g.SessionID,
+			OwnerSub:  g.OwnerSub,
+			Model:     g.Model,
+			StartedAt: g.StartedAt,
+		})
+	}
+	return infos, nil
+}
+
+// StopAllGenerations 取消所有进行中的生成（上游故障时的应急手段）
+func (s *adminService) StopAllGenerations(ctx context.Context) (int, error) {
+	return s.chatUsecase.CancelAllGenerations(), nil
+}
+
+// ReloadConfig 重新读取并校验配置文件，通过后热替换 client 配置与默认模型；
+// 任何一步失败都不触碰运行中的配置
+func (s *adminService) ReloadConfig(ctx context.Context) (*api.ReloadInfo, error) {
+	if s.reloader == nil {
+		return nil, fmt.Errorf("config reload is not supported")
+	}
+	cfg, err := conf.Load(s.confPath)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("validate config: %w", err)
+	}
+
+	s.reloader.ReloadClients(cfg.Eino)
+	s.chatUsecase.SetDefaultModel(cfg.Eino.DefaultModel)
+	s.chatUsecase.SetClientDefaultModels(biz.ClientDefaultModels(cfg.Eino.Clients))
+
+	return &api.ReloadInfo{
+		Clients:      len(cfg.Eino.Clients),
+		DefaultModel: cfg.Eino.DefaultModel,
+	}, nil
+}
+
+// SetUserQuota 设置用户级 token 配额覆盖
+func (s *adminService) SetUserQuota(ctx context.Context, sub string, quota int64) error {
+	return s.chatUsecase.SetQuotaOverride(sub, quota)
+}
+
+// TestModel 对指定 client/model 发起最小化生成测试连通性；
+// 上游错误原文写进结果而不作为 error 返回，让排障端点总能拿到路由信息
+func (s *adminService) TestModel(ctx context.Context, client, model string) (*api.ModelTestInfo, error) {
+	result, err := s.chatUsecase.TestModel(ctx, client, model)
+	info := &api.ModelTestInfo{
+		OK:        err == nil,
+		Client:    result.Client,
+		Model:     result.Model,
+		Adapter:   result.Adapter,
+		LatencyMS: result.Latency.Milliseconds(),
+		Output:    result.Output,
+	}
+	if err != nil {
+		info.Error = err.Error()
+	}
+	return info, nil
+}
+
+// ListModelCapabilities 返回当前生效的模型能力注册表快照
+func (s *adminService) ListModelCapabilities(ctx context.Context) (map[string]provider.ModelCapabilities, error) {
+	return provider.GetModelCapabilityRegistry().Snapshot(), nil
+}
+
+// SetModelCapabilities 运行时覆盖某个模型的能力；persist 时同步写入外部
+// JSON 覆盖文件（写文件失败不回滚内存，调整仍对本进程生效）
+func (s *adminService) SetModelCapabilities(ctx context.Context, model string, caps provider.ModelCapabilities, persist bool) error {
+	if model == "" {
+		return fmt.Errorf("model is required")
+	}
+	registry := provider.GetModelCapabilityRegistry()
+	registry.SetCapabilities(model, caps)
+	if persist {
+		if err := registry.PersistCapability(model, caps); err != nil {
+			return fmt.Errorf("persist capability: %w", err)
+		}
+	}
+	return nil
+}
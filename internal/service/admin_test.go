@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data/provider"
+
+	"github.com/cloudwego/eino/components/model"
+)
+
+// captureReloader 记录热重载收到的配置
+type captureReloader struct {
+	got *conf.Eino
+}
+
+func (c *captureReloader) ReloadClients(cfg conf.Eino) { c.got = &cfg }
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestReloadConfig_SwapsClientsAndDefaultModel(t *testing.T) {
+	path := writeConfigFile(t, `
+eino:
+  default_model: "glm-4"
+  clients:
+    openai:
+      base_url: "https://api.example.com/v1"
+      api_key: "k1"
+    glm:
+      base_url: "https://glm.example.com/v1"
+      api_key: "k2"
+`)
+	uc := biz.NewChatUsecase(nil, conf.Eino{DefaultModel: "gpt-4o-mini"})
+	reloader := &captureReloader{}
+	svc := NewAdminService(uc, path, reloader)
+
+	info, err := svc.ReloadConfig(context.Background())
+	if err != nil {
+		t.Fatalf("ReloadConfig failed: %v", err)
+	}
+	if info.Clients != 2 || info.DefaultModel != "glm-4" {
+		t.Errorf("info = %+v, want 2 clients and default glm-4", info)
+	}
+	if reloader.got == nil {
+		t.Fatal("reloader was not invoked")
+	}
+	if _, ok := reloader.got.Clients["glm"]; !ok {
+		t.Error("added glm client did not reach the reloader")
+	}
+}
+
+func TestReloadConfig_InvalidConfigKeepsOldState(t *testing.T) {
+	// 空的 client 条目无法通过校验
+	path := writeConfigFile(t, `
+eino:
+  default_model: "gpt-4o-mini"
+  clients:
+    openai: {}
+`)
+	uc := biz.NewChatUsecase(nil, conf.Eino{DefaultModel: "gpt-4o-mini"})
+	reloader := &captureReloader{}
+	svc := NewAdminService(uc, path, reloader)
+
+	if _, err := svc.ReloadConfig(context.Background()); err == nil {
+		t.Fatal("expected validation error for empty client entry")
+	}
+	if reloader.got != nil {
+		t.Error("invalid config must not be swapped in")
+	}
+}
+
+func TestAdminCapabilities_GetAndUpdate(t *testing.T) {
+	svc := NewAdminService(nil, "", nil)
+
+	caps, err := svc.ListModelCapabilities(context.Background())
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if _, ok := caps["claude"]; !ok {
+		t.Fatal("expected built-in claude entry in the registry snapshot")
+	}
+
+	if err := svc.SetModelCapabilities(context.Background(), "", provider.ModelCapabilities{}, false); err == nil {
+		t.Fatal("expected error for empty model name")
+	}
+
+	update := provider.ModelCapabilities{
+		SupportedModalities: map[provider.ModalityType]bool{provider.ModalityText: true},
+		MaxContextTokens:    8192,
+	}
+	if err := svc.SetModelCapabilities(context.Background(), "svc-tuned-model", update, false); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+
+	caps, err = svc.ListModelCapabilities(context.Background())
+	if err != nil {
+		t.Fatalf("list after update failed: %v", err)
+	}
+	if got, ok := caps["svc-tuned-model"]; !ok || got.MaxContextTokens != 8192 {
+		t.Fatalf("update not visible in snapshot: %+v", got)
+	}
+}
+
+// failingProvider 构造 ChatModel 即失败（模拟凭证/连通性问题），可解释路由
+type failingProvider struct{}
+
+func (failingProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return nil, errors.New("401 invalid api key")
+}
+
+func (failingProvider) ResolveClient(modelName string) biz.ClientResolution {
+	return biz.ClientResolution{Client: "openai", Source: biz.ResolutionSourceKeyword, Keyword: "gpt", Adapter: true}
+}
+
+func TestTestModel_Success(t *testing.T) {
+	uc := biz.NewChatUsecase(resolvingProvider{}, conf.Eino{DefaultModel: "gpt-4o"})
+	svc := NewAdminService(uc, "", nil)
+
+	info, err := svc.TestModel(context.Background(), "", "gpt-4o")
+	if err != nil {
+		t.Fatalf("TestModel failed: %v", err)
+	}
+	if !info.OK || info.Error != "" {
+		t.Fatalf("expected success, got %+v", info)
+	}
+	if info.Client != "openai" || !info.Adapter || info.Model != "gpt-4o" {
+		t.Errorf("routing info = %+v, want client openai with adapter", info)
+	}
+	if info.Output != "hello" {
+		t.Errorf("output = %q, want hello", info.Output)
+	}
+}
+
+func TestTestModel_UpstreamErrorVerbatim(t *testing.T) {
+	uc := biz.NewChatUsecase(failingProvider{}, conf.Eino{DefaultModel: "gpt-4o"})
+	svc := NewAdminService(uc, "", nil)
+
+	info, err := svc.TestModel(context.Background(), "", "gpt-4o")
+	if err != nil {
+		t.Fatalf("TestModel must not fail on upstream errors: %v", err)
+	}
+	if info.OK || info.Error != "401 invalid api key" {
+		t.Errorf("expected verbatim upstream error, got %+v", info)
+	}
+	// 路由信息在上游失败时也要带上，否则排障没有抓手
+	if info.Client != "openai" {
+		t.Errorf("client = %q, want openai", info.Client)
+	}
+}
@@ -2,16 +2,22 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 
 	"devops-backend/internal/api"
 	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
 )
 
 // chatService 聊天服务实现
 type chatService struct {
 	chatUsecase    *biz.ChatUsecase
 	sessionUsecase *biz.SessionUsecase
+	streamFlush    time.Duration // 流式增量落库的合帧间隔，0 表示关闭
 }
 
 // NewChatService creates a ChatService.
@@ -22,47 +28,153 @@ func NewChatService(chat *biz.ChatUsecase, session *biz.SessionUsecase) api.Chat
 	}
 }
 
+// SetStreamFlushInterval 开启流式增量落库并设置合帧间隔（conf.Data.StreamFlushMs）
+func (s *chatService) SetStreamFlushInterval(d time.Duration) {
+	s.streamFlush = d
+}
+
 // Chat 执行聊天，进行 DTO 转换
 func (s *chatService) Chat(ctx context.Context, req *api.ChatRequest) (*api.ChatResponse, error) {
 	bizReq := &biz.ChatRequest{
-		Message:  req.Message,
-		Model:    req.Model,
-		ThreadID: req.ThreadID,
-		Thinking: req.Thinking,
+		Message:          req.Message,
+		Model:            req.Model,
+		ThreadID:         req.ThreadID,
+		Thinking:         req.Thinking,
+		ThinkingBudget:   req.ThinkingBudget,
+		ReasoningSummary: req.ReasoningSummary,
+		Seed:             req.Seed,
+		NoCache:          req.NoCache,
+		Store:            req.Store,
+		Extra:            req.Extra,
+		APIKey:           req.APIKey,
+		BaseURL:          req.BaseURL,
+	}
+	// 指定 client 且未指定 model 时，优先用该 client 配置的默认模型
+	if bizReq.Model == "" && req.Client != "" {
+		bizReq.Model = s.chatUsecase.ClientDefaultModel(req.Client)
 	}
 
-	threadID, sessionID, _, err := s.sessionUsecase.ResolveThread(bizReq.ThreadID)
+	// 无状态一次性请求：不建会话、不读写历史，直接把请求消息发给模型
+	if req.Stateless {
+		result, modelName, err := s.chatUsecase.Chat(ctx, statelessMessages(req, bizReq), bizReq.Model, bizReq.Params())
+		if err != nil {
+			return nil, err
+		}
+		return &api.ChatResponse{
+			Message:          *result,
+			Model:            modelName,
+			Annotations:      toAPIAnnotations(biz.AnnotationsFromMessage(result)),
+			AppliedThinking:  s.chatUsecase.AppliedThinking(ctx, bizReq.Model, bizReq.Thinking),
+			ClientResolution: s.clientResolution(req, bizReq.Model),
+		}, nil
+	}
+
+	owner := biz.OwnerSubFromContext(ctx)
+	threadID, sessionID, isNew, err := s.sessionUsecase.ResolveThread(owner, bizReq.ThreadID)
 	if err != nil {
 		return nil, fmt.Errorf("resolve thread: %w", err)
 	}
+	// 新建对话时落盘请求附带的自定义元数据（失败不影响聊天主流程）
+	if isNew && len(req.Metadata) > 0 {
+		_ = s.sessionUsecase.SetMetadata(owner, threadID, req.Metadata)
+	}
+	// 新建对话时落盘对话级自定义指令，之后每轮注入
+	if isNew && req.SystemPrompt != "" {
+		_ = s.sessionUsecase.SetSystemPrompt(owner, threadID, req.SystemPrompt)
+	}
 
 	userMsg := biz.BuildUserMessage(bizReq)
-	if _, err := s.sessionUsecase.AppendMessage(sessionID, userMsg, ""); err != nil {
+
+	// 重复提交保护：短窗口内完全相同的用户消息不重复入库、不重复生成
+	if window, behavior := s.chatUsecase.DedupPolicy(); window > 0 {
+		if reply, dup := s.sessionUsecase.FindRecentDuplicate(owner, sessionID, userMsg, window); dup {
+			if behavior == biz.DedupBehaviorReplay && reply != nil {
+				return &api.ChatResponse{
+					Message:     reply.Message,
+					ID:          reply.ID,
+					Model:       reply.Model,
+					SessionID:   sessionID,
+					TreeID:      threadID,
+					Annotations: toAPIAnnotations(biz.AnnotationsFromMessage(&reply.Message)),
+				}, nil
+			}
+			return nil, biz.ErrDuplicateMessage
+		}
+	}
+
+	if _, err := s.sessionUsecase.AppendMessage(sessionID, userMsg, "", false); err != nil {
 		return nil, fmt.Errorf("append user message: %w", err)
 	}
 
-	messages, err := s.sessionUsecase.GetHistory(sessionID)
+	messages, err := s.sessionUsecase.GetHistory(owner, sessionID)
 	if err != nil {
 		return nil, fmt.Errorf("get session history: %w", err)
 	}
 
-	result, modelName, err := s.chatUsecase.Chat(ctx, messages, bizReq.Model, bizReq.Thinking)
+	result, modelName, err := s.chatUsecase.Chat(ctx, messages, bizReq.Model, bizReq.Params())
 	if err != nil {
 		return nil, err
 	}
 
-	if _, err := s.sessionUsecase.AppendMessage(sessionID, result, modelName); err != nil {
+	if _, err := s.sessionUsecase.AppendMessage(sessionID, result, modelName, false); err != nil {
 		return nil, fmt.Errorf("append assistant message: %w", err)
 	}
 
 	return &api.ChatResponse{
-		Message:   *result,
-		Model:     modelName,
-		SessionID: sessionID,
-		TreeID:    threadID,
+		Message:          *result,
+		Model:            modelName,
+		SessionID:        sessionID,
+		TreeID:           threadID,
+		Annotations:      toAPIAnnotations(biz.AnnotationsFromMessage(result)),
+		AppliedThinking:  s.chatUsecase.AppliedThinking(ctx, bizReq.Model, bizReq.Thinking),
+		ClientResolution: s.clientResolution(req, bizReq.Model),
 	}, nil
 }
 
+// clientResolution debug 模式下的路由决策追踪；未开启 debug 或 provider 无法
+// 解释路由时返回 nil（响应中省略该字段）
+func (s *chatService) clientResolution(req *api.ChatRequest, model string) *api.ClientResolutionInfo {
+	if !req.Debug {
+		return nil
+	}
+	// 显式指定 client 且未带 model 时，路由由 client 字段决定而非关键词匹配
+	if req.Client != "" && req.Model == "" {
+		info := &api.ClientResolutionInfo{Client: req.Client, Source: biz.ResolutionSourceExplicit}
+		if res := s.chatUsecase.ExplainClientResolution(model); res != nil {
+			info.Adapter = res.Adapter
+		}
+		return info
+	}
+	res := s.chatUsecase.ExplainClientResolution(model)
+	if res == nil {
+		return nil
+	}
+	return &api.ClientResolutionInfo{
+		Client:  res.Client,
+		Source:  res.Source,
+		Keyword: res.Keyword,
+		Adapter: res.Adapter,
+	}
+}
+
+// toAPIAnnotations biz → api 注释 DTO 转换
+func toAPIAnnotations(annotations []biz.Annotation) []api.Annotation {
+	if len(annotations) == 0 {
+		return nil
+	}
+	result := make([]api.Annotation, len(annotations))
+	for i, a := range annotations {
+		result[i] = api.Annotation{
+			Type:       a.Type,
+			URL:        a.URL,
+			Title:      a.Title,
+			StartIndex: a.StartIndex,
+			EndIndex:   a.EndIndex,
+		}
+	}
+	return result
+}
+
 // ChatStream 执行流式聊天
 func (s *chatService) ChatStream(
 	ctx context.Context,
@@ -71,64 +183,402 @@ func (s *chatService) ChatStream(
 	onChunk api.StreamChunkCallback,
 ) error {
 	bizReq := &biz.ChatRequest{
-		Message:  req.Message,
-		Model:    req.Model,
-		ThreadID: req.ThreadID,
-		Thinking: req.Thinking,
+		Message:          req.Message,
+		Model:            req.Model,
+		ThreadID:         req.ThreadID,
+		Thinking:         req.Thinking,
+		ThinkingBudget:   req.ThinkingBudget,
+		ReasoningSummary: req.ReasoningSummary,
+		Seed:             req.Seed,
+		NoCache:          req.NoCache,
+		Store:            req.Store,
+		Extra:            req.Extra,
+		APIKey:           req.APIKey,
+		BaseURL:          req.BaseURL,
+	}
+	// 指定 client 且未指定 model 时，优先用该 client 配置的默认模型
+	if bizReq.Model == "" && req.Client != "" {
+		bizReq.Model = s.chatUsecase.ClientDefaultModel(req.Client)
 	}
 
-	threadID, sessionID, isNew, err := s.sessionUsecase.ResolveThread(bizReq.ThreadID)
+	// 无状态一次性请求：SSE 流程照常，但元信息不带 session ID，全程不碰数据库
+	if req.Stateless {
+		return s.chatStreamStateless(ctx, req, bizReq, onStart, onChunk)
+	}
+
+	owner := biz.OwnerSubFromContext(ctx)
+	threadID, sessionID, isNew, err := s.sessionUsecase.ResolveThread(owner, bizReq.ThreadID)
 	if err != nil {
 		return fmt.Errorf("resolve thread: %w", err)
 	}
+	// 新建对话时落盘请求附带的自定义元数据（失败不影响聊天主流程）
+	if isNew && len(req.Metadata) > 0 {
+		_ = s.sessionUsecase.SetMetadata(owner, threadID, req.Metadata)
+	}
+	// 新建对话时落盘对话级自定义指令，之后每轮注入
+	if isNew && req.SystemPrompt != "" {
+		_ = s.sessionUsecase.SetSystemPrompt(owner, threadID, req.SystemPrompt)
+	}
 
 	if err := onStart(api.StreamMetaInfo{
-		ThreadID:  threadID,
-		SessionID: sessionID,
-		RunID:     req.RunID,
-		IsNew:     isNew,
+		ThreadID:         threadID,
+		SessionID:        sessionID,
+		RunID:            req.RunID,
+		IsNew:            isNew,
+		AppliedThinking:  s.chatUsecase.AppliedThinking(ctx, bizReq.Model, bizReq.Thinking),
+		ClientResolution: s.clientResolution(req, bizReq.Model),
 	}); err != nil {
 		return err
 	}
 
 	userMsg := biz.BuildUserMessage(bizReq)
-	if _, err := s.sessionUsecase.AppendMessage(sessionID, userMsg, ""); err != nil {
+
+	// 重复提交保护：短窗口内完全相同的用户消息不重复入库、不重复生成
+	if window, behavior := s.chatUsecase.DedupPolicy(); window > 0 {
+		if reply, dup := s.sessionUsecase.FindRecentDuplicate(owner, sessionID, userMsg, window); dup {
+			if behavior == biz.DedupBehaviorReplay && reply != nil {
+				// 重放已有回复：整条内容作为单个 chunk 下发
+				return onChunk(api.StreamChunk{
+					Content:          reply.Content,
+					ReasoningContent: reply.ReasoningContent,
+					Annotations:      toAPIAnnotations(biz.AnnotationsFromMessage(&reply.Message)),
+				})
+			}
+			return biz.ErrDuplicateMessage
+		}
+	}
+
+	if _, err := s.sessionUsecase.AppendMessage(sessionID, userMsg, "", false); err != nil {
 		return fmt.Errorf("append user message: %w", err)
 	}
 
-	messages, err := s.sessionUsecase.GetHistory(sessionID)
+	messages, err := s.sessionUsecase.GetHistory(owner, sessionID)
 	if err != nil {
 		return fmt.Errorf("get session history: %w", err)
 	}
 
+	// 流式增量落库：开启后生成过程中分批把已生成内容写入数据库
+	persister := newStreamPersister(s.sessionUsecase, owner, sessionID, bizReq.Model, s.streamFlush)
+
 	bizChunkAdapter := func(chunk biz.StreamChunk) error {
+		persister.onChunk(chunk)
 		return onChunk(api.StreamChunk{
 			Content:                  chunk.Content,
 			ReasoningContent:         chunk.ReasoningContent,
 			AssistantGenMultiContent: chunk.AssistantGenMultiContent,
 			ToolCalls:                chunk.ToolCalls,
+			Annotations:              toAPIAnnotations(chunk.Annotations),
+			FinishReason:             chunk.FinishReason,
+			ToolName:                 chunk.ToolName,
+			ToolCallID:               chunk.ToolCallID,
+			ToolOutput:               chunk.ToolOutput,
+			Usage:                    chunk.Usage,
+			ReasoningDone:            chunk.ReasoningDone,
+			HistoryTruncated:         chunk.HistoryTruncated,
 		})
 	}
 
-	assistantMsg, modelName, err := s.chatUsecase.ChatStream(ctx, messages, bizReq.Model, bizReq.Thinking, bizChunkAdapter)
+	// 注册进行中的生成，允许 POST /sessions/{id}/cancel 从其它连接取消
+	genCtx, release := s.chatUsecase.TrackGeneration(ctx, sessionID, owner, bizReq.Model)
+	defer release()
+
+	assistantMsg, modelName, err := s.chatUsecase.ChatStream(genCtx, messages, bizReq.Model, bizReq.Params(), bizChunkAdapter)
 	if err != nil {
+		// 取消、断连或上游停更时保存已生成的部分内容，并打上 partial 标记
+		if (errors.Is(err, biz.ErrGenerationCanceled) || errors.Is(err, context.Canceled) || errors.Is(err, biz.ErrStreamStalled)) && assistantMsg != nil {
+			markInterrupted(assistantMsg)
+			if _, appendErr := persister.finish(assistantMsg, modelName, true); appendErr != nil {
+				return fmt.Errorf("append partial assistant message: %w", appendErr)
+			}
+		} else if errors.Is(err, biz.ErrEmptyResponse) && s.chatUsecase.PersistEmptyResponses() && assistantMsg != nil {
+			// 空回复默认不入库，避免历史里出现幽灵消息
+			if _, appendErr := persister.finish(assistantMsg, modelName, false); appendErr != nil {
+				return fmt.Errorf("append empty assistant message: %w", appendErr)
+			}
+		} else {
+			// 既不保存 partial 也不整条入库的错误：清理增量落库建的行
+			persister.abort()
+		}
 		return err
 	}
 
-	if _, err := s.sessionUsecase.AppendMessage(sessionID, assistantMsg, modelName); err != nil {
+	if _, err := persister.finish(assistantMsg, modelName, false); err != nil {
 		return fmt.Errorf("append assistant message: %w", err)
 	}
 
+	// 首轮对话结束后生成会话标题并实时推给前端
+	if isNew {
+		s.maybeGenerateTitle(ctx, owner, threadID, userMsg, assistantMsg, onChunk)
+	}
+
 	return nil
 }
 
-// ListSessions 列出所有会话树
+// chatStreamStateless 无状态流式聊天：跳过会话解析与消息持久化，
+// 取消只能依赖客户端断开连接（没有 session 可供 /cancel 寻址）
+func (s *chatService) chatStreamStateless(
+	ctx context.Context,
+	req *api.ChatRequest,
+	bizReq *biz.ChatRequest,
+	onStart api.StreamStartCallback,
+	onChunk api.StreamChunkCallback,
+) error {
+	if err := onStart(api.StreamMetaInfo{
+		RunID:            req.RunID,
+		AppliedThinking:  s.chatUsecase.AppliedThinking(ctx, bizReq.Model, bizReq.Thinking),
+		ClientResolution: s.clientResolution(req, bizReq.Model),
+	}); err != nil {
+		return err
+	}
+
+	_, _, err := s.chatUsecase.ChatStream(ctx, statelessMessages(req, bizReq), bizReq.Model, bizReq.Params(), func(chunk biz.StreamChunk) error {
+		apiChunk := toAPIStreamChunk(chunk)
+		return onChunk(apiChunk)
+	})
+	return err
+}
+
+// statelessMessages 无状态请求的模型输入：优先用客户端提供的完整消息列表
+func statelessMessages(req *api.ChatRequest, bizReq *biz.ChatRequest) []*schema.Message {
+	if len(req.History) > 0 {
+		return req.History
+	}
+	return []*schema.Message{biz.BuildUserMessage(bizReq)}
+}
+
+// maybeGenerateTitle 用标题模型把首轮对话总结成简短标题；仅在标题仍是
+// 首条消息截断产物时写入，失败不影响聊天主流程
+func (s *chatService) maybeGenerateTitle(
+	ctx context.Context,
+	ownerSub, treeID string,
+	userMsg, assistantMsg *schema.Message,
+	onChunk api.StreamChunkCallback,
+) {
+	if !s.chatUsecase.TitleGenerationEnabled() {
+		return
+	}
+	title, err := s.chatUsecase.GenerateTitle(ctx, userMsg.Content, assistantMsg.Content)
+	if err != nil || title == "" {
+		return
+	}
+	applied, err := s.sessionUsecase.SetGeneratedTitle(ownerSub, treeID, title)
+	if err != nil || !applied {
+		return
+	}
+	_ = onChunk(api.StreamChunk{Title: title})
+}
+
+// markInterrupted 给未跑完的流补上结束原因，方便前端与续写逻辑判断
+func markInterrupted(msg *schema.Message) {
+	if msg.ResponseMeta == nil {
+		msg.ResponseMeta = &schema.ResponseMeta{}
+	}
+	if msg.ResponseMeta.FinishReason == "" {
+		msg.ResponseMeta.FinishReason = "interrupted"
+	}
+}
+
+// CancelSession 取消 session 上进行中的生成
+func (s *chatService) CancelSession(ctx context.Context, sessionID string) error {
+	if !s.chatUsecase.CancelGeneration(sessionID) {
+		return fmt.Errorf("%w: %s", biz.ErrNoActiveGeneration, sessionID)
+	}
+	return nil
+}
+
+// UploadFile 把文件上传到厂商 /files 接口，返回可在后续请求中引用的 file_id
+func (s *chatService) UploadFile(ctx context.Context, client, filename string, data []byte, purpose string) (*api.UploadFileResponse, error) {
+	fileID, err := s.chatUsecase.UploadFile(ctx, client, filename, data, purpose)
+	if err != nil {
+		return nil, fmt.Errorf("upload file: %w", err)
+	}
+	return &api.UploadFileResponse{FileID: fileID}, nil
+}
+
+// CountTokens 估算一次请求将发送的 token 数（dry-run，不写入任何会话）
+func (s *chatService) CountTokens(ctx context.Context, req *api.ChatRequest) (*api.CountTokensResponse, error) {
+	bizReq := &biz.ChatRequest{
+		Message:  req.Message,
+		Model:    req.Model,
+		ThreadID: req.ThreadID,
+	}
+
+	// 与 ChatStream 相同的消息组装，但本次用户消息只在内存中拼接
+	var history []*schema.Message
+	if bizReq.ThreadID != "" {
+		owner := biz.OwnerSubFromContext(ctx)
+		_, sessionID, _, err := s.sessionUsecase.ResolveThread(owner, bizReq.ThreadID)
+		if err != nil {
+			return nil, fmt.Errorf("resolve thread: %w", err)
+		}
+		history, err = s.sessionUsecase.GetHistory(owner, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("get session history: %w", err)
+		}
+	}
+
+	messages := append(history, biz.BuildUserMessage(bizReq))
+	count, modelName, method := s.chatUsecase.CountTokens(messages, bizReq.Model)
+	return &api.CountTokensResponse{
+		Model:      modelName,
+		TokenCount: count,
+		Method:     method,
+	}, nil
+}
+
+// DebugPrompt 调试用 dry-run：执行与 Chat/ChatStream 相同的消息组装，
+// 返回将要发给模型的最终消息列表，不调用上游、不写入会话
+func (s *chatService) DebugPrompt(ctx context.Context, req *api.ChatRequest) (*api.DebugPromptResponse, error) {
+	bizReq := &biz.ChatRequest{
+		Message:  req.Message,
+		Model:    req.Model,
+		ThreadID: req.ThreadID,
+	}
+
+	// 与 ChatStream 相同的消息组装，但本次用户消息只在内存中拼接
+	var history []*schema.Message
+	if bizReq.ThreadID != "" {
+		owner := biz.OwnerSubFromContext(ctx)
+		_, sessionID, _, err := s.sessionUsecase.ResolveThread(owner, bizReq.ThreadID)
+		if err != nil {
+			return nil, fmt.Errorf("resolve thread: %w", err)
+		}
+		history, err = s.sessionUsecase.GetHistory(owner, sessionID)
+		if err != nil {
+			return nil, fmt.Errorf("get session history: %w", err)
+		}
+	}
+
+	messages := append(history, biz.BuildUserMessage(bizReq))
+	final, modelName, err := s.chatUsecase.ResolveOutboundPrompt(messages, bizReq.Model)
+	if err != nil {
+		return nil, err
+	}
+	return &api.DebugPromptResponse{
+		Model:    modelName,
+		Messages: final,
+	}, nil
+}
+
+// ContinueSession 续写 session 中被截断的最后一条助手消息，并把续写结果合并回原消息
+func (s *chatService) ContinueSession(ctx context.Context, sessionID string) (*api.ChatResponse, error) {
+	owner := biz.OwnerSubFromContext(ctx)
+	msgID, last, err := s.sessionUsecase.GetLastMessage(owner, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastFinishReason string
+	if last.ResponseMeta != nil {
+		lastFinishReason = last.ResponseMeta.FinishReason
+	}
+	if last.Role != schema.Assistant || !biz.IsTruncatedFinishReason(lastFinishReason) {
+		return nil, biz.ErrNotTruncated
+	}
+
+	history, err := s.sessionUsecase.GetHistory(owner, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("get session history: %w", err)
+	}
+
+	// 续写提示不入库，仅用于本次请求
+	messages := append(history, &schema.Message{
+		Role:    schema.User,
+		Content: "Continue your previous answer exactly where it stopped. Do not repeat anything.",
+	})
+
+	continuation, modelName, err := s.chatUsecase.Chat(ctx, messages, last.Model, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := biz.MergeContinuation(&last.Message, continuation)
+	if err := s.sessionUsecase.UpdateMessage(msgID, merged); err != nil {
+		return nil, fmt.Errorf("update assistant message: %w", err)
+	}
+
+	return &api.ChatResponse{
+		Message:     *merged,
+		Model:       modelName,
+		SessionID:   sessionID,
+		Annotations: toAPIAnnotations(biz.AnnotationsFromMessage(merged)),
+	}, nil
+}
+
+// DeleteMessage 删除 session 中的消息及其后代
+func (s *chatService) DeleteMessage(ctx context.Context, sessionID string, msgID int64) error {
+	return s.sessionUsecase.DeleteMessage(biz.OwnerSubFromContext(ctx), sessionID, msgID)
+}
+
+// ForkSessionFrom 从指定消息 fork 出新分支（时间漫游），返回新 session ID
+func (s *chatService) ForkSessionFrom(ctx context.Context, sessionID string, msgID int64) (string, error) {
+	return s.sessionUsecase.ForkFromMessage(biz.OwnerSubFromContext(ctx), sessionID, msgID)
+}
+
+// GetMessage 按 ID 获取单条消息（永久链接 / 按需加载分支点）
+func (s *chatService) GetMessage(ctx context.Context, msgID int64) (*api.ChatResponse, error) {
+	msg, err := s.sessionUsecase.GetMessage(biz.OwnerSubFromContext(ctx), msgID)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ChatResponse{
+		Message:     msg.Message,
+		ID:          msg.ID,
+		Model:       msg.Model,
+		Annotations: toAPIAnnotations(biz.AnnotationsFromMessage(&msg.Message)),
+		Partial:     msg.Partial,
+		CreatedAt:   msg.CreatedAt,
+	}, nil
+}
+
+// ListSessions 列出所有未删除的会话树
 func (s *chatService) ListSessions(ctx context.Context) ([]api.SessionInfo, error) {
-	trees, err := s.sessionUsecase.ListSessions()
+	trees, err := s.sessionUsecase.ListSessions(biz.OwnerSubFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return toAPISessionInfos(trees), nil
+}
+
+// ListSessionsByMetadata 按 metadata 顶层键值过滤会话树列表
+func (s *chatService) ListSessionsByMetadata(ctx context.Context, key, value string) ([]api.SessionInfo, error) {
+	trees, err := s.sessionUsecase.ListSessionsByMetadata(biz.OwnerSubFromContext(ctx), key, value)
+	if err != nil {
+		return nil, err
+	}
+	return toAPISessionInfos(trees), nil
+}
+
+// ListTreeSessions 列出 tree 内的所有分支 session（分支切换器用）
+func (s *chatService) ListTreeSessions(ctx context.Context, treeID string) ([]api.TreeSessionInfo, error) {
+	branches, err := s.sessionUsecase.ListTreeSessions(biz.OwnerSubFromContext(ctx), treeID)
 	if err != nil {
 		return nil, err
 	}
 
+	result := make([]api.TreeSessionInfo, len(branches))
+	for i, b := range branches {
+		result[i] = api.TreeSessionInfo{
+			ID:           b.ID,
+			MessageCount: b.MessageCount,
+			ForkAnchor:   b.ForkAnchor,
+			CreatedAt:    b.CreatedAt,
+		}
+	}
+	return result, nil
+}
+
+// ListTrash 列出回收站中的会话树
+func (s *chatService) ListTrash(ctx context.Context) ([]api.SessionInfo, error) {
+	trees, err := s.sessionUsecase.ListTrash(biz.OwnerSubFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return toAPISessionInfos(trees), nil
+}
+
+// toAPISessionInfos biz → api 会话树 DTO 转换
+func toAPISessionInfos(trees []biz.SessionTreeInfo) []api.SessionInfo {
 	result := make([]api.SessionInfo, len(trees))
 	for i, tree := range trees {
 		result[i] = api.SessionInfo{
@@ -136,20 +586,89 @@ func (s *chatService) ListSessions(ctx context.Context) ([]api.SessionInfo, erro
 			Title:               tree.Title,
 			LastActiveSessionID: tree.LastActiveSessionID,
 			LastMessage:         tree.LastMessage,
+			Pinned:              tree.Pinned,
+			Metadata:            tree.Metadata,
 			CreatedAt:           tree.CreatedAt,
 			UpdatedAt:           tree.UpdatedAt,
+			DeletedAt:           tree.DeletedAt,
 		}
 	}
-	return result, nil
+	return result
+}
+
+// DeleteSession 软删除会话树（支持 tree_id 或 session_id）
+func (s *chatService) DeleteSession(ctx context.Context, id string) error {
+	return s.withResolvedTree(ctx, id, s.sessionUsecase.DeleteTree)
+}
+
+// BulkDeleteSessions 批量软删除会话树，返回每个 tree_id 的结果（deleted / not_found）
+func (s *chatService) BulkDeleteSessions(ctx context.Context, ids []string) (map[string]string, error) {
+	results, err := s.sessionUsecase.DeleteTrees(biz.OwnerSubFromContext(ctx), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(results))
+	for id, res := range results {
+		switch {
+		case res == nil:
+			out[id] = "deleted"
+		case errors.Is(res, biz.ErrTreeNotFound):
+			out[id] = "not_found"
+		default:
+			out[id] = "error"
+		}
+	}
+	return out, nil
+}
+
+// SetSessionPinned 置顶/取消置顶会话树（支持 tree_id 或 session_id）
+func (s *chatService) SetSessionPinned(ctx context.Context, id string, pinned bool) error {
+	return s.withResolvedTree(ctx, id, func(ownerSub, treeID string) error {
+		return s.sessionUsecase.SetPinned(ownerSub, treeID, pinned)
+	})
+}
+
+// SetSessionMetadata 设置会话树的自定义元数据（支持 tree_id 或 session_id）
+func (s *chatService) SetSessionMetadata(ctx context.Context, id string, meta json.RawMessage) error {
+	return s.withResolvedTree(ctx, id, func(ownerSub, treeID string) error {
+		return s.sessionUsecase.SetMetadata(ownerSub, treeID, meta)
+	})
+}
+
+// SetSessionSystemPrompt 设置对话级自定义指令（支持 tree_id 或 session_id），
+// 空串清空
+func (s *chatService) SetSessionSystemPrompt(ctx context.Context, id, prompt string) error {
+	return s.withResolvedTree(ctx, id, func(ownerSub, treeID string) error {
+		return s.sessionUsecase.SetSystemPrompt(ownerSub, treeID, prompt)
+	})
+}
+
+// RestoreSession 从回收站恢复会话树（支持 tree_id 或 session_id）
+func (s *chatService) RestoreSession(ctx context.Context, id string) error {
+	return s.withResolvedTree(ctx, id, s.sessionUsecase.RestoreTree)
+}
+
+// withResolvedTree 对 tree 执行操作；兼容：传入 session_id 时解析到所属 tree
+func (s *chatService) withResolvedTree(ctx context.Context, id string, op func(ownerSub, treeID string) error) error {
+	owner := biz.OwnerSubFromContext(ctx)
+	err := op(owner, id)
+	if errors.Is(err, biz.ErrTreeNotFound) {
+		if treeID, _, _, resolveErr := s.sessionUsecase.ResolveSession(owner, id); resolveErr == nil {
+			return op(owner, treeID)
+		}
+	}
+	return err
 }
 
 // GetSession 获取会话详情
 func (s *chatService) GetSession(ctx context.Context, sessionID string) (*api.GetSessionResponse, error) {
-	session, err := s.sessionUsecase.GetSession(sessionID)
+	owner := biz.OwnerSubFromContext(ctx)
+	session, err := s.sessionUsecase.GetSession(owner, sessionID)
 	if err != nil {
 		// 兼容：如果传入的是 thread_id，则解析到最后活跃 session 再读取消息
-		if _, resolvedSessionID, _, resolveErr := s.sessionUsecase.ResolveThread(sessionID); resolveErr == nil {
-			session, err = s.sessionUsecase.GetSession(resolvedSessionID)
+		if _, resolvedSessionID, _, resolveErr := s.sessionUsecase.ResolveThread(owner, sessionID); resolveErr == nil {
+			session, err = s.sessionUsecase.GetSession(owner, resolvedSessionID)
 		}
 	}
 	if err != nil {
@@ -159,10 +678,60 @@ func (s *chatService) GetSession(ctx context.Context, sessionID string) (*api.Ge
 	messages := make([]*api.ChatResponse, len(session))
 	for i, msg := range session {
 		messages[i] = &api.ChatResponse{
-			Message: msg.Message,
-			Model:   msg.Model,
+			Message:     msg.Message,
+			ID:          msg.ID,
+			Model:       msg.Model,
+			Annotations: toAPIAnnotations(biz.AnnotationsFromMessage(&msg.Message)),
+			Partial:     msg.Partial,
+			CreatedAt:   msg.CreatedAt,
 		}
 	}
 
 	return &api.GetSessionResponse{Messages: messages}, nil
 }
+
+// 分页读取的单页默认条数与上限
+const (
+	defaultSessionPageSize = 50
+	maxSessionPageSize     = 500
+)
+
+// GetSessionMessages 沿线性链分页读取会话消息（长对话懒加载，不整棵进内存）
+func (s *chatService) GetSessionMessages(ctx context.Context, sessionID string, afterID int64, limit int) (*api.GetSessionMessagesResponse, error) {
+	owner := biz.OwnerSubFromContext(ctx)
+	if limit <= 0 {
+		limit = defaultSessionPageSize
+	}
+	if limit > maxSessionPageSize {
+		limit = maxSessionPageSize
+	}
+
+	// 多取一条判断是否还有下一页
+	page, err := s.sessionUsecase.GetSessionPage(owner, sessionID, afterID, limit+1)
+	if err != nil {
+		// 兼容：如果传入的是 thread_id，则解析到最后活跃 session 再读取
+		if _, resolvedSessionID, _, resolveErr := s.sessionUsecase.ResolveThread(owner, sessionID); resolveErr == nil {
+			page, err = s.sessionUsecase.GetSessionPage(owner, resolvedSessionID, afterID, limit+1)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hasMore := len(page) > limit
+	if hasMore {
+		page = page[:limit]
+	}
+	messages := make([]*api.ChatResponse, len(page))
+	for i, msg := range page {
+		messages[i] = &api.ChatResponse{
+			Message:     msg.Message,
+			ID:          msg.ID,
+			Model:       msg.Model,
+			Annotations: toAPIAnnotations(biz.AnnotationsFromMessage(&msg.Message)),
+			Partial:     msg.Partial,
+			CreatedAt:   msg.CreatedAt,
+		}
+	}
+	return &api.GetSessionMessagesResponse{Messages: messages, HasMore: hasMore}, nil
+}
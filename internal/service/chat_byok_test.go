@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// byokProvider 记录凭证覆盖调用，区分一次性 client 与常规 client 的构造路径
+type byokProvider struct {
+	echoProvider
+	cred      biz.ClientCredentials
+	credCalls int
+	plainCall int
+}
+
+func (p *byokProvider) CreateChatModel(ctx context.Context, modelName string, opts ...model.Option) (model.ToolCallingChatModel, error) {
+	p.plainCall++
+	return p.echoProvider.CreateChatModel(ctx, modelName, opts...)
+}
+
+func (p *byokProvider) CreateChatModelWithCredentials(_ context.Context, _ string, cred biz.ClientCredentials, _ ...model.Option) (model.ToolCallingChatModel, error) {
+	p.cred = cred
+	p.credCalls++
+	return echoChatModel{}, nil
+}
+
+func newByokTestService(t *testing.T, cfg conf.Eino, provider *byokProvider) api.ChatService {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(t.TempDir() + "/test.db")
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	sessionUsecase := biz.NewSessionUsecase(repo)
+	chatUsecase := biz.NewChatUsecase(provider, cfg)
+	return NewChatService(chatUsecase, sessionUsecase)
+}
+
+// TestChat_UserKeyReachesModelConstruction 开启 allow_user_keys 后，请求携带的
+// api_key/base_url 应到达一次性 client 的构造处，且不落入会话存储。
+func TestChat_UserKeyReachesModelConstruction(t *testing.T) {
+	provider := &byokProvider{}
+	svc := newByokTestService(t, conf.Eino{DefaultModel: "test-model", AllowUserKeys: true}, provider)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+		APIKey:  "sk-user-byok",
+		BaseURL: "https://byok.example.com/v1",
+	})
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+	if provider.credCalls != 1 {
+		t.Fatalf("expected one credential-override construction, got %d", provider.credCalls)
+	}
+	if provider.plainCall != 0 {
+		t.Errorf("plain CreateChatModel must not be used for BYO key requests, called %d times", provider.plainCall)
+	}
+	if provider.cred.APIKey != "sk-user-byok" || provider.cred.BaseURL != "https://byok.example.com/v1" {
+		t.Errorf("credentials not propagated, got %+v", provider.cred)
+	}
+
+	// 会话里任何一条消息序列化后都不得出现用户 key
+	sess, err := svc.GetSession(context.Background(), resp.SessionID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	raw, err := json.Marshal(sess)
+	if err != nil {
+		t.Fatalf("failed to marshal session: %v", err)
+	}
+	if strings.Contains(string(raw), "sk-user-byok") {
+		t.Errorf("user-supplied api key leaked into stored session: %s", raw)
+	}
+}
+
+// TestChat_UserKeyStrippedWhenDisabled 未开启 allow_user_keys 时凭证被剥离，
+// 走常规的 CreateChatModel。
+func TestChat_UserKeyStrippedWhenDisabled(t *testing.T) {
+	provider := &byokProvider{}
+	svc := newByokTestService(t, conf.Eino{DefaultModel: "test-model"}, provider)
+
+	_, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+		APIKey:  "sk-user-byok",
+	})
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+	if provider.credCalls != 0 {
+		t.Errorf("credential-override construction must not happen when allow_user_keys is off, called %d times", provider.credCalls)
+	}
+	if provider.plainCall != 1 {
+		t.Errorf("expected plain CreateChatModel, called %d times", provider.plainCall)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// namedChatModel 回复里带上自身模型名，便于区分各模型的结果
+type namedChatModel struct{ name string }
+
+func (m namedChatModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return &schema.Message{Role: schema.Assistant, Content: "reply from " + m.name}, nil
+}
+
+func (m namedChatModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{{Role: schema.Assistant, Content: "reply from " + m.name}}), nil
+}
+
+func (m namedChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+// namedProvider 按模型名创建 namedChatModel；broken-model 模拟上游不可用
+type namedProvider struct{}
+
+func (namedProvider) CreateChatModel(_ context.Context, name string, _ ...model.Option) (model.ToolCallingChatModel, error) {
+	if name == "broken-model" {
+		return nil, errors.New("model unavailable")
+	}
+	return namedChatModel{name: name}, nil
+}
+
+func newCompareTestService(t *testing.T) api.ChatService {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	sessionUsecase := biz.NewSessionUsecase(repo)
+	chatUsecase := biz.NewChatUsecase(namedProvider{}, conf.Eino{DefaultModel: "model-a"})
+	return NewChatService(chatUsecase, sessionUsecase)
+}
+
+// TestCompareChat_TwoModels 两个模型各自回复，且各自落在独立分支上。
+func TestCompareChat_TwoModels(t *testing.T) {
+	svc := newCompareTestService(t)
+
+	resp, err := svc.CompareChat(context.Background(), &api.CompareRequest{
+		Prompt: "which is better",
+		Models: []string{"model-a", "model-b"},
+	})
+	if err != nil {
+		t.Fatalf("CompareChat failed: %v", err)
+	}
+	if resp.TreeID == "" || len(resp.Results) != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	for i, want := range []string{"model-a", "model-b"} {
+		res := resp.Results[i]
+		if res.Error != "" {
+			t.Fatalf("model %s failed: %s", want, res.Error)
+		}
+		if res.Model != want || res.Message == nil || res.Message.Content != "reply from "+want {
+			t.Errorf("result %d = %+v, want reply from %s", i, res, want)
+		}
+		// 每个模型的回复持久化在自己的分支 session 里：提示 + 回复
+		session, err := svc.GetSession(context.Background(), res.SessionID)
+		if err != nil {
+			t.Fatalf("failed to get branch session: %v", err)
+		}
+		if len(session.Messages) != 2 || session.Messages[1].Content != "reply from "+want {
+			t.Errorf("branch for %s not persisted correctly: %+v", want, session.Messages)
+		}
+	}
+	if resp.Results[0].SessionID == resp.Results[1].SessionID {
+		t.Error("models must reply on distinct branches")
+	}
+}
+
+// TestCompareChat_PartialFailure 一个模型失败不影响另一个的结果。
+func TestCompareChat_PartialFailure(t *testing.T) {
+	svc := newCompareTestService(t)
+
+	resp, err := svc.CompareChat(context.Background(), &api.CompareRequest{
+		Prompt: "hello",
+		Models: []string{"model-a", "broken-model"},
+	})
+	if err != nil {
+		t.Fatalf("CompareChat failed: %v", err)
+	}
+
+	if resp.Results[0].Error != "" || resp.Results[0].Message == nil {
+		t.Errorf("healthy model must succeed: %+v", resp.Results[0])
+	}
+	if resp.Results[1].Error == "" {
+		t.Errorf("broken model must report an error: %+v", resp.Results[1])
+	}
+}
+
+// TestCompareChatStream_TaggedEvents 流式事件带 model 标签，完整覆盖生命周期。
+func TestCompareChatStream_TaggedEvents(t *testing.T) {
+	svc := newCompareTestService(t)
+
+	var mu sync.Mutex
+	var events []api.CompareStreamEvent
+	err := svc.CompareChatStream(context.Background(), &api.CompareRequest{
+		Prompt: "hello",
+		Models: []string{"model-a", "model-b"},
+	}, func(event api.CompareStreamEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("CompareChatStream failed: %v", err)
+	}
+
+	if first := events[0]; first.Type != api.CompareEventStart || first.TreeID == "" {
+		t.Fatalf("first event = %+v, want start with tree_id", first)
+	}
+	if last := events[len(events)-1]; last.Type != api.CompareEventFinish {
+		t.Fatalf("last event = %+v, want finish", last)
+	}
+
+	counts := map[string]map[string]int{} // model → type → count
+	for _, ev := range events[1 : len(events)-1] {
+		if ev.Model == "" {
+			t.Errorf("per-model event missing model tag: %+v", ev)
+			continue
+		}
+		if counts[ev.Model] == nil {
+			counts[ev.Model] = map[string]int{}
+		}
+		counts[ev.Model][ev.Type]++
+		if ev.Type == api.CompareEventChunk && !strings.HasPrefix(ev.Chunk.Content, "reply from ") {
+			t.Errorf("unexpected chunk content: %+v", ev.Chunk)
+		}
+	}
+	for _, m := range []string{"model-a", "model-b"} {
+		c := counts[m]
+		if c[api.CompareEventModelStart] != 1 || c[api.CompareEventDone] != 1 || c[api.CompareEventChunk] == 0 {
+			t.Errorf("model %s lifecycle incomplete: %v", m, c)
+		}
+	}
+}
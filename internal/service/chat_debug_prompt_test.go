@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"devops-backend/internal/api"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// TestDebugPrompt_ResolvesFullAssembly 调试端点返回与真实对话一致的
+// 最终 prompt（对话级指令 + 完整历史 + 本次消息），且不调用模型、
+// 不把本次消息写入会话。
+func TestDebugPrompt_ResolvesFullAssembly(t *testing.T) {
+	var calls [][]*schema.Message
+	svc := newSystemPromptTestService(t, &calls)
+
+	first, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message:      schema.Message{Role: schema.User, Content: "q1"},
+		SystemPrompt: "you are a pirate",
+	})
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+	modelCalls := len(calls)
+
+	resp, err := svc.DebugPrompt(context.Background(), &api.ChatRequest{
+		Message:  schema.Message{Role: schema.User, Content: "q2"},
+		ThreadID: first.TreeID,
+	})
+	if err != nil {
+		t.Fatalf("debug prompt failed: %v", err)
+	}
+
+	// 指令 + q1 + 回复 + 本次的 q2
+	if len(resp.Messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(resp.Messages))
+	}
+	if resp.Messages[0].Role != schema.System || resp.Messages[0].Content != "you are a pirate" {
+		t.Errorf("tree system prompt must lead the resolved prompt, got %#v", resp.Messages[0])
+	}
+	if last := resp.Messages[len(resp.Messages)-1]; last.Role != schema.User || last.Content != "q2" {
+		t.Errorf("request message must close the resolved prompt, got %#v", last)
+	}
+	if resp.Model != "test-model" {
+		t.Errorf("unexpected resolved model %q", resp.Model)
+	}
+
+	// dry-run：没有新的模型调用，本次消息也没有写入会话
+	if len(calls) != modelCalls {
+		t.Errorf("debug prompt must not call the model, got %d extra calls", len(calls)-modelCalls)
+	}
+	again, err := svc.DebugPrompt(context.Background(), &api.ChatRequest{
+		Message:  schema.Message{Role: schema.User, Content: "q3"},
+		ThreadID: first.TreeID,
+	})
+	if err != nil {
+		t.Fatalf("second debug prompt failed: %v", err)
+	}
+	if len(again.Messages) != 4 {
+		t.Errorf("previous debug call must not persist its message, got %d messages", len(again.Messages))
+	}
+}
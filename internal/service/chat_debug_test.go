@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// resolvingProvider 能解释路由决策的 echoProvider（ClientResolver 实现）
+type resolvingProvider struct {
+	echoProvider
+}
+
+func (resolvingProvider) ResolveClient(modelName string) biz.ClientResolution {
+	return biz.ClientResolution{
+		Client:  "openai",
+		Source:  biz.ResolutionSourceKeyword,
+		Keyword: "gpt",
+		Adapter: true,
+	}
+}
+
+func newDebugTestService(t *testing.T, cfg conf.Eino) api.ChatService {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	sessionUsecase := biz.NewSessionUsecase(repo)
+	chatUsecase := biz.NewChatUsecase(resolvingProvider{}, cfg)
+	return NewChatService(chatUsecase, sessionUsecase)
+}
+
+// TestChat_DebugReturnsKeywordResolution debug 模式下响应携带关键词路由追踪。
+func TestChat_DebugReturnsKeywordResolution(t *testing.T) {
+	svc := newDebugTestService(t, conf.Eino{DefaultModel: "gpt-4o"})
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+		Model:   "gpt-4o",
+		Debug:   true,
+	})
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+
+	res := resp.ClientResolution
+	if res == nil {
+		t.Fatal("expected client_resolution in debug mode")
+	}
+	if res.Client != "openai" || res.Source != biz.ResolutionSourceKeyword {
+		t.Errorf("resolution = %+v, want openai via keyword", res)
+	}
+	if res.Keyword != "gpt" || !res.Adapter {
+		t.Errorf("resolution = %+v, want keyword gpt with adapter", res)
+	}
+}
+
+// TestChat_DebugExplicitClientResolution 显式指定 client 且未带 model 时，
+// 路由来源标记为 explicit 而非关键词匹配。
+func TestChat_DebugExplicitClientResolution(t *testing.T) {
+	svc := newDebugTestService(t, conf.Eino{
+		DefaultModel: "gpt-4o",
+		Clients:      map[string]conf.Client{"mock": {DefaultModel: "mock-default"}},
+	})
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+		Client:  "mock",
+		Debug:   true,
+	})
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+
+	res := resp.ClientResolution
+	if res == nil {
+		t.Fatal("expected client_resolution in debug mode")
+	}
+	if res.Client != "mock" || res.Source != biz.ResolutionSourceExplicit {
+		t.Errorf("resolution = %+v, want mock via explicit", res)
+	}
+	if res.Keyword != "" {
+		t.Errorf("explicit resolution must not carry a keyword, got %q", res.Keyword)
+	}
+}
+
+// TestChat_NoDebugOmitsClientResolution 未开启 debug 时响应省略路由追踪。
+func TestChat_NoDebugOmitsClientResolution(t *testing.T) {
+	svc := newDebugTestService(t, conf.Eino{DefaultModel: "gpt-4o"})
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+	})
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+	if resp.ClientResolution != nil {
+		t.Errorf("expected no client_resolution without debug, got %+v", resp.ClientResolution)
+	}
+}
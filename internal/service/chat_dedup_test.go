@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// echoChatModel 立即返回固定回复
+type echoChatModel struct{}
+
+func (echoChatModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return &schema.Message{Role: schema.Assistant, Content: "hello"}, nil
+}
+
+func (echoChatModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{{Role: schema.Assistant, Content: "hello"}}), nil
+}
+
+func (m echoChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type echoProvider struct{}
+
+func (echoProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return echoChatModel{}, nil
+}
+
+func newDedupTestService(t *testing.T, cfg conf.Eino) api.ChatService {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	sessionUsecase := biz.NewSessionUsecase(repo)
+	chatUsecase := biz.NewChatUsecase(echoProvider{}, cfg)
+	return NewChatService(chatUsecase, sessionUsecase)
+}
+
+// TestChat_DuplicateSubmitRejected 窗口内重复提交相同消息默认被拒绝，且不重复入库。
+func TestChat_DuplicateSubmitRejected(t *testing.T) {
+	svc := newDedupTestService(t, conf.Eino{DefaultModel: "test-model", DedupWindowSeconds: 60})
+
+	first, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+	})
+	if err != nil {
+		t.Fatalf("first chat failed: %v", err)
+	}
+
+	_, err = svc.Chat(context.Background(), &api.ChatRequest{
+		Message:  schema.Message{Role: schema.User, Content: "hi"},
+		ThreadID: first.TreeID,
+	})
+	if !errors.Is(err, biz.ErrDuplicateMessage) {
+		t.Fatalf("expected ErrDuplicateMessage, got: %v", err)
+	}
+
+	resp, err := svc.GetSession(context.Background(), first.SessionID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(resp.Messages) != 2 {
+		t.Fatalf("duplicate must not be appended, got %d messages", len(resp.Messages))
+	}
+}
+
+// TestChat_DuplicateSubmitReplayed replay 模式下重复提交返回已有的助手回复。
+func TestChat_DuplicateSubmitReplayed(t *testing.T) {
+	svc := newDedupTestService(t, conf.Eino{
+		DefaultModel:       "test-model",
+		DedupWindowSeconds: 60,
+		DedupBehavior:      biz.DedupBehaviorReplay,
+	})
+
+	first, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+	})
+	if err != nil {
+		t.Fatalf("first chat failed: %v", err)
+	}
+
+	second, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message:  schema.Message{Role: schema.User, Content: "hi"},
+		ThreadID: first.TreeID,
+	})
+	if err != nil {
+		t.Fatalf("replayed chat failed: %v", err)
+	}
+	if second.Content != "hello" {
+		t.Errorf("expected replayed assistant reply, got %q", second.Content)
+	}
+
+	resp, err := svc.GetSession(context.Background(), first.SessionID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(resp.Messages) != 2 {
+		t.Fatalf("replay must not append new messages, got %d", len(resp.Messages))
+	}
+}
+
+// TestChat_DifferentMessageNotDeduped 不同内容的连续提交不受去重影响。
+func TestChat_DifferentMessageNotDeduped(t *testing.T) {
+	svc := newDedupTestService(t, conf.Eino{DefaultModel: "test-model", DedupWindowSeconds: 60})
+
+	first, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+	})
+	if err != nil {
+		t.Fatalf("first chat failed: %v", err)
+	}
+
+	if _, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message:  schema.Message{Role: schema.User, Content: "how are you"},
+		ThreadID: first.TreeID,
+	}); err != nil {
+		t.Fatalf("different message must not be deduped: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// echoModelProvider 记录每次请求实际解析到的模型名
+type echoModelProvider struct {
+	requested []string
+}
+
+func (p *echoModelProvider) CreateChatModel(_ context.Context, modelName string, _ ...model.Option) (model.ToolCallingChatModel, error) {
+	p.requested = append(p.requested, modelName)
+	return echoModel{}, nil
+}
+
+type echoModel struct{}
+
+func (echoModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return &schema.Message{Role: schema.Assistant, Content: "ok"}, nil
+}
+
+func (echoModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{
+		{Role: schema.Assistant, Content: "ok"},
+	}), nil
+}
+
+func (m echoModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func newDefaultModelTestService(t *testing.T) (*echoModelProvider, api.ChatService) {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	p := &echoModelProvider{}
+	svc := NewChatService(
+		biz.NewChatUsecase(p, conf.Eino{
+			DefaultModel: "global-default",
+			Clients: map[string]conf.Client{
+				"claude": {BaseURL: "https://example.com", APIKey: "k", DefaultModel: "claude-default"},
+				"openai": {BaseURL: "https://example.com", APIKey: "k"},
+			},
+		}),
+		biz.NewSessionUsecase(repo),
+	)
+	return p, svc
+}
+
+// TestChat_DefaultModelResolution model/client 组合下的默认模型解析：
+// 显式 model 永远优先，空 model + client 用该 client 的默认，
+// 其余情况回退全局默认
+func TestChat_DefaultModelResolution(t *testing.T) {
+	cases := []struct {
+		name   string
+		model  string
+		client string
+		want   string
+	}{
+		{"empty model falls back to global default", "", "", "global-default"},
+		{"explicit model wins", "my-model", "claude", "my-model"},
+		{"client default used when model empty", "", "claude", "claude-default"},
+		{"client without default falls back to global", "", "openai", "global-default"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, svc := newDefaultModelTestService(t)
+			_, err := svc.Chat(context.Background(), &api.ChatRequest{
+				Message: schema.Message{Role: schema.User, Content: "hi"},
+				Model:   tc.model,
+				Client:  tc.client,
+			})
+			if err != nil {
+				t.Fatalf("chat failed: %v", err)
+			}
+			if len(p.requested) == 0 || p.requested[0] != tc.want {
+				t.Errorf("provider got models %v, first should be %q", p.requested, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// emptyChatModel 模拟安全拒答：流结束但没有任何输出
+type emptyChatModel struct{}
+
+func (emptyChatModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return &schema.Message{Role: schema.Assistant, ResponseMeta: &schema.ResponseMeta{FinishReason: "content_filter"}}, nil
+}
+
+func (emptyChatModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{
+		{Role: schema.Assistant, ResponseMeta: &schema.ResponseMeta{FinishReason: "content_filter"}},
+	}), nil
+}
+
+func (m emptyChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type emptyProvider struct{}
+
+func (emptyProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return emptyChatModel{}, nil
+}
+
+func runEmptyStream(t *testing.T, cfg conf.Eino) (api.ChatService, string, error) {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	svc := NewChatService(biz.NewChatUsecase(emptyProvider{}, cfg), biz.NewSessionUsecase(repo))
+
+	var sessionID string
+	streamErr := svc.ChatStream(context.Background(),
+		&api.ChatRequest{Message: schema.Message{Role: schema.User, Content: "hi"}},
+		func(info api.StreamMetaInfo) error {
+			sessionID = info.SessionID
+			return nil
+		},
+		func(api.StreamChunk) error { return nil },
+	)
+	return svc, sessionID, streamErr
+}
+
+// TestChatStream_EmptyResponseNotPersisted 空回复上报 ErrEmptyResponse 且默认不入库。
+func TestChatStream_EmptyResponseNotPersisted(t *testing.T) {
+	svc, sessionID, err := runEmptyStream(t, conf.Eino{DefaultModel: "test-model"})
+	if !errors.Is(err, biz.ErrEmptyResponse) {
+		t.Fatalf("expected ErrEmptyResponse, got: %v", err)
+	}
+
+	resp, err := svc.GetSession(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(resp.Messages) != 1 {
+		t.Fatalf("blank assistant turn must not be persisted, got %d messages", len(resp.Messages))
+	}
+}
+
+// TestChatStream_EmptyResponsePersistedWhenConfigured 配置开启后空回复仍写入历史。
+func TestChatStream_EmptyResponsePersistedWhenConfigured(t *testing.T) {
+	svc, sessionID, err := runEmptyStream(t, conf.Eino{DefaultModel: "test-model", PersistEmptyResponses: true})
+	if !errors.Is(err, biz.ErrEmptyResponse) {
+		t.Fatalf("expected ErrEmptyResponse, got: %v", err)
+	}
+
+	resp, err := svc.GetSession(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(resp.Messages) != 2 {
+		t.Fatalf("expected persisted empty assistant turn, got %d messages", len(resp.Messages))
+	}
+}
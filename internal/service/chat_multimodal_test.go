@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// imageChatModel 模拟图像生成模型：回复只含一个 image 输出分片，没有文本
+type imageChatModel struct{}
+
+func imagePart() schema.MessageOutputPart {
+	data := "aGVsbG8="
+	return schema.MessageOutputPart{
+		Type: schema.ChatMessagePartTypeImageURL,
+		Image: &schema.MessageOutputImage{MessagePartCommon: schema.MessagePartCommon{
+			Base64Data: &data,
+			MIMEType:   "image/png",
+		}},
+	}
+}
+
+func (imageChatModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return &schema.Message{
+		Role:                     schema.Assistant,
+		AssistantGenMultiContent: []schema.MessageOutputPart{imagePart()},
+	}, nil
+}
+
+func (imageChatModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{
+		{Role: schema.Assistant, AssistantGenMultiContent: []schema.MessageOutputPart{imagePart()}},
+	}), nil
+}
+
+func (m imageChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type imageProvider struct{}
+
+func (imageProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return imageChatModel{}, nil
+}
+
+// TestChat_SyncPathPreservesGeneratedImage 同步 Chat 的图像输出分片必须完整
+// 走完响应、入库、重新读取三个环节，不能在 DTO 转换或持久化时丢失。
+func TestChat_SyncPathPreservesGeneratedImage(t *testing.T) {
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	svc := NewChatService(
+		biz.NewChatUsecase(imageProvider{}, conf.Eino{DefaultModel: "test-model"}),
+		biz.NewSessionUsecase(repo),
+	)
+
+	resp, err := svc.Chat(context.Background(),
+		&api.ChatRequest{Message: schema.Message{Role: schema.User, Content: "draw a cat"}})
+	if err != nil {
+		t.Fatalf("sync chat failed: %v", err)
+	}
+	assertImagePart := func(where string, parts []schema.MessageOutputPart) {
+		t.Helper()
+		if len(parts) != 1 {
+			t.Fatalf("%s: expected 1 output part, got %d", where, len(parts))
+		}
+		part := parts[0]
+		if part.Type != schema.ChatMessagePartTypeImageURL || part.Image == nil {
+			t.Fatalf("%s: unexpected part: %+v", where, part)
+		}
+		if part.Image.Base64Data == nil || *part.Image.Base64Data != "aGVsbG8=" || part.Image.MIMEType != "image/png" {
+			t.Fatalf("%s: image payload mangled: %+v", where, part.Image)
+		}
+	}
+	assertImagePart("sync response", resp.AssistantGenMultiContent)
+
+	// 重新读取会话：image 分片必须原样经过 JSON 持久化往返
+	session, err := svc.GetSession(context.Background(), resp.SessionID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(session.Messages) != 2 {
+		t.Fatalf("expected user + assistant messages, got %d", len(session.Messages))
+	}
+	assertImagePart("persisted history", session.Messages[1].AssistantGenMultiContent)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// hangingChatModel 先吐一个 chunk，之后挂起直到 ctx 取消
+type hangingChatModel struct{}
+
+func (hangingChatModel) Generate(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (hangingChatModel) Stream(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	sr, sw := schema.Pipe[*schema.Message](1)
+	go func() {
+		sw.Send(&schema.Message{Role: schema.Assistant, Content: "partial "}, nil)
+		<-ctx.Done()
+		sw.Send(nil, ctx.Err())
+		sw.Close()
+	}()
+	return sr, nil
+}
+
+func (m hangingChatModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type hangingProvider struct{}
+
+func (hangingProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return hangingChatModel{}, nil
+}
+
+// TestChatStream_CancelStoresPartialMessage 模拟流中途取消，
+// 校验部分内容入库且带 partial 标记与 interrupted 结束原因。
+func TestChatStream_CancelStoresPartialMessage(t *testing.T) {
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	defer repo.Close()
+
+	sessionUsecase := biz.NewSessionUsecase(repo)
+	chatUsecase := biz.NewChatUsecase(hangingProvider{}, conf.Eino{DefaultModel: "test-model"})
+	svc := NewChatService(chatUsecase, sessionUsecase)
+
+	var sessionID string
+	gotChunk := make(chan struct{})
+	var once bool
+
+	go func() {
+		<-gotChunk
+		if cancelErr := svc.CancelSession(context.Background(), sessionID); cancelErr != nil {
+			t.Errorf("cancel failed: %v", cancelErr)
+		}
+	}()
+
+	err = svc.ChatStream(context.Background(),
+		&api.ChatRequest{Message: schema.Message{Role: schema.User, Content: "hi"}},
+		func(info api.StreamMetaInfo) error {
+			sessionID = info.SessionID
+			return nil
+		},
+		func(api.StreamChunk) error {
+			if !once {
+				once = true
+				close(gotChunk)
+			}
+			return nil
+		},
+	)
+	if !errors.Is(err, biz.ErrGenerationCanceled) {
+		t.Fatalf("expected ErrGenerationCanceled, got: %v", err)
+	}
+
+	resp, err := svc.GetSession(context.Background(), sessionID)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if len(resp.Messages) != 2 {
+		t.Fatalf("expected user + partial assistant message, got %d", len(resp.Messages))
+	}
+
+	assistant := resp.Messages[1]
+	if !assistant.Partial {
+		t.Error("stored assistant message must be marked partial")
+	}
+	if assistant.Content != "partial " {
+		t.Errorf("expected accumulated partial content, got %q", assistant.Content)
+	}
+	if assistant.ResponseMeta == nil || assistant.ResponseMeta.FinishReason != "interrupted" {
+		t.Errorf("expected interrupted finish reason, got %+v", assistant.ResponseMeta)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func newStatelessTestService(t *testing.T) api.ChatService {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	sessionUsecase := biz.NewSessionUsecase(repo)
+	chatUsecase := biz.NewChatUsecase(echoProvider{}, conf.Eino{DefaultModel: "test-model"})
+	return NewChatService(chatUsecase, sessionUsecase)
+}
+
+// TestChat_StatelessSkipsPersistence 无状态请求不建会话、不落任何消息。
+func TestChat_StatelessSkipsPersistence(t *testing.T) {
+	svc := newStatelessTestService(t)
+
+	resp, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message:   schema.Message{Role: schema.User, Content: "hi"},
+		Stateless: true,
+	})
+	if err != nil {
+		t.Fatalf("stateless chat failed: %v", err)
+	}
+	if resp.Content != "hello" {
+		t.Errorf("content = %q, want hello", resp.Content)
+	}
+	if resp.SessionID != "" || resp.TreeID != "" {
+		t.Errorf("stateless response must not carry session IDs: %+v", resp)
+	}
+
+	sessions, err := svc.ListSessions(context.Background())
+	if err != nil {
+		t.Fatalf("failed to list sessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("stateless chat must not write to the DB, found %d session trees", len(sessions))
+	}
+}
+
+// TestChatStream_StatelessEmitsNoSessionIDs SSE 路径照常工作，但元信息不带
+// session ID，客户端提供的完整消息列表原样送入模型。
+func TestChatStream_StatelessEmitsNoSessionIDs(t *testing.T) {
+	svc := newStatelessTestService(t)
+
+	var meta api.StreamMetaInfo
+	var content string
+	err := svc.ChatStream(context.Background(), &api.ChatRequest{
+		Message:   schema.Message{Role: schema.User, Content: "and now?"},
+		RunID:     "run_1",
+		Stateless: true,
+		History: []*schema.Message{
+			{Role: schema.System, Content: "be brief"},
+			{Role: schema.User, Content: "and now?"},
+		},
+	}, func(info api.StreamMetaInfo) error {
+		meta = info
+		return nil
+	}, func(chunk api.StreamChunk) error {
+		content += chunk.Content
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stateless stream failed: %v", err)
+	}
+
+	if meta.SessionID != "" || meta.ThreadID != "" {
+		t.Errorf("stateless stream meta must not carry session IDs: %+v", meta)
+	}
+	if meta.RunID != "run_1" {
+		t.Errorf("run ID must be preserved, got %q", meta.RunID)
+	}
+	if content != "hello" {
+		t.Errorf("streamed content = %q, want hello", content)
+	}
+
+	sessions, err := svc.ListSessions(context.Background())
+	if err != nil {
+		t.Fatalf("failed to list sessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("stateless stream must not write to the DB, found %d session trees", len(sessions))
+	}
+}
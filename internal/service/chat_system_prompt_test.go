@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// promptRecordingModel 记录每次调用收到的消息列表
+type promptRecordingModel struct {
+	calls *[][]*schema.Message
+}
+
+func (m promptRecordingModel) Generate(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	*m.calls = append(*m.calls, messages)
+	return &schema.Message{Role: schema.Assistant, Content: "ok"}, nil
+}
+
+func (m promptRecordingModel) Stream(_ context.Context, messages []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	*m.calls = append(*m.calls, messages)
+	return schema.StreamReaderFromArray([]*schema.Message{{Role: schema.Assistant, Content: "ok"}}), nil
+}
+
+func (m promptRecordingModel) WithTools(_ []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+type promptRecordingProvider struct {
+	calls *[][]*schema.Message
+}
+
+func (p promptRecordingProvider) CreateChatModel(context.Context, string, ...model.Option) (model.ToolCallingChatModel, error) {
+	return promptRecordingModel{calls: p.calls}, nil
+}
+
+func newSystemPromptTestService(t *testing.T, calls *[][]*schema.Message) api.ChatService {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	sessionUsecase := biz.NewSessionUsecase(repo)
+	chatUsecase := biz.NewChatUsecase(promptRecordingProvider{calls: calls}, conf.Eino{DefaultModel: "test-model"})
+	return NewChatService(chatUsecase, sessionUsecase)
+}
+
+// TestChat_TreeSystemPromptAppliedEveryTurn 新建对话时设置的对话级指令
+// 在首轮和后续轮次都作为最前面的 system 消息注入。
+func TestChat_TreeSystemPromptAppliedEveryTurn(t *testing.T) {
+	var calls [][]*schema.Message
+	svc := newSystemPromptTestService(t, &calls)
+
+	first, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message:      schema.Message{Role: schema.User, Content: "q1"},
+		SystemPrompt: "you are a pirate",
+	})
+	if err != nil {
+		t.Fatalf("first chat failed: %v", err)
+	}
+	if _, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message:  schema.Message{Role: schema.User, Content: "q2"},
+		ThreadID: first.TreeID,
+	}); err != nil {
+		t.Fatalf("second chat failed: %v", err)
+	}
+
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 model calls, got %d", len(calls))
+	}
+	for i, messages := range calls {
+		if len(messages) == 0 || messages[0].Role != schema.System || messages[0].Content != "you are a pirate" {
+			t.Errorf("turn %d: custom instruction must lead the prompt, got %#v", i+1, messages[0])
+		}
+	}
+	// 用户消息跟在指令后面，历史完整
+	if last := calls[1]; len(last) < 3 || last[len(last)-1].Content != "q2" {
+		t.Errorf("second turn history malformed: %d messages", len(calls[1]))
+	}
+}
+
+// TestSetSessionSystemPrompt_PatchTakesEffectNextTurn 通过 PATCH 改指令后
+// 下一轮立即生效；清空后不再注入。
+func TestSetSessionSystemPrompt_PatchTakesEffectNextTurn(t *testing.T) {
+	var calls [][]*schema.Message
+	svc := newSystemPromptTestService(t, &calls)
+
+	first, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "q1"},
+	})
+	if err != nil {
+		t.Fatalf("first chat failed: %v", err)
+	}
+	// 未配置对话级指令时走全局默认 system 提示词
+	if len(calls[0]) != 2 || calls[0][0].Role != schema.System {
+		t.Fatalf("expected default instruction + user message, got %d messages", len(calls[0]))
+	}
+	defaultInstruction := calls[0][0].Content
+
+	if err := svc.SetSessionSystemPrompt(context.Background(), first.SessionID, "answer in French"); err != nil {
+		t.Fatalf("SetSessionSystemPrompt failed: %v", err)
+	}
+	if _, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message:  schema.Message{Role: schema.User, Content: "q2"},
+		ThreadID: first.TreeID,
+	}); err != nil {
+		t.Fatalf("second chat failed: %v", err)
+	}
+	if msgs := calls[1]; msgs[0].Role != schema.System || msgs[0].Content != "answer in French" {
+		t.Errorf("patched instruction not applied: %#v", msgs[0])
+	}
+
+	if err := svc.SetSessionSystemPrompt(context.Background(), first.SessionID, ""); err != nil {
+		t.Fatalf("clearing system prompt failed: %v", err)
+	}
+	if _, err := svc.Chat(context.Background(), &api.ChatRequest{
+		Message:  schema.Message{Role: schema.User, Content: "q3"},
+		ThreadID: first.TreeID,
+	}); err != nil {
+		t.Fatalf("third chat failed: %v", err)
+	}
+	if msgs := calls[2]; msgs[0].Role != schema.System || msgs[0].Content != defaultInstruction {
+		t.Errorf("cleared instruction must fall back to the default, got %#v", msgs[0])
+	}
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/conf"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// runTitleStream 跑一轮新会话的流式对话，返回收到的 chunks 与流元信息
+func runTitleStream(t *testing.T, svc api.ChatService) ([]api.StreamChunk, api.StreamMetaInfo) {
+	t.Helper()
+	var (
+		chunks []api.StreamChunk
+		meta   api.StreamMetaInfo
+	)
+	err := svc.ChatStream(context.Background(),
+		&api.ChatRequest{Message: schema.Message{Role: schema.User, Content: "explain git rebase to me"}},
+		func(info api.StreamMetaInfo) error { meta = info; return nil },
+		func(chunk api.StreamChunk) error { chunks = append(chunks, chunk); return nil },
+	)
+	if err != nil {
+		t.Fatalf("ChatStream failed: %v", err)
+	}
+	return chunks, meta
+}
+
+// TestChatStream_GeneratedTitleEmitted 配置标题模型后，首轮对话结束会生成
+// 标题、写入会话并作为单独的 chunk 下发。
+func TestChatStream_GeneratedTitleEmitted(t *testing.T) {
+	svc := newDedupTestService(t, conf.Eino{DefaultModel: "test-model", TitleModel: "title-model"})
+
+	chunks, _ := runTitleStream(t, svc)
+
+	var titles []string
+	for _, chunk := range chunks {
+		if chunk.Title != "" {
+			titles = append(titles, chunk.Title)
+		}
+	}
+	if len(titles) != 1 || titles[0] != "hello" {
+		t.Fatalf("expected exactly one title chunk %q, got %v", "hello", titles)
+	}
+
+	sessions, err := svc.ListSessions(context.Background())
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Title != "hello" {
+		t.Fatalf("expected generated title on the tree, got: %+v", sessions)
+	}
+}
+
+// TestChatStream_TitleGenerationDisabled 未配置标题模型时保持首条消息截断标题，
+// 也不下发标题 chunk。
+func TestChatStream_TitleGenerationDisabled(t *testing.T) {
+	svc := newDedupTestService(t, conf.Eino{DefaultModel: "test-model"})
+
+	chunks, _ := runTitleStream(t, svc)
+
+	for _, chunk := range chunks {
+		if chunk.Title != "" {
+			t.Fatalf("unexpected title chunk: %q", chunk.Title)
+		}
+	}
+
+	sessions, err := svc.ListSessions(context.Background())
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Title != "explain git..." {
+		t.Fatalf("expected truncated auto title, got: %+v", sessions)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+	"devops-backend/internal/conf"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func newTreeSessionsTestService(t *testing.T) (api.ChatService, *biz.SessionUsecase) {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	sessionUsecase := biz.NewSessionUsecase(repo)
+	chatUsecase := biz.NewChatUsecase(echoProvider{}, conf.Eino{DefaultModel: "test-model"})
+	return NewChatService(chatUsecase, sessionUsecase), sessionUsecase
+}
+
+// TestListTreeSessions_RootAndTwoBranches 根 session 加两个分支的树要完整列出，
+// 根在前且不带分支点，分支带各自的父消息 ID。
+func TestListTreeSessions_RootAndTwoBranches(t *testing.T) {
+	svc, sessionUsecase := newTreeSessionsTestService(t)
+	ctx := context.Background()
+
+	first, err := svc.Chat(ctx, &api.ChatRequest{
+		Message: schema.Message{Role: schema.User, Content: "hi"},
+	})
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+
+	// 根 session 现在有 user + assistant 两条消息；从 user 消息分出两个分支
+	session, err := svc.GetSession(ctx, first.SessionID)
+	if err != nil {
+		t.Fatalf("get session failed: %v", err)
+	}
+	if len(session.Messages) != 2 {
+		t.Fatalf("root session has %d messages, want 2", len(session.Messages))
+	}
+	userMsgID := session.Messages[0].ID
+
+	branch1, err := svc.ForkSessionFrom(ctx, first.SessionID, userMsgID)
+	if err != nil {
+		t.Fatalf("fork branch1 failed: %v", err)
+	}
+	branch2, err := svc.ForkSessionFrom(ctx, first.SessionID, userMsgID)
+	if err != nil {
+		t.Fatalf("fork branch2 failed: %v", err)
+	}
+
+	// 给第一个分支追加一条自己的消息
+	if _, err := sessionUsecase.AppendMessage(branch1, &schema.Message{
+		Role: schema.User, Content: "branch follow-up",
+	}, "", false); err != nil {
+		t.Fatalf("append to branch1 failed: %v", err)
+	}
+
+	sessions, err := svc.ListTreeSessions(ctx, first.TreeID)
+	if err != nil {
+		t.Fatalf("list tree sessions failed: %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("got %d sessions, want 3: %+v", len(sessions), sessions)
+	}
+
+	byID := make(map[string]api.TreeSessionInfo, len(sessions))
+	for _, s := range sessions {
+		byID[s.ID] = s
+	}
+
+	root := byID[first.SessionID]
+	if root.ForkAnchor != 0 {
+		t.Errorf("root fork anchor = %d, want 0", root.ForkAnchor)
+	}
+	if root.MessageCount != 2 {
+		t.Errorf("root message count = %d, want 2", root.MessageCount)
+	}
+	if sessions[0].ID != first.SessionID {
+		t.Errorf("root must come first, got %s", sessions[0].ID)
+	}
+
+	if b := byID[branch1]; b.ForkAnchor != userMsgID || b.MessageCount != 1 {
+		t.Errorf("branch1 = %+v, want fork anchor %d and 1 message", b, userMsgID)
+	}
+	if b := byID[branch2]; b.ForkAnchor != userMsgID || b.MessageCount != 0 {
+		t.Errorf("branch2 = %+v, want fork anchor %d and 0 messages", b, userMsgID)
+	}
+}
+
+// TestListTreeSessions_UnknownTree 不存在（或非本人）的 tree 返回 not found。
+func TestListTreeSessions_UnknownTree(t *testing.T) {
+	svc, _ := newTreeSessionsTestService(t)
+
+	if _, err := svc.ListTreeSessions(context.Background(), "tree_missing"); err == nil {
+		t.Fatal("expected an error for an unknown tree")
+	}
+}
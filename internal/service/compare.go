@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// CompareChat 把同一提示并发发给多个模型并收集全部结果。提示落盘到一棵
+// 新对话树的根上，每个模型的回复保存在该树的独立分支里，方便事后回看；
+// 单个模型失败只标记该模型的结果，不影响其它模型
+func (s *chatService) CompareChat(ctx context.Context, req *api.CompareRequest) (*api.CompareResponse, error) {
+	owner := biz.OwnerSubFromContext(ctx)
+	userMsg, treeID, rootSessionID, msgID, err := s.prepareCompare(owner, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// 分支串行创建：SQLite 并发写会互相咬锁，只有模型调用值得 fan-out
+	results := make([]api.CompareResult, len(req.Models))
+	branches := make([]string, len(req.Models))
+	for i, reqModel := range req.Models {
+		branches[i], err = s.sessionUsecase.ForkFromMessage(owner, rootSessionID, msgID)
+		if err != nil {
+			results[i] = api.CompareResult{Model: reqModel, Error: fmt.Sprintf("fork branch: %v", err)}
+		}
+	}
+
+	// 并发上限由 ChatUsecase 内部的上游信号量控制，这里只管 fan-out
+	var wg sync.WaitGroup
+	for i, reqModel := range req.Models {
+		if results[i].Error != "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, reqModel string) {
+			defer wg.Done()
+			reply, modelName, err := s.chatUsecase.Chat(ctx, []*schema.Message{userMsg}, reqModel, nil)
+			if err != nil {
+				results[i] = api.CompareResult{Model: reqModel, SessionID: branches[i], Error: err.Error()}
+				return
+			}
+			results[i] = api.CompareResult{Model: modelName, SessionID: branches[i], Message: reply}
+		}(i, reqModel)
+	}
+	wg.Wait()
+
+	// 回复同样串行落盘
+	for i, res := range results {
+		if res.Error != "" || res.Message == nil {
+			continue
+		}
+		if _, err := s.sessionUsecase.AppendMessage(res.SessionID, res.Message, res.Model, false); err != nil {
+			results[i].Error = fmt.Sprintf("append assistant message: %v", err)
+		}
+	}
+
+	return &api.CompareResponse{TreeID: treeID, Results: results}, nil
+}
+
+// compareOutcome 单个模型流式生成的最终结果，经 channel 汇回主 goroutine 落盘
+type compareOutcome struct {
+	reqModel  string
+	modelName string
+	branchID  string
+	reply     *schema.Message
+	err       error
+}
+
+// CompareChatStream 流式版本：各模型的增量事件按到达顺序交错下发，
+// 事件里的 model 字段标识归属，onEvent 必须可并发调用
+func (s *chatService) CompareChatStream(ctx context.Context, req *api.CompareRequest, onEvent api.CompareEventCallback) error {
+	owner := biz.OwnerSubFromContext(ctx)
+	userMsg, treeID, rootSessionID, msgID, err := s.prepareCompare(owner, req)
+	if err != nil {
+		return err
+	}
+	if err := onEvent(api.CompareStreamEvent{Type: api.CompareEventStart, TreeID: treeID}); err != nil {
+		return err
+	}
+
+	// 生成 fan-out 到 goroutine，所有 SQLite 写集中在本 goroutine 串行执行；
+	// 缓冲留足容量，中途断连提前返回时已启动的 goroutine 也能发完退出
+	outcomes := make(chan compareOutcome, len(req.Models))
+	launched := 0
+	for _, reqModel := range req.Models {
+		branchID, err := s.sessionUsecase.ForkFromMessage(owner, rootSessionID, msgID)
+		if err != nil {
+			_ = onEvent(api.CompareStreamEvent{Type: api.CompareEventError, Model: reqModel, Error: fmt.Sprintf("fork branch: %v", err)})
+			continue
+		}
+		if err := onEvent(api.CompareStreamEvent{Type: api.CompareEventModelStart, Model: reqModel, SessionID: branchID}); err != nil {
+			return err
+		}
+		launched++
+		go func(reqModel, branchID string) {
+			reply, modelName, err := s.chatUsecase.ChatStream(ctx, []*schema.Message{userMsg}, reqModel, nil, func(chunk biz.StreamChunk) error {
+				apiChunk := toAPIStreamChunk(chunk)
+				return onEvent(api.CompareStreamEvent{Type: api.CompareEventChunk, Model: reqModel, Chunk: &apiChunk})
+			})
+			outcomes <- compareOutcome{reqModel: reqModel, modelName: modelName, branchID: branchID, reply: reply, err: err}
+		}(reqModel, branchID)
+	}
+
+	for ; launched > 0; launched-- {
+		o := <-outcomes
+		if o.err != nil {
+			_ = onEvent(api.CompareStreamEvent{Type: api.CompareEventError, Model: o.reqModel, SessionID: o.branchID, Error: o.err.Error()})
+			continue
+		}
+		if _, err := s.sessionUsecase.AppendMessage(o.branchID, o.reply, o.modelName, false); err != nil {
+			_ = onEvent(api.CompareStreamEvent{Type: api.CompareEventError, Model: o.modelName, SessionID: o.branchID, Error: fmt.Sprintf("append assistant message: %v", err)})
+			continue
+		}
+		_ = onEvent(api.CompareStreamEvent{Type: api.CompareEventDone, Model: o.modelName, SessionID: o.branchID})
+	}
+
+	return onEvent(api.CompareStreamEvent{Type: api.CompareEventFinish, TreeID: treeID})
+}
+
+// prepareCompare 新建对话树并落盘提示消息，返回各模型分支共享的根信息
+func (s *chatService) prepareCompare(owner string, req *api.CompareRequest) (userMsg *schema.Message, treeID, rootSessionID string, msgID int64, err error) {
+	treeID, rootSessionID, _, err = s.sessionUsecase.ResolveThread(owner, "")
+	if err != nil {
+		return nil, "", "", 0, fmt.Errorf("resolve thread: %w", err)
+	}
+	userMsg = &schema.Message{Role: schema.User, Content: req.Prompt}
+	msgID, err = s.sessionUsecase.AppendMessage(rootSessionID, userMsg, "", false)
+	if err != nil {
+		return nil, "", "", 0, fmt.Errorf("append user message: %w", err)
+	}
+	return userMsg, treeID, rootSessionID, msgID, nil
+}
+
+// toAPIStreamChunk biz → api 流式块 DTO 转换
+func toAPIStreamChunk(chunk biz.StreamChunk) api.StreamChunk {
+	return api.StreamChunk{
+		Content:                  chunk.Content,
+		ReasoningContent:         chunk.ReasoningContent,
+		AssistantGenMultiContent: chunk.AssistantGenMultiContent,
+		ToolCalls:                chunk.ToolCalls,
+		Annotations:              toAPIAnnotations(chunk.Annotations),
+		FinishReason:             chunk.FinishReason,
+		Usage:                    chunk.Usage,
+		ReasoningDone:            chunk.ReasoningDone,
+		HistoryTruncated:         chunk.HistoryTruncated,
+	}
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+
+	"devops-backend/internal/api"
+	"devops-backend/internal/biz"
+)
+
+// modelsService 模型健康查询服务实现
+type modelsService struct {
+	reporter biz.ClientHealthReporter
+}
+
+// NewModelsService 创建模型健康查询服务
+func NewModelsService(reporter biz.ClientHealthReporter) api.ModelsService {
+	return &modelsService{reporter: reporter}
+}
+
+// ClientHealth 返回所有上游 client 的最近探活结果
+func (s *modelsService) ClientHealth(ctx context.Context) ([]api.ClientHealthInfo, error) {
+	snapshot := s.reporter.ClientHealthSnapshot()
+	infos := make([]api.ClientHealthInfo, 0, len(snapshot))
+	for _, h := range snapshot {
+		infos = append(infos, api.ClientHealthInfo{
+			Client:    h.Client,
+			Healthy:   h.Healthy,
+			LatencyMs: h.LatencyMs,
+			CheckedAt: h.CheckedAt,
+			Error:     h.Error,
+		})
+	}
+	return infos, nil
+}
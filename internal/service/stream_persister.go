@@ -0,0 +1,116 @@
+package service
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"devops-backend/internal/biz"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// streamFlushBytes 合帧缓冲的大小阈值：未到时间窗口但已攒下这么多内容时
+// 也立即落库，避免高吞吐模型在一个窗口里堆积过多未保存内容
+const streamFlushBytes = 4096
+
+// streamPersister 流式回复的批量落库器：生成过程中把增量内容合帧写入
+// 数据库（首次写入以 partial 标记建行，之后原地覆盖），断线后历史里能
+// 读到已生成的部分。逐 chunk 直写会打爆 SQLite，这里按时间窗口/缓冲
+// 大小合帧，流结束时由 finish 强制收尾。interval <= 0 表示关闭增量落库，
+// 此时 onChunk 为空操作、finish 退化为一次性整条追加（当前默认行为）。
+type streamPersister struct {
+	session   *biz.SessionUsecase
+	owner     string
+	sessionID string
+	reqModel  string        // 请求的模型名，最终落库时以实际模型覆盖
+	interval  time.Duration // 合帧时间窗口，<=0 关闭增量落库
+
+	mu        sync.Mutex
+	msgID     int64 // 增量落库建行后的消息 ID，0 表示尚未建行
+	content   strings.Builder
+	reasoning strings.Builder
+	unflushed int // 距上次 flush 新攒下的字节数
+	lastFlush time.Time
+}
+
+func newStreamPersister(session *biz.SessionUsecase, owner, sessionID, reqModel string, interval time.Duration) *streamPersister {
+	return &streamPersister{
+		session:   session,
+		owner:     owner,
+		sessionID: sessionID,
+		reqModel:  reqModel,
+		interval:  interval,
+		lastFlush: time.Now(),
+	}
+}
+
+// onChunk 累积增量内容，到达时间窗口或大小阈值时落库。
+// 中途落库失败只记日志不打断流，最终内容由 finish 兜底。
+func (p *streamPersister) onChunk(chunk biz.StreamChunk) {
+	if p.interval <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.content.WriteString(chunk.Content)
+	p.reasoning.WriteString(chunk.ReasoningContent)
+	p.unflushed += len(chunk.Content) + len(chunk.ReasoningContent)
+	if p.unflushed == 0 {
+		return
+	}
+	if p.unflushed < streamFlushBytes && time.Since(p.lastFlush) < p.interval {
+		return
+	}
+	p.flushLocked()
+}
+
+// flushLocked 把当前快照写入数据库；调用方必须持有 p.mu
+func (p *streamPersister) flushLocked() {
+	snapshot := &schema.Message{
+		Role:             schema.Assistant,
+		Content:          p.content.String(),
+		ReasoningContent: p.reasoning.String(),
+	}
+	if p.msgID == 0 {
+		id, err := p.session.AppendMessage(p.sessionID, snapshot, p.reqModel, true)
+		if err != nil {
+			slog.Warn("stream flush: append partial message failed", "session", p.sessionID, "err", err)
+			return
+		}
+		p.msgID = id
+	} else if err := p.session.UpdateStreamedMessage(p.msgID, snapshot, p.reqModel, true); err != nil {
+		slog.Warn("stream flush: update partial message failed", "msg_id", p.msgID, "err", err)
+	}
+	p.unflushed = 0
+	p.lastFlush = time.Now()
+}
+
+// finish 流结束后的最终落库：增量落库已建行时原地覆盖并清除 partial
+// 标记（流被中断保存 partial 内容时保留），否则整条追加。
+func (p *streamPersister) finish(final *schema.Message, model string, partial bool) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.msgID == 0 {
+		return p.session.AppendMessage(p.sessionID, final, model, partial)
+	}
+	if err := p.session.UpdateStreamedMessage(p.msgID, final, model, partial); err != nil {
+		return 0, err
+	}
+	return p.msgID, nil
+}
+
+// abort 丢弃增量落库建的行：流以既不保存 partial 也不保存整条的错误
+// 结束时调用，保持与关闭增量落库时完全一致的历史
+func (p *streamPersister) abort() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.msgID == 0 {
+		return
+	}
+	if err := p.session.DeleteMessage(p.owner, p.sessionID, p.msgID); err != nil {
+		slog.Warn("stream flush: cleanup partial message failed", "msg_id", p.msgID, "err", err)
+	}
+	p.msgID = 0
+}
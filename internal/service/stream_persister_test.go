@@ -0,0 +1,124 @@
+package service
+
+import (
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"devops-backend/internal/biz"
+	"devops-backend/internal/infra/data"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// countingSessionRepo 统计消息写入次数的 SessionRepo 包装，用于验证合帧效果
+type countingSessionRepo struct {
+	biz.SessionRepo
+	writes int32
+}
+
+func (r *countingSessionRepo) AppendMessage(sessionID string, msg *schema.Message, model string, partial bool) (int64, error) {
+	atomic.AddInt32(&r.writes, 1)
+	return r.SessionRepo.AppendMessage(sessionID, msg, model, partial)
+}
+
+func (r *countingSessionRepo) UpdateStreamedMessage(msgID int64, msg *schema.Message, model string, partial bool) error {
+	atomic.AddInt32(&r.writes, 1)
+	return r.SessionRepo.UpdateStreamedMessage(msgID, msg, model, partial)
+}
+
+func newPersisterTestEnv(t *testing.T) (*countingSessionRepo, *biz.SessionUsecase, string) {
+	t.Helper()
+	repo, err := data.NewSQLiteSessionRepo(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to create session repo: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	counting := &countingSessionRepo{SessionRepo: repo}
+	session := biz.NewSessionUsecase(counting)
+	_, sessionID := counting.NewConversation("u1")
+	return counting, session, sessionID
+}
+
+// TestStreamPersister_CoalescesWrites N 个 chunk 只产生远少于 N 次的
+// 数据库写入；生成中能读到 partial 快照，收尾后内容完整、标记清除。
+func TestStreamPersister_CoalescesWrites(t *testing.T) {
+	counting, session, sessionID := newPersisterTestEnv(t)
+	// 时间窗口拉到 1 小时，让 flush 只由大小阈值触发，测试保持确定性
+	p := newStreamPersister(session, "u1", sessionID, "test-model", time.Hour)
+
+	const chunks = 100
+	delta := strings.Repeat("x", 100)
+	for i := 0; i < chunks; i++ {
+		p.onChunk(biz.StreamChunk{Content: delta})
+	}
+
+	// 生成中：已有 partial 快照可供断线重连读取
+	if _, mid, err := counting.GetLastMessage(sessionID, "u1"); err != nil {
+		t.Fatalf("expected a partial snapshot mid-stream: %v", err)
+	} else if !mid.Partial || len(mid.Content) == 0 {
+		t.Errorf("mid-stream snapshot must be partial with content, got partial=%v len=%d", mid.Partial, len(mid.Content))
+	}
+
+	final := &schema.Message{Role: schema.Assistant, Content: strings.Repeat("x", chunks*100)}
+	if _, err := p.finish(final, "actual-model", false); err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+
+	writes := atomic.LoadInt32(&counting.writes)
+	if writes >= chunks/4 {
+		t.Errorf("expected far fewer writes than chunks, got %d writes for %d chunks", writes, chunks)
+	}
+	_, stored, err := counting.GetLastMessage(sessionID, "u1")
+	if err != nil {
+		t.Fatalf("get last message: %v", err)
+	}
+	if stored.Content != final.Content {
+		t.Errorf("final content mismatch: got %d bytes, want %d", len(stored.Content), len(final.Content))
+	}
+	if stored.Partial {
+		t.Error("partial flag must be cleared after finish")
+	}
+	if stored.Model != "actual-model" {
+		t.Errorf("final write must record the actual model, got %q", stored.Model)
+	}
+}
+
+// TestStreamPersister_DisabledWritesOnce 关闭增量落库（interval 0）时
+// 退化为流结束的一次性整条写入。
+func TestStreamPersister_DisabledWritesOnce(t *testing.T) {
+	counting, session, sessionID := newPersisterTestEnv(t)
+	p := newStreamPersister(session, "u1", sessionID, "test-model", 0)
+
+	for i := 0; i < 50; i++ {
+		p.onChunk(biz.StreamChunk{Content: strings.Repeat("x", 200)})
+	}
+	if _, err := p.finish(schema.AssistantMessage("done", nil), "test-model", false); err != nil {
+		t.Fatalf("finish failed: %v", err)
+	}
+	if writes := atomic.LoadInt32(&counting.writes); writes != 1 {
+		t.Errorf("expected exactly 1 write with batching disabled, got %d", writes)
+	}
+}
+
+// TestStreamPersister_AbortDeletesPartial 流以不保存内容的错误结束时，
+// 增量落库建的 partial 行被清理，历史与关闭增量落库时一致。
+func TestStreamPersister_AbortDeletesPartial(t *testing.T) {
+	counting, session, sessionID := newPersisterTestEnv(t)
+	p := newStreamPersister(session, "u1", sessionID, "test-model", time.Hour)
+
+	// 攒够大小阈值，确保已经建行
+	for i := 0; i < 50; i++ {
+		p.onChunk(biz.StreamChunk{Content: strings.Repeat("x", 100)})
+	}
+	if _, mid, err := counting.GetLastMessage(sessionID, "u1"); err != nil || !mid.Partial {
+		t.Fatalf("expected a partial row before abort (err=%v)", err)
+	}
+
+	p.abort()
+	if _, _, err := counting.GetLastMessage(sessionID, "u1"); err == nil {
+		t.Error("partial row must be deleted on abort")
+	}
+}